@@ -1,8 +1,6 @@
 package main
 
 import (
-	"net/http"
-	_ "net/http/pprof"
 	"log"
 	"os"
 	"os/signal"
@@ -15,9 +13,6 @@ import (
 func main() {
 	// Print version information
 	log.Printf("GitCommitInfo:%s\n", version.Version())
-	go func() {
-		log.Println(http.ListenAndServe(":6060", nil))
-	}()
 	app, err := application.New()
 	if err != nil {
 		log.Fatalf("Failed to create application: %v", err)
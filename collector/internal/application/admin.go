@@ -0,0 +1,287 @@
+package application
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component"
+	"github.com/Kindling-project/kindling/collector/pkg/metadata/kubernetes"
+)
+
+// AdminKey is the key of the configuration section of the admin server.
+const AdminKey = "admin"
+
+const (
+	healthzPath  = "/healthz"
+	readyzPath   = "/readyz"
+	pipelinePath = "/debug/pipeline"
+	pprofPath    = "/debug/pprof/"
+
+	// sampleInterval is the interval to calculate the throughput of the components.
+	sampleInterval = 5 * time.Second
+	// adminShutdownTimeout is how long the requests in flight are waited for when shutting down.
+	adminShutdownTimeout = 5 * time.Second
+)
+
+// AdminConfig configures the admin server, which serves the health and readiness probes
+// and the status of the pipeline components.
+type AdminConfig struct {
+	Enable        bool   `mapstructure:"enable"`
+	ListenAddress string `mapstructure:"listen_address"`
+	// EnablePprof serves the profiles of net/http/pprof at /debug/pprof/.
+	EnablePprof bool `mapstructure:"enable_pprof"`
+	// EventTimeout is how long the analyzers can receive no events before /healthz fails.
+	// The check is disabled if it is not positive.
+	EventTimeout time.Duration `mapstructure:"event_timeout"`
+}
+
+func NewDefaultAdminConfig() *AdminConfig {
+	return &AdminConfig{
+		Enable:        false,
+		ListenAddress: ":9506",
+		EnablePprof:   false,
+		EventTimeout:  time.Minute,
+	}
+}
+
+// healthResponse is the body of /healthz and /readyz. Each check is a component or a
+// dependency, which has the error if it fails.
+type healthResponse struct {
+	Status string        `json:"status"`
+	Checks []healthCheck `json:"checks"`
+}
+
+type healthCheck struct {
+	Name  string `json:"name"`
+	Error string `json:"error,omitempty"`
+}
+
+const (
+	statusOK          = "ok"
+	statusUnavailable = "unavailable"
+)
+
+type adminServer struct {
+	cfg     *AdminConfig
+	monitor *pipelineMonitor
+	// factory returns the factory of the configuration in effect.
+	factory func() *ComponentsFactory
+	logger  *component.TelemetryLogger
+
+	srv      *http.Server
+	listener net.Listener
+	stopCh   chan struct{}
+	wg       sync.WaitGroup
+}
+
+func newAdminServer(cfg *AdminConfig, monitor *pipelineMonitor, factory func() *ComponentsFactory,
+	logger *component.TelemetryLogger) *adminServer {
+	s := &adminServer{
+		cfg:     cfg,
+		monitor: monitor,
+		factory: factory,
+		logger:  logger,
+		stopCh:  make(chan struct{}),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(healthzPath, s.handleHealthz)
+	mux.HandleFunc(readyzPath, s.handleReadyz)
+	mux.HandleFunc(pipelinePath, s.handlePipeline)
+	if cfg.EnablePprof {
+		mux.HandleFunc(pprofPath, pprof.Index)
+		mux.HandleFunc(pprofPath+"cmdline", pprof.Cmdline)
+		mux.HandleFunc(pprofPath+"profile", pprof.Profile)
+		mux.HandleFunc(pprofPath+"symbol", pprof.Symbol)
+		mux.HandleFunc(pprofPath+"trace", pprof.Trace)
+	}
+	s.srv = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return s
+}
+
+// start listens on the address so the error can be returned, and starts sampling the
+// throughput of the components.
+func (s *adminServer) start() error {
+	listener, err := net.Listen("tcp", s.cfg.ListenAddress)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s for the admin server: %w", s.cfg.ListenAddress, err)
+	}
+	s.listener = listener
+	s.wg.Add(2)
+	go func() {
+		defer s.wg.Done()
+		s.logger.Infof("Admin server listens on %s", listener.Addr())
+		if err := s.srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error("Admin server stopped", zap.Error(err))
+		}
+	}()
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(sampleInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				s.monitor.sample(now)
+			case <-s.stopCh:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (s *adminServer) shutdown(ctx context.Context) error {
+	close(s.stopCh)
+	err := s.srv.Shutdown(ctx)
+	s.wg.Wait()
+	return err
+}
+
+// checkHealth checks whether the receivers work and the events are being processed.
+func (s *adminServer) checkHealth() []healthCheck {
+	var checks []healthCheck
+	for _, c := range s.monitor.list() {
+		if c.kind != receiverKind {
+			continue
+		}
+		check := healthCheck{Name: string(c.kind) + "/" + c.name}
+		if checker, ok := c.instance().(component.HealthChecker); ok {
+			if err := checker.CheckHealth(); err != nil {
+				check.Error = err.Error()
+			}
+		}
+		checks = append(checks, check)
+	}
+	if s.cfg.EventTimeout > 0 {
+		check := healthCheck{Name: "events"}
+		if idle := s.monitor.idleTime(time.Now()); idle > s.cfg.EventTimeout+sampleInterval {
+			check.Error = fmt.Sprintf("no events have been analyzed for %s", idle.Truncate(time.Second))
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// checkReadiness also checks whether the exporters can send the data and the Kubernetes
+// metadata is synced.
+func (s *adminServer) checkReadiness() []healthCheck {
+	checks := s.checkHealth()
+	for _, c := range s.monitor.list() {
+		if c.kind != exporterKind {
+			continue
+		}
+		check := healthCheck{Name: string(c.kind) + "/" + c.name}
+		if c.stats != nil && c.stats.lastFailed.Load() {
+			c.stats.lock.Lock()
+			check.Error = c.stats.lastError
+			c.stats.lock.Unlock()
+		}
+		checks = append(checks, check)
+	}
+	if enabled, pending := kubernetes.SyncStatus(); enabled {
+		check := healthCheck{Name: "kubernetes_metadata"}
+		if len(pending) > 0 {
+			check.Error = "the caches are not synced: " + strings.Join(pending, ", ")
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+func (s *adminServer) handleHealthz(w http.ResponseWriter, _ *http.Request) {
+	s.writeChecks(w, s.checkHealth())
+}
+
+func (s *adminServer) handleReadyz(w http.ResponseWriter, _ *http.Request) {
+	s.writeChecks(w, s.checkReadiness())
+}
+
+func (s *adminServer) writeChecks(w http.ResponseWriter, checks []healthCheck) {
+	resp := healthResponse{Status: statusOK, Checks: checks}
+	code := http.StatusOK
+	for _, check := range checks {
+		if check.Error != "" {
+			resp.Status = statusUnavailable
+			code = http.StatusServiceUnavailable
+			break
+		}
+	}
+	s.writeJSON(w, code, resp)
+}
+
+// handlePipeline shows the status of the components in HTML, or in JSON if the query
+// parameter format=json is given.
+func (s *adminServer) handlePipeline(w http.ResponseWriter, r *http.Request) {
+	status := s.monitor.status(s.factory())
+	if r.URL.Query().Get("format") == "json" {
+		s.writeJSON(w, http.StatusOK, status)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := pipelineTemplate.Execute(w, status); err != nil {
+		s.logger.Warn("Failed to render the pipeline page", zap.Error(err))
+	}
+}
+
+func (s *adminServer) writeJSON(w http.ResponseWriter, code int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		s.logger.Warn("Failed to write the response of the admin server", zap.Error(err))
+	}
+}
+
+var pipelineTemplate = template.Must(template.New("pipeline").Funcs(template.FuncMap{
+	"json": func(v interface{}) string {
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err.Error()
+		}
+		return string(data)
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<title>Kindling Pipeline</title>
+<style>
+body { font-family: sans-serif; font-size: 14px; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: top; }
+th { background: #eee; }
+pre { margin: 0; max-height: 240px; overflow: auto; }
+.error { color: #c00; }
+</style>
+</head>
+<body>
+<h1>Pipeline</h1>
+<table>
+<tr><th>Kind</th><th>Name</th><th>Throughput</th><th>Queues</th><th>Health</th><th>Last Error</th><th>Config</th></tr>
+{{range .}}
+<tr>
+<td>{{.Kind}}</td>
+<td>{{.Name}}</td>
+<td>{{with .Throughput}}{{printf "%.1f" .PerSecond}}/s<br>total: {{.Total}}<br>failed: {{.Failed}}{{else}}-{{end}}</td>
+<td>{{range $name, $depth := .QueueDepths}}{{$name}}: {{$depth.Length}}/{{$depth.Capacity}}<br>{{else}}-{{end}}</td>
+<td>{{if .Health}}<span class="error">{{.Health}}</span>{{else}}ok{{end}}</td>
+<td>{{if .LastError}}<span class="error">{{.LastError}}</span><br>{{.LastErrorTime.Format "2006-01-02 15:04:05"}}{{else}}-{{end}}</td>
+<td><details><summary>show</summary><pre>{{json .Config}}</pre></details></td>
+</tr>
+{{end}}
+</table>
+</body>
+</html>
+`))
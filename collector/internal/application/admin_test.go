@@ -0,0 +1,209 @@
+package application
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component"
+	"github.com/Kindling-project/kindling/collector/pkg/component/consumer/exporter"
+	"github.com/Kindling-project/kindling/collector/pkg/component/consumer/processor/attributesprocessor"
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+)
+
+type probedReceiver struct {
+	fakeReceiver
+	err error
+}
+
+func (r *probedReceiver) CheckHealth() error { return r.err }
+
+type flakyExporter struct {
+	err error
+}
+
+func (e *flakyExporter) Consume(*model.DataGroup) error { return e.err }
+
+func (e *flakyExporter) QueueDepths() map[string]component.QueueDepth {
+	return map[string]component.QueueDepth{"batches": {Length: 3, Capacity: 10}}
+}
+
+type exporterConfig struct {
+	Endpoint string
+	Password string
+	Headers  map[string]string
+}
+
+func newMonitorTestFactory() *ComponentsFactory {
+	factory := NewComponentsFactory()
+	factory.RegisterExporter("e1", func(interface{}, *component.TelemetryTools) exporter.Exporter { return nil },
+		&exporterConfig{
+			Endpoint: "http://localhost:9200",
+			Password: "secret",
+			Headers:  map[string]string{"Authorization": "Bearer abc", "X-Scope-OrgID": "kindling"},
+		})
+	return factory
+}
+
+func TestPipelineMonitor(t *testing.T) {
+	m := newPipelineMonitor(true)
+	e := &flakyExporter{}
+	handle := newConsumerHandle(e)
+	monitored := m.monitorConsumer(exporterKind, "e1", handle)
+	an := m.monitorAnalyzer("a1", &fakeAnalyzer{name: "a1"})
+
+	start := time.Now()
+	m.sample(start)
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, monitored.Consume(&model.DataGroup{}))
+		assert.NoError(t, an.ConsumeEvent(&model.KindlingEvent{}))
+	}
+	e.err = errors.New("connection refused")
+	assert.Error(t, monitored.Consume(&model.DataGroup{}))
+	m.sample(start.Add(2 * time.Second))
+	assert.Equal(t, time.Duration(0), m.idleTime(start.Add(2*time.Second)))
+	m.sample(start.Add(4 * time.Second))
+	assert.Equal(t, 2*time.Second, m.idleTime(start.Add(4*time.Second)))
+
+	status := m.status(newMonitorTestFactory())
+	require.Len(t, status, 2)
+	exporterStatus := status[0]
+	assert.Equal(t, exporterKind, exporterStatus.Kind)
+	assert.Equal(t, &throughput{Total: 11, Failed: 1, PerSecond: 0}, exporterStatus.Throughput)
+	assert.Equal(t, "connection refused", exporterStatus.LastError)
+	assert.NotNil(t, exporterStatus.LastErrorTime)
+	// The exporter behind the handle is inspected.
+	assert.Equal(t, component.QueueDepth{Length: 3, Capacity: 10}, exporterStatus.QueueDepths["batches"])
+	assert.Equal(t, map[string]interface{}{
+		"Endpoint": "http://localhost:9200",
+		"Password": redacted,
+		// All the headers are redacted, but their names are kept.
+		"Headers": map[string]interface{}{"Authorization": redacted, "X-Scope-OrgID": redacted},
+	}, exporterStatus.Config)
+	assert.Equal(t, &throughput{Total: 10}, status[1].Throughput)
+
+	// The rate is calculated between the samples.
+	for i := 0; i < 10; i++ {
+		_ = monitored.Consume(&model.DataGroup{})
+	}
+	m.sample(start.Add(6 * time.Second))
+	assert.Equal(t, 5.0, m.status(newMonitorTestFactory())[0].Throughput.PerSecond)
+
+	disabled := newPipelineMonitor(false)
+	assert.Same(t, handle, disabled.monitorConsumer(exporterKind, "e1", handle))
+	// The components are still kept to be started and shut down.
+	require.Len(t, disabled.list(), 1)
+	assert.Nil(t, disabled.list()[0].stats)
+}
+
+func TestAdminServer(t *testing.T) {
+	m := newPipelineMonitor(true)
+	r := &probedReceiver{}
+	m.addReceiver("r1", r)
+	e := &flakyExporter{}
+	exporterConsumer := m.monitorConsumer(exporterKind, "e1", e)
+	an := m.monitorAnalyzer("a1", &fakeAnalyzer{name: "a1"})
+
+	cfg := &AdminConfig{ListenAddress: "127.0.0.1:0", EnablePprof: true, EventTimeout: time.Minute}
+	s := newAdminServer(cfg, m, newMonitorTestFactory, component.NewDefaultTelemetryTools().Logger)
+	require.NoError(t, s.start())
+	defer func() { assert.NoError(t, s.shutdown(context.Background())) }()
+	url := "http://" + s.listener.Addr().String()
+
+	get := func(path string) (int, string) {
+		resp, err := http.Get(url + path)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(body)
+	}
+	code, body := get(healthzPath)
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `{"status":"ok","checks":[{"name":"receiver/r1"},{"name":"events"}]}`, body)
+
+	r.err = errors.New("the probe is not running")
+	code, body = get(healthzPath)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Contains(t, body, "the probe is not running")
+	r.err = nil
+
+	// The events are not analyzed longer than the timeout.
+	_ = an.ConsumeEvent(&model.KindlingEvent{})
+	m.sample(time.Now().Add(-2 * time.Minute))
+	code, body = get(healthzPath)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Contains(t, body, "no events have been analyzed")
+	_ = an.ConsumeEvent(&model.KindlingEvent{})
+	m.sample(time.Now())
+
+	code, _ = get(readyzPath)
+	assert.Equal(t, http.StatusOK, code)
+	e.err = errors.New("connection refused")
+	_ = exporterConsumer.Consume(&model.DataGroup{})
+	code, body = get(readyzPath)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Contains(t, body, `{"name":"exporter/e1","error":"connection refused"}`)
+	e.err = nil
+	_ = exporterConsumer.Consume(&model.DataGroup{})
+	code, _ = get(readyzPath)
+	assert.Equal(t, http.StatusOK, code)
+
+	code, body = get(pipelinePath)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, "batches: 3/10")
+	assert.Contains(t, body, "connection refused")
+	assert.NotContains(t, body, "secret")
+	code, body = get(pipelinePath + "?format=json")
+	assert.Equal(t, http.StatusOK, code)
+	var status []componentStatus
+	require.NoError(t, json.Unmarshal([]byte(body), &status))
+	assert.Len(t, status, 3)
+
+	code, _ = get(pprofPath)
+	assert.Equal(t, http.StatusOK, code)
+}
+
+func TestAdminServerWithoutPprof(t *testing.T) {
+	cfg := &AdminConfig{ListenAddress: "127.0.0.1:0"}
+	s := newAdminServer(cfg, newPipelineMonitor(true), NewComponentsFactory, component.NewDefaultTelemetryTools().Logger)
+	require.NoError(t, s.start())
+	defer func() { assert.NoError(t, s.shutdown(context.Background())) }()
+	resp, err := http.Get("http://" + s.listener.Addr().String() + pprofPath)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestRedactConfig(t *testing.T) {
+	cfg := map[string]interface{}{
+		"proxy":  map[string]interface{}{"Authorization": "Basic abc", "url": "http://proxy"},
+		"tokens": []interface{}{"abc"},
+		// The headers which are not a map are checked as usual.
+		"headers": "X-Scope-OrgID",
+	}
+	assert.Equal(t, map[string]interface{}{
+		"proxy":   map[string]interface{}{"Authorization": redacted, "url": "http://proxy"},
+		"tokens":  redacted,
+		"headers": "X-Scope-OrgID",
+	}, redactConfig(cfg))
+}
+
+func TestRedactHashSalt(t *testing.T) {
+	cfg := &attributesprocessor.Config{Rules: []attributesprocessor.RuleConfig{{
+		Actions: []attributesprocessor.ActionConfig{
+			{Action: attributesprocessor.ActionHash, Key: "src_ip", Salt: "abc"},
+		},
+	}}}
+	rules := redactConfig(cfg).(map[string]interface{})["Rules"].([]interface{})
+	action := rules[0].(map[string]interface{})["Actions"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, redacted, action["Salt"])
+	assert.Equal(t, "src_ip", action["Key"])
+}
@@ -1,11 +1,15 @@
 package application
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"time"
 
 	"github.com/spf13/viper"
 	"go.uber.org/multierr"
+	"go.uber.org/zap"
 
 	"github.com/Kindling-project/kindling/collector/pkg/component"
 	"github.com/Kindling-project/kindling/collector/pkg/component/analyzer"
@@ -17,13 +21,25 @@ import (
 	"github.com/Kindling-project/kindling/collector/pkg/component/analyzer/tcpmetricanalyzer"
 	"github.com/Kindling-project/kindling/collector/pkg/component/consumer"
 	"github.com/Kindling-project/kindling/collector/pkg/component/consumer/exporter/cameraexporter"
+	"github.com/Kindling-project/kindling/collector/pkg/component/consumer/exporter/clickhouseexporter"
+	"github.com/Kindling-project/kindling/collector/pkg/component/consumer/exporter/esexporter"
 	"github.com/Kindling-project/kindling/collector/pkg/component/consumer/exporter/logexporter"
 	"github.com/Kindling-project/kindling/collector/pkg/component/consumer/exporter/otelexporter"
+	"github.com/Kindling-project/kindling/collector/pkg/component/consumer/exporter/remotewriteexporter"
+	"github.com/Kindling-project/kindling/collector/pkg/component/consumer/exporter/tools/batcher"
+	"github.com/Kindling-project/kindling/collector/pkg/component/consumer/exporter/zipkinexporter"
 	"github.com/Kindling-project/kindling/collector/pkg/component/consumer/processor/aggregateprocessor"
+	"github.com/Kindling-project/kindling/collector/pkg/component/consumer/processor/attributesprocessor"
+	"github.com/Kindling-project/kindling/collector/pkg/component/consumer/processor/filterprocessor"
 	"github.com/Kindling-project/kindling/collector/pkg/component/consumer/processor/k8sprocessor"
+	"github.com/Kindling-project/kindling/collector/pkg/component/consumer/processor/tailsamplingprocessor"
 	"github.com/Kindling-project/kindling/collector/pkg/component/controller"
 	"github.com/Kindling-project/kindling/collector/pkg/component/receiver"
 	"github.com/Kindling-project/kindling/collector/pkg/component/receiver/cgoreceiver"
+	"github.com/Kindling-project/kindling/collector/pkg/component/receiver/filereceiver"
+	"github.com/Kindling-project/kindling/collector/pkg/component/receiver/otlpreceiver"
+	"github.com/Kindling-project/kindling/collector/pkg/component/receiver/syntheticreceiver"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constnames"
 )
 
 type Application struct {
@@ -31,8 +47,17 @@ type Application struct {
 	controllerFactory *controller.ControllerFactory
 	componentsFactory *ComponentsFactory
 	telemetry         *component.TelemetryManager
-	receiver          receiver.Receiver
+	receivers         []receiver.Receiver
 	analyzerManager   *analyzer.Manager
+	serviceConfig     *ServiceConfig
+	reloadConfig      *ConfigReloadConfig
+	reloader          *configReloader
+	adminConfig       *AdminConfig
+	shutdownConfig    *ShutdownConfig
+	batcherConfig     *batcher.LimiterConfig
+	batchLimiter      *batcher.MemoryLimiter
+	monitor           *pipelineMonitor
+	adminServer       *adminServer
 }
 
 func New() (*Application, error) {
@@ -41,8 +66,13 @@ func New() (*Application, error) {
 		componentsFactory: NewComponentsFactory(),
 		telemetry:         component.NewTelemetryManager(),
 		controllerFactory: &controller.ControllerFactory{},
+		reloadConfig:      &ConfigReloadConfig{},
+		serviceConfig:     &ServiceConfig{},
+		adminConfig:       NewDefaultAdminConfig(),
+		shutdownConfig:    NewDefaultShutdownConfig(),
+		batcherConfig:     batcher.NewDefaultLimiterConfig(),
 	}
-	app.registerFactory()
+	registerComponents(app.componentsFactory)
 	// Initialize flags
 	configPath := flag.String("config", "kindling-collector-config.yml", "Configuration file")
 	flag.Parse()
@@ -50,44 +80,102 @@ func New() (*Application, error) {
 	if err != nil {
 		return nil, fmt.Errorf("fail to read configuration: %w", err)
 	}
+	// The limiter is shared by the batchers of all the exporters, including the ones
+	// rebuilt when the configuration is reloaded.
+	app.batchLimiter = batcher.NewMemoryLimiter(app.batcherConfig)
+	app.reloader = newConfigReloader(*configPath, registerComponents, app.viper, app.componentsFactory,
+		app.telemetry.GetGlobalTelemetryTools())
+	if err = app.reloader.init(); err != nil {
+		return nil, fmt.Errorf("fail to read configuration: %w", err)
+	}
+	app.monitor = newPipelineMonitor(app.adminConfig.Enable)
 	// Build processing pipeline
 	err = app.buildPipeline()
 	if err != nil {
 		return nil, fmt.Errorf("failed to build pipeline: %w", err)
 	}
+	if app.adminConfig.Enable {
+		app.adminServer = newAdminServer(app.adminConfig, app.monitor, app.reloader.currentFactory,
+			app.telemetry.GetGlobalTelemetryTools().Logger)
+	}
 	return app, nil
 }
 
+// Run starts the components from the exporters to the receivers, so each one is ready
+// before the data is sent to it.
 func (a *Application) Run() error {
+	if err := a.startConsumers(); err != nil {
+		return fmt.Errorf("failed to start application: %w", err)
+	}
 	err := a.analyzerManager.StartAll(a.telemetry.GetGlobalTelemetryTools().Logger)
 	if err != nil {
 		return fmt.Errorf("failed to start application: %v", err)
 	}
-	// Wait until the receiver shutdowns
-	err = a.receiver.Start()
-	if err != nil {
-		return fmt.Errorf("failed to start application: %v", err)
+	a.controllerFactory.StartScheduler()
+	// The admin server is started before the receivers, so the probes can tell the
+	// receivers are still starting.
+	if a.adminServer != nil {
+		if err = a.adminServer.start(); err != nil {
+			return fmt.Errorf("failed to start application: %w", err)
+		}
+	}
+	if a.reloadConfig.Enable {
+		go a.reloader.watch(time.Duration(a.reloadConfig.CheckInterval) * time.Second)
+	}
+	for _, r := range a.receivers {
+		if err = r.Start(); err != nil {
+			return fmt.Errorf("failed to start application: %v", err)
+		}
 	}
 	return nil
 }
 
+// Shutdown drains the pipelines within the timeout of the shutdown section. The admin
+// server is shut down last, so the probes are still served while the data is flushed.
 func (a *Application) Shutdown() error {
-	return multierr.Combine(a.receiver.Shutdown(), a.analyzerManager.ShutdownAll(a.telemetry.GetGlobalTelemetryTools().Logger))
+	if a.reloadConfig.Enable {
+		a.reloader.stop()
+	}
+	a.controllerFactory.ShutdownScheduler()
+	retErr := a.shutdownComponents(a.shutdownConfig.Timeout)
+	if a.adminServer != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), adminShutdownTimeout)
+		retErr = multierr.Append(retErr, a.adminServer.shutdown(ctx))
+		cancel()
+	}
+	return retErr
+}
+
+// ReloadStatus returns the status of the configuration in effect and the last reload.
+func (a *Application) ReloadStatus() ReloadStatus {
+	return a.reloader.getStatus()
 }
 
-func (a *Application) registerFactory() {
-	a.componentsFactory.RegisterReceiver(cgoreceiver.Cgo, cgoreceiver.NewCgoReceiver, cgoreceiver.NewDefaultConfig())
-	a.componentsFactory.RegisterAnalyzer(network.Network.String(), network.NewNetworkAnalyzer, network.NewDefaultConfig())
-	a.componentsFactory.RegisterAnalyzer(cpuanalyzer.CpuProfile.String(), cpuanalyzer.NewCpuAnalyzer, cpuanalyzer.NewDefaultConfig())
-	a.componentsFactory.RegisterProcessor(k8sprocessor.K8sMetadata, k8sprocessor.NewKubernetesProcessor, &k8sprocessor.DefaultConfig)
-	a.componentsFactory.RegisterExporter(otelexporter.Otel, otelexporter.NewExporter, &otelexporter.Config{})
-	a.componentsFactory.RegisterAnalyzer(tcpmetricanalyzer.TcpMetric.String(), tcpmetricanalyzer.NewTcpMetricAnalyzer, &tcpmetricanalyzer.Config{})
-	a.componentsFactory.RegisterExporter(logexporter.Type, logexporter.New, &logexporter.Config{})
-	a.componentsFactory.RegisterAnalyzer(noopanalyzer.Type.String(), noopanalyzer.New, &noopanalyzer.Config{})
-	a.componentsFactory.RegisterAnalyzer(k8sinfoanalyzer.Type.String(), k8sinfoanalyzer.New, k8sinfoanalyzer.NewDefaultConfig())
-	a.componentsFactory.RegisterProcessor(aggregateprocessor.Type, aggregateprocessor.New, aggregateprocessor.NewDefaultConfig())
-	a.componentsFactory.RegisterAnalyzer(tcpconnectanalyzer.Type.String(), tcpconnectanalyzer.New, tcpconnectanalyzer.NewDefaultConfig())
-	a.componentsFactory.RegisterExporter(cameraexporter.Type, cameraexporter.New, cameraexporter.NewDefaultConfig())
+// registerComponents registers all the components. It is also used to construct the
+// configuration again when the file is reloaded, so the configs must be newly allocated.
+func registerComponents(factory *ComponentsFactory) {
+	factory.RegisterReceiver(cgoreceiver.Cgo, cgoreceiver.NewCgoReceiver, cgoreceiver.NewDefaultConfig())
+	factory.RegisterReceiver(filereceiver.Type, filereceiver.New, filereceiver.NewDefaultConfig())
+	factory.RegisterReceiver(syntheticreceiver.Type, syntheticreceiver.New, syntheticreceiver.NewDefaultConfig())
+	factory.RegisterReceiver(otlpreceiver.Type, otlpreceiver.New, otlpreceiver.NewDefaultConfig())
+	factory.RegisterAnalyzer(network.Network.String(), network.NewNetworkAnalyzer, network.NewDefaultConfig())
+	factory.RegisterAnalyzer(cpuanalyzer.CpuProfile.String(), cpuanalyzer.NewCpuAnalyzer, cpuanalyzer.NewDefaultConfig())
+	factory.RegisterProcessor(k8sprocessor.K8sMetadata, k8sprocessor.NewKubernetesProcessor, k8sprocessor.NewDefaultConfig())
+	factory.RegisterExporter(otelexporter.Otel, otelexporter.NewExporter, &otelexporter.Config{})
+	factory.RegisterAnalyzer(tcpmetricanalyzer.TcpMetric.String(), tcpmetricanalyzer.NewTcpMetricAnalyzer, &tcpmetricanalyzer.Config{})
+	factory.RegisterExporter(logexporter.Type, logexporter.New, &logexporter.Config{})
+	factory.RegisterAnalyzer(noopanalyzer.Type.String(), noopanalyzer.New, &noopanalyzer.Config{})
+	factory.RegisterAnalyzer(k8sinfoanalyzer.Type.String(), k8sinfoanalyzer.New, k8sinfoanalyzer.NewDefaultConfig())
+	factory.RegisterProcessor(aggregateprocessor.Type, aggregateprocessor.New, aggregateprocessor.NewDefaultConfig())
+	factory.RegisterProcessor(filterprocessor.Type, filterprocessor.New, filterprocessor.NewDefaultConfig())
+	factory.RegisterProcessor(attributesprocessor.Type, attributesprocessor.New, attributesprocessor.NewDefaultConfig())
+	factory.RegisterProcessor(tailsamplingprocessor.Type, tailsamplingprocessor.New, tailsamplingprocessor.NewDefaultConfig())
+	factory.RegisterAnalyzer(tcpconnectanalyzer.Type.String(), tcpconnectanalyzer.New, tcpconnectanalyzer.NewDefaultConfig())
+	factory.RegisterExporter(cameraexporter.Type, cameraexporter.New, cameraexporter.NewDefaultConfig())
+	factory.RegisterExporter(clickhouseexporter.Type, clickhouseexporter.New, clickhouseexporter.NewDefaultConfig())
+	factory.RegisterExporter(remotewriteexporter.Type, remotewriteexporter.New, remotewriteexporter.NewDefaultConfig())
+	factory.RegisterExporter(zipkinexporter.Type, zipkinexporter.New, zipkinexporter.NewDefaultConfig())
+	factory.RegisterExporter(esexporter.Type, esexporter.New, esexporter.NewDefaultConfig())
 }
 
 func (a *Application) readInConfig(path string) error {
@@ -97,6 +185,21 @@ func (a *Application) readInConfig(path string) error {
 		return fmt.Errorf("error happened while reading config file: %w", err)
 	}
 	a.telemetry.ConstructConfig(a.viper)
+	if err = a.viper.UnmarshalKey(ConfigReloadKey, a.reloadConfig, mapStructureDecoderConfigFunc); err != nil {
+		return fmt.Errorf("error happened while reading config reload config: %w", err)
+	}
+	if err = a.viper.UnmarshalKey(ServiceKey, a.serviceConfig, mapStructureDecoderConfigFunc); err != nil {
+		return fmt.Errorf("error happened while reading service config: %w", err)
+	}
+	if err = a.viper.UnmarshalKey(AdminKey, a.adminConfig, mapStructureDecoderConfigFunc); err != nil {
+		return fmt.Errorf("error happened while reading admin config: %w", err)
+	}
+	if err = a.viper.UnmarshalKey(ShutdownKey, a.shutdownConfig, mapStructureDecoderConfigFunc); err != nil {
+		return fmt.Errorf("error happened while reading shutdown config: %w", err)
+	}
+	if err = a.viper.UnmarshalKey(BatcherKey, a.batcherConfig, mapStructureDecoderConfigFunc); err != nil {
+		return fmt.Errorf("error happened while reading batcher config: %w", err)
+	}
 	err = a.componentsFactory.ConstructConfig(a.viper)
 	_ = a.controllerFactory.ConstructConfig(a.viper, a.telemetry.GetGlobalTelemetryTools())
 	if err != nil {
@@ -105,52 +208,288 @@ func (a *Application) readInConfig(path string) error {
 	return nil
 }
 
-// buildPipeline builds a event processing pipeline based on hard-code.
+// buildPipeline builds the pipelines in the service section if they are configured.
+// Otherwise, it builds the default event processing pipeline based on hard-code.
+// The exporters except otelexporter, the processors except k8smetadataprocessor and the
+// analyzers except cpuanalyzer are placed behind the handles, so they can be rebuilt when the configuration is reloaded.
 func (a *Application) buildPipeline() error {
-	// TODO: Build pipeline via configuration to implement dependency injection
+	if a.viper.IsSet(ServiceKey + "." + PipelinesKey) {
+		return a.buildConfiguredPipelines(a.serviceConfig)
+	}
 	// Initialize exporters
 	otelExporterFactory := a.componentsFactory.Exporters[otelexporter.Otel]
 	otelExporter := otelExporterFactory.NewFunc(otelExporterFactory.Config, a.telemetry.GetTelemetryTools(otelexporter.Otel))
-	cameraExporterFactory := a.componentsFactory.Exporters[cameraexporter.Type]
-	cameraExporter := cameraExporterFactory.NewFunc(cameraExporterFactory.Config, a.telemetry.GetTelemetryTools(cameraexporter.Type))
+	monitoredOtelExporter := a.monitor.monitorConsumer(exporterKind, otelexporter.Otel,
+		a.wrapExporter(a.viper, otelexporter.Otel, otelExporter))
+	// The metrics are also written to the remote write endpoint if it is configured.
+	remoteWriteExporter, err := a.newReloadableExporter(remotewriteexporter.Type, true)
+	if err != nil {
+		return err
+	}
+	// The raw records are written to ClickHouse if it is configured.
+	clickHouseExporter, err := a.newReloadableExporter(clickhouseexporter.Type, true)
+	if err != nil {
+		return err
+	}
+	// The request records are also sent as spans if the Zipkin endpoint is configured.
+	zipkinExporter, err := a.newReloadableExporter(zipkinexporter.Type, true)
+	if err != nil {
+		return err
+	}
+	// The DataGroups configured are indexed into Elasticsearch if it is configured.
+	esExporter, err := a.newReloadableExporter(esexporter.Type, true)
+	if err != nil {
+		return err
+	}
+	// The request records are renamed to SingleNetRequestMetricGroup by aggregateprocessor.
+	metricExporter := newFanoutConsumer(monitoredOtelExporter, remoteWriteExporter, esExporter,
+		newNameFilterConsumer(clickHouseExporter, constnames.SingleNetRequestMetricGroup),
+		newNameFilterConsumer(zipkinExporter, constnames.SingleNetRequestMetricGroup))
+	cameraExporter, err := a.newReloadableExporter(cameraexporter.Type, false)
+	if err != nil {
+		return err
+	}
 	// Initialize all processors
 	// 1. DataGroup Aggregator
-	aggregateProcessorFactory := a.componentsFactory.Processors[aggregateprocessor.Type]
-	aggregateProcessor := aggregateProcessorFactory.NewFunc(aggregateProcessorFactory.Config, a.telemetry.GetTelemetryTools(aggregateprocessor.Type), otelExporter)
+	aggregateProcessor, err := a.newReloadableProcessor(aggregateprocessor.Type, metricExporter)
+	if err != nil {
+		return err
+	}
 	// 2. Kubernetes metadata processor
 	k8sProcessorFactory := a.componentsFactory.Processors[k8sprocessor.K8sMetadata]
-	k8sMetadataProcessor := k8sProcessorFactory.NewFunc(k8sProcessorFactory.Config, a.telemetry.GetTelemetryTools(k8sprocessor.K8sMetadata), aggregateProcessor)
+	// The connection records are aggregated by aggregateprocessor, so they must be written before it.
+	var aggregateNext consumer.Consumer = newFanoutConsumer(
+		newNameFilterConsumer(clickHouseExporter, constnames.TcpConnectMetricGroupName), aggregateProcessor)
+	if scheduler := a.controllerFactory.Scheduler; scheduler != nil && scheduler.HasTriggers() {
+		// The profile scheduler watches the request records, which are renamed by aggregateprocessor.
+		aggregateNext = newFanoutConsumer(scheduler, aggregateNext)
+	}
+	// 3. Tail sampling processor samples the request records per trace and passes them to
+	// aggregateprocessor as SingleNetRequestMetricGroup. It passes all through if it is disabled.
+	tailSamplingProcessor, err := a.newReloadableProcessor(tailsamplingprocessor.Type, aggregateNext)
+	if err != nil {
+		return err
+	}
+	// 4. Attributes processor changes the labels, e.g. copies the pod labels or hashes the IPs.
+	attributesProcessor, err := a.newReloadableProcessor(attributesprocessor.Type, tailSamplingProcessor)
+	if err != nil {
+		return err
+	}
+	// 5. Filter processor drops the DataGroups by the rules over the Kubernetes metadata,
+	// so they are neither aggregated nor exported. It keeps all if no rules are configured.
+	// It runs before attributesprocessor so the rules see the original labels.
+	filterProcessor, err := a.newReloadableProcessor(filterprocessor.Type, attributesProcessor)
+	if err != nil {
+		return err
+	}
+	k8sMetadataProcessor := a.monitor.monitorConsumer(processorKind, k8sprocessor.K8sMetadata,
+		k8sProcessorFactory.NewFunc(k8sProcessorFactory.Config, a.telemetry.GetTelemetryTools(k8sprocessor.K8sMetadata), filterProcessor))
 	// Initialize all analyzers
 	// 1. Common network request analyzer
 	networkAnalyzerFactory := a.componentsFactory.Analyzers[network.Network.String()]
 	// Now NetworkAnalyzer must be initialized before any other analyzers, because it will
 	// use its configuration to initialize the conntracker module which is also used by others.
 	networkAnalyzer := networkAnalyzerFactory.NewFunc(networkAnalyzerFactory.Config, a.telemetry.GetTelemetryTools(network.Network.String()), []consumer.Consumer{k8sMetadataProcessor})
+	a.controllerFactory.RegistReconfigurable(controller.NetworkModule, networkAnalyzer.(*network.NetworkAnalyzer))
+	a.addNetworkAnalyzerReloader(networkAnalyzer.(*network.NetworkAnalyzer))
 	// 2. Layer 4 TCP events analyzer
-	tcpAnalyzerFactory := a.componentsFactory.Analyzers[tcpmetricanalyzer.TcpMetric.String()]
-	tcpAnalyzer := tcpAnalyzerFactory.NewFunc(tcpAnalyzerFactory.Config, a.telemetry.GetTelemetryTools(tcpmetricanalyzer.TcpMetric.String()), []consumer.Consumer{k8sMetadataProcessor})
-	tcpConnectAnalyzerFactory := a.componentsFactory.Analyzers[tcpconnectanalyzer.Type.String()]
-	tcpConnectAnalyzer := tcpConnectAnalyzerFactory.NewFunc(tcpConnectAnalyzerFactory.Config, a.telemetry.GetTelemetryTools(tcpconnectanalyzer.Type.String()), []consumer.Consumer{k8sMetadataProcessor})
+	tcpAnalyzer, err := a.newReloadableAnalyzer(tcpmetricanalyzer.TcpMetric.String(), []consumer.Consumer{k8sMetadataProcessor})
+	if err != nil {
+		return err
+	}
+	tcpConnectAnalyzer, err := a.newReloadableAnalyzer(tcpconnectanalyzer.Type.String(), []consumer.Consumer{k8sMetadataProcessor})
+	if err != nil {
+		return err
+	}
 
 	cpuAnalyzerFactory := a.componentsFactory.Analyzers[cpuanalyzer.CpuProfile.String()]
-	cpuAnalyzer := cpuAnalyzerFactory.NewFunc(cpuAnalyzerFactory.Config, a.telemetry.GetTelemetryTools(cpuanalyzer.CpuProfile.String()), []consumer.Consumer{cameraExporter})
-	k8sInfoAnalyzerFactory := a.componentsFactory.Analyzers[k8sinfoanalyzer.Type.String()]
-	k8sInfoAnalyzer := k8sInfoAnalyzerFactory.NewFunc(k8sInfoAnalyzerFactory.Config, a.telemetry.GetTelemetryTools(k8sinfoanalyzer.Type.String()), []consumer.Consumer{otelExporter})
+	// The time breakdown of the profiled requests and the contended locks are aggregated as metrics.
+	cpuAnalyzer := cpuAnalyzerFactory.NewFunc(cpuAnalyzerFactory.Config, a.telemetry.GetTelemetryTools(cpuanalyzer.CpuProfile.String()), []consumer.Consumer{
+		newNameFilterConsumer(cameraExporter, constnames.SingleNetRequestMetricGroup, constnames.SpanEvent, constnames.CameraEventGroupName),
+		newNameFilterConsumer(aggregateProcessor, constnames.RequestTimeBreakdownMetricGroupName, constnames.LockContentionMetricGroupName),
+	})
+	k8sInfoAnalyzer, err := a.newReloadableAnalyzer(k8sinfoanalyzer.Type.String(), []consumer.Consumer{metricExporter})
+	if err != nil {
+		return err
+	}
 	// Initialize receiver packaged with multiple analyzers
-	analyzerManager, err := analyzer.NewManager(networkAnalyzer, tcpAnalyzer, tcpConnectAnalyzer, cpuAnalyzer, k8sInfoAnalyzer)
+	analyzerManager, err := analyzer.NewManager(a.monitor.monitorAnalyzer(network.Network.String(), networkAnalyzer),
+		tcpAnalyzer, tcpConnectAnalyzer, a.monitor.monitorAnalyzer(cpuanalyzer.CpuProfile.String(), cpuAnalyzer), k8sInfoAnalyzer)
 	if err != nil {
 		return fmt.Errorf("error happened while creating analyzer manager: %w", err)
 	}
 	a.analyzerManager = analyzerManager
 
-	cgoReceiverFactory := a.componentsFactory.Receivers[cgoreceiver.Cgo]
-	cgoReceiver := cgoReceiverFactory.NewFunc(cgoReceiverFactory.Config, a.telemetry.GetTelemetryTools(cgoreceiver.Cgo), analyzerManager)
-	a.receiver = cgoReceiver
-
-	a.controllerFactory.RegistModule("profile",
-		cpuAnalyzer.(*cpuanalyzer.CpuAnalyzer).ProfileModule,
-		cgoReceiver.(*cgoreceiver.CgoReceiver).ProfileModule,
-	)
+	// The events recorded are replayed instead of being received from the probe if filereceiver is configured,
+	// and the events are generated if syntheticreceiver is configured.
+	receiverName := cgoreceiver.Cgo
+	if a.viper.IsSet(ReceiversKey + "." + filereceiver.Type) {
+		receiverName = filereceiver.Type
+	} else if a.viper.IsSet(ReceiversKey + "." + syntheticreceiver.Type) {
+		receiverName = syntheticreceiver.Type
+	}
+	receiverFactory := a.componentsFactory.Receivers[receiverName]
+	eventReceiver := receiverFactory.NewFunc(receiverFactory.Config, a.telemetry.GetTelemetryTools(receiverName), analyzerManager)
+	a.receivers = []receiver.Receiver{eventReceiver}
+	a.monitor.addReceiver(receiverName, eventReceiver)
+	// The spans of the instrumented applications are received along with the events if otlpreceiver is configured.
+	if a.viper.IsSet(ReceiversKey + "." + otlpreceiver.Type) {
+		otlpFactory := a.componentsFactory.Receivers[otlpreceiver.Type]
+		otlpReceiver := otlpFactory.NewFunc(otlpFactory.Config, a.telemetry.GetTelemetryTools(otlpreceiver.Type), analyzerManager)
+		a.receivers = append(a.receivers, otlpReceiver)
+		a.monitor.addReceiver(otlpreceiver.Type, otlpReceiver)
+	}
+	a.registerProfileModule(cpuAnalyzer)
 
 	return nil
 }
+
+// newReloadableExporter creates the exporter behind a handle, which is rebuilt and started
+// when its configuration section is changed. The optional exporter is only created if its
+// section is set.
+func (a *Application) newReloadableExporter(name string, optional bool) (consumer.Consumer, error) {
+	key := ExportersKey + "." + name
+	newExporter := func(v *viper.Viper, factory *ComponentsFactory) (ret consumer.Consumer, err error) {
+		if optional && !v.IsSet(key) {
+			return nil, nil
+		}
+		err = buildSafely(func() error {
+			exporterFactory := factory.Exporters[name]
+			e := exporterFactory.NewFunc(exporterFactory.Config, a.telemetry.GetTelemetryTools(name))
+			ret = a.wrapExporter(v, name, e)
+			return nil
+		})
+		return ret, err
+	}
+	first, err := newExporter(a.viper, a.componentsFactory)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create exporter %s: %w", name, err)
+	}
+	handle := newConsumerHandle(first)
+	a.addReloadableComponent(key, handle, func(v *viper.Viper, factory *ComponentsFactory) (interface{}, error) {
+		return newExporter(v, factory)
+	})
+	return a.monitor.monitorConsumer(exporterKind, name, handle), nil
+}
+
+// newReloadableProcessor creates the processor behind a handle, which is rebuilt and started
+// when its configuration section is changed.
+func (a *Application) newReloadableProcessor(name string, next consumer.Consumer) (consumer.Consumer, error) {
+	newProcessor := func(factory *ComponentsFactory) (ret consumer.Consumer, err error) {
+		err = buildSafely(func() error {
+			processorFactory := factory.Processors[name]
+			ret = processorFactory.NewFunc(processorFactory.Config, a.telemetry.GetTelemetryTools(name), next)
+			return nil
+		})
+		return ret, err
+	}
+	first, err := newProcessor(a.componentsFactory)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create processor %s: %w", name, err)
+	}
+	handle := newConsumerHandle(first)
+	// The data held by the previous processor is sent to next when it is shut down.
+	a.addReloadableComponent(ProcessorsKey+"."+name, handle, func(v *viper.Viper, factory *ComponentsFactory) (interface{}, error) {
+		return newProcessor(factory)
+	})
+	return a.monitor.monitorConsumer(processorKind, name, handle), nil
+}
+
+// newReloadableAnalyzer creates the analyzer behind a handle, which is rebuilt and started
+// when its configuration section is changed.
+func (a *Application) newReloadableAnalyzer(name string, consumers []consumer.Consumer) (analyzer.Analyzer, error) {
+	newAnalyzer := func(factory *ComponentsFactory) (ret analyzer.Analyzer, err error) {
+		err = buildSafely(func() error {
+			analyzerFactory := factory.Analyzers[name]
+			ret = analyzerFactory.NewFunc(analyzerFactory.Config, a.telemetry.GetTelemetryTools(name), consumers)
+			return nil
+		})
+		return ret, err
+	}
+	first, err := newAnalyzer(a.componentsFactory)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create analyzer %s: %w", name, err)
+	}
+	handle := newAnalyzerHandle(first)
+	a.addReloadableComponent(AnalyzersKey+"."+name, handle, func(v *viper.Viper, factory *ComponentsFactory) (interface{}, error) {
+		return newAnalyzer(factory)
+	})
+	return a.monitor.monitorAnalyzer(name, handle), nil
+}
+
+// addReloadableComponent rebuilds the instance behind the handle with build when its
+// configuration section is changed. The new instance is built when the configuration is
+// validated, so an invalid configuration is found before any component is changed, and it
+// is swapped in after it is started, so the data keeps flowing into the previous instance
+// until then. The components in stopFirstComponents are shut down before the new instances
+// are built instead, and the data is dropped and counted in the meantime.
+func (a *Application) addReloadableComponent(key string, handle reloadableHandle,
+	build func(v *viper.Viper, factory *ComponentsFactory) (interface{}, error)) {
+	stopFirst := stopFirstComponents[key]
+	// prepared is the instance built by validate with the factory preparedFor. It is only
+	// accessed by the reloader holding its lock.
+	var prepared interface{}
+	var preparedFor *ComponentsFactory
+	shutdown := func(instance interface{}) {
+		if err := shutdownComponent(instance); err != nil {
+			a.telemetry.Logger.Warn("Error happened when shutting down the component replaced",
+				zap.String("section", key), zap.Error(err))
+		}
+	}
+	c := &reloadableComponent{key: key}
+	if !stopFirst {
+		c.validate = func(v *viper.Viper, factory *ComponentsFactory) error {
+			instance, err := build(v, factory)
+			if err != nil {
+				return err
+			}
+			prepared, preparedFor = instance, factory
+			return nil
+		}
+		c.discard = func() {
+			shutdown(prepared)
+			prepared, preparedFor = nil, nil
+		}
+	}
+	c.rebuild = func(v *viper.Viper, factory *ComponentsFactory) error {
+		if stopFirst {
+			shutdown(handle.stop())
+		}
+		// The instance is built here when the component is rolled back.
+		instance := prepared
+		if preparedFor != factory {
+			var err error
+			if instance, err = build(v, factory); err != nil {
+				return err
+			}
+		}
+		prepared, preparedFor = nil, nil
+		if err := startComponent(instance); err != nil {
+			shutdown(instance)
+			return err
+		}
+		shutdown(handle.replace(instance))
+		return nil
+	}
+	a.reloader.addComponent(c)
+	registerHandle(key, handle)
+}
+
+// addNetworkAnalyzerReloader applies the options of the protocol parsing to the network
+// analyzer when they are changed. The message pairs in flight are kept.
+func (a *Application) addNetworkAnalyzerReloader(na *network.NetworkAnalyzer) {
+	name := network.Network.String()
+	a.reloader.addComponent(&reloadableComponent{
+		key: AnalyzersKey + "." + name,
+		validate: func(v *viper.Viper, factory *ComponentsFactory) error {
+			err := na.ValidateConfig(factory.Analyzers[name].Config.(*network.Config))
+			if errors.Is(err, network.ErrRestartRequired) {
+				return errRestartRequired
+			}
+			return err
+		},
+		rebuild: func(v *viper.Viper, factory *ComponentsFactory) error {
+			return na.ApplyConfig(factory.Analyzers[name].Config.(*network.Config))
+		},
+	})
+}
@@ -0,0 +1,150 @@
+package application
+
+import (
+	"github.com/spf13/viper"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component"
+	"github.com/Kindling-project/kindling/collector/pkg/component/consumer"
+	"github.com/Kindling-project/kindling/collector/pkg/component/consumer/exporter"
+	"github.com/Kindling-project/kindling/collector/pkg/component/consumer/exporter/tools/batcher"
+	"github.com/Kindling-project/kindling/collector/pkg/component/consumer/exporter/tools/diskqueue"
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+)
+
+const (
+	// DiskQueueKey is the key of the disk queue section under each exporter's configuration,
+	// e.g. "exporters.otelexporter.disk_queue".
+	DiskQueueKey = "disk_queue"
+	// BatchKey is the key of the batcher section under each exporter's configuration,
+	// e.g. "exporters.otelexporter.batch".
+	BatchKey = "batch"
+	// BatcherKey is the key of the configuration section shared by the batchers of all the
+	// exporters, e.g. the global memory limit.
+	BatcherKey = "batcher"
+)
+
+// wrapExporter decorates the exporter with the wrappers enabled in its configuration section.
+// The original exporter is returned if no wrapper is enabled.
+// The wrappers are chained as: batcher -> disk queue -> exporter, so that the batcher
+// returns quickly and the disk queue retries the data the exporter fails to send.
+// The batcher is enabled by default for the exporters implementing exporter.BatchConsumer,
+// which don't batch the data by themselves.
+// The exporters implementing diskqueue.QueueOwner are given the disk queue configuration
+// instead of being wrapped by the disk queue. The batchers share the memory limiter of the
+// application.
+func (a *Application) wrapExporter(v *viper.Viper, name string, e exporter.Exporter) exporter.Exporter {
+	chain := []exporter.Exporter{e}
+	_, batchConsumer := e.(exporter.BatchConsumer)
+	queueCfg := diskqueue.NewDefaultConfig()
+	key := ExportersKey + "." + name + "." + DiskQueueKey
+	if err := v.UnmarshalKey(key, queueCfg, mapStructureDecoderConfigFunc); err != nil {
+		a.telemetry.Logger.Error("Failed to read disk queue config, the queue is disabled",
+			zap.String("exporter", name), zap.Error(err))
+	} else if owner, ok := e.(diskqueue.QueueOwner); ok && queueCfg.Enable {
+		// The exporter pushes the data asynchronously, so it persists the data by itself.
+		if err := owner.EnableDiskQueue(name, queueCfg, a.telemetry.GetTelemetryTools(name)); err != nil {
+			a.telemetry.Logger.Error("Failed to enable disk queue, the data will be sent directly",
+				zap.String("exporter", name), zap.Error(err))
+		}
+	} else if queueCfg.Enable {
+		queued, err := diskqueue.NewQueuedExporter(name, queueCfg, a.telemetry.GetTelemetryTools(name), e)
+		if err != nil {
+			a.telemetry.Logger.Error("Failed to create disk queue, the data will be sent directly",
+				zap.String("exporter", name), zap.Error(err))
+		} else {
+			e = queued
+			chain = append([]exporter.Exporter{e}, chain...)
+		}
+	}
+
+	batchCfg := batcher.NewDefaultConfig()
+	batchCfg.Enable = batchConsumer
+	key = ExportersKey + "." + name + "." + BatchKey
+	if err := v.UnmarshalKey(key, batchCfg, mapStructureDecoderConfigFunc); err != nil {
+		a.telemetry.Logger.Error("Failed to read batch config, the batcher is disabled",
+			zap.String("exporter", name), zap.Error(err))
+	} else if batchCfg.Enable {
+		e = batcher.NewBatchExporter(name, batchCfg, a.batchLimiter, a.telemetry.GetTelemetryTools(name), e)
+		chain = append([]exporter.Exporter{e}, chain...)
+	}
+	if len(chain) == 1 {
+		return e
+	}
+	return &wrappedExporter{Exporter: e, chain: chain}
+}
+
+// wrappedExporter is the outermost wrapper of an exporter. It shuts down the wrappers from
+// the outermost one, so that the data they hold is flushed into the exporter before it stops.
+type wrappedExporter struct {
+	exporter.Exporter
+	chain []exporter.Exporter
+}
+
+func (w *wrappedExporter) Shutdown() error {
+	var retErr error
+	for _, e := range w.chain {
+		retErr = multierr.Append(retErr, shutdownComponent(e))
+	}
+	return retErr
+}
+
+// QueueDepths returns the fill levels of the queues in the wrappers and the exporter.
+func (w *wrappedExporter) QueueDepths() map[string]component.QueueDepth {
+	ret := make(map[string]component.QueueDepth)
+	for _, e := range w.chain {
+		if reporter, ok := e.(component.QueueReporter); ok {
+			for name, depth := range reporter.QueueDepths() {
+				ret[name] = depth
+			}
+		}
+	}
+	return ret
+}
+
+// shutdownComponent shuts down the component if it supports.
+func shutdownComponent(c interface{}) error {
+	if s, ok := c.(interface{ Shutdown() error }); ok {
+		return s.Shutdown()
+	}
+	return nil
+}
+
+// fanoutConsumer sends each DataGroup to all the consumers in order.
+type fanoutConsumer struct {
+	consumers []consumer.Consumer
+}
+
+func newFanoutConsumer(consumers ...consumer.Consumer) *fanoutConsumer {
+	return &fanoutConsumer{consumers: consumers}
+}
+
+func (f *fanoutConsumer) Consume(dataGroup *model.DataGroup) error {
+	var retError error
+	for _, c := range f.consumers {
+		retError = multierr.Append(retError, c.Consume(dataGroup))
+	}
+	return retError
+}
+
+// nameFilterConsumer only passes the DataGroups with the given names to the next consumer.
+type nameFilterConsumer struct {
+	names map[string]struct{}
+	next  consumer.Consumer
+}
+
+func newNameFilterConsumer(next consumer.Consumer, names ...string) *nameFilterConsumer {
+	nameMap := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		nameMap[name] = struct{}{}
+	}
+	return &nameFilterConsumer{names: nameMap, next: next}
+}
+
+func (f *nameFilterConsumer) Consume(dataGroup *model.DataGroup) error {
+	if _, ok := f.names[dataGroup.Name]; !ok {
+		return nil
+	}
+	return f.next.Consume(dataGroup)
+}
@@ -0,0 +1,118 @@
+package application
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component"
+)
+
+// ShutdownKey is the key of the configuration section of the graceful shutdown.
+const ShutdownKey = "shutdown"
+
+// ShutdownConfig configures how the pipelines are drained when the collector exits.
+type ShutdownConfig struct {
+	// Timeout is how long the components are waited for to flush the data they hold. It
+	// should be less than the terminationGracePeriodSeconds of the pod, otherwise the
+	// collector is killed before the data dropped is reported.
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+func NewDefaultShutdownConfig() *ShutdownConfig {
+	return &ShutdownConfig{
+		Timeout: 20 * time.Second,
+	}
+}
+
+// startComponent starts the component if it supports.
+func startComponent(c interface{}) error {
+	if s, ok := c.(interface{ Start() error }); ok {
+		return s.Start()
+	}
+	return nil
+}
+
+// startConsumers starts the processors and the exporters in the order they are created,
+// so each one is started after the consumers it sends data to.
+func (a *Application) startConsumers() error {
+	for _, c := range a.monitor.list() {
+		if c.kind != processorKind && c.kind != exporterKind {
+			continue
+		}
+		if err := startComponent(c.target); err != nil {
+			return fmt.Errorf("failed to start %s %s: %w", c.kind, c.name, err)
+		}
+	}
+	return nil
+}
+
+// shutdownComponents shuts down the components in the reverse order they are created,
+// which is from the receivers to the exporters. Each one flushes the data it holds into
+// the next ones before they are shut down, e.g. the analyzers drain their channels and
+// aggregateprocessor sends the last window. The components not shut down before the
+// timeout are reported with the data left in their queues, which is dropped when the
+// process exits.
+func (a *Application) shutdownComponents(timeout time.Duration) error {
+	logger := a.telemetry.GetGlobalTelemetryTools().Logger
+	components := a.monitor.list()
+	// current is the index of the component being shut down.
+	var current atomic.Int64
+	current.Store(int64(len(components) - 1))
+	done := make(chan error, 1)
+	go func() {
+		var retErr error
+		for i := len(components) - 1; i >= 0; i-- {
+			c := components[i]
+			current.Store(int64(i))
+			logger.Infof("Shutting down %s [%s]", c.kind, c.name)
+			if err := shutdownComponent(c.target); err != nil {
+				retErr = multierr.Append(retErr, fmt.Errorf("failed to shut down %s %s: %w", c.kind, c.name, err))
+			}
+		}
+		done <- retErr
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+	}
+	pending := components[:current.Load()+1]
+	names := make([]string, 0, len(pending))
+	for i := len(pending) - 1; i >= 0; i-- {
+		c := pending[i]
+		names = append(names, string(c.kind)+"/"+c.name)
+		if reporter, ok := c.instance().(component.QueueReporter); ok {
+			if dropped := formatQueueDepths(reporter.QueueDepths()); dropped != "" {
+				logger.Warn("The data left in the queues is dropped",
+					zap.String("component", string(c.kind)+"/"+c.name), zap.String("queues", dropped))
+			}
+		}
+	}
+	logger.Error("Shutdown timed out, the data held by the components not shut down is dropped",
+		zap.Duration("timeout", timeout), zap.Strings("components", names))
+	return fmt.Errorf("shutdown timed out after %s: %s not shut down", timeout, strings.Join(names, ", "))
+}
+
+// formatQueueDepths returns the queues which are not empty, e.g. "batches: 3/10".
+func formatQueueDepths(depths map[string]component.QueueDepth) string {
+	names := make([]string, 0, len(depths))
+	for name, depth := range depths {
+		if depth.Length > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		names[i] = fmt.Sprintf("%s: %d/%d", name, depths[name].Length, depths[name].Capacity)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,106 @@
+package application
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component"
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+)
+
+// lifecycleRecorder records the order the components are started and shut down in.
+type lifecycleRecorder struct {
+	started  []string
+	shutdown []string
+}
+
+type lifecycleConsumer struct {
+	name     string
+	recorder *lifecycleRecorder
+	// block blocks Shutdown until it is closed if it is not nil.
+	block chan struct{}
+}
+
+func (c *lifecycleConsumer) Consume(*model.DataGroup) error { return nil }
+
+func (c *lifecycleConsumer) Start() error {
+	c.recorder.started = append(c.recorder.started, c.name)
+	return nil
+}
+
+func (c *lifecycleConsumer) Shutdown() error {
+	if c.block != nil {
+		<-c.block
+	}
+	c.recorder.shutdown = append(c.recorder.shutdown, c.name)
+	return nil
+}
+
+func (c *lifecycleConsumer) QueueDepths() map[string]component.QueueDepth {
+	return map[string]component.QueueDepth{"batches": {Length: 2, Capacity: 10}, "memory_bytes": {Capacity: 1024}}
+}
+
+type lifecycleAnalyzer struct {
+	fakeAnalyzer
+	recorder *lifecycleRecorder
+}
+
+func (a *lifecycleAnalyzer) Shutdown() error {
+	a.recorder.shutdown = append(a.recorder.shutdown, a.name)
+	return nil
+}
+
+type lifecycleReceiver struct {
+	fakeReceiver
+	recorder *lifecycleRecorder
+}
+
+func (r *lifecycleReceiver) Shutdown() error {
+	r.recorder.shutdown = append(r.recorder.shutdown, "r1")
+	return nil
+}
+
+func newLifecycleTestApp(monitorEnabled bool, recorder *lifecycleRecorder, block chan struct{}) *Application {
+	app := &Application{
+		telemetry: component.NewTelemetryManager(),
+		monitor:   newPipelineMonitor(monitorEnabled),
+	}
+	// The components are created from the exporters to the receiver.
+	e1 := &lifecycleConsumer{name: "e1", recorder: recorder, block: block}
+	app.monitor.monitorConsumer(exporterKind, "e1", newConsumerHandle(e1))
+	app.monitor.monitorConsumer(processorKind, "p1", &lifecycleConsumer{name: "p1", recorder: recorder})
+	app.monitor.monitorConsumer(processorKind, "p2", &lifecycleConsumer{name: "p2", recorder: recorder})
+	app.monitor.monitorAnalyzer("a1", &lifecycleAnalyzer{fakeAnalyzer: fakeAnalyzer{name: "a1"}, recorder: recorder})
+	app.monitor.addReceiver("r1", &lifecycleReceiver{recorder: recorder})
+	return app
+}
+
+func TestLifecycle(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		recorder := &lifecycleRecorder{}
+		app := newLifecycleTestApp(enabled, recorder, nil)
+		require.NoError(t, app.startConsumers())
+		assert.Equal(t, []string{"e1", "p1", "p2"}, recorder.started)
+		require.NoError(t, app.shutdownComponents(time.Second))
+		assert.Equal(t, []string{"r1", "a1", "p2", "p1", "e1"}, recorder.shutdown)
+	}
+}
+
+func TestLifecycleShutdownTimeout(t *testing.T) {
+	recorder := &lifecycleRecorder{}
+	block := make(chan struct{})
+	defer close(block)
+	app := newLifecycleTestApp(true, recorder, block)
+	err := app.shutdownComponents(100 * time.Millisecond)
+	require.Error(t, err)
+	assert.Equal(t, "shutdown timed out after 100ms: exporter/e1 not shut down", err.Error())
+	assert.Equal(t, []string{"r1", "a1", "p2", "p1"}, recorder.shutdown)
+}
+
+func TestFormatQueueDepths(t *testing.T) {
+	assert.Equal(t, "batches: 2/10", formatQueueDepths((&lifecycleConsumer{}).QueueDepths()))
+	assert.Equal(t, "", formatQueueDepths(nil))
+}
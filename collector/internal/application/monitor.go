@@ -0,0 +1,335 @@
+package application
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component"
+	"github.com/Kindling-project/kindling/collector/pkg/component/analyzer"
+	"github.com/Kindling-project/kindling/collector/pkg/component/consumer"
+	"github.com/Kindling-project/kindling/collector/pkg/component/receiver"
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+)
+
+type componentKind string
+
+const (
+	receiverKind  componentKind = "receiver"
+	analyzerKind  componentKind = "analyzer"
+	processorKind componentKind = "processor"
+	exporterKind  componentKind = "exporter"
+)
+
+// componentStats counts the data consumed by a component.
+type componentStats struct {
+	consumed atomic.Int64
+	failed   atomic.Int64
+	// lastFailed is true if the last data failed to be consumed, e.g. the exporter can't
+	// connect to its backend.
+	lastFailed atomic.Bool
+
+	lock          sync.Mutex
+	lastError     string
+	lastErrorTime time.Time
+	// rate is the data consumed per second, which is calculated when it is sampled.
+	rate         float64
+	sampledCount int64
+	sampledTime  time.Time
+}
+
+func (s *componentStats) record(err error) {
+	s.consumed.Add(1)
+	if err == nil {
+		if s.lastFailed.Load() {
+			s.lastFailed.Store(false)
+		}
+		return
+	}
+	s.failed.Add(1)
+	s.lastFailed.Store(true)
+	s.lock.Lock()
+	s.lastError = err.Error()
+	s.lastErrorTime = time.Now()
+	s.lock.Unlock()
+}
+
+func (s *componentStats) sample(now time.Time) {
+	count := s.consumed.Load()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if elapsed := now.Sub(s.sampledTime).Seconds(); !s.sampledTime.IsZero() && elapsed > 0 {
+		s.rate = float64(count-s.sampledCount) / elapsed
+	}
+	s.sampledCount = count
+	s.sampledTime = now
+}
+
+// monitoredConsumer counts the DataGroups passed to the consumer.
+type monitoredConsumer struct {
+	next  consumer.Consumer
+	stats *componentStats
+}
+
+func (c *monitoredConsumer) Consume(dataGroup *model.DataGroup) error {
+	err := c.next.Consume(dataGroup)
+	c.stats.record(err)
+	return err
+}
+
+func (c *monitoredConsumer) Shutdown() error {
+	return shutdownComponent(c.next)
+}
+
+// monitoredAnalyzer counts the events passed to the analyzer.
+type monitoredAnalyzer struct {
+	analyzer.Analyzer
+	stats *componentStats
+}
+
+func (a *monitoredAnalyzer) ConsumeEvent(event *model.KindlingEvent) error {
+	err := a.Analyzer.ConsumeEvent(event)
+	a.stats.record(err)
+	return err
+}
+
+// componentHolder is implemented by the handles, which return the component in effect
+// to be inspected instead of themselves.
+type componentHolder interface {
+	current() interface{}
+}
+
+type monitoredComponent struct {
+	kind componentKind
+	// name is the type the component is registered with, which its configuration is read by.
+	name   string
+	target interface{}
+	// stats is nil for the receivers, or if the monitor is disabled.
+	stats *componentStats
+}
+
+func (c *monitoredComponent) instance() interface{} {
+	if holder, ok := c.target.(componentHolder); ok {
+		return holder.current()
+	}
+	return c.target
+}
+
+// pipelineMonitor keeps the components of the pipelines in the order they are created,
+// which is also the order they are started and shut down in. It reports their status,
+// but the components are not wrapped if it is disabled, so nothing is counted.
+type pipelineMonitor struct {
+	enabled bool
+
+	lock       sync.RWMutex
+	components []*monitoredComponent
+
+	// The events consumed by all the analyzers are sampled to tell whether the events
+	// are still being processed.
+	progressLock   sync.Mutex
+	lastEventCount int64
+	lastProgress   time.Time
+}
+
+func newPipelineMonitor(enabled bool) *pipelineMonitor {
+	return &pipelineMonitor{enabled: enabled, lastProgress: time.Now()}
+}
+
+func (m *pipelineMonitor) add(c *monitoredComponent) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.components = append(m.components, c)
+}
+
+func (m *pipelineMonitor) addReceiver(name string, r receiver.Receiver) {
+	m.add(&monitoredComponent{kind: receiverKind, name: name, target: r})
+}
+
+// monitorConsumer returns the consumer counting the DataGroups passed to c.
+func (m *pipelineMonitor) monitorConsumer(kind componentKind, name string, c consumer.Consumer) consumer.Consumer {
+	if !m.enabled {
+		m.add(&monitoredComponent{kind: kind, name: name, target: c})
+		return c
+	}
+	stats := &componentStats{}
+	m.add(&monitoredComponent{kind: kind, name: name, target: c, stats: stats})
+	return &monitoredConsumer{next: c, stats: stats}
+}
+
+// monitorAnalyzer returns the analyzer counting the events passed to an.
+func (m *pipelineMonitor) monitorAnalyzer(name string, an analyzer.Analyzer) analyzer.Analyzer {
+	if !m.enabled {
+		m.add(&monitoredComponent{kind: analyzerKind, name: name, target: an})
+		return an
+	}
+	stats := &componentStats{}
+	m.add(&monitoredComponent{kind: analyzerKind, name: name, target: an, stats: stats})
+	return &monitoredAnalyzer{Analyzer: an, stats: stats}
+}
+
+func (m *pipelineMonitor) list() []*monitoredComponent {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return append([]*monitoredComponent(nil), m.components...)
+}
+
+// sample calculates the rates of the components, and records the time when the analyzers
+// consumed events last.
+func (m *pipelineMonitor) sample(now time.Time) {
+	var events int64
+	for _, c := range m.list() {
+		if c.stats == nil {
+			continue
+		}
+		c.stats.sample(now)
+		if c.kind == analyzerKind {
+			events += c.stats.consumed.Load()
+		}
+	}
+	m.progressLock.Lock()
+	defer m.progressLock.Unlock()
+	if events != m.lastEventCount {
+		m.lastEventCount = events
+		m.lastProgress = now
+	}
+}
+
+// idleTime returns how long the analyzers have not consumed any events when it is sampled.
+func (m *pipelineMonitor) idleTime(now time.Time) time.Duration {
+	m.progressLock.Lock()
+	defer m.progressLock.Unlock()
+	return now.Sub(m.lastProgress)
+}
+
+// componentStatus is the status of a component shown by /debug/pipeline.
+type componentStatus struct {
+	Kind   componentKind `json:"kind"`
+	Name   string        `json:"name"`
+	Config interface{}   `json:"config,omitempty"`
+	// Throughput is absent for the receivers.
+	Throughput  *throughput                     `json:"throughput,omitempty"`
+	QueueDepths map[string]component.QueueDepth `json:"queue_depths,omitempty"`
+	// Health is the error reported by the component if it is unhealthy.
+	Health        string     `json:"health,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	LastErrorTime *time.Time `json:"last_error_time,omitempty"`
+}
+
+type throughput struct {
+	Total     int64   `json:"total"`
+	Failed    int64   `json:"failed"`
+	PerSecond float64 `json:"per_second"`
+}
+
+// status returns the status of the components. The configurations are read from the factory.
+func (m *pipelineMonitor) status(factory *ComponentsFactory) []componentStatus {
+	components := m.list()
+	ret := make([]componentStatus, 0, len(components))
+	for _, c := range components {
+		s := componentStatus{Kind: c.kind, Name: c.name, Config: redactConfig(componentConfig(factory, c.kind, c.name))}
+		instance := c.instance()
+		if reporter, ok := instance.(component.QueueReporter); ok {
+			s.QueueDepths = reporter.QueueDepths()
+		}
+		if checker, ok := instance.(component.HealthChecker); ok {
+			if err := checker.CheckHealth(); err != nil {
+				s.Health = err.Error()
+			}
+		}
+		if c.stats != nil {
+			c.stats.lock.Lock()
+			s.Throughput = &throughput{Total: c.stats.consumed.Load(), Failed: c.stats.failed.Load(), PerSecond: c.stats.rate}
+			if s.LastError = c.stats.lastError; s.LastError != "" {
+				lastErrorTime := c.stats.lastErrorTime
+				s.LastErrorTime = &lastErrorTime
+			}
+			c.stats.lock.Unlock()
+		}
+		ret = append(ret, s)
+	}
+	return ret
+}
+
+func componentConfig(factory *ComponentsFactory, kind componentKind, name string) interface{} {
+	switch kind {
+	case receiverKind:
+		if f, ok := factory.Receivers[name]; ok {
+			return f.Config
+		}
+	case analyzerKind:
+		if f, ok := factory.Analyzers[name]; ok {
+			return f.Config
+		}
+	case processorKind:
+		if f, ok := factory.Processors[name]; ok {
+			return f.Config
+		}
+	case exporterKind:
+		if f, ok := factory.Exporters[name]; ok {
+			return f.Config
+		}
+	}
+	return nil
+}
+
+// sensitiveKeys are the parts of the configuration keys whose values are not shown. The
+// salts are included because the hashed values, e.g. the IPs, can be recovered by brute
+// force with them.
+var sensitiveKeys = []string{"password", "secret", "token", "credential", "apikey", "api_key", "authorization", "salt"}
+
+// headersKey is the key of the HTTP headers of the exporters. All the values under it are
+// not shown, because any header could carry credentials, e.g. "Authorization: Bearer ...".
+const headersKey = "headers"
+
+const redacted = "<redacted>"
+
+// redactConfig converts the configuration into the generic form with the sensitive values
+// replaced, e.g. the passwords of the exporters.
+func redactConfig(cfg interface{}) interface{} {
+	if cfg == nil {
+		return nil
+	}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return "failed to encode the configuration: " + err.Error()
+	}
+	var generic interface{}
+	if err = json.Unmarshal(data, &generic); err != nil {
+		return "failed to encode the configuration: " + err.Error()
+	}
+	return redactValue(generic)
+}
+
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if isSensitiveKey(key) && item != nil {
+				v[key] = redacted
+			} else if headers, ok := item.(map[string]interface{}); ok && strings.EqualFold(key, headersKey) {
+				for name := range headers {
+					headers[name] = redacted
+				}
+			} else {
+				v[key] = redactValue(item)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactValue(item)
+		}
+	}
+	return value
+}
+
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,340 @@
+package application
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component/analyzer"
+	"github.com/Kindling-project/kindling/collector/pkg/component/analyzer/cpuanalyzer"
+	"github.com/Kindling-project/kindling/collector/pkg/component/analyzer/network"
+	"github.com/Kindling-project/kindling/collector/pkg/component/consumer"
+	"github.com/Kindling-project/kindling/collector/pkg/component/consumer/exporter/cameraexporter"
+	"github.com/Kindling-project/kindling/collector/pkg/component/consumer/exporter/otelexporter"
+	"github.com/Kindling-project/kindling/collector/pkg/component/consumer/processor/aggregateprocessor"
+	"github.com/Kindling-project/kindling/collector/pkg/component/consumer/processor/k8sprocessor"
+	"github.com/Kindling-project/kindling/collector/pkg/component/controller"
+	"github.com/Kindling-project/kindling/collector/pkg/component/receiver/cgoreceiver"
+)
+
+const (
+	// ServiceKey is the key of the service section, which configures the pipelines.
+	ServiceKey = "service"
+	// PipelinesKey is the key of the pipelines under the service section.
+	PipelinesKey = "pipelines"
+)
+
+// ServiceConfig builds the pipelines from the configuration instead of the default one.
+type ServiceConfig struct {
+	Pipelines map[string]*PipelineConfig `mapstructure:"pipelines"`
+}
+
+// PipelineConfig is a pipeline where the events received by Receiver are analyzed by
+// Analyzers, and the DataGroups are passed through Processors in order and sent to all
+// the Exporters. The components are referred to by the names they are registered with.
+type PipelineConfig struct {
+	// Receiver is empty if the pipeline is only fed by the other pipelines.
+	Receiver  string   `mapstructure:"receiver"`
+	Analyzers []string `mapstructure:"analyzers"`
+	// DataGroups are the names of the DataGroups passed into the pipeline. All are
+	// passed if it is empty.
+	DataGroups []string `mapstructure:"data_groups"`
+	Processors []string `mapstructure:"processors"`
+	Exporters  []string `mapstructure:"exporters"`
+	// Pipelines are the pipelines the output is also sent to, which have no receivers.
+	Pipelines []string `mapstructure:"pipelines"`
+}
+
+// The components which are built without handles, so they are not rebuilt when the
+// configuration is reloaded.
+var notReloadableComponents = map[string]bool{
+	ExportersKey + "." + otelexporter.Otel:               true,
+	ProcessorsKey + "." + k8sprocessor.K8sMetadata:       true,
+	AnalyzersKey + "." + cpuanalyzer.CpuProfile.String(): true,
+	AnalyzersKey + "." + network.Network.String():        true,
+}
+
+// The components holding the resources their new instances need, e.g. the query service of
+// cameraexporter listening on a fixed port. They are shut down before the new instances are
+// built when they are rebuilt.
+var stopFirstComponents = map[string]bool{
+	ExportersKey + "." + cameraexporter.Type: true,
+}
+
+// validatePipelines checks the references of the components and the pipelines, and
+// returns the names of the pipelines in the order to build, where the pipelines are
+// built before the ones sending data to them.
+func validatePipelines(cfg *ServiceConfig, factory *ComponentsFactory) ([]string, error) {
+	if len(cfg.Pipelines) == 0 {
+		return nil, errors.New("no pipelines are configured")
+	}
+	names := make([]string, 0, len(cfg.Pipelines))
+	for name := range cfg.Pipelines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fed := make(map[string]bool)
+	analyzerReceivers := make(map[string]string)
+	for _, name := range names {
+		p := cfg.Pipelines[name]
+		if p == nil {
+			return nil, fmt.Errorf("pipeline %s is empty", name)
+		}
+		if p.Receiver != "" {
+			if _, ok := factory.Receivers[p.Receiver]; !ok {
+				return nil, fmt.Errorf("pipeline %s: unknown receiver %s", name, p.Receiver)
+			}
+			if len(p.Analyzers) == 0 {
+				return nil, fmt.Errorf("pipeline %s: the receiver requires at least one analyzer", name)
+			}
+		} else if len(p.Analyzers) > 0 {
+			return nil, fmt.Errorf("pipeline %s: the analyzers require a receiver", name)
+		}
+		for _, a := range p.Analyzers {
+			if _, ok := factory.Analyzers[a]; !ok {
+				return nil, fmt.Errorf("pipeline %s: unknown analyzer %s", name, a)
+			}
+			// The analyzer is created once, so it can only receive the events from one receiver.
+			if receiver, ok := analyzerReceivers[a]; ok && receiver != p.Receiver {
+				return nil, fmt.Errorf("pipeline %s: analyzer %s is used with receivers %s and %s", name, a, receiver, p.Receiver)
+			}
+			analyzerReceivers[a] = p.Receiver
+		}
+		for _, processor := range p.Processors {
+			if _, ok := factory.Processors[processor]; !ok {
+				return nil, fmt.Errorf("pipeline %s: unknown processor %s", name, processor)
+			}
+		}
+		for _, e := range p.Exporters {
+			if _, ok := factory.Exporters[e]; !ok {
+				return nil, fmt.Errorf("pipeline %s: unknown exporter %s", name, e)
+			}
+		}
+		for _, next := range p.Pipelines {
+			nextPipeline, ok := cfg.Pipelines[next]
+			if !ok || nextPipeline == nil {
+				return nil, fmt.Errorf("pipeline %s: unknown pipeline %s", name, next)
+			}
+			if nextPipeline.Receiver != "" {
+				return nil, fmt.Errorf("pipeline %s: pipeline %s has a receiver, so it can't be fed by the others", name, next)
+			}
+			fed[next] = true
+		}
+		if len(p.Exporters) == 0 && len(p.Pipelines) == 0 {
+			return nil, fmt.Errorf("pipeline %s: at least one exporter or pipeline is required", name)
+		}
+	}
+	for _, name := range names {
+		if cfg.Pipelines[name].Receiver == "" && !fed[name] {
+			return nil, fmt.Errorf("pipeline %s has neither a receiver nor a pipeline feeding it", name)
+		}
+	}
+
+	// Sort the pipelines topologically and report the cycles.
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	states := make(map[string]int, len(names))
+	order := make([]string, 0, len(names))
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch states[name] {
+		case visiting:
+			return fmt.Errorf("pipelines form a cycle: %s", strings.Join(append(path, name), " -> "))
+		case visited:
+			return nil
+		}
+		states[name] = visiting
+		for _, next := range cfg.Pipelines[name].Pipelines {
+			if err := visit(next, append(path, name)); err != nil {
+				return err
+			}
+		}
+		states[name] = visited
+		order = append(order, name)
+		return nil
+	}
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// buildConfiguredPipelines builds the pipelines in the service section. The exporters and
+// the analyzers are shared by the pipelines referring to them, while each pipeline has its
+// own processors.
+func (a *Application) buildConfiguredPipelines(cfg *ServiceConfig) error {
+	order, err := validatePipelines(cfg, a.componentsFactory)
+	if err != nil {
+		return fmt.Errorf("invalid pipelines: %w", err)
+	}
+
+	exporters := make(map[string]consumer.Consumer)
+	getExporter := func(name string) (consumer.Consumer, error) {
+		if e, ok := exporters[name]; ok {
+			return e, nil
+		}
+		var e consumer.Consumer
+		if notReloadableComponents[ExportersKey+"."+name] {
+			exporterFactory := a.componentsFactory.Exporters[name]
+			e = a.monitor.monitorConsumer(exporterKind, name,
+				a.wrapExporter(a.viper, name, exporterFactory.NewFunc(exporterFactory.Config, a.telemetry.GetTelemetryTools(name))))
+		} else {
+			handle, err := a.newReloadableExporter(name, false)
+			if err != nil {
+				return nil, err
+			}
+			e = handle
+		}
+		exporters[name] = e
+		return e, nil
+	}
+
+	// The pipelines are built from the downstream ones, and heads are their first consumers.
+	heads := make(map[string]consumer.Consumer, len(order))
+	for _, name := range order {
+		p := cfg.Pipelines[name]
+		outputs := make([]consumer.Consumer, 0, len(p.Exporters)+len(p.Pipelines))
+		for _, exporterName := range p.Exporters {
+			e, err := getExporter(exporterName)
+			if err != nil {
+				return err
+			}
+			outputs = append(outputs, e)
+		}
+		for _, next := range p.Pipelines {
+			outputs = append(outputs, heads[next])
+		}
+		var next consumer.Consumer = newFanoutConsumer(outputs...)
+		if len(outputs) == 1 {
+			next = outputs[0]
+		}
+		for i := len(p.Processors) - 1; i >= 0; i-- {
+			next, err = a.newPipelineProcessor(p.Processors[i], next)
+			if err != nil {
+				return fmt.Errorf("pipeline %s: %w", name, err)
+			}
+		}
+		if len(p.DataGroups) > 0 {
+			next = newNameFilterConsumer(next, p.DataGroups...)
+		}
+		heads[name] = next
+	}
+
+	// Each analyzer sends the DataGroups to all the pipelines it belongs to.
+	analyzerConsumers := make(map[string][]consumer.Consumer)
+	receiverAnalyzers := make(map[string][]string)
+	analyzerNames := make([]string, 0)
+	for _, name := range order {
+		p := cfg.Pipelines[name]
+		for _, analyzerName := range p.Analyzers {
+			if _, ok := analyzerConsumers[analyzerName]; !ok {
+				analyzerNames = append(analyzerNames, analyzerName)
+				receiverAnalyzers[p.Receiver] = append(receiverAnalyzers[p.Receiver], analyzerName)
+			}
+			analyzerConsumers[analyzerName] = append(analyzerConsumers[analyzerName], heads[name])
+		}
+	}
+	// Now NetworkAnalyzer must be initialized before any other analyzers, because it will
+	// use its configuration to initialize the conntracker module which is also used by others.
+	sort.SliceStable(analyzerNames, func(i, j int) bool {
+		return analyzerNames[i] == network.Network.String() && analyzerNames[j] != network.Network.String()
+	})
+	analyzers := make(map[string]analyzer.Analyzer, len(analyzerNames))
+	allAnalyzers := make([]analyzer.Analyzer, 0, len(analyzerNames))
+	var cpuAnalyzer analyzer.Analyzer
+	for _, name := range analyzerNames {
+		var an analyzer.Analyzer
+		if notReloadableComponents[AnalyzersKey+"."+name] {
+			analyzerFactory := a.componentsFactory.Analyzers[name]
+			an = analyzerFactory.NewFunc(analyzerFactory.Config, a.telemetry.GetTelemetryTools(name), analyzerConsumers[name])
+			if na, ok := an.(*network.NetworkAnalyzer); ok {
+				a.controllerFactory.RegistReconfigurable(controller.NetworkModule, na)
+				a.addNetworkAnalyzerReloader(na)
+			}
+			if name == cpuanalyzer.CpuProfile.String() {
+				cpuAnalyzer = an
+			}
+			an = a.monitor.monitorAnalyzer(name, an)
+		} else {
+			an, err = a.newReloadableAnalyzer(name, analyzerConsumers[name])
+			if err != nil {
+				return err
+			}
+		}
+		analyzers[name] = an
+		allAnalyzers = append(allAnalyzers, an)
+	}
+	// The analyzers are started together, and each receiver only dispatches the events to
+	// its own analyzers.
+	a.analyzerManager, err = analyzer.NewManager(allAnalyzers...)
+	if err != nil {
+		return fmt.Errorf("error happened while creating analyzer manager: %w", err)
+	}
+	receiverNames := make([]string, 0, len(receiverAnalyzers))
+	for name := range receiverAnalyzers {
+		receiverNames = append(receiverNames, name)
+	}
+	sort.Strings(receiverNames)
+	for _, name := range receiverNames {
+		managed := make([]analyzer.Analyzer, 0, len(receiverAnalyzers[name]))
+		for _, analyzerName := range receiverAnalyzers[name] {
+			managed = append(managed, analyzers[analyzerName])
+		}
+		manager, err := analyzer.NewManager(managed...)
+		if err != nil {
+			return fmt.Errorf("error happened while creating analyzer manager: %w", err)
+		}
+		receiverFactory := a.componentsFactory.Receivers[name]
+		r := receiverFactory.NewFunc(receiverFactory.Config, a.telemetry.GetTelemetryTools(name), manager)
+		a.receivers = append(a.receivers, r)
+		a.monitor.addReceiver(name, r)
+	}
+	a.registerProfileModule(cpuAnalyzer)
+	return nil
+}
+
+// newPipelineProcessor creates the processor of a pipeline. The profile scheduler also
+// watches the DataGroups passed to aggregateprocessor, as it does in the default pipeline.
+func (a *Application) newPipelineProcessor(name string, next consumer.Consumer) (consumer.Consumer, error) {
+	var p consumer.Consumer
+	if notReloadableComponents[ProcessorsKey+"."+name] {
+		processorFactory := a.componentsFactory.Processors[name]
+		p = a.monitor.monitorConsumer(processorKind, name,
+			processorFactory.NewFunc(processorFactory.Config, a.telemetry.GetTelemetryTools(name), next))
+	} else {
+		handle, err := a.newReloadableProcessor(name, next)
+		if err != nil {
+			return nil, err
+		}
+		p = handle
+	}
+	if scheduler := a.controllerFactory.Scheduler; name == aggregateprocessor.Type && scheduler != nil && scheduler.HasTriggers() {
+		p = newFanoutConsumer(scheduler, p)
+	}
+	return p, nil
+}
+
+// registerProfileModule registers the APIs of the profiling to the controller if cpuanalyzer is used.
+func (a *Application) registerProfileModule(an analyzer.Analyzer) {
+	cpuAnalyzer, ok := an.(*cpuanalyzer.CpuAnalyzer)
+	if !ok {
+		return
+	}
+	subModules := []controller.ExportSubModule{cpuAnalyzer.ProfileModule}
+	for _, r := range a.receivers {
+		if cgoReceiver, ok := r.(*cgoreceiver.CgoReceiver); ok {
+			subModules = append(subModules, cgoReceiver.ProfileModule)
+		}
+	}
+	a.controllerFactory.RegistModule(controller.ProfileModule, subModules...)
+	a.controllerFactory.RegistSessionModule(controller.ProfileModule, cpuAnalyzer)
+	a.controllerFactory.RegistHandler(cpuanalyzer.FlameGraphPath, cpuAnalyzer.FlameGraphHandler())
+	a.controllerFactory.RegistHandler(cpuanalyzer.LockContentionPath, cpuAnalyzer.LockContentionHandler())
+}
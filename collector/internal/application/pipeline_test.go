@@ -0,0 +1,221 @@
+package application
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component"
+	"github.com/Kindling-project/kindling/collector/pkg/component/analyzer"
+	"github.com/Kindling-project/kindling/collector/pkg/component/consumer"
+	"github.com/Kindling-project/kindling/collector/pkg/component/consumer/exporter"
+	"github.com/Kindling-project/kindling/collector/pkg/component/consumer/exporter/tools/batcher"
+	"github.com/Kindling-project/kindling/collector/pkg/component/consumer/processor"
+	"github.com/Kindling-project/kindling/collector/pkg/component/controller"
+	"github.com/Kindling-project/kindling/collector/pkg/component/receiver"
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+)
+
+type fakeReceiver struct {
+	manager *analyzer.Manager
+}
+
+func (r *fakeReceiver) Start() error    { return nil }
+func (r *fakeReceiver) Shutdown() error { return nil }
+
+type fakeAnalyzer struct {
+	name      string
+	consumers []consumer.Consumer
+}
+
+func (a *fakeAnalyzer) Start() error                            { return nil }
+func (a *fakeAnalyzer) Shutdown() error                         { return nil }
+func (a *fakeAnalyzer) Type() analyzer.Type                     { return analyzer.Type(a.name) }
+func (a *fakeAnalyzer) ConsumableEvents() []string              { return []string{a.name} }
+func (a *fakeAnalyzer) ConsumeEvent(*model.KindlingEvent) error { return nil }
+
+func (a *fakeAnalyzer) send(name string) {
+	for _, c := range a.consumers {
+		_ = c.Consume(&model.DataGroup{Name: name})
+	}
+}
+
+type recordingProcessor struct {
+	name    string
+	records *[]string
+	next    consumer.Consumer
+}
+
+func (p *recordingProcessor) Consume(dataGroup *model.DataGroup) error {
+	*p.records = append(*p.records, p.name+":"+dataGroup.Name)
+	return p.next.Consume(dataGroup)
+}
+
+type recordingExporter struct {
+	recordingProcessor
+}
+
+func (e *recordingExporter) Consume(dataGroup *model.DataGroup) error {
+	*e.records = append(*e.records, e.name+":"+dataGroup.Name)
+	return nil
+}
+
+type fakeComponents struct {
+	receivers map[string]*fakeReceiver
+	analyzers map[string]*fakeAnalyzer
+	records   []string
+}
+
+func (c *fakeComponents) register(factory *ComponentsFactory) {
+	for _, name := range []string{"r1", "r2"} {
+		name := name
+		factory.RegisterReceiver(name, func(cfg interface{}, telemetry *component.TelemetryTools, manager *analyzer.Manager) receiver.Receiver {
+			c.receivers[name] = &fakeReceiver{manager: manager}
+			return c.receivers[name]
+		}, &struct{}{})
+	}
+	for _, name := range []string{"a1", "a2"} {
+		name := name
+		factory.RegisterAnalyzer(name, func(cfg interface{}, telemetry *component.TelemetryTools, consumers []consumer.Consumer) analyzer.Analyzer {
+			c.analyzers[name] = &fakeAnalyzer{name: name, consumers: consumers}
+			return c.analyzers[name]
+		}, &struct{}{})
+	}
+	for _, name := range []string{"p1", "p2"} {
+		name := name
+		factory.RegisterProcessor(name, func(cfg interface{}, telemetry *component.TelemetryTools, next consumer.Consumer) processor.Processor {
+			return &recordingProcessor{name: name, records: &c.records, next: next}
+		}, &struct{}{})
+	}
+	for _, name := range []string{"e1", "e2"} {
+		name := name
+		factory.RegisterExporter(name, func(cfg interface{}, telemetry *component.TelemetryTools) exporter.Exporter {
+			return &recordingExporter{recordingProcessor{name: name, records: &c.records}}
+		}, &struct{}{})
+	}
+}
+
+func newPipelineTestApplication(t *testing.T, config string) (*Application, *fakeComponents, error) {
+	components := &fakeComponents{receivers: map[string]*fakeReceiver{}, analyzers: map[string]*fakeAnalyzer{}}
+	v := viper.New()
+	v.SetConfigType("yaml")
+	require.NoError(t, v.ReadConfig(strings.NewReader(config)))
+	app := &Application{
+		viper:             v,
+		componentsFactory: NewComponentsFactory(),
+		telemetry:         component.NewTelemetryManager(),
+		controllerFactory: &controller.ControllerFactory{},
+		serviceConfig:     &ServiceConfig{},
+		monitor:           newPipelineMonitor(true),
+		batchLimiter:      batcher.NewMemoryLimiter(batcher.NewDefaultLimiterConfig()),
+	}
+	components.register(app.componentsFactory)
+	require.NoError(t, v.UnmarshalKey(ServiceKey, app.serviceConfig, mapStructureDecoderConfigFunc))
+	app.reloader = newConfigReloader("", components.register, v, app.componentsFactory, component.NewDefaultTelemetryTools())
+	return app, components, app.buildPipeline()
+}
+
+func TestBuildConfiguredPipelines(t *testing.T) {
+	app, components, err := newPipelineTestApplication(t, `
+service:
+  pipelines:
+    first:
+      receiver: r1
+      analyzers: [a1, a2]
+      processors: [p1, p2]
+      exporters: [e1, e2]
+      pipelines: [filtered]
+    filtered:
+      data_groups: [kept]
+      processors: [p2]
+      exporters: [e2]
+    second:
+      receiver: r2
+      analyzers: [a2]
+      exporters: [e1]
+`)
+	require.EqualError(t, err, "invalid pipelines: pipeline second: analyzer a2 is used with receivers r1 and r2")
+
+	app, components, err = newPipelineTestApplication(t, `
+service:
+  pipelines:
+    first:
+      receiver: r1
+      analyzers: [a1]
+      processors: [p1, p2]
+      exporters: [e1, e2]
+      pipelines: [filtered]
+    filtered:
+      data_groups: [kept]
+      processors: [p2]
+      exporters: [e2]
+    second:
+      receiver: r2
+      analyzers: [a2]
+      exporters: [e1]
+`)
+	require.NoError(t, err)
+	assert.Len(t, app.receivers, 2)
+	assert.Len(t, components.receivers["r1"].manager.GetConsumableAnalyzers("a1"), 1)
+	assert.Empty(t, components.receivers["r1"].manager.GetConsumableAnalyzers("a2"))
+
+	components.analyzers["a1"].send("kept")
+	assert.Equal(t, []string{"p1:kept", "p2:kept", "e1:kept", "e2:kept", "p2:kept", "e2:kept"}, components.records)
+	components.records = components.records[:0]
+	components.analyzers["a1"].send("dropped")
+	assert.Equal(t, []string{"p1:dropped", "p2:dropped", "e1:dropped", "e2:dropped"}, components.records)
+	components.records = components.records[:0]
+	components.analyzers["a2"].send("other")
+	assert.Equal(t, []string{"e1:other"}, components.records)
+}
+
+func TestValidatePipelines(t *testing.T) {
+	components := &fakeComponents{}
+	factory := NewComponentsFactory()
+	components.register(factory)
+
+	order, err := validatePipelines(&ServiceConfig{Pipelines: map[string]*PipelineConfig{
+		"a": {Receiver: "r1", Analyzers: []string{"a1"}, Pipelines: []string{"b", "c"}},
+		"b": {Exporters: []string{"e1"}, Pipelines: []string{"c"}},
+		"c": {Exporters: []string{"e2"}},
+	}}, factory)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"c", "b", "a"}, order)
+
+	for expected, pipelines := range map[string]map[string]*PipelineConfig{
+		"no pipelines are configured": {},
+		"pipeline a: unknown receiver r3": {
+			"a": {Receiver: "r3", Analyzers: []string{"a1"}, Exporters: []string{"e1"}},
+		},
+		"pipeline a: the receiver requires at least one analyzer": {
+			"a": {Receiver: "r1", Exporters: []string{"e1"}},
+		},
+		"pipeline a: unknown processor p3": {
+			"a": {Receiver: "r1", Analyzers: []string{"a1"}, Processors: []string{"p3"}, Exporters: []string{"e1"}},
+		},
+		"pipeline a: unknown exporter e3": {
+			"a": {Receiver: "r1", Analyzers: []string{"a1"}, Exporters: []string{"e3"}},
+		},
+		"pipeline a: at least one exporter or pipeline is required": {
+			"a": {Receiver: "r1", Analyzers: []string{"a1"}},
+		},
+		"pipeline a: unknown pipeline b": {
+			"a": {Receiver: "r1", Analyzers: []string{"a1"}, Pipelines: []string{"b"}},
+		},
+		"pipeline b has neither a receiver nor a pipeline feeding it": {
+			"a": {Receiver: "r1", Analyzers: []string{"a1"}, Exporters: []string{"e1"}},
+			"b": {Exporters: []string{"e1"}},
+		},
+		"pipelines form a cycle: a -> b -> c -> b": {
+			"a": {Receiver: "r1", Analyzers: []string{"a1"}, Pipelines: []string{"b"}},
+			"b": {Pipelines: []string{"c"}},
+			"c": {Pipelines: []string{"b"}},
+		},
+	} {
+		_, err := validatePipelines(&ServiceConfig{Pipelines: pipelines}, factory)
+		assert.EqualError(t, err, expected)
+	}
+}
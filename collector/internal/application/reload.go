@@ -0,0 +1,528 @@
+package application
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"reflect"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/spf13/viper"
+	"go.uber.org/atomic"
+	"go.uber.org/zap"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component"
+	"github.com/Kindling-project/kindling/collector/pkg/component/analyzer"
+	"github.com/Kindling-project/kindling/collector/pkg/component/consumer"
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+)
+
+// ConfigReloadKey is the key of the configuration section of the hot reload.
+const ConfigReloadKey = "config_reload"
+
+// ConfigReloadConfig enables reloading the configuration file at runtime.
+type ConfigReloadConfig struct {
+	// Enable reloads the configuration file when SIGHUP is received.
+	Enable bool `mapstructure:"enable"`
+	// CheckInterval is the interval in seconds to check whether the file is changed.
+	// The file is only reloaded on SIGHUP if it is not positive.
+	CheckInterval int `mapstructure:"check_interval"`
+}
+
+// ReloadStatus is the status of the configuration in effect and the last reload.
+type ReloadStatus struct {
+	// ConfigHash is the SHA-256 of the configuration file in effect.
+	ConfigHash     string    `json:"config_hash"`
+	LastReloadTime time.Time `json:"last_reload_time,omitempty"`
+	// LastReloadSuccess is false if the last reload was rejected or rolled back.
+	LastReloadSuccess bool   `json:"last_reload_success"`
+	LastError         string `json:"last_error,omitempty"`
+	// Reloaded are the configuration sections applied by the last successful reload.
+	Reloaded []string `json:"reloaded,omitempty"`
+	// RestartRequired are the configuration sections changed since the start, which
+	// can't be applied without restarting.
+	RestartRequired []string `json:"restart_required,omitempty"`
+}
+
+// errRestartRequired is returned by the validation if the configuration of the component
+// can't be applied without restarting.
+var errRestartRequired = errors.New("restart required")
+
+// reloadableComponent is a component which can be rebuilt with a new configuration while
+// the others keep running.
+type reloadableComponent struct {
+	// key is the key of the configuration section, e.g. "exporters.zipkinexporter".
+	key string
+	// validate checks whether the configuration can be applied. It is optional.
+	// The component is kept as is if errRestartRequired is returned.
+	validate func(v *viper.Viper, factory *ComponentsFactory) error
+	// rebuild replaces the component in effect with the one built with the configuration.
+	// It is also used to roll back with the previous configuration.
+	rebuild func(v *viper.Viper, factory *ComponentsFactory) error
+	// discard releases what validate prepared if the component is not rebuilt because the
+	// reload fails. It is optional.
+	discard func()
+}
+
+// configReloader reloads the configuration file and rebuilds the components whose
+// configuration sections are changed.
+type configReloader struct {
+	path     string
+	register func(factory *ComponentsFactory)
+	logger   *component.TelemetryLogger
+
+	lock    sync.Mutex
+	viper   *viper.Viper
+	factory *ComponentsFactory
+	// components are rebuilt in the order they are added. Several components may share a
+	// configuration section, e.g. the processors of the same type in different pipelines.
+	components []*reloadableComponent
+	// lastAttempt is the hash of the file reloaded last time, which is not reloaded again
+	// by the periodic check even if it failed.
+	lastAttempt     string
+	restartRequired map[string]struct{}
+	// stopped is true after stop, so no components are rebuilt while they are shut down.
+	stopped bool
+
+	statusLock sync.RWMutex
+	status     ReloadStatus
+
+	stopCh chan struct{}
+}
+
+func newConfigReloader(path string, register func(factory *ComponentsFactory), v *viper.Viper,
+	factory *ComponentsFactory, tools *component.TelemetryTools) *configReloader {
+	r := &configReloader{
+		path:            path,
+		register:        register,
+		logger:          tools.Logger,
+		viper:           v,
+		factory:         factory,
+		restartRequired: make(map[string]struct{}),
+		stopCh:          make(chan struct{}),
+	}
+	newSelfMetrics(tools.MeterProvider, r)
+	return r
+}
+
+func (r *configReloader) addComponent(c *reloadableComponent) {
+	r.components = append(r.components, c)
+}
+
+// init records the hash of the configuration file read at the start.
+func (r *configReloader) init() error {
+	content, err := os.ReadFile(r.path)
+	if err != nil {
+		return err
+	}
+	hash := configHash(content)
+	r.lastAttempt = hash
+	r.statusLock.Lock()
+	r.status.ConfigHash = hash
+	r.status.LastReloadSuccess = true
+	r.statusLock.Unlock()
+	return nil
+}
+
+// currentFactory returns the factory of the configuration in effect.
+func (r *configReloader) currentFactory() *ComponentsFactory {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.factory
+}
+
+func (r *configReloader) getStatus() ReloadStatus {
+	r.statusLock.RLock()
+	defer r.statusLock.RUnlock()
+	return r.status
+}
+
+// watch reloads the configuration file on SIGHUP, or when it is changed if checkInterval is positive.
+func (r *configReloader) watch(checkInterval time.Duration) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGHUP)
+	defer signal.Stop(sigCh)
+	var checkCh <-chan time.Time
+	if checkInterval > 0 {
+		ticker := time.NewTicker(checkInterval)
+		defer ticker.Stop()
+		checkCh = ticker.C
+	}
+	for {
+		select {
+		case <-sigCh:
+			r.logger.Info("Received SIGHUP, reloading the configuration")
+			_ = r.reload(true)
+		case <-checkCh:
+			_ = r.reload(false)
+		case <-r.stopCh:
+			return
+		}
+	}
+}
+
+// stop stops watching the file and waits for the reload in progress.
+func (r *configReloader) stop() {
+	close(r.stopCh)
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.stopped = true
+}
+
+// reload applies the configuration file if it is changed. The file is validated before
+// any component is changed. If a component fails to be rebuilt, the components rebuilt
+// are rolled back with the previous configuration. The file which failed last time is
+// only tried again if force is true.
+func (r *configReloader) reload(force bool) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.stopped {
+		return nil
+	}
+	content, err := os.ReadFile(r.path)
+	if err != nil {
+		return r.fail(fmt.Errorf("failed to read the configuration file: %w", err))
+	}
+	hash := configHash(content)
+	if hash == r.getStatus().ConfigHash || (hash == r.lastAttempt && !force) {
+		return nil
+	}
+	r.lastAttempt = hash
+
+	v := viper.New()
+	v.SetConfigFile(r.path)
+	if err = v.ReadInConfig(); err != nil {
+		return r.fail(fmt.Errorf("error happened while reading config file: %w", err))
+	}
+	factory := NewComponentsFactory()
+	r.register(factory)
+	if err = factory.ConstructConfig(v); err != nil {
+		return r.fail(fmt.Errorf("error happened while constructing config: %w", err))
+	}
+
+	changed, restartRequired := r.diff(v)
+	toRebuild := make([]*reloadableComponent, 0, len(changed))
+	for _, c := range changed {
+		if c.validate != nil {
+			err = c.validate(v, factory)
+			if errors.Is(err, errRestartRequired) {
+				restartRequired = append(restartRequired, c.key)
+				continue
+			} else if err != nil {
+				discardAll(append(toRebuild, c))
+				return r.fail(fmt.Errorf("invalid configuration of %s: %w", c.key, err))
+			}
+		}
+		toRebuild = append(toRebuild, c)
+	}
+
+	reloaded := make([]string, 0, len(toRebuild))
+	for i, c := range toRebuild {
+		if err = c.rebuild(v, factory); err != nil {
+			err = fmt.Errorf("failed to rebuild %s: %w", c.key, err)
+			r.rollback(toRebuild[:i+1])
+			discardAll(toRebuild[i+1:])
+			return r.fail(err)
+		}
+		reloaded = append(reloaded, c.key)
+	}
+	r.viper = v
+	r.factory = factory
+	for _, key := range restartRequired {
+		r.restartRequired[key] = struct{}{}
+		r.logger.Warn("The configuration is changed but can't be applied without restarting",
+			zap.String("section", key))
+	}
+	r.logger.Info("The configuration is reloaded", zap.String("hash", hash), zap.Strings("reloaded", reloaded))
+
+	r.statusLock.Lock()
+	r.status = ReloadStatus{
+		ConfigHash:        hash,
+		LastReloadTime:    time.Now(),
+		LastReloadSuccess: true,
+		Reloaded:          reloaded,
+		RestartRequired:   sortedKeys(r.restartRequired),
+	}
+	r.statusLock.Unlock()
+	recordReload(true)
+	return nil
+}
+
+// rollback rebuilds the components with the previous configuration in reverse order.
+func (r *configReloader) rollback(components []*reloadableComponent) {
+	for i := len(components) - 1; i >= 0; i-- {
+		if err := components[i].rebuild(r.viper, r.factory); err != nil {
+			r.logger.Error("Failed to roll back the component, it keeps stopped until the next reload",
+				zap.String("section", components[i].key), zap.Error(err))
+		}
+	}
+}
+
+// discardAll releases what the components prepared for the reload failed.
+func discardAll(components []*reloadableComponent) {
+	for _, c := range components {
+		if c.discard != nil {
+			c.discard()
+		}
+	}
+}
+
+func (r *configReloader) fail(err error) error {
+	r.logger.Error("Failed to reload the configuration, the previous one is kept", zap.Error(err))
+	r.statusLock.Lock()
+	r.status.LastReloadTime = time.Now()
+	r.status.LastReloadSuccess = false
+	r.status.LastError = err.Error()
+	r.statusLock.Unlock()
+	recordReload(false)
+	return err
+}
+
+// diff returns the reloadable components whose configuration sections are changed in
+// order, and the keys of the other sections changed.
+func (r *configReloader) diff(v *viper.Viper) ([]*reloadableComponent, []string) {
+	keys := make(map[string]struct{})
+	isComponentsKey := make(map[string]bool, len(ComponentsKeyMap))
+	for _, kind := range ComponentsKeyMap {
+		isComponentsKey[kind] = true
+		for _, settings := range []*viper.Viper{r.viper, v} {
+			for name := range settings.GetStringMap(kind) {
+				keys[kind+"."+name] = struct{}{}
+			}
+		}
+	}
+	for _, settings := range []*viper.Viper{r.viper, v} {
+		for key := range settings.AllSettings() {
+			if !isComponentsKey[key] {
+				keys[key] = struct{}{}
+			}
+		}
+	}
+
+	changedKeys := make(map[string]struct{})
+	for key := range keys {
+		if !reflect.DeepEqual(r.viper.Get(key), v.Get(key)) {
+			changedKeys[key] = struct{}{}
+		}
+	}
+	changed := make([]*reloadableComponent, 0)
+	for _, c := range r.components {
+		if _, ok := changedKeys[c.key]; ok {
+			changed = append(changed, c)
+		}
+	}
+	for _, c := range changed {
+		delete(changedKeys, c.key)
+	}
+	return changed, sortedKeys(changedKeys)
+}
+
+func configHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	ret := make([]string, 0, len(m))
+	for key := range m {
+		ret = append(ret, key)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// buildSafely calls build and converts the panic into an error, because the components
+// panic if they can't be created with the configuration.
+func buildSafely(build func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return build()
+}
+
+// reloadableHandle is the handle of a component whose instance is replaced when it is
+// rebuilt with a new configuration.
+type reloadableHandle interface {
+	// replace sets the instance in effect and returns the previous one.
+	replace(instance interface{}) interface{}
+	// stop removes the instance in effect and returns it. The data is dropped and counted
+	// until the next instance is set.
+	stop() interface{}
+	// droppedCount returns the total number of the data dropped while it is stopped.
+	droppedCount() int64
+}
+
+// consumerHandle passes the data to the consumer in effect, which is replaced when the
+// consumer is rebuilt with a new configuration.
+type consumerHandle struct {
+	// lock is held by Consume, so the consumer replaced is not in use when it is shut down.
+	lock     sync.RWMutex
+	consumer consumer.Consumer
+	// stopped is true after the consumer is removed by stop and before the next one is set.
+	stopped bool
+	dropped *atomic.Int64
+}
+
+func newConsumerHandle(c consumer.Consumer) *consumerHandle {
+	return &consumerHandle{consumer: c, dropped: atomic.NewInt64(0)}
+}
+
+func (h *consumerHandle) Consume(dataGroup *model.DataGroup) error {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	if h.consumer == nil {
+		if h.stopped {
+			h.dropped.Inc()
+		}
+		return nil
+	}
+	return h.consumer.Consume(dataGroup)
+}
+
+// swap replaces the consumer and returns the previous one. The data is dropped if c is nil.
+func (h *consumerHandle) swap(c consumer.Consumer) consumer.Consumer {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	old := h.consumer
+	h.consumer = c
+	h.stopped = false
+	return old
+}
+
+func (h *consumerHandle) replace(instance interface{}) interface{} {
+	c, _ := instance.(consumer.Consumer)
+	return h.swap(c)
+}
+
+func (h *consumerHandle) stop() interface{} {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	old := h.consumer
+	h.consumer = nil
+	h.stopped = true
+	return old
+}
+
+func (h *consumerHandle) droppedCount() int64 {
+	return h.dropped.Load()
+}
+
+func (h *consumerHandle) Start() error {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	return startComponent(h.consumer)
+}
+
+func (h *consumerHandle) Shutdown() error {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	return shutdownComponent(h.consumer)
+}
+
+func (h *consumerHandle) current() interface{} {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	return h.consumer
+}
+
+// analyzerHandle passes the events to the analyzer in effect, which is replaced when the
+// analyzer is rebuilt with a new configuration. The consumable events can't be changed.
+type analyzerHandle struct {
+	analyzerType     analyzer.Type
+	consumableEvents []string
+
+	lock     sync.RWMutex
+	analyzer analyzer.Analyzer
+	// stopped is true after the analyzer is removed by stop and before the next one is set.
+	stopped bool
+	dropped *atomic.Int64
+}
+
+func newAnalyzerHandle(a analyzer.Analyzer) *analyzerHandle {
+	return &analyzerHandle{
+		analyzerType:     a.Type(),
+		consumableEvents: a.ConsumableEvents(),
+		analyzer:         a,
+		dropped:          atomic.NewInt64(0),
+	}
+}
+
+func (h *analyzerHandle) Start() error {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	if h.analyzer == nil {
+		return nil
+	}
+	return h.analyzer.Start()
+}
+
+func (h *analyzerHandle) Shutdown() error {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	if h.analyzer == nil {
+		return nil
+	}
+	return h.analyzer.Shutdown()
+}
+
+func (h *analyzerHandle) Type() analyzer.Type {
+	return h.analyzerType
+}
+
+func (h *analyzerHandle) ConsumableEvents() []string {
+	return h.consumableEvents
+}
+
+func (h *analyzerHandle) ConsumeEvent(event *model.KindlingEvent) error {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	if h.analyzer == nil {
+		if h.stopped {
+			h.dropped.Inc()
+		}
+		return nil
+	}
+	return h.analyzer.ConsumeEvent(event)
+}
+
+func (h *analyzerHandle) current() interface{} {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	return h.analyzer
+}
+
+// swap replaces the analyzer and returns the previous one. The events are dropped if a is nil.
+func (h *analyzerHandle) swap(a analyzer.Analyzer) analyzer.Analyzer {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	old := h.analyzer
+	h.analyzer = a
+	h.stopped = false
+	return old
+}
+
+func (h *analyzerHandle) replace(instance interface{}) interface{} {
+	a, _ := instance.(analyzer.Analyzer)
+	return h.swap(a)
+}
+
+func (h *analyzerHandle) stop() interface{} {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	old := h.analyzer
+	h.analyzer = nil
+	h.stopped = true
+	return old
+}
+
+func (h *analyzerHandle) droppedCount() int64 {
+	return h.dropped.Load()
+}
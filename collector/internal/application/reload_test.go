@@ -0,0 +1,291 @@
+package application
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component"
+	"github.com/Kindling-project/kindling/collector/pkg/component/consumer/exporter"
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+)
+
+type fakeExporterConfig struct {
+	Value int `mapstructure:"value"`
+}
+
+type fakeExporter struct {
+	received int
+	shutdown bool
+}
+
+func (e *fakeExporter) Consume(*model.DataGroup) error {
+	e.received++
+	return nil
+}
+
+func (e *fakeExporter) Shutdown() error {
+	e.shutdown = true
+	return nil
+}
+
+func registerFakeExporters(factory *ComponentsFactory) {
+	newFunc := func(cfg interface{}, telemetry *component.TelemetryTools) exporter.Exporter {
+		return &fakeExporter{}
+	}
+	factory.RegisterExporter("fake", newFunc, &fakeExporterConfig{})
+	factory.RegisterExporter("other", newFunc, &fakeExporterConfig{})
+}
+
+func writeConfig(t *testing.T, path string, content string) {
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+}
+
+// newTestReloader creates a reloader of the exporters "fake" and "other", whose values
+// are recorded when they are rebuilt. Rebuilding fails if the value is negative.
+func newTestReloader(t *testing.T, path string) (*configReloader, *[]string) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	require.NoError(t, v.ReadInConfig())
+	factory := NewComponentsFactory()
+	registerFakeExporters(factory)
+	require.NoError(t, factory.ConstructConfig(v))
+
+	r := newConfigReloader(path, registerFakeExporters, v, factory, component.NewDefaultTelemetryTools())
+	require.NoError(t, r.init())
+	builds := make([]string, 0)
+	for _, name := range []string{"fake", "other"} {
+		name := name
+		r.addComponent(&reloadableComponent{
+			key: ExportersKey + "." + name,
+			rebuild: func(v *viper.Viper, factory *ComponentsFactory) error {
+				value := factory.Exporters[name].Config.(*fakeExporterConfig).Value
+				builds = append(builds, name+":"+v.GetString(ExportersKey+"."+name+".value"))
+				if value < 0 {
+					return errors.New("negative value")
+				}
+				return nil
+			},
+		})
+	}
+	return r, &builds
+}
+
+func TestConfigReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	writeConfig(t, path, `
+exporters:
+  fake:
+    value: 1
+  other:
+    value: 1
+controller:
+  enable: false
+`)
+	r, builds := newTestReloader(t, path)
+	initialHash := r.getStatus().ConfigHash
+	assert.NotEmpty(t, initialHash)
+
+	// Only the exporter changed is rebuilt, and the controller requires a restart.
+	writeConfig(t, path, `
+exporters:
+  fake:
+    value: 2
+  other:
+    value: 1
+controller:
+  enable: true
+`)
+	assert.NoError(t, r.reload(false))
+	assert.Equal(t, []string{"fake:2"}, *builds)
+	status := r.getStatus()
+	assert.True(t, status.LastReloadSuccess)
+	assert.NotEqual(t, initialHash, status.ConfigHash)
+	assert.Equal(t, []string{"exporters.fake"}, status.Reloaded)
+	assert.Equal(t, []string{"controller"}, status.RestartRequired)
+	// Nothing is rebuilt if the file is not changed.
+	assert.NoError(t, r.reload(true))
+	assert.Len(t, *builds, 1)
+
+	// The exporter rebuilt is rolled back if the next one fails.
+	*builds = (*builds)[:0]
+	writeConfig(t, path, `
+exporters:
+  fake:
+    value: 3
+  other:
+    value: -1
+controller:
+  enable: true
+`)
+	assert.Error(t, r.reload(false))
+	assert.Equal(t, []string{"fake:3", "other:-1", "other:1", "fake:2"}, *builds)
+	failed := r.getStatus()
+	assert.False(t, failed.LastReloadSuccess)
+	assert.Contains(t, failed.LastError, "exporters.other")
+	assert.Equal(t, status.ConfigHash, failed.ConfigHash)
+	assert.Equal(t, 2, r.viper.GetInt("exporters.fake.value"))
+
+	// The file failed is only tried again on demand.
+	*builds = (*builds)[:0]
+	assert.NoError(t, r.reload(false))
+	assert.Empty(t, *builds)
+	assert.Error(t, r.reload(true))
+	assert.Len(t, *builds, 4)
+}
+
+func TestConfigReloadInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	writeConfig(t, path, `
+exporters:
+  fake:
+    value: 1
+`)
+	r, builds := newTestReloader(t, path)
+
+	for _, content := range []string{
+		"exporters: [",
+		`
+exporters:
+  fake:
+    value: abc
+`,
+	} {
+		writeConfig(t, path, content)
+		assert.Error(t, r.reload(true))
+		assert.Empty(t, *builds)
+		assert.False(t, r.getStatus().LastReloadSuccess)
+	}
+
+	// The component which can't be changed at runtime is kept as is.
+	r.components[0].validate = func(v *viper.Viper, factory *ComponentsFactory) error {
+		return errRestartRequired
+	}
+	writeConfig(t, path, `
+exporters:
+  fake:
+    value: 2
+`)
+	assert.NoError(t, r.reload(false))
+	assert.Empty(t, *builds)
+	assert.Equal(t, []string{"exporters.fake"}, r.getStatus().RestartRequired)
+}
+
+func TestReloadableHandles(t *testing.T) {
+	first := &fakeExporter{}
+	handle := newConsumerHandle(first)
+	assert.NoError(t, handle.Consume(&model.DataGroup{}))
+	assert.Same(t, first, handle.swap(nil))
+	// The data is dropped until the new consumer is set.
+	assert.NoError(t, handle.Consume(&model.DataGroup{}))
+	second := &fakeExporter{}
+	handle.swap(second)
+	assert.NoError(t, handle.Consume(&model.DataGroup{}))
+	assert.Equal(t, 1, first.received)
+	assert.Equal(t, 1, second.received)
+
+	inner, outer := &fakeExporter{}, &fakeExporter{}
+	wrapped := &wrappedExporter{Exporter: outer, chain: []exporter.Exporter{outer, inner}}
+	assert.NoError(t, shutdownComponent(wrapped))
+	assert.True(t, inner.shutdown)
+	assert.True(t, outer.shutdown)
+}
+
+func TestRebuildBeforeSwap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	writeConfig(t, path, `
+exporters:
+  fake:
+    value: 1
+  other:
+    value: 1
+`)
+	v := viper.New()
+	v.SetConfigFile(path)
+	require.NoError(t, v.ReadInConfig())
+	factory := NewComponentsFactory()
+	registerFakeExporters(factory)
+	require.NoError(t, factory.ConstructConfig(v))
+	r := newConfigReloader(path, registerFakeExporters, v, factory, component.NewDefaultTelemetryTools())
+	require.NoError(t, r.init())
+	app := &Application{telemetry: component.NewTelemetryManager(), reloader: r}
+
+	handles := make(map[string]*consumerHandle)
+	firsts := make(map[string]*fakeExporter)
+	built := make(map[string][]*fakeExporter)
+	for _, name := range []string{"fake", "other"} {
+		name := name
+		firsts[name] = &fakeExporter{}
+		handles[name] = newConsumerHandle(firsts[name])
+		app.addReloadableComponent(ExportersKey+"."+name, handles[name], func(v *viper.Viper, factory *ComponentsFactory) (interface{}, error) {
+			if factory.Exporters[name].Config.(*fakeExporterConfig).Value < 0 {
+				return nil, errors.New("negative value")
+			}
+			// The previous exporter keeps receiving the data while the new one is built.
+			_ = handles[name].Consume(&model.DataGroup{})
+			e := &fakeExporter{}
+			built[name] = append(built[name], e)
+			return e, nil
+		})
+	}
+
+	// The exporter built for the valid section is discarded if the other one is invalid.
+	writeConfig(t, path, `
+exporters:
+  fake:
+    value: 2
+  other:
+    value: -1
+`)
+	assert.Error(t, r.reload(false))
+	require.Len(t, built["fake"], 1)
+	assert.True(t, built["fake"][0].shutdown)
+	for _, name := range []string{"fake", "other"} {
+		assert.Same(t, firsts[name], handles[name].current())
+		assert.False(t, firsts[name].shutdown)
+	}
+	assert.Equal(t, 1, firsts["fake"].received)
+
+	// The previous exporter is shut down after the new one is swapped in.
+	writeConfig(t, path, `
+exporters:
+  fake:
+    value: 2
+  other:
+    value: 1
+`)
+	assert.NoError(t, r.reload(false))
+	require.Len(t, built["fake"], 2)
+	assert.Same(t, built["fake"][1], handles["fake"].current())
+	assert.True(t, firsts["fake"].shutdown)
+	assert.Equal(t, 2, firsts["fake"].received)
+	assert.Equal(t, int64(0), handles["fake"].droppedCount())
+
+	// The exporter holding a listener is shut down first, and the data is dropped meanwhile.
+	stopFirstComponents[ExportersKey+".fake"] = true
+	defer delete(stopFirstComponents, ExportersKey+".fake")
+	listening := &fakeExporter{}
+	stopFirstHandle := newConsumerHandle(listening)
+	r.components = r.components[:0]
+	app.addReloadableComponent(ExportersKey+".fake", stopFirstHandle, func(v *viper.Viper, factory *ComponentsFactory) (interface{}, error) {
+		assert.True(t, listening.shutdown)
+		_ = stopFirstHandle.Consume(&model.DataGroup{})
+		return &fakeExporter{}, nil
+	})
+	writeConfig(t, path, `
+exporters:
+  fake:
+    value: 3
+  other:
+    value: 1
+`)
+	assert.NoError(t, r.reload(false))
+	assert.Equal(t, int64(1), stopFirstHandle.droppedCount())
+	assert.Equal(t, 0, listening.received)
+	assert.NotSame(t, listening, stopFirstHandle.current())
+}
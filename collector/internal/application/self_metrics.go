@@ -0,0 +1,78 @@
+package application
+
+import (
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	configReloadsMetric           = "kindling_telemetry_config_reloads_total"
+	configInfoMetric              = "kindling_telemetry_config_info"
+	configLastReloadSuccessMetric = "kindling_telemetry_config_last_reload_success"
+	configRestartRequiredMetric   = "kindling_telemetry_config_restart_required"
+	configReloadDroppedMetric     = "kindling_telemetry_config_reload_dropped_total"
+)
+
+var once sync.Once
+
+var (
+	handlesMu sync.RWMutex
+	// handles are the handles of the reloadable components by their configuration sections.
+	handles = make(map[string][]reloadableHandle)
+)
+
+var reloadsCounter metric.Int64Counter
+
+func newSelfMetrics(meterProvider metric.MeterProvider, reloader *configReloader) {
+	once.Do(func() {
+		meter := metric.Must(meterProvider.Meter("kindling"))
+		reloadsCounter = meter.NewInt64Counter(configReloadsMetric,
+			metric.WithDescription("The total number of the configuration reloads by result"))
+		meter.NewInt64GaugeObserver(configInfoMetric,
+			func(ctx context.Context, result metric.Int64ObserverResult) {
+				result.Observe(1, attribute.String("hash", reloader.getStatus().ConfigHash))
+			}, metric.WithDescription("The SHA-256 of the configuration file in effect, whose value is always 1"))
+		meter.NewInt64GaugeObserver(configLastReloadSuccessMetric,
+			func(ctx context.Context, result metric.Int64ObserverResult) {
+				var value int64
+				if reloader.getStatus().LastReloadSuccess {
+					value = 1
+				}
+				result.Observe(value)
+			}, metric.WithDescription("Whether the last configuration reload succeeded"))
+		meter.NewInt64GaugeObserver(configRestartRequiredMetric,
+			func(ctx context.Context, result metric.Int64ObserverResult) {
+				result.Observe(int64(len(reloader.getStatus().RestartRequired)))
+			}, metric.WithDescription("The number of the configuration sections changed which require a restart"))
+		meter.NewInt64CounterObserver(configReloadDroppedMetric,
+			func(ctx context.Context, result metric.Int64ObserverResult) {
+				handlesMu.RLock()
+				defer handlesMu.RUnlock()
+				for key, list := range handles {
+					var dropped int64
+					for _, h := range list {
+						dropped += h.droppedCount()
+					}
+					result.Observe(dropped, attribute.String("section", key))
+				}
+			}, metric.WithDescription("The total number of the data dropped while the components holding listeners are rebuilt"))
+	})
+}
+
+func recordReload(success bool) {
+	result := "success"
+	if !success {
+		result = "failure"
+	}
+	reloadsCounter.Add(context.Background(), 1, attribute.String("result", result))
+}
+
+// registerHandle reports the data dropped by the handle while its component is rebuilt.
+func registerHandle(key string, h reloadableHandle) {
+	handlesMu.Lock()
+	handles[key] = append(handles[key], h)
+	handlesMu.Unlock()
+}
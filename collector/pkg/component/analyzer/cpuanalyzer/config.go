@@ -26,6 +26,12 @@ type Config struct {
 	// JavaTraceExpirationTime is the expiration time for data in javatraces.
 	// The unit is seconds.
 	JavaTraceExpirationTime int `mapstructure:"java_trace_expiration_time"`
+	// LockContentionInterval is the interval for reporting the contended locks of the
+	// Java processes. The unit is seconds. The reporting is disabled if it is 0.
+	LockContentionInterval int `mapstructure:"lock_contention_interval"`
+	// LockContentionTopN is how many contended locks of each process are reported at most
+	// in each interval.
+	LockContentionTopN int `mapstructure:"lock_contention_top_n"`
 }
 
 func NewDefaultConfig() *Config {
@@ -37,5 +43,7 @@ func NewDefaultConfig() *Config {
 		EdgeEventsWindowSize:    2,
 		JavaTraceDeleteInterval: 20,
 		JavaTraceExpirationTime: 120,
+		LockContentionInterval:  15,
+		LockContentionTopN:      10,
 	}
 }
@@ -84,6 +84,10 @@ func (ca *CpuAnalyzer) ConsumeEvent(event *model.KindlingEvent) error {
 	if !enableProfile {
 		return nil
 	}
+	// Drop the events of the processes not selected by the profiling sessions.
+	if !sessionScope.selected(event.GetPid(), event.GetContainerId()) {
+		return nil
+	}
 	switch event.Name {
 	case constnames.CpuEvent:
 		ca.ConsumeCpuEvent(event)
@@ -0,0 +1,293 @@
+package cpuanalyzer
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constlabels"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constnames"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constvalues"
+)
+
+// lockWait is a futex wait of a Java thread parsed from JavaFutexEvent.
+//
+// The field DataVal of JavaFutexEvent is in the format of
+// "kd@startTime!endTime!tid!lockAddress!eventType!threadName!duration!waitForTid!stack!",
+// in which waitForTid is -1 if the agent doesn't know the holder of the lock.
+type lockWait struct {
+	startTime  uint64
+	endTime    uint64
+	tid        uint32
+	address    string
+	eventType  string
+	waitForTid uint32
+	stack      string
+}
+
+// lockWaitKey identifies a wait. The same event can be stored in multiple segments.
+type lockWaitKey struct {
+	tid       uint32
+	startTime uint64
+}
+
+func parseLockWait(ev *JavaFutexEvent) (*lockWait, bool) {
+	fields := strings.Split(ev.DataVal, "!")
+	if len(fields) < 8 || fields[3] == "" || ev.EndTime <= ev.StartTime {
+		return nil, false
+	}
+	wait := &lockWait{
+		startTime: ev.StartTime,
+		endTime:   ev.EndTime,
+		address:   fields[3],
+		eventType: fields[4],
+	}
+	if waitForTid, err := strconv.ParseUint(fields[7], 10, 32); err == nil {
+		wait.waitForTid = uint32(waitForTid)
+	}
+	if len(fields) > 8 {
+		wait.stack = strings.TrimSpace(fields[8])
+	}
+	return wait, true
+}
+
+// LockContention summarizes the waits of the threads of a process for the same lock at
+// the same stack.
+type LockContention struct {
+	Pid       uint32 `json:"pid"`
+	Address   string `json:"lockAddress"`
+	EventType string `json:"eventType"`
+	// Stack is the stack of the waiting threads.
+	Stack   string `json:"stack"`
+	Count   int    `json:"count"`
+	Waiters int    `json:"waiters"`
+	// TotalWait and MaxWait are in nanoseconds.
+	TotalWait uint64 `json:"totalWait"`
+	MaxWait   uint64 `json:"maxWait"`
+	// HolderTid is the thread which most likely held the lock. It is 0 if unknown.
+	HolderTid        uint32 `json:"holderTid"`
+	HolderThreadName string `json:"holderThreadName"`
+
+	waiters map[uint32]struct{}
+	// holders is the on-CPU time of the other threads during the waits.
+	holders map[uint32]uint64
+}
+
+type lockContentionKey struct {
+	address string
+	stack   string
+}
+
+// ToDataGroup converts the contention to a DataGroup named lock_contention_metric_group.
+func (c *LockContention) ToDataGroup(timestamp uint64) *model.DataGroup {
+	labels := model.NewAttributeMap()
+	labels.AddIntValue(constlabels.Pid, int64(c.Pid))
+	labels.AddStringValue(constlabels.LockAddress, c.Address)
+	labels.AddStringValue(constlabels.LockEventType, c.EventType)
+	labels.AddStringValue(constlabels.LockStack, c.Stack)
+	labels.AddIntValue(constlabels.HolderTid, int64(c.HolderTid))
+	labels.AddStringValue(constlabels.HolderThreadName, c.HolderThreadName)
+	return model.NewDataGroup(constnames.LockContentionMetricGroupName, labels, timestamp,
+		model.NewIntMetric(constvalues.LockWaitTime, int64(c.TotalWait)),
+		model.NewIntMetric(constvalues.LockMaxWaitTime, int64(c.MaxWait)),
+		model.NewIntMetric(constvalues.LockWaitCount, int64(c.Count)))
+}
+
+type onCpuInterval struct {
+	from uint64
+	to   uint64
+}
+
+// LockContentions groups the futex waits of the process ended in the time range
+// [startTime, endTime) by the lock address and the waiting stack, and returns the top
+// ones ordered by the total waiting time. All the events cached are included if endTime
+// is 0, and all the groups are returned if top is not positive.
+//
+// The holder of a lock is the thread reported by the agent, or otherwise the thread of
+// the same process that was on CPU for the longest time during the waits.
+func (ca *CpuAnalyzer) LockContentions(pid uint32, startTime uint64, endTime uint64, top int) []*LockContention {
+	if endTime == 0 {
+		endTime = ^uint64(0)
+	}
+	ca.lock.RLock()
+	defer ca.lock.RUnlock()
+	tidEvents := ca.cpuPidEvents[pid]
+	var waits []*lockWait
+	seenWaits := make(map[lockWaitKey]struct{})
+	// The on-CPU intervals are only needed during the waits, which may start before startTime.
+	onCpuStart := startTime
+	for _, timeSegments := range tidEvents {
+		ca.forEachSegment(timeSegments, startTime, endTime, func(segment *Segment) {
+			for _, event := range segment.JavaFutexEvents {
+				wait, ok := parseLockWait(event.(*JavaFutexEvent))
+				if !ok || wait.endTime < startTime || wait.endTime >= endTime {
+					continue
+				}
+				key := lockWaitKey{tid: timeSegments.Tid, startTime: wait.startTime}
+				if _, ok := seenWaits[key]; ok {
+					continue
+				}
+				seenWaits[key] = struct{}{}
+				wait.tid = timeSegments.Tid
+				waits = append(waits, wait)
+				if wait.startTime < onCpuStart {
+					onCpuStart = wait.startTime
+				}
+			}
+		})
+	}
+	if len(waits) == 0 {
+		return nil
+	}
+	onCpuIntervals := make(map[uint32][]onCpuInterval, len(tidEvents))
+	for tid, timeSegments := range tidEvents {
+		seenEvents := make(map[uint64]struct{})
+		ca.forEachSegment(timeSegments, onCpuStart, endTime, func(segment *Segment) {
+			for _, event := range segment.CpuEvents {
+				ev := event.(*CpuEvent)
+				if _, ok := seenEvents[ev.StartTime]; ok {
+					continue
+				}
+				seenEvents[ev.StartTime] = struct{}{}
+				onCpuIntervals[tid] = appendOnCpuIntervals(onCpuIntervals[tid], ev)
+			}
+		})
+	}
+
+	groups := make(map[lockContentionKey]*LockContention)
+	for _, wait := range waits {
+		key := lockContentionKey{address: wait.address, stack: wait.stack}
+		c, ok := groups[key]
+		if !ok {
+			c = &LockContention{
+				Pid:       pid,
+				Address:   wait.address,
+				EventType: wait.eventType,
+				Stack:     wait.stack,
+				waiters:   make(map[uint32]struct{}),
+				holders:   make(map[uint32]uint64),
+			}
+			groups[key] = c
+		}
+		duration := wait.endTime - wait.startTime
+		c.Count++
+		c.TotalWait += duration
+		if duration > c.MaxWait {
+			c.MaxWait = duration
+		}
+		c.waiters[wait.tid] = struct{}{}
+		if _, ok := tidEvents[wait.waitForTid]; ok && wait.waitForTid != wait.tid {
+			// The holder reported by the agent takes precedence over the guessed ones.
+			c.holders[wait.waitForTid] += duration
+			continue
+		}
+		for tid, intervals := range onCpuIntervals {
+			if tid == wait.tid {
+				continue
+			}
+			for _, interval := range intervals {
+				c.holders[tid] += overlap(interval.from, interval.to, wait.startTime, wait.endTime)
+			}
+		}
+	}
+
+	ret := make([]*LockContention, 0, len(groups))
+	for _, c := range groups {
+		c.Waiters = len(c.waiters)
+		var holderTime uint64
+		for tid, t := range c.holders {
+			if t > holderTime || (t == holderTime && t > 0 && tid < c.HolderTid) {
+				holderTime = t
+				c.HolderTid = tid
+			}
+		}
+		if c.HolderTid != 0 {
+			c.HolderThreadName = tidEvents[c.HolderTid].ThreadName
+		}
+		ret = append(ret, c)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].TotalWait != ret[j].TotalWait {
+			return ret[i].TotalWait > ret[j].TotalWait
+		}
+		if ret[i].Address != ret[j].Address {
+			return ret[i].Address < ret[j].Address
+		}
+		return ret[i].Stack < ret[j].Stack
+	})
+	if top > 0 && len(ret) > top {
+		ret = ret[:top]
+	}
+	return ret
+}
+
+// forEachSegment calls fn with the segments of the thread overlapping the time range.
+func (ca *CpuAnalyzer) forEachSegment(timeSegments *TimeSegments, startTime uint64, endTime uint64, fn func(segment *Segment)) {
+	for i := 0; i < ca.cfg.SegmentSize; i++ {
+		val := timeSegments.Segments.GetByIndex(i)
+		if val == nil {
+			continue
+		}
+		segment := val.(*Segment)
+		if segment.EndTime < startTime || segment.StartTime > endTime {
+			continue
+		}
+		fn(segment)
+	}
+}
+
+func appendOnCpuIntervals(intervals []onCpuInterval, ev *CpuEvent) []onCpuInterval {
+	intervalStart := ev.StartTime
+	for i, timeType := range ev.TimeType {
+		if i >= len(ev.TypeSpecs) {
+			break
+		}
+		intervalEnd := intervalStart + ev.TypeSpecs[i]
+		if timeType == CPUType_ON && intervalEnd > intervalStart {
+			intervals = append(intervals, onCpuInterval{from: intervalStart, to: intervalEnd})
+		}
+		intervalStart = intervalEnd
+	}
+	return intervals
+}
+
+// lockContentionDelay is how long the analyzer waits for the futex events before
+// reporting them, as the events are received after the waits end.
+const lockContentionDelay = time.Second
+
+// sendLockContentions reports the top contended locks of each process periodically.
+// Each wait is reported once in the interval in which it ends.
+func (ca *CpuAnalyzer) sendLockContentions() {
+	interval := time.Duration(ca.cfg.LockContentionInterval) * time.Second
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	lastEndTime := uint64(time.Now().Add(-lockContentionDelay).UnixNano())
+	for {
+		select {
+		case <-ticker.C:
+			endTime := uint64(time.Now().Add(-lockContentionDelay).UnixNano())
+			ca.lock.RLock()
+			pids := make([]uint32, 0, len(ca.cpuPidEvents))
+			for pid := range ca.cpuPidEvents {
+				pids = append(pids, pid)
+			}
+			ca.lock.RUnlock()
+			for _, pid := range pids {
+				for _, c := range ca.LockContentions(pid, lastEndTime, endTime, ca.cfg.LockContentionTopN) {
+					dataGroup := c.ToDataGroup(endTime)
+					for _, nexConsumer := range ca.nextConsumers {
+						_ = nexConsumer.Consume(dataGroup)
+					}
+				}
+			}
+			lastEndTime = endTime
+		case <-ca.stopProfileChan:
+			return
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package cpuanalyzer
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component"
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constlabels"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constnames"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constvalues"
+)
+
+func newLockWaitEvent(startTime uint64, endTime uint64, tid uint32, address string, waitForTid int, stack string) *JavaFutexEvent {
+	return &JavaFutexEvent{
+		StartTime: startTime,
+		EndTime:   endTime,
+		DataVal: fmt.Sprintf("kd@%d!%d!%d!%s!MonitorEnter!worker!%d!%d!%s!\n",
+			startTime, endTime, tid, address, endTime-startTime, waitForTid, stack),
+	}
+}
+
+func TestParseLockWait(t *testing.T) {
+	wait, ok := parseLockWait(newLockWaitEvent(100, 300, 12, "d0390ae8", 13, "lock.Lfoo;run.Ljava/lang/Thread;"))
+	assert.True(t, ok)
+	assert.Equal(t, "d0390ae8", wait.address)
+	assert.Equal(t, "MonitorEnter", wait.eventType)
+	assert.Equal(t, uint32(13), wait.waitForTid)
+	assert.Equal(t, "lock.Lfoo;run.Ljava/lang/Thread;", wait.stack)
+
+	// The holder is unknown.
+	wait, ok = parseLockWait(newLockWaitEvent(100, 300, 12, "d0390ae8", -1, ""))
+	assert.True(t, ok)
+	assert.Equal(t, uint32(0), wait.waitForTid)
+
+	_, ok = parseLockWait(&JavaFutexEvent{StartTime: 100, EndTime: 300, DataVal: "kd@100!300!12!"})
+	assert.False(t, ok)
+}
+
+func TestLockContentions(t *testing.T) {
+	enableProfile = true
+	defer func() { enableProfile = false }()
+	analyzer := &CpuAnalyzer{
+		cpuPidEvents: make(map[uint32]map[uint32]*TimeSegments),
+		telemetry:    component.NewDefaultTelemetryTools(),
+		cfg:          &Config{SegmentSize: 40},
+	}
+	const startTime = 1660790515e9
+	// tid 13 is on CPU in [0, 4e8] and tid 14 is on CPU in [4e8, 5e8].
+	analyzer.PutEventToSegments(100, 13, "holder", &CpuEvent{CpuEvent: model.CpuEvent{
+		StartTime: startTime,
+		EndTime:   startTime + 4e8,
+		TypeSpecs: []uint64{4e8},
+		TimeType:  []CPUType{CPUType_ON},
+	}})
+	analyzer.PutEventToSegments(100, 14, "other", &CpuEvent{CpuEvent: model.CpuEvent{
+		StartTime: startTime + 4e8,
+		EndTime:   startTime + 5e8,
+		TypeSpecs: []uint64{1e8},
+		TimeType:  []CPUType{CPUType_ON},
+	}})
+	// The holder of this wait is guessed as tid 13.
+	analyzer.PutEventToSegments(100, 12, "waiter", newLockWaitEvent(startTime+1e8, startTime+5e8, 12, "a1", -1, "s1"))
+	// The holder of this wait is reported by the agent.
+	analyzer.PutEventToSegments(100, 14, "other", newLockWaitEvent(startTime+6e8, startTime+7e8, 14, "a1", 12, "s1"))
+	// The wait crosses two segments but is counted once.
+	analyzer.PutEventToSegments(100, 12, "waiter", newLockWaitEvent(startTime+8e8, startTime+12e8, 12, "a2", -1, "s2"))
+
+	contentions := analyzer.LockContentions(100, 0, 0, 0)
+	assert.Len(t, contentions, 2)
+	c := contentions[0]
+	assert.Equal(t, "a1", c.Address)
+	assert.Equal(t, "s1", c.Stack)
+	assert.Equal(t, 2, c.Count)
+	assert.Equal(t, 2, c.Waiters)
+	assert.Equal(t, uint64(5e8), c.TotalWait)
+	assert.Equal(t, uint64(4e8), c.MaxWait)
+	assert.Equal(t, uint32(13), c.HolderTid)
+	assert.Equal(t, "holder", c.HolderThreadName)
+
+	c = contentions[1]
+	assert.Equal(t, "a2", c.Address)
+	assert.Equal(t, 1, c.Count)
+	assert.Equal(t, uint64(4e8), c.TotalWait)
+	// No other threads are on CPU during the wait.
+	assert.Equal(t, uint32(0), c.HolderTid)
+
+	// Only the top one is returned.
+	assert.Len(t, analyzer.LockContentions(100, 0, 0, 1), 1)
+	// The waits are selected by their end time.
+	contentions = analyzer.LockContentions(100, startTime+6e8, startTime+10e8, 0)
+	assert.Len(t, contentions, 1)
+	assert.Equal(t, uint32(12), contentions[0].HolderTid)
+	assert.Empty(t, analyzer.LockContentions(200, 0, 0, 0))
+
+	dataGroup := contentions[0].ToDataGroup(startTime)
+	assert.Equal(t, constnames.LockContentionMetricGroupName, dataGroup.Name)
+	assert.Equal(t, "a1", dataGroup.Labels.GetStringValue(constlabels.LockAddress))
+	assert.Equal(t, "waiter", dataGroup.Labels.GetStringValue(constlabels.HolderThreadName))
+	waitTime, _ := dataGroup.GetMetric(constvalues.LockWaitTime)
+	assert.Equal(t, int64(1e8), waitTime.GetInt().Value)
+	count, _ := dataGroup.GetMetric(constvalues.LockWaitCount)
+	assert.Equal(t, int64(1), count.GetInt().Value)
+}
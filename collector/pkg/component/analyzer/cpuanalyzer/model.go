@@ -19,7 +19,7 @@ const (
 )
 
 const (
-	CpuEventLabel           = "cpuEvents"
+	CpuEventLabel           = constlabels.CpuEvents
 	JavaFutexEventLabel     = "javaFutexEvents"
 	TransactionIdEventLabel = "transactionIds"
 	SpanLabel               = "spans"
@@ -169,43 +169,23 @@ func (s *Segment) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
-type CPUType uint8
+type CPUType = model.CPUType
 
 const (
-	CPUType_ON    CPUType = 0
-	CPUType_FILE  CPUType = 1
-	CPUType_NET   CPUType = 2
-	CPUType_FUTEX CPUType = 3
-	CPUType_IDLE  CPUType = 4
-	CPUType_OTHER CPUType = 5
-	CPUType_EPOLL CPUType = 6
-	CPUTYPE_MAX   CPUType = 7
+	CPUType_ON    = model.CPUType_ON
+	CPUType_FILE  = model.CPUType_FILE
+	CPUType_NET   = model.CPUType_NET
+	CPUType_FUTEX = model.CPUType_FUTEX
+	CPUType_IDLE  = model.CPUType_IDLE
+	CPUType_OTHER = model.CPUType_OTHER
+	CPUType_EPOLL = model.CPUType_EPOLL
+	CPUTYPE_MAX   = model.CPUTYPE_MAX
 )
 
-func (ct CPUType) MarshalJSON() ([]byte, error) {
-	return json.Marshal(uint16(ct))
-}
-
-func (ct *CPUType) UnmarshalJSON(data []byte) error {
-	var val uint16
-	err := json.Unmarshal(data, &val)
-	if err != nil {
-		return err
-	}
-	*ct = CPUType(val)
-	return nil
-}
-
+// CpuEvent embeds model.CpuEvent, which is shared with the consumers of the
+// camera_event_group, so that it is encoded in the same way.
 type CpuEvent struct {
-	StartTime   uint64    `json:"startTime"`
-	EndTime     uint64    `json:"endTime"`
-	TypeSpecs   []uint64  `json:"typeSpecs"`
-	RunqLatency []uint64  `json:"runqLatency"`
-	TimeType    []CPUType `json:"timeType"`
-	OnInfo      string    `json:"onInfo"`
-	OffInfo     string    `json:"offInfo"`
-	Log         string    `json:"log"`
-	Stack       string    `json:"stack"`
+	model.CpuEvent
 }
 
 func (c *CpuEvent) StartTimestamp() uint64 {
@@ -1,9 +1,15 @@
 package cpuanalyzer
 
 import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
 	"sync"
 	"time"
 
+	"github.com/Kindling-project/kindling/collector/pkg/flamegraph"
 	"github.com/Kindling-project/kindling/collector/pkg/model"
 )
 
@@ -18,12 +24,14 @@ func (ca *CpuAnalyzer) StartProfile() error {
 	traceChan = make(chan *model.DataGroup, 1e4)
 	isInstallApm = make(map[uint64]bool, 100000)
 	sampleMap = sync.Map{}
+	sessionScope.reset()
 	ca.stopProfileChan = make(chan struct{})
 	enableProfile = true
 	go ca.sampleSend()
 	go ca.ReadTriggerEventChan()
 	go ca.ReadTraceChan()
 	go ca.TidDelete(30*time.Second, 10*time.Second)
+	go ca.sendLockContentions()
 	return nil
 }
 
@@ -40,5 +48,129 @@ func (ca *CpuAnalyzer) StopProfile() error {
 	traceChan = make(chan *model.DataGroup, 1e4)
 	isInstallApm = make(map[uint64]bool, 100000)
 	sampleMap = sync.Map{}
+	sessionScope.reset()
 	return nil
 }
+
+// FlameGraphPath is the path of the controller API which serves the flame graphs built
+// from the CPU events cached by the analyzer.
+const FlameGraphPath = "/profile/flamegraph"
+
+// FlameGraph aggregates the on-CPU stacks of the process cached in the time range
+// [startTime, endTime]. All the threads are included if tid is 0, and all the events
+// cached are included if endTime is 0.
+func (ca *CpuAnalyzer) FlameGraph(pid uint32, tid uint32, startTime uint64, endTime uint64) *flamegraph.Graph {
+	ca.lock.RLock()
+	defer ca.lock.RUnlock()
+	fg := flamegraph.New()
+	for _, timeSegments := range ca.cpuPidEvents[pid] {
+		if tid != 0 && timeSegments.Tid != tid {
+			continue
+		}
+		for i := 0; i < ca.cfg.SegmentSize; i++ {
+			val := timeSegments.Segments.GetByIndex(i)
+			if val == nil {
+				continue
+			}
+			segment := val.(*Segment)
+			if segment.EndTime < startTime || (endTime != 0 && segment.StartTime > endTime) {
+				continue
+			}
+			for _, event := range segment.CpuEvents {
+				fg.AddCpuEvent(timeSegments.ThreadName, timeSegments.Tid, &event.(*CpuEvent).CpuEvent, startTime, endTime)
+			}
+		}
+	}
+	return fg
+}
+
+// FlameGraphHandler serves the flame graphs with the query parameters "pid", "tid",
+// "start_time", "end_time" and "format". The times are Unix timestamps in nanoseconds.
+func (ca *CpuAnalyzer) FlameGraphHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !enableProfile {
+			http.Error(w, "profiling is not started", http.StatusServiceUnavailable)
+			return
+		}
+		query := r.URL.Query()
+		format, err := flamegraph.ParseFormat(query.Get("format"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if query.Get("pid") == "" {
+			http.Error(w, "pid is required", http.StatusBadRequest)
+			return
+		}
+		var params [4]uint64
+		for i, key := range []string{"pid", "tid", "start_time", "end_time"} {
+			if params[i], err = parseUintParam(query, key); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		fg := ca.FlameGraph(uint32(params[0]), uint32(params[1]), params[2], params[3])
+		if fg.Empty() {
+			http.Error(w, "no on-CPU stacks found", http.StatusNotFound)
+			return
+		}
+		if err := flamegraph.Serve(w, fg, format, fmt.Sprintf("kindling-%d", params[0])); err != nil {
+			ca.telemetry.Logger.Warnf("Failed to write the flame graph: %v", err)
+		}
+	})
+}
+
+// LockContentionPath is the path of the controller API which serves the contended locks
+// of the Java processes.
+const LockContentionPath = "/profile/locks"
+
+// defaultLockContentionTop is how many contended locks are returned by default.
+const defaultLockContentionTop = 10
+
+// LockContentionHandler serves the contended locks in JSON with the query parameters
+// "pid", "start_time", "end_time" and "top". The times are Unix timestamps in nanoseconds.
+func (ca *CpuAnalyzer) LockContentionHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !enableProfile {
+			http.Error(w, "profiling is not started", http.StatusServiceUnavailable)
+			return
+		}
+		query := r.URL.Query()
+		if query.Get("pid") == "" {
+			http.Error(w, "pid is required", http.StatusBadRequest)
+			return
+		}
+		var params [4]uint64
+		var err error
+		for i, key := range []string{"pid", "start_time", "end_time", "top"} {
+			if params[i], err = parseUintParam(query, key); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		top := int(params[3])
+		if top == 0 {
+			top = defaultLockContentionTop
+		}
+		contentions := ca.LockContentions(uint32(params[0]), params[1], params[2], top)
+		if contentions == nil {
+			contentions = make([]*LockContention, 0)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(contentions); err != nil {
+			ca.telemetry.Logger.Warnf("Failed to write the lock contentions: %v", err)
+		}
+	})
+}
+
+func parseUintParam(query url.Values, key string) (uint64, error) {
+	value := query.Get(key)
+	if value == "" {
+		return 0, nil
+	}
+	ret, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %s", key, value)
+	}
+	return ret, nil
+}
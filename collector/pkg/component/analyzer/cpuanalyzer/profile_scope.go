@@ -0,0 +1,234 @@
+package cpuanalyzer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/Kindling-project/kindling/collector/pkg/metadata/kubernetes"
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constlabels"
+)
+
+// ProfileSelector limits a profiling session to the selected processes. A process is
+// selected if it matches any of the criteria. The containers are resolved to the pods
+// through K8sMetaDataCache.
+type ProfileSelector struct {
+	Pids         []uint32 `json:"pids"`
+	ContainerIds []string `json:"containerIds"`
+	Pods         []PodRef `json:"pods"`
+	// Namespace selects all the pods in the namespace if LabelSelector is empty, or
+	// limits LabelSelector to the namespace otherwise.
+	Namespace string `json:"namespace"`
+	// LabelSelector selects the pods by their labels in the format of Kubernetes label
+	// selectors, e.g. "app=business,tier in (web, api)".
+	LabelSelector string `json:"labelSelector"`
+}
+
+type PodRef struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// processSelector is the compiled ProfileSelector. A nil processSelector selects all.
+type processSelector struct {
+	pids          map[uint32]struct{}
+	containerIds  map[string]struct{}
+	pods          map[PodRef]struct{}
+	namespace     string
+	labelSelector labels.Selector
+}
+
+func parseProfileSelector(raw json.RawMessage) (*processSelector, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var selector ProfileSelector
+	if err := json.Unmarshal(raw, &selector); err != nil {
+		return nil, fmt.Errorf("invalid selector: %w", err)
+	}
+	s := &processSelector{
+		pids:         make(map[uint32]struct{}, len(selector.Pids)),
+		containerIds: make(map[string]struct{}, len(selector.ContainerIds)),
+		pods:         make(map[PodRef]struct{}, len(selector.Pods)),
+		namespace:    selector.Namespace,
+	}
+	for _, pid := range selector.Pids {
+		s.pids[pid] = struct{}{}
+	}
+	for _, containerId := range selector.ContainerIds {
+		if containerId = shortContainerId(containerId); containerId != "" {
+			s.containerIds[containerId] = struct{}{}
+		}
+	}
+	for _, pod := range selector.Pods {
+		s.pods[pod] = struct{}{}
+	}
+	if selector.LabelSelector != "" {
+		var err error
+		if s.labelSelector, err = labels.Parse(selector.LabelSelector); err != nil {
+			return nil, fmt.Errorf("invalid label selector: %w", err)
+		}
+	}
+	if len(s.pids) == 0 && len(s.containerIds) == 0 && len(s.pods) == 0 && s.namespace == "" && s.labelSelector == nil {
+		return nil, errors.New("the selector selects nothing")
+	}
+	return s, nil
+}
+
+// shortContainerId converts the container id to the 12-byte one used by the events and
+// K8sMetaDataCache. The id can be prefixed with the runtime, e.g. "docker://".
+func shortContainerId(containerId string) string {
+	if strings.Contains(containerId, "://") {
+		return kubernetes.TruncateContainerId(containerId)
+	}
+	if len(containerId) > 12 {
+		return containerId[:12]
+	}
+	return containerId
+}
+
+func (s *processSelector) matches(pid uint32, containerId string, metadata *kubernetes.K8sMetaDataCache) bool {
+	if s == nil {
+		return true
+	}
+	if _, ok := s.pids[pid]; ok {
+		return true
+	}
+	if containerId == "" {
+		return false
+	}
+	if _, ok := s.containerIds[containerId]; ok {
+		return true
+	}
+	if len(s.pods) == 0 && s.namespace == "" && s.labelSelector == nil {
+		return false
+	}
+	if metadata == nil {
+		return false
+	}
+	pod, ok := metadata.GetPodByContainerId(containerId)
+	if !ok || pod == nil {
+		return false
+	}
+	if _, ok := s.pods[PodRef{Namespace: pod.Namespace, Name: pod.PodName}]; ok {
+		return true
+	}
+	if s.namespace != "" && s.namespace != pod.Namespace {
+		return false
+	}
+	if s.labelSelector == nil {
+		return s.namespace != ""
+	}
+	return s.labelSelector.Matches(labels.Set(pod.Labels))
+}
+
+// scopeCacheExpiration is how long the decision for a process is cached. The pods may
+// be added to K8sMetaDataCache after the process is seen first.
+const scopeCacheExpiration = 10 * time.Second
+
+type scopeDecision struct {
+	selected bool
+	time     time.Time
+}
+
+// profileScope is the union of the selectors of the running sessions. All the processes
+// are selected if there are no sessions.
+type profileScope struct {
+	lock      sync.RWMutex
+	sessions  map[string]*processSelector
+	decisions map[uint32]scopeDecision
+	metadata  *kubernetes.K8sMetaDataCache
+}
+
+func newProfileScope(metadata *kubernetes.K8sMetaDataCache) *profileScope {
+	return &profileScope{
+		sessions:  make(map[string]*processSelector),
+		decisions: make(map[uint32]scopeDecision),
+		metadata:  metadata,
+	}
+}
+
+func (s *profileScope) addSession(id string, selector *processSelector) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.sessions[id] = selector
+	s.decisions = make(map[uint32]scopeDecision)
+}
+
+func (s *profileScope) removeSession(id string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.sessions[id]; !ok {
+		return false
+	}
+	delete(s.sessions, id)
+	s.decisions = make(map[uint32]scopeDecision)
+	return true
+}
+
+func (s *profileScope) reset() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.sessions = make(map[string]*processSelector)
+	s.decisions = make(map[uint32]scopeDecision)
+}
+
+// selected returns true if the process is selected by any session.
+func (s *profileScope) selected(pid uint32, containerId string) bool {
+	now := time.Now()
+	s.lock.RLock()
+	if len(s.sessions) == 0 {
+		s.lock.RUnlock()
+		return true
+	}
+	decision, ok := s.decisions[pid]
+	s.lock.RUnlock()
+	if ok && now.Sub(decision.time) < scopeCacheExpiration {
+		return decision.selected
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	selected := false
+	for _, selector := range s.sessions {
+		if selector.matches(pid, containerId, s.metadata) {
+			selected = true
+			break
+		}
+	}
+	s.decisions[pid] = scopeDecision{selected: selected, time: now}
+	return selected
+}
+
+// selectedDataGroup returns true if the process of the trace is selected.
+func (s *profileScope) selectedDataGroup(data *model.DataGroup) bool {
+	return s.selected(uint32(data.Labels.GetIntValue(constlabels.Pid)), data.Labels.GetStringValue(constlabels.ContainerId))
+}
+
+// StartSession limits the profiling to the processes selected by the session, in addition
+// to the ones of the other sessions. All the processes are profiled if the selector is
+// empty, which is also the case when no sessions are started.
+func (ca *CpuAnalyzer) StartSession(id string, selector json.RawMessage) error {
+	s, err := parseProfileSelector(selector)
+	if err != nil {
+		return err
+	}
+	sessionScope.addSession(id, s)
+	ca.telemetry.Logger.Infof("Profiling session %s is started with selector: %s", id, string(selector))
+	return nil
+}
+
+// StopSession removes the session from the scope.
+func (ca *CpuAnalyzer) StopSession(id string) error {
+	if !sessionScope.removeSession(id) {
+		return fmt.Errorf("profiling session %s not found", id)
+	}
+	ca.telemetry.Logger.Infof("Profiling session %s is stopped", id)
+	return nil
+}
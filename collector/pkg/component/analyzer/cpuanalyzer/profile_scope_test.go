@@ -0,0 +1,59 @@
+package cpuanalyzer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Kindling-project/kindling/collector/pkg/metadata/kubernetes"
+)
+
+func TestProfileScope(t *testing.T) {
+	metadata := kubernetes.New()
+	metadata.AddByContainerId("aaaaaaaaaaaa", &kubernetes.K8sContainerInfo{
+		RefPodInfo: &kubernetes.K8sPodInfo{PodName: "business-1", Namespace: "default", Labels: map[string]string{"app": "business"}},
+	})
+	metadata.AddByContainerId("bbbbbbbbbbbb", &kubernetes.K8sContainerInfo{
+		RefPodInfo: &kubernetes.K8sPodInfo{PodName: "mysql-1", Namespace: "db", Labels: map[string]string{"app": "mysql"}},
+	})
+	scope := newProfileScope(metadata)
+	// All the processes are selected without sessions.
+	assert.True(t, scope.selected(1, "cccccccccccc"))
+
+	byPid, err := parseProfileSelector([]byte(`{"pids": [1]}`))
+	assert.NoError(t, err)
+	scope.addSession("pid", byPid)
+	assert.True(t, scope.selected(1, ""))
+	assert.False(t, scope.selected(2, "aaaaaaaaaaaa"))
+
+	byLabel, err := parseProfileSelector([]byte(`{"namespace": "default", "labelSelector": "app in (business, web)"}`))
+	assert.NoError(t, err)
+	scope.addSession("label", byLabel)
+	assert.True(t, scope.selected(2, "aaaaaaaaaaaa"))
+	assert.False(t, scope.selected(3, "bbbbbbbbbbbb"))
+
+	byContainer, err := parseProfileSelector([]byte(`{"containerIds": ["docker://bbbbbbbbbbbbbbbbbbbb"]}`))
+	assert.NoError(t, err)
+	scope.addSession("container", byContainer)
+	assert.True(t, scope.selected(3, "bbbbbbbbbbbb"))
+
+	assert.True(t, scope.removeSession("container"))
+	assert.False(t, scope.removeSession("container"))
+	assert.False(t, scope.selected(3, "bbbbbbbbbbbb"))
+
+	byPod, err := parseProfileSelector([]byte(`{"pods": [{"namespace": "db", "name": "mysql-1"}]}`))
+	assert.NoError(t, err)
+	scope.addSession("pod", byPod)
+	assert.True(t, scope.selected(3, "bbbbbbbbbbbb"))
+
+	scope.reset()
+	assert.True(t, scope.selected(4, ""))
+
+	_, err = parseProfileSelector([]byte(`{}`))
+	assert.Error(t, err)
+	_, err = parseProfileSelector([]byte(`{"labelSelector": "app in business"}`))
+	assert.Error(t, err)
+	all, err := parseProfileSelector(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, all)
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/Kindling-project/kindling/collector/pkg/filepathhelper"
+	"github.com/Kindling-project/kindling/collector/pkg/metadata/kubernetes"
 	"github.com/Kindling-project/kindling/collector/pkg/model"
 	"github.com/Kindling-project/kindling/collector/pkg/model/constlabels"
 	"github.com/Kindling-project/kindling/collector/pkg/model/constvalues"
@@ -18,12 +19,14 @@ var (
 	traceChan        chan *model.DataGroup
 	sampleMap        sync.Map
 	isInstallApm     map[uint64]bool
+	// sessionScope limits the profiling to the processes selected by the sessions.
+	sessionScope = newProfileScope(kubernetes.MetaDataCache)
 )
 
 // ReceiveDataGroupAsSignal receives model.DataGroup as a signal.
 // Signal is used to trigger to send CPU on/off events
 func ReceiveDataGroupAsSignal(data *model.DataGroup) {
-	if !enableProfile {
+	if !enableProfile || !sessionScope.selectedDataGroup(data) {
 		return
 	}
 	isFromApm := data.Labels.GetBoolValue("isInstallApm")
@@ -86,9 +89,14 @@ func (ca *CpuAnalyzer) ReadTriggerEventChan() {
 			if !sendContent.OriginalData.Labels.GetBoolValue(constlabels.IsSlow) {
 				continue
 			}
+			// Attach the time breakdown of the request before storing the trace.
+			breakdown := ca.attachTimeBreakdown(sendContent.OriginalData, sendContent.StartTime, sendContent.SpendTime)
 			// Store the traces first
 			for _, nexConsumer := range ca.nextConsumers {
 				_ = nexConsumer.Consume(sendContent.OriginalData)
+				if breakdown != nil {
+					_ = nexConsumer.Consume(breakdown)
+				}
 			}
 			// Copy the value and then get its pointer to create a new task
 			triggerEvent := sendContent
@@ -0,0 +1,136 @@
+package cpuanalyzer
+
+import (
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constlabels"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constnames"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constvalues"
+)
+
+// timeBreakdownMetrics are the names of the metrics of each CPUType.
+var timeBreakdownMetrics = [CPUTYPE_MAX]string{
+	CPUType_ON:    constvalues.OnCpuTime,
+	CPUType_FILE:  constvalues.FileTime,
+	CPUType_NET:   constvalues.NetTime,
+	CPUType_FUTEX: constvalues.FutexTime,
+	CPUType_IDLE:  constvalues.IdleTime,
+	CPUType_OTHER: constvalues.OtherTime,
+	CPUType_EPOLL: constvalues.EpollTime,
+}
+
+// TimeBreakdown is the time a thread spent on-CPU and in each kind of off-CPU state
+// during a request.
+type TimeBreakdown struct {
+	// Durations are indexed by CPUType. The unit is nanoseconds.
+	Durations [CPUTYPE_MAX]uint64
+	// Runq is the time waiting in the run queue after being woken up. It is part of
+	// the off-CPU time. The unit is nanoseconds.
+	Runq uint64
+
+	seen map[uint64]struct{}
+}
+
+func newTimeBreakdown() *TimeBreakdown {
+	return &TimeBreakdown{seen: make(map[uint64]struct{})}
+}
+
+// addCpuEvent adds the intervals of the event in the time range [startTime, endTime].
+// The same event stored in multiple segments is only added once.
+func (b *TimeBreakdown) addCpuEvent(ev *CpuEvent, startTime uint64, endTime uint64) {
+	if _, ok := b.seen[ev.StartTime]; ok {
+		return
+	}
+	b.seen[ev.StartTime] = struct{}{}
+	offIndex := 0
+	intervalStart := ev.StartTime
+	for i, timeType := range ev.TimeType {
+		if i >= len(ev.TypeSpecs) {
+			break
+		}
+		intervalEnd := intervalStart + ev.TypeSpecs[i]
+		from, to := intervalStart, intervalEnd
+		intervalStart = intervalEnd
+		if timeType != CPUType_ON {
+			// The thread waits in the run queue at the end of the off-CPU interval.
+			if offIndex < len(ev.RunqLatency) && ev.RunqLatency[offIndex] <= to-from {
+				b.Runq += overlap(to-ev.RunqLatency[offIndex], to, startTime, endTime)
+			}
+			offIndex++
+		}
+		if timeType < CPUTYPE_MAX {
+			b.Durations[timeType] += overlap(from, to, startTime, endTime)
+		}
+	}
+}
+
+// overlap returns the length of the intersection of [from, to] and [startTime, endTime].
+func overlap(from uint64, to uint64, startTime uint64, endTime uint64) uint64 {
+	if from < startTime {
+		from = startTime
+	}
+	if to > endTime {
+		to = endTime
+	}
+	if from >= to {
+		return 0
+	}
+	return to - from
+}
+
+// Metrics returns the durations as the int metrics.
+func (b *TimeBreakdown) Metrics() []*model.Metric {
+	metrics := make([]*model.Metric, 0, len(timeBreakdownMetrics)+1)
+	for cpuType, name := range timeBreakdownMetrics {
+		metrics = append(metrics, model.NewIntMetric(name, int64(b.Durations[cpuType])))
+	}
+	return append(metrics, model.NewIntMetric(constvalues.RunqTime, int64(b.Runq)))
+}
+
+// timeBreakdown sums the intervals of the thread in the time range. It returns false
+// if no CPU events of the thread cover the time range.
+func (ca *CpuAnalyzer) timeBreakdown(pid uint32, tid uint32, startTime uint64, endTime uint64) (*TimeBreakdown, bool) {
+	ca.lock.RLock()
+	defer ca.lock.RUnlock()
+	timeSegments, ok := ca.cpuPidEvents[pid][tid]
+	if !ok {
+		return nil, false
+	}
+	b := newTimeBreakdown()
+	for i := 0; i < ca.cfg.SegmentSize; i++ {
+		val := timeSegments.Segments.GetByIndex(i)
+		if val == nil {
+			continue
+		}
+		segment := val.(*Segment)
+		if segment.EndTime < startTime || segment.StartTime > endTime {
+			continue
+		}
+		for _, event := range segment.CpuEvents {
+			b.addCpuEvent(event.(*CpuEvent), startTime, endTime)
+		}
+	}
+	var total uint64
+	for _, d := range b.Durations {
+		total += d
+	}
+	return b, total > 0
+}
+
+// attachTimeBreakdown adds the time breakdown of the thread handling the request to the
+// trace, and returns a DataGroup containing the same metrics so it can be aggregated.
+// It returns nil if the thread is unknown or has no CPU events during the request.
+func (ca *CpuAnalyzer) attachTimeBreakdown(trace *model.DataGroup, startTime uint64, spendTime uint64) *model.DataGroup {
+	tid := trace.Labels.GetIntValue(constlabels.RequestTid)
+	if tid == 0 {
+		return nil
+	}
+	pid := trace.Labels.GetIntValue(constlabels.Pid)
+	b, ok := ca.timeBreakdown(uint32(pid), uint32(tid), startTime, startTime+spendTime)
+	if !ok {
+		return nil
+	}
+	for _, metric := range b.Metrics() {
+		trace.UpdateAddIntMetric(metric.Name, metric.GetInt().Value)
+	}
+	return model.NewDataGroup(constnames.RequestTimeBreakdownMetricGroupName, trace.Labels.Clone(), trace.Timestamp, b.Metrics()...)
+}
@@ -0,0 +1,80 @@
+package cpuanalyzer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component"
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constlabels"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constnames"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constvalues"
+)
+
+func TestTimeBreakdown(t *testing.T) {
+	b := newTimeBreakdown()
+	// on 100ns, futex 60ns with 10ns in the run queue, on 40ns, net 50ns with 20ns in the run queue.
+	ev := &CpuEvent{CpuEvent: model.CpuEvent{
+		StartTime:   1000,
+		TypeSpecs:   []uint64{100, 60, 40, 50},
+		TimeType:    []CPUType{CPUType_ON, CPUType_FUTEX, CPUType_ON, CPUType_NET},
+		RunqLatency: []uint64{10, 20},
+	}}
+	// Only [1050, 1230] is counted.
+	b.addCpuEvent(ev, 1050, 1230)
+	// The duplicated event is ignored.
+	b.addCpuEvent(ev, 1050, 1230)
+	assert.Equal(t, uint64(50+40), b.Durations[CPUType_ON])
+	assert.Equal(t, uint64(60), b.Durations[CPUType_FUTEX])
+	assert.Equal(t, uint64(30), b.Durations[CPUType_NET])
+	// The run queue latency of the net interval is in [1230, 1250].
+	assert.Equal(t, uint64(10), b.Runq)
+}
+
+func TestAttachTimeBreakdown(t *testing.T) {
+	enableProfile = true
+	defer func() { enableProfile = false }()
+	analyzer := &CpuAnalyzer{
+		cpuPidEvents: make(map[uint32]map[uint32]*TimeSegments),
+		telemetry:    component.NewDefaultTelemetryTools(),
+		cfg:          &Config{SegmentSize: 40},
+	}
+	const startTime = 1660790515e9
+	// The event crosses two segments.
+	analyzer.PutEventToSegments(100, 12, "worker", &CpuEvent{CpuEvent: model.CpuEvent{
+		StartTime:   startTime + 5e8,
+		EndTime:     startTime + 15e8,
+		TypeSpecs:   []uint64{6e8, 4e8},
+		TimeType:    []CPUType{CPUType_ON, CPUType_FUTEX},
+		RunqLatency: []uint64{1e8},
+	}})
+
+	labels := model.NewAttributeMap()
+	labels.AddIntValue(constlabels.Pid, 100)
+	labels.AddStringValue(constlabels.ContentKey, "/lock")
+	trace := model.NewDataGroup(constnames.SingleNetRequestMetricGroup, labels, startTime+5e8,
+		model.NewIntMetric(constvalues.RequestTotalTime, 1e9))
+	// The thread handling the request is unknown.
+	assert.Nil(t, analyzer.attachTimeBreakdown(trace, trace.Timestamp, 1e9))
+
+	labels.AddIntValue(constlabels.RequestTid, 12)
+	breakdown := analyzer.attachTimeBreakdown(trace, trace.Timestamp, 1e9)
+	assert.NotNil(t, breakdown)
+	assert.Equal(t, constnames.RequestTimeBreakdownMetricGroupName, breakdown.Name)
+	assert.Equal(t, "/lock", breakdown.Labels.GetStringValue(constlabels.ContentKey))
+	for _, g := range []*model.DataGroup{trace, breakdown} {
+		onCpu, _ := g.GetMetric(constvalues.OnCpuTime)
+		assert.Equal(t, int64(6e8), onCpu.GetInt().Value)
+		futex, _ := g.GetMetric(constvalues.FutexTime)
+		assert.Equal(t, int64(4e8), futex.GetInt().Value)
+		runq, _ := g.GetMetric(constvalues.RunqTime)
+		assert.Equal(t, int64(1e8), runq.GetInt().Value)
+		net, _ := g.GetMetric(constvalues.NetTime)
+		assert.Equal(t, int64(0), net.GetInt().Value)
+	}
+	// The metrics are updated rather than appended if the trace is triggered again.
+	metricCount := len(trace.Metrics)
+	analyzer.attachTimeBreakdown(trace, trace.Timestamp, 1e9)
+	assert.Len(t, trace.Metrics, metricCount)
+}
@@ -40,12 +40,10 @@ type NetworkAnalyzer struct {
 	nextConsumers []consumer.Consumer
 	conntracker   conntracker.Conntracker
 
-	staticPortMap    map[uint32]string
-	slowThresholdMap map[string]int
-	protocolMap      map[string]*protocol.ProtocolParser
-	parserFactory    *factory.ParserFactory
-	parsers          []*protocol.ProtocolParser
-	udpDnsParser     *protocol.ProtocolParser
+	// parserCfg holds the *parserConfig in effect, which can be replaced at runtime.
+	parserCfg     atomic.Value
+	reconfigLock  sync.Mutex
+	parserFactory *factory.ParserFactory
 
 	dataGroupPool      DataGroupPool
 	dnsRequestMonitor  sync.Map
@@ -56,10 +54,15 @@ type NetworkAnalyzer struct {
 
 	eventChan chan *model.KindlingEvent
 	stopChan  chan bool
+	// consumerWg waits for the events left in eventChan to be processed when shutting down.
+	consumerWg sync.WaitGroup
 
 	// snaplen is the maximum data size the event could accommodate bytes.
 	// It is set by setting the environment variable SNAPLEN. See https://github.com/KindlingProject/kindling/pull/387.
 	snaplen int
+
+	// spans are the APM spans used to correlate the records to the traces.
+	spans *spanIndex
 }
 
 func NewNetworkAnalyzer(cfg interface{}, telemetry *component.TelemetryTools, consumers []consumer.Consumer) analyzer.Analyzer {
@@ -72,6 +75,7 @@ func NewNetworkAnalyzer(cfg interface{}, telemetry *component.TelemetryTools, co
 
 		eventChan: make(chan *model.KindlingEvent, config.EventChannelSize),
 		stopChan:  make(chan bool),
+		spans:     newSpanIndex(),
 	}
 	if config.EnableConntrack {
 		connConfig := &conntracker.Config{
@@ -112,6 +116,7 @@ func (na *NetworkAnalyzer) ConsumableEvents() []string {
 		constnames.SendMsgEvent,
 		constnames.RecvMsgEvent,
 		constnames.SendMMsgEvent,
+		constnames.SpanEvent,
 	}
 }
 
@@ -123,49 +128,23 @@ func (na *NetworkAnalyzer) Start() error {
 		go na.consumerFdNoReusingTrace()
 	}
 	// go na.consumerUnFinishTrace()
-	na.staticPortMap = map[uint32]string{}
-	for _, config := range na.cfg.ProtocolConfigs {
-		for _, port := range config.Ports {
-			na.staticPortMap[port] = config.Key
-		}
-	}
-
-	na.slowThresholdMap = map[string]int{}
-	disableDisernProtocols := map[string]bool{}
-	for _, config := range na.cfg.ProtocolConfigs {
-		protocol.SetPayLoadLength(config.Key, config.PayloadLength)
-		na.slowThresholdMap[config.Key] = config.Threshold
-		disableDisernProtocols[config.Key] = config.DisableDiscern
-	}
-
-	na.protocolMap = map[string]*protocol.ProtocolParser{}
-	parsers := make([]*protocol.ProtocolParser, 0)
-	for _, protocolName := range na.cfg.ProtocolParser {
-		protocolParser := na.parserFactory.GetParser(protocolName)
-		if protocolParser != nil {
-			na.protocolMap[protocolName] = protocolParser
-			disableDiscern, ok := disableDisernProtocols[protocolName]
-			if !ok || !disableDiscern {
-				parsers = append(parsers, protocolParser)
-			}
-		}
-	}
-	// Add Generic Last
-	parsers = append(parsers, na.parserFactory.GetGenericParser())
-	na.parsers = parsers
-
-	// Add Udp Dns
-	na.udpDnsParser = na.parserFactory.GetUdpDnsParser()
+	na.storeParserConfig(newParserConfig(na.cfg.ProtocolParser, na.cfg.ProtocolConfigs, na.cfg.UrlClusteringMethod,
+		na.cfg.getResponseSlowThreshold(), na.parserFactory))
 
 	rand.Seed(time.Now().UnixNano())
-	go na.ConsumeEventFromChannel()
+	na.consumerWg.Add(1)
+	go func() {
+		defer na.consumerWg.Done()
+		na.ConsumeEventFromChannel()
+	}()
 	return nil
 }
 
+// Shutdown stops analyzing after the events left in the channel are processed, so the
+// records of them are sent to the next consumers. The receivers must be shut down first.
 func (na *NetworkAnalyzer) Shutdown() error {
 	close(na.stopChan)
-
-	// TODO: implement
+	na.consumerWg.Wait()
 	return nil
 }
 
@@ -173,6 +152,13 @@ func (na *NetworkAnalyzer) Type() analyzer.Type {
 	return Network
 }
 
+// QueueDepths returns the fill level of the events waiting to be analyzed.
+func (na *NetworkAnalyzer) QueueDepths() map[string]component.QueueDepth {
+	return map[string]component.QueueDepth{
+		"event_channel": {Length: int64(len(na.eventChan)), Capacity: int64(cap(na.eventChan))},
+	}
+}
+
 func (na *NetworkAnalyzer) ConsumeEvent(evt *model.KindlingEvent) error {
 	na.eventChan <- evt
 	return nil
@@ -187,12 +173,30 @@ func (na *NetworkAnalyzer) ConsumeEventFromChannel() {
 				na.telemetry.Logger.Error("error happened when processing event: ", zap.Error(err))
 			}
 		case <-na.stopChan:
+			na.drainEvents()
+			return
+		}
+	}
+}
+
+func (na *NetworkAnalyzer) drainEvents() {
+	for {
+		select {
+		case evt := <-na.eventChan:
+			if err := na.processEvent(evt); err != nil {
+				na.telemetry.Logger.Error("error happened when processing event: ", zap.Error(err))
+			}
+		default:
 			return
 		}
 	}
 }
 
 func (na *NetworkAnalyzer) processEvent(evt *model.KindlingEvent) error {
+	if evt.Name == constnames.SpanEvent {
+		na.spans.add(evt)
+		return nil
+	}
 	if evt.Category != model.Category_CAT_NET {
 		return nil
 	}
@@ -212,7 +216,8 @@ func (na *NetworkAnalyzer) processEvent(evt *model.KindlingEvent) error {
 
 	// if not dns and udp == 1, return
 	if fd.GetProtocol() == model.L4Proto_UDP {
-		if protocolName, ok := na.staticPortMap[evt.GetDport()]; !ok || protocolName != protocol.DNS {
+		pc := na.getParserConfig()
+		if protocolName, ok := pc.staticPortMap[evt.GetDport()]; !ok || protocolName != protocol.DNS {
 			return nil
 		}
 		isRequest, err := evt.IsRequest()
@@ -234,7 +239,7 @@ func (na *NetworkAnalyzer) processEvent(evt *model.KindlingEvent) error {
 			}
 			return nil
 		} else {
-			if responseAttributes, success := parseDnsUdpResponse(na.udpDnsParser, evt); success {
+			if responseAttributes, success := parseDnsUdpResponse(pc.udpDnsParser, evt); success {
 				if udpDnsInterface, exist := na.dnsRequestMonitor.Load(udpKey); exist {
 					dnsUdpCache := udpDnsInterface.(*DnsUdpCache)
 					matchRequest, size := dnsUdpCache.getMatchRequest(responseAttributes)
@@ -290,7 +295,7 @@ func (na *NetworkAnalyzer) processEvent(evt *model.KindlingEvent) error {
 }
 
 func (na *NetworkAnalyzer) consumeUdpDnsRequest(evt *model.KindlingEvent, key udpKey) {
-	if parsedRequest, successs := parseDnsUdpRequest(na.udpDnsParser, evt); successs {
+	if parsedRequest, successs := parseDnsUdpRequest(na.getParserConfig().udpDnsParser, evt); successs {
 		udpDnsInterface, _ := na.dnsRequestMonitor.LoadOrStore(key, newDnsUdpCache())
 		udpDnsInterface.(*DnsUdpCache).addRequest(parsedRequest)
 	} else {
@@ -494,14 +499,15 @@ func (na *NetworkAnalyzer) distributeRecords(records []*model.DataGroup) error {
 func (na *NetworkAnalyzer) parseProtocols(mps *messagePairs) []*model.DataGroup {
 	// Step 1:  Static Config for port and protocol set in config file
 	port := mps.getPort()
-	staticProtocol, found := na.staticPortMap[port]
+	pc := na.getParserConfig()
+	staticProtocol, found := pc.staticPortMap[port]
 	if found {
 		if mps.requests == nil {
 			// Connect Timeout
 			return na.getConnectFailRecords(mps)
 		}
 
-		if parser, exist := na.protocolMap[staticProtocol]; exist {
+		if parser, exist := pc.protocolMap[staticProtocol]; exist {
 			records := na.parseProtocol(mps, parser)
 			if records != nil {
 				return records
@@ -520,7 +526,7 @@ func (na *NetworkAnalyzer) parseProtocols(mps *messagePairs) []*model.DataGroup
 
 	// Step2 Cache protocol and port
 	// TODO There is concurrent modify case when looping. Considering threadsafe.
-	cacheParsers, ok := na.parserFactory.GetCachedParsersByPort(port)
+	cacheParsers, ok := pc.parserFactory.GetCachedParsersByPort(port)
 	if ok {
 		for _, parser := range cacheParsers {
 			records := na.parseProtocol(mps, parser)
@@ -529,7 +535,7 @@ func (na *NetworkAnalyzer) parseProtocols(mps *messagePairs) []*model.DataGroup
 					// Reset mapping for  generic and port when exceed threshold so as to parsed by other protcols.
 					if parser.AddPortCount(port) == CACHE_RESET_THRESHOLD {
 						parser.ResetPort(port)
-						na.parserFactory.RemoveCachedParser(port, parser)
+						pc.parserFactory.RemoveCachedParser(port, parser)
 					}
 				}
 				return records
@@ -538,12 +544,12 @@ func (na *NetworkAnalyzer) parseProtocols(mps *messagePairs) []*model.DataGroup
 	}
 
 	// Step3 Loop all protocols
-	for _, parser := range na.parsers {
+	for _, parser := range pc.parsers {
 		records := na.parseProtocol(mps, parser)
 		if records != nil {
 			// Add mapping for port and protocol when exceed threshold
 			if parser.AddPortCount(port) == CACHE_ADD_THRESHOLD {
-				na.parserFactory.AddCachedParser(port, parser)
+				pc.parserFactory.AddCachedParser(port, parser)
 			}
 			return records
 		}
@@ -709,10 +715,12 @@ func (na *NetworkAnalyzer) getRecords(mps *messagePairs, protocol string, attrib
 
 	labels.Merge(attributes)
 
+	endTimestamp := evt.GetStartTime()
 	if mps.responses != nil {
-		endTimestamp := mps.responses.getLastTimestamp()
+		endTimestamp = mps.responses.getLastTimestamp()
 		labels.UpdateAddIntValue(constlabels.EndTimestamp, int64(endTimestamp))
 	}
+	na.correlateSpan(labels, evt.GetPid(), evt.GetTid(), evt.GetStartTime(), endTimestamp)
 
 	if mps.responses == nil {
 		addProtocolPayload(protocol, labels, mps.requests.getData(), nil)
@@ -770,9 +778,12 @@ func (na *NetworkAnalyzer) getRecordWithSinglePair(mp *messagePair, protocol str
 	labels.UpdateAddStringValue(constlabels.Protocol, protocol)
 
 	labels.Merge(attributes)
+	endTimestamp := evt.GetStartTime()
 	if mp.response != nil {
-		labels.UpdateAddIntValue(constlabels.EndTimestamp, int64(mp.response.Timestamp))
+		endTimestamp = mp.response.Timestamp
+		labels.UpdateAddIntValue(constlabels.EndTimestamp, int64(endTimestamp))
 	}
+	na.correlateSpan(labels, evt.GetPid(), evt.GetTid(), evt.GetStartTime(), endTimestamp)
 	if mp.response == nil {
 		addProtocolPayload(protocol, labels, evt.GetData(), nil)
 	} else {
@@ -802,6 +813,16 @@ func (na *NetworkAnalyzer) getRecordWithSinglePair(mp *messagePair, protocol str
 	return ret
 }
 
+// correlateSpan adds the trace id of the APM span on the same thread if the payload doesn't carry one.
+func (na *NetworkAnalyzer) correlateSpan(labels *model.AttributeMap, pid uint32, tid uint32, startTime uint64, endTime uint64) {
+	if labels.GetStringValue(constlabels.HttpApmTraceId) != "" {
+		return
+	}
+	if traceId := na.spans.lookup(pid, tid, startTime, endTime); traceId != "" {
+		labels.UpdateAddStringValue(constlabels.HttpApmTraceId, traceId)
+	}
+}
+
 func addMessagePairTid(labels *model.AttributeMap, mp *messagePair) {
 	if mp.request != nil {
 		labels.UpdateAddIntValue(constlabels.RequestTid, int64(mp.request.GetTid()))
@@ -842,9 +863,5 @@ func (na *NetworkAnalyzer) isSlow(duration uint64, protocol string) bool {
 }
 
 func (na *NetworkAnalyzer) getResponseSlowThreshold(protocol string) int {
-	if value, ok := na.slowThresholdMap[protocol]; ok && value > 0 {
-		// If value is not set, use response_slow_threshold by default.
-		return value
-	}
-	return na.cfg.getResponseSlowThreshold()
+	return na.getParserConfig().getResponseSlowThreshold(protocol)
 }
@@ -13,7 +13,6 @@ import (
 	viperpackage "github.com/spf13/viper"
 
 	"github.com/Kindling-project/kindling/collector/pkg/component"
-	"github.com/Kindling-project/kindling/collector/pkg/component/analyzer/network/protocol"
 	"github.com/Kindling-project/kindling/collector/pkg/component/analyzer/network/protocol/factory"
 	"github.com/Kindling-project/kindling/collector/pkg/component/consumer"
 	"github.com/Kindling-project/kindling/collector/pkg/model"
@@ -124,17 +123,7 @@ func prepareNetworkAnalyzer() *NetworkAnalyzer {
 			dataGroupPool: &NoCacheDataGroupPool{},
 			nextConsumers: []consumer.Consumer{&NopProcessor{}},
 			telemetry:     component.NewDefaultTelemetryTools(),
-		}
-		na.staticPortMap = map[uint32]string{}
-		for _, config := range na.cfg.ProtocolConfigs {
-			for _, port := range config.Ports {
-				na.staticPortMap[port] = config.Key
-			}
-		}
-		na.slowThresholdMap = map[string]int{}
-		for _, config := range na.cfg.ProtocolConfigs {
-			protocol.SetPayLoadLength(config.Key, config.PayloadLength)
-			na.slowThresholdMap[config.Key] = config.Threshold
+			spans:         newSpanIndex(),
 		}
 		na.parserFactory = factory.NewParserFactory(factory.WithUrlClusteringMethod(na.cfg.UrlClusteringMethod), factory.WithIgnoreDnsRcode3Error(na.cfg.IgnoreDnsRcode3Error))
 		na.snaplen = 200
@@ -0,0 +1,84 @@
+package network
+
+import (
+	"github.com/Kindling-project/kindling/collector/pkg/component/analyzer/network/protocol"
+	"github.com/Kindling-project/kindling/collector/pkg/component/analyzer/network/protocol/factory"
+)
+
+// parserConfig is the configuration of the protocol parsing in effect. It is replaced as
+// a whole when the analyzer is reconfigured at runtime, so each message pair in flight is
+// parsed with either the old one or the new one.
+type parserConfig struct {
+	protocolParser        []string
+	protocolConfigs       []ProtocolConfig
+	urlClusteringMethod   string
+	responseSlowThreshold int
+
+	staticPortMap    map[uint32]string
+	slowThresholdMap map[string]int
+	protocolMap      map[string]*protocol.ProtocolParser
+	parserFactory    *factory.ParserFactory
+	parsers          []*protocol.ProtocolParser
+	udpDnsParser     *protocol.ProtocolParser
+}
+
+func newParserConfig(protocolParser []string, protocolConfigs []ProtocolConfig, urlClusteringMethod string,
+	responseSlowThreshold int, parserFactory *factory.ParserFactory) *parserConfig {
+	pc := &parserConfig{
+		protocolParser:        protocolParser,
+		protocolConfigs:       protocolConfigs,
+		urlClusteringMethod:   urlClusteringMethod,
+		responseSlowThreshold: responseSlowThreshold,
+		staticPortMap:         map[uint32]string{},
+		slowThresholdMap:      map[string]int{},
+		protocolMap:           map[string]*protocol.ProtocolParser{},
+		parserFactory:         parserFactory,
+	}
+	for _, config := range protocolConfigs {
+		for _, port := range config.Ports {
+			pc.staticPortMap[port] = config.Key
+		}
+	}
+
+	disableDisernProtocols := map[string]bool{}
+	for _, config := range protocolConfigs {
+		pc.slowThresholdMap[config.Key] = config.Threshold
+		disableDisernProtocols[config.Key] = config.DisableDiscern
+	}
+
+	parsers := make([]*protocol.ProtocolParser, 0)
+	for _, protocolName := range protocolParser {
+		protocolParser := parserFactory.GetParser(protocolName)
+		if protocolParser != nil {
+			pc.protocolMap[protocolName] = protocolParser
+			disableDiscern, ok := disableDisernProtocols[protocolName]
+			if !ok || !disableDiscern {
+				parsers = append(parsers, protocolParser)
+			}
+		}
+	}
+	// Add Generic Last
+	parsers = append(parsers, parserFactory.GetGenericParser())
+	pc.parsers = parsers
+
+	// Add Udp Dns
+	pc.udpDnsParser = parserFactory.GetUdpDnsParser()
+	return pc
+}
+
+// payloadLengths returns the payload lengths of the protocols configured.
+func (pc *parserConfig) payloadLengths() map[string]int {
+	lengths := make(map[string]int, len(pc.protocolConfigs))
+	for _, config := range pc.protocolConfigs {
+		lengths[config.Key] = config.PayloadLength
+	}
+	return lengths
+}
+
+func (pc *parserConfig) getResponseSlowThreshold(protocol string) int {
+	if value, ok := pc.slowThresholdMap[protocol]; ok && value > 0 {
+		// If value is not set, use response_slow_threshold by default.
+		return value
+	}
+	return pc.responseSlowThreshold
+}
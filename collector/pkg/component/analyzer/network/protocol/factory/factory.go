@@ -108,3 +108,24 @@ func (f *ParserFactory) RemoveCachedParser(port uint32, parser *protocol.Protoco
 	}
 	f.mutex.Unlock()
 }
+
+// RemoveCachedParserFromAllPorts removes the parser from the cache of all the ports, e.g.
+// when it is disabled. The counters of the ports are reset, so the ports can be cached again
+// if the parser is enabled later.
+func (f *ParserFactory) RemoveCachedParserFromAllPorts(parser *protocol.ProtocolParser) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for port, val := range f.cachePortParsersMap {
+		// The slice is copied because it may be looped by the parsing goroutine.
+		parsers := make([]*protocol.ProtocolParser, 0, len(val))
+		for _, value := range val {
+			if value != parser {
+				parsers = append(parsers, value)
+			}
+		}
+		if len(parsers) != len(val) {
+			f.cachePortParsersMap[port] = parsers
+			parser.ResetPort(port)
+		}
+	}
+}
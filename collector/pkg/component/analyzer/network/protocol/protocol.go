@@ -1,5 +1,10 @@
 package protocol
 
+import (
+	"sync"
+	"sync/atomic"
+)
+
 const (
 	HTTP      = "http"
 	DNS       = "dns"
@@ -11,18 +16,42 @@ const (
 	NOSUPPORT = "NOSUPPORT"
 )
 
-var payloadLength map[string]int = map[string]int{}
+// payloadLength holds a map[string]int which is replaced as a whole when it is changed,
+// so the lengths can be changed at runtime while the parsers are reading them.
+var (
+	payloadLength     atomic.Value
+	payloadLengthLock sync.Mutex
+)
+
+func init() {
+	payloadLength.Store(map[string]int{})
+}
 
 func SetPayLoadLength(protocol string, length int) {
-	if length > 0 {
-		payloadLength[protocol] = length
-	} else {
-		payloadLength[protocol] = 200
+	SetPayLoadLengths(map[string]int{protocol: length})
+}
+
+// SetPayLoadLengths changes the lengths of the protocols at once.
+func SetPayLoadLengths(lengths map[string]int) {
+	payloadLengthLock.Lock()
+	defer payloadLengthLock.Unlock()
+	old := payloadLength.Load().(map[string]int)
+	newLengths := make(map[string]int, len(old)+len(lengths))
+	for protocol, length := range old {
+		newLengths[protocol] = length
+	}
+	for protocol, length := range lengths {
+		if length > 0 {
+			newLengths[protocol] = length
+		} else {
+			newLengths[protocol] = 200
+		}
 	}
+	payloadLength.Store(newLengths)
 }
 
 func GetPayLoadLength(protocol string) int {
-	if length, ok := payloadLength[protocol]; ok {
+	if length, ok := payloadLength.Load().(map[string]int)[protocol]; ok {
 		return length
 	}
 	return 200
@@ -0,0 +1,283 @@
+package network
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component/analyzer/network/protocol"
+	"github.com/Kindling-project/kindling/collector/pkg/component/analyzer/network/protocol/factory"
+)
+
+// The operations to reconfigure the network analyzer at runtime.
+const (
+	// OperationEnableParser enables the parsers of Protocols.
+	OperationEnableParser = "enable_parser"
+	// OperationDisableParser disables the parsers of Protocols.
+	OperationDisableParser = "disable_parser"
+	// OperationSetSlowThreshold sets the slow threshold of Protocol in milliseconds, or
+	// response_slow_threshold if Protocol is empty.
+	OperationSetSlowThreshold = "set_slow_threshold"
+	// OperationSetPayloadLength sets the payload length of Protocol in bytes.
+	OperationSetPayloadLength = "set_payload_length"
+	// OperationSetUrlClustering switches the URL clustering method of HTTP.
+	OperationSetUrlClustering = "set_url_clustering"
+)
+
+var urlClusteringMethods = map[string]bool{"alphabet": true, "noparam": true, "blank": true}
+
+// ReconfigureOptions are the options of the operations.
+type ReconfigureOptions struct {
+	Protocols []string `json:"protocols"`
+	Protocol  string   `json:"protocol"`
+	Threshold int      `json:"threshold"`
+	Length    int      `json:"length"`
+	Method    string   `json:"method"`
+}
+
+// EffectiveConfig is the configuration of the protocol parsing in effect.
+type EffectiveConfig struct {
+	ProtocolParser        []string                  `json:"protocol_parser"`
+	ProtocolConfigs       []EffectiveProtocolConfig `json:"protocol_config"`
+	ResponseSlowThreshold int                       `json:"response_slow_threshold"`
+	UrlClusteringMethod   string                    `json:"url_clustering_method"`
+}
+
+type EffectiveProtocolConfig struct {
+	Key     string   `json:"key"`
+	Enabled bool     `json:"enabled"`
+	Ports   []uint32 `json:"ports,omitempty"`
+	// PayloadLength and SlowThreshold are the values in effect, including the defaults.
+	PayloadLength  int  `json:"payload_length"`
+	SlowThreshold  int  `json:"slow_threshold"`
+	DisableDiscern bool `json:"disable_discern,omitempty"`
+}
+
+func (na *NetworkAnalyzer) getParserConfig() *parserConfig {
+	return na.parserCfg.Load().(*parserConfig)
+}
+
+func (na *NetworkAnalyzer) storeParserConfig(pc *parserConfig) {
+	protocol.SetPayLoadLengths(pc.payloadLengths())
+	na.parserCfg.Store(pc)
+}
+
+// replaceParserConfig stores pc in place of old. The ports cached are checked before the
+// parsers in effect, so the parsers which are not discerned any more are removed from the
+// cache if the factory is reused. Otherwise they would keep parsing the ports cached.
+func (na *NetworkAnalyzer) replaceParserConfig(old *parserConfig, pc *parserConfig) {
+	na.storeParserConfig(pc)
+	if old.parserFactory != pc.parserFactory {
+		return
+	}
+	for _, parser := range old.parsers {
+		if !containsParser(pc.parsers, parser) {
+			pc.parserFactory.RemoveCachedParserFromAllPorts(parser)
+		}
+	}
+}
+
+// EffectiveConfig returns the configuration of the protocol parsing in effect.
+func (na *NetworkAnalyzer) EffectiveConfig() interface{} {
+	pc, ok := na.parserCfg.Load().(*parserConfig)
+	if !ok {
+		// The analyzer is not started yet.
+		return nil
+	}
+	configs := make(map[string]*EffectiveProtocolConfig)
+	getConfig := func(key string) *EffectiveProtocolConfig {
+		config, ok := configs[key]
+		if !ok {
+			config = &EffectiveProtocolConfig{
+				Key:           key,
+				PayloadLength: protocol.GetPayLoadLength(key),
+				SlowThreshold: pc.getResponseSlowThreshold(key),
+			}
+			configs[key] = config
+		}
+		return config
+	}
+	for _, config := range pc.protocolConfigs {
+		c := getConfig(config.Key)
+		c.Ports = config.Ports
+		c.DisableDiscern = config.DisableDiscern
+	}
+	for key := range pc.protocolMap {
+		getConfig(key).Enabled = true
+	}
+	ret := &EffectiveConfig{
+		ProtocolParser:        append([]string(nil), pc.protocolParser...),
+		ProtocolConfigs:       make([]EffectiveProtocolConfig, 0, len(configs)),
+		ResponseSlowThreshold: pc.responseSlowThreshold,
+		UrlClusteringMethod:   pc.urlClusteringMethod,
+	}
+	for _, config := range configs {
+		ret.ProtocolConfigs = append(ret.ProtocolConfigs, *config)
+	}
+	sort.Slice(ret.ProtocolConfigs, func(i, j int) bool {
+		return ret.ProtocolConfigs[i].Key < ret.ProtocolConfigs[j].Key
+	})
+	return ret
+}
+
+// Reconfigure applies the operation to a copy of the configuration in effect and replaces
+// it at once. The message pairs in flight are kept and parsed with the new configuration.
+// Nothing is changed if the options are invalid.
+func (na *NetworkAnalyzer) Reconfigure(operation string, rawOptions json.RawMessage) error {
+	var options ReconfigureOptions
+	if len(rawOptions) > 0 {
+		if err := json.Unmarshal(rawOptions, &options); err != nil {
+			return fmt.Errorf("invalid options: %w", err)
+		}
+	}
+	na.reconfigLock.Lock()
+	defer na.reconfigLock.Unlock()
+	old, ok := na.parserCfg.Load().(*parserConfig)
+	if !ok {
+		return errors.New("network analyzer is not started")
+	}
+	protocolParser := append([]string(nil), old.protocolParser...)
+	protocolConfigs := append([]ProtocolConfig(nil), old.protocolConfigs...)
+	urlClusteringMethod := old.urlClusteringMethod
+	responseSlowThreshold := old.responseSlowThreshold
+	parserFactory := old.parserFactory
+
+	// protocolConfig returns the config of the protocol in protocolConfigs, which is
+	// added if it doesn't exist.
+	protocolConfig := func(key string) *ProtocolConfig {
+		for i := range protocolConfigs {
+			if protocolConfigs[i].Key == key {
+				return &protocolConfigs[i]
+			}
+		}
+		protocolConfigs = append(protocolConfigs, ProtocolConfig{Key: key, PayloadLength: protocol.GetPayLoadLength(key)})
+		return &protocolConfigs[len(protocolConfigs)-1]
+	}
+
+	switch operation {
+	case OperationEnableParser:
+		if len(options.Protocols) == 0 {
+			return errors.New("no protocols to enable")
+		}
+		for _, name := range options.Protocols {
+			if name == protocol.NOSUPPORT || parserFactory.GetParser(name) == nil {
+				return fmt.Errorf("unsupported protocol: %s", name)
+			}
+			if !containsString(protocolParser, name) {
+				protocolParser = append(protocolParser, name)
+			}
+		}
+	case OperationDisableParser:
+		if len(options.Protocols) == 0 {
+			return errors.New("no protocols to disable")
+		}
+		for _, name := range options.Protocols {
+			protocolParser = removeString(protocolParser, name)
+		}
+	case OperationSetSlowThreshold:
+		if options.Threshold <= 0 {
+			return fmt.Errorf("invalid threshold: %d", options.Threshold)
+		}
+		if options.Protocol == "" {
+			responseSlowThreshold = options.Threshold
+		} else {
+			protocolConfig(options.Protocol).Threshold = options.Threshold
+		}
+	case OperationSetPayloadLength:
+		if options.Protocol == "" || options.Length <= 0 {
+			return fmt.Errorf("invalid payload length %d of protocol %q", options.Length, options.Protocol)
+		}
+		protocolConfig(options.Protocol).PayloadLength = options.Length
+	case OperationSetUrlClustering:
+		if !urlClusteringMethods[options.Method] {
+			return fmt.Errorf("unsupported url clustering method: %s", options.Method)
+		}
+		if options.Method != urlClusteringMethod {
+			urlClusteringMethod = options.Method
+			// The parsers are created with the clustering method, so the cached parsers of
+			// the ports are rebuilt with the new factory.
+			parserFactory = factory.NewParserFactory(factory.WithUrlClusteringMethod(urlClusteringMethod),
+				factory.WithIgnoreDnsRcode3Error(na.cfg.IgnoreDnsRcode3Error))
+		}
+	default:
+		return fmt.Errorf("unexpected operation: %s", operation)
+	}
+
+	na.replaceParserConfig(old, newParserConfig(protocolParser, protocolConfigs, urlClusteringMethod, responseSlowThreshold, parserFactory))
+	na.telemetry.Logger.Infof("Network analyzer is reconfigured by %s: %s", operation, string(rawOptions))
+	return nil
+}
+
+// ErrRestartRequired is returned if the options which can't be changed at runtime are changed.
+var ErrRestartRequired = errors.New("only protocol_parser, protocol_config, url_clustering_method and response_slow_threshold can be changed without restarting")
+
+// ValidateConfig checks whether cfg can be applied by ApplyConfig.
+func (na *NetworkAnalyzer) ValidateConfig(cfg *Config) error {
+	reloadable := *na.cfg
+	reloadable.ProtocolParser = cfg.ProtocolParser
+	reloadable.ProtocolConfigs = cfg.ProtocolConfigs
+	reloadable.UrlClusteringMethod = cfg.UrlClusteringMethod
+	reloadable.ResponseSlowThreshold = cfg.ResponseSlowThreshold
+	if !reflect.DeepEqual(&reloadable, cfg) {
+		return ErrRestartRequired
+	}
+	if cfg.UrlClusteringMethod != "" && !urlClusteringMethods[cfg.UrlClusteringMethod] {
+		return fmt.Errorf("unsupported url clustering method: %s", cfg.UrlClusteringMethod)
+	}
+	return nil
+}
+
+// ApplyConfig applies the options of the protocol parsing in cfg at runtime, which are
+// protocol_parser, protocol_config, url_clustering_method and response_slow_threshold.
+// The other options are ignored, so ValidateConfig should be called first. The changes
+// made by Reconfigure are overridden.
+func (na *NetworkAnalyzer) ApplyConfig(cfg *Config) error {
+	if cfg.UrlClusteringMethod != "" && !urlClusteringMethods[cfg.UrlClusteringMethod] {
+		return fmt.Errorf("unsupported url clustering method: %s", cfg.UrlClusteringMethod)
+	}
+	na.reconfigLock.Lock()
+	defer na.reconfigLock.Unlock()
+	old, ok := na.parserCfg.Load().(*parserConfig)
+	if !ok {
+		return errors.New("network analyzer is not started")
+	}
+	parserFactory := old.parserFactory
+	if cfg.UrlClusteringMethod != old.urlClusteringMethod {
+		parserFactory = factory.NewParserFactory(factory.WithUrlClusteringMethod(cfg.UrlClusteringMethod),
+			factory.WithIgnoreDnsRcode3Error(na.cfg.IgnoreDnsRcode3Error))
+	}
+	na.replaceParserConfig(old, newParserConfig(cfg.ProtocolParser, cfg.ProtocolConfigs, cfg.UrlClusteringMethod,
+		cfg.getResponseSlowThreshold(), parserFactory))
+	na.telemetry.Logger.Infof("Network analyzer is reconfigured with the configuration file")
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsParser(parsers []*protocol.ProtocolParser, parser *protocol.ProtocolParser) bool {
+	for _, p := range parsers {
+		if p == parser {
+			return true
+		}
+	}
+	return false
+}
+
+func removeString(values []string, value string) []string {
+	ret := values[:0]
+	for _, v := range values {
+		if v != value {
+			ret = append(ret, v)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,138 @@
+package network
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component"
+	"github.com/Kindling-project/kindling/collector/pkg/component/analyzer/network/protocol"
+	"github.com/Kindling-project/kindling/collector/pkg/component/analyzer/network/protocol/factory"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constlabels"
+)
+
+func TestReconfigure(t *testing.T) {
+	cfg := NewDefaultConfig()
+	analyzer := &NetworkAnalyzer{
+		cfg:           cfg,
+		telemetry:     component.NewDefaultTelemetryTools(),
+		parserFactory: factory.NewParserFactory(factory.WithUrlClusteringMethod(cfg.UrlClusteringMethod)),
+	}
+	analyzer.storeParserConfig(newParserConfig(cfg.ProtocolParser, cfg.ProtocolConfigs, cfg.UrlClusteringMethod,
+		cfg.getResponseSlowThreshold(), analyzer.parserFactory))
+	old := analyzer.getParserConfig()
+
+	assert.NoError(t, analyzer.Reconfigure(OperationDisableParser, []byte(`{"protocols": ["kafka", "dubbo"]}`)))
+	assert.NotContains(t, analyzer.getParserConfig().protocolMap, protocol.KAFKA)
+	// The configuration in effect before is not changed.
+	assert.Contains(t, old.protocolMap, protocol.KAFKA)
+	assert.Contains(t, old.protocolParser, protocol.DUBBO)
+	assert.NoError(t, analyzer.Reconfigure(OperationEnableParser, []byte(`{"protocols": ["rocketmq"]}`)))
+	assert.Contains(t, analyzer.getParserConfig().protocolMap, protocol.ROCKETMQ)
+	assert.Error(t, analyzer.Reconfigure(OperationEnableParser, []byte(`{"protocols": ["unknown"]}`)))
+
+	assert.NoError(t, analyzer.Reconfigure(OperationSetSlowThreshold, []byte(`{"protocol": "mysql", "threshold": 30}`)))
+	assert.NoError(t, analyzer.Reconfigure(OperationSetSlowThreshold, []byte(`{"threshold": 800}`)))
+	assert.True(t, analyzer.isSlow(uint64(40e6), protocol.MYSQL))
+	assert.False(t, analyzer.isSlow(uint64(600e6), protocol.HTTP))
+	assert.Error(t, analyzer.Reconfigure(OperationSetSlowThreshold, []byte(`{"threshold": -1}`)))
+
+	assert.NoError(t, analyzer.Reconfigure(OperationSetPayloadLength, []byte(`{"protocol": "http", "length": 1000}`)))
+	assert.Equal(t, 1000, protocol.GetPayLoadLength(protocol.HTTP))
+
+	assert.NoError(t, analyzer.Reconfigure(OperationSetUrlClustering, []byte(`{"method": "noparam"}`)))
+	assert.NotSame(t, analyzer.parserFactory, analyzer.getParserConfig().parserFactory)
+	assert.Error(t, analyzer.Reconfigure(OperationSetUrlClustering, []byte(`{"method": "regex"}`)))
+	assert.Error(t, analyzer.Reconfigure("restart", nil))
+
+	effective := analyzer.EffectiveConfig().(*EffectiveConfig)
+	assert.Equal(t, "noparam", effective.UrlClusteringMethod)
+	assert.Equal(t, 800, effective.ResponseSlowThreshold)
+	for _, config := range effective.ProtocolConfigs {
+		switch config.Key {
+		case protocol.HTTP:
+			assert.True(t, config.Enabled)
+			assert.Equal(t, 1000, config.PayloadLength)
+			assert.Equal(t, 800, config.SlowThreshold)
+		case protocol.MYSQL:
+			assert.Equal(t, 30, config.SlowThreshold)
+			assert.Equal(t, []uint32{3306}, config.Ports)
+		case protocol.KAFKA:
+			assert.False(t, config.Enabled)
+		}
+	}
+	protocol.SetPayLoadLength(protocol.HTTP, 200)
+}
+
+func TestApplyConfig(t *testing.T) {
+	cfg := NewDefaultConfig()
+	analyzer := &NetworkAnalyzer{
+		cfg:           cfg,
+		telemetry:     component.NewDefaultTelemetryTools(),
+		parserFactory: factory.NewParserFactory(factory.WithUrlClusteringMethod(cfg.UrlClusteringMethod)),
+	}
+	analyzer.storeParserConfig(newParserConfig(cfg.ProtocolParser, cfg.ProtocolConfigs, cfg.UrlClusteringMethod,
+		cfg.getResponseSlowThreshold(), analyzer.parserFactory))
+
+	newCfg := NewDefaultConfig()
+	newCfg.ProtocolParser = []string{protocol.HTTP, protocol.MYSQL}
+	newCfg.ResponseSlowThreshold = 300
+	newCfg.UrlClusteringMethod = "blank"
+	assert.NoError(t, analyzer.ApplyConfig(newCfg))
+	effective := analyzer.EffectiveConfig().(*EffectiveConfig)
+	assert.Equal(t, []string{protocol.HTTP, protocol.MYSQL}, effective.ProtocolParser)
+	assert.Equal(t, 300, effective.ResponseSlowThreshold)
+	assert.Equal(t, "blank", effective.UrlClusteringMethod)
+
+	newCfg = NewDefaultConfig()
+	newCfg.EventChannelSize = 100
+	assert.True(t, errors.Is(analyzer.ValidateConfig(newCfg), ErrRestartRequired))
+	newCfg = NewDefaultConfig()
+	newCfg.UrlClusteringMethod = "regex"
+	assert.Error(t, analyzer.ValidateConfig(newCfg))
+	assert.Error(t, analyzer.ApplyConfig(newCfg))
+	// Nothing is changed if the configuration is rejected.
+	assert.Equal(t, "blank", analyzer.EffectiveConfig().(*EffectiveConfig).UrlClusteringMethod)
+}
+
+func TestDisableCachedParser(t *testing.T) {
+	cfg := NewDefaultConfig()
+	analyzer := &NetworkAnalyzer{
+		cfg:           cfg,
+		dataGroupPool: &NoCacheDataGroupPool{},
+		telemetry:     component.NewDefaultTelemetryTools(),
+		spans:         newSpanIndex(),
+		parserFactory: factory.NewParserFactory(factory.WithUrlClusteringMethod(cfg.UrlClusteringMethod)),
+	}
+	analyzer.storeParserConfig(newParserConfig(cfg.ProtocolParser, cfg.ProtocolConfigs, cfg.UrlClusteringMethod,
+		cfg.getResponseSlowThreshold(), analyzer.parserFactory))
+	eventCommon := getEventCommon("protocol/testdata/http/server-event.yml")
+	trace := getTrace("protocol/testdata/http/server-trace-normal.yml")
+	parseProtocol := func() string {
+		records := analyzer.parseProtocols(trace.prepareMessagePairs(eventCommon))
+		assert.Len(t, records, 1)
+		return records[0].Labels.GetStringValue(constlabels.Protocol)
+	}
+	// The port 9001 is cached as HTTP after the HTTP requests are parsed on it.
+	port := eventCommon.Ctx.Fd.Dport
+	httpParser := analyzer.parserFactory.GetParser(protocol.HTTP)
+	for i := 0; i < CACHE_ADD_THRESHOLD; i++ {
+		assert.Equal(t, protocol.HTTP, parseProtocol())
+	}
+	cached, _ := analyzer.parserFactory.GetCachedParsersByPort(port)
+	assert.True(t, containsParser(cached, httpParser))
+
+	assert.NoError(t, analyzer.Reconfigure(OperationDisableParser, []byte(`{"protocols": ["http"]}`)))
+	cached, _ = analyzer.parserFactory.GetCachedParsersByPort(port)
+	assert.False(t, containsParser(cached, httpParser))
+	assert.Equal(t, protocol.NOSUPPORT, parseProtocol())
+
+	// The port is cached again after the parser is enabled.
+	assert.NoError(t, analyzer.Reconfigure(OperationEnableParser, []byte(`{"protocols": ["http"]}`)))
+	for i := 0; i < CACHE_ADD_THRESHOLD; i++ {
+		assert.Equal(t, protocol.HTTP, parseProtocol())
+	}
+	cached, _ = analyzer.parserFactory.GetCachedParsersByPort(port)
+	assert.True(t, containsParser(cached, httpParser))
+}
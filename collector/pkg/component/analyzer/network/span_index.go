@@ -0,0 +1,110 @@
+package network
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+)
+
+const (
+	// spanRetention is how long a span is kept after it ends. The records are distributed
+	// when the next request arrives or the fd times out, so the span must outlive them.
+	spanRetention = time.Minute
+	// maxSpansPerThread bounds the spans kept for a thread, and the oldest ones are dropped.
+	maxSpansPerThread = 64
+)
+
+type apmSpan struct {
+	startTime uint64
+	endTime   uint64
+	traceId   string
+}
+
+// spanIndex keeps the recent APM spans of every thread, so the records whose trace id is
+// not carried by the payload can be correlated to the spans on the same thread.
+type spanIndex struct {
+	mutex   sync.Mutex
+	threads map[uint64][]apmSpan
+	// latest is the latest end time of the spans, used to expire the spans.
+	latest    uint64
+	lastSweep uint64
+}
+
+func newSpanIndex() *spanIndex {
+	return &spanIndex{threads: make(map[uint64][]apmSpan)}
+}
+
+func threadKey(pid uint32, tid uint32) uint64 {
+	return uint64(pid)<<32 | uint64(tid)
+}
+
+// add stores the span event, which has the user attributes end_time and trace_id.
+func (s *spanIndex) add(evt *model.KindlingEvent) {
+	traceId := evt.GetStringUserAttribute("trace_id")
+	endTime, _ := strconv.ParseUint(evt.GetStringUserAttribute("end_time"), 10, 64)
+	if traceId == "" || endTime < evt.Timestamp {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	key := threadKey(evt.GetPid(), evt.Ctx.ThreadInfo.GetTid())
+	spans := s.threads[key]
+	if len(spans) >= maxSpansPerThread {
+		spans = append(spans[:0], spans[1:]...)
+	}
+	s.threads[key] = append(spans, apmSpan{startTime: evt.Timestamp, endTime: endTime, traceId: traceId})
+	if endTime > s.latest {
+		s.latest = endTime
+	}
+	if s.latest-s.lastSweep > uint64(spanRetention) {
+		s.sweep()
+	}
+}
+
+// sweep removes the spans ended longer than spanRetention ago.
+func (s *spanIndex) sweep() {
+	s.lastSweep = s.latest
+	expiry := s.latest - uint64(spanRetention)
+	for key, spans := range s.threads {
+		kept := spans[:0]
+		for _, span := range spans {
+			if span.endTime >= expiry {
+				kept = append(kept, span)
+			}
+		}
+		if len(kept) == 0 {
+			delete(s.threads, key)
+		} else {
+			s.threads[key] = kept
+		}
+	}
+}
+
+// lookup returns the trace id of the span overlapping the time range most on the thread.
+// The innermost span is preferred if the spans overlap the range equally.
+func (s *spanIndex) lookup(pid uint32, tid uint32, startTime uint64, endTime uint64) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var traceId string
+	var maxOverlap, minDuration uint64
+	for _, span := range s.threads[threadKey(pid, tid)] {
+		start, end := span.startTime, span.endTime
+		if startTime > start {
+			start = startTime
+		}
+		if endTime < end {
+			end = endTime
+		}
+		// A request without response has no length, so it only needs to be within the span.
+		if start > end {
+			continue
+		}
+		overlap, duration := end-start+1, span.endTime-span.startTime
+		if overlap > maxOverlap || (overlap == maxOverlap && duration < minDuration) {
+			traceId, maxOverlap, minDuration = span.traceId, overlap, duration
+		}
+	}
+	return traceId
+}
@@ -0,0 +1,57 @@
+package network
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constnames"
+)
+
+func newSpanEvent(pid uint32, tid uint32, startTime uint64, endTime uint64, traceId string) *model.KindlingEvent {
+	evt := &model.KindlingEvent{
+		Name:      constnames.SpanEvent,
+		Timestamp: startTime,
+		Ctx:       model.Context{ThreadInfo: model.Thread{Pid: pid, Tid: tid}},
+	}
+	evt.UserAttributes[0] = model.KeyValue{Key: "end_time", ValueType: model.ValueType_CHARBUF, Value: []byte(strconv.FormatUint(endTime, 10))}
+	evt.UserAttributes[1] = model.KeyValue{Key: "trace_id", ValueType: model.ValueType_CHARBUF, Value: []byte(traceId)}
+	evt.ParamsNumber = 2
+	return evt
+}
+
+func TestSpanIndexLookup(t *testing.T) {
+	s := newSpanIndex()
+	s.add(newSpanEvent(1, 2, 100, 200, "outer"))
+	s.add(newSpanEvent(1, 2, 150, 180, "inner"))
+	s.add(newSpanEvent(1, 3, 100, 200, "other-thread"))
+
+	// The server reads the request before the span starts.
+	assert.Equal(t, "outer", s.lookup(1, 2, 90, 195))
+	assert.Equal(t, "inner", s.lookup(1, 2, 155, 175))
+	// The request without response is within the span.
+	assert.Equal(t, "outer", s.lookup(1, 2, 120, 120))
+	assert.Equal(t, "", s.lookup(1, 2, 210, 220))
+	assert.Equal(t, "", s.lookup(2, 2, 100, 200))
+}
+
+func TestSpanIndexExpiry(t *testing.T) {
+	s := newSpanIndex()
+	base := uint64(time.Now().UnixNano())
+	s.add(newSpanEvent(1, 2, base, base+10, "old"))
+	for i := 0; i < maxSpansPerThread+1; i++ {
+		s.add(newSpanEvent(1, 3, base+uint64(i), base+uint64(i)+1, strconv.Itoa(i)))
+	}
+	// The oldest span of the thread is dropped.
+	assert.Len(t, s.threads[threadKey(1, 3)], maxSpansPerThread)
+	assert.Equal(t, "", s.lookup(1, 3, base, base))
+
+	later := base + uint64(2*spanRetention)
+	s.add(newSpanEvent(1, 4, later, later+10, "new"))
+	assert.Equal(t, "", s.lookup(1, 2, base, base+10))
+	assert.Equal(t, "new", s.lookup(1, 4, later, later+10))
+	assert.Len(t, s.threads, 1)
+}
@@ -3,6 +3,7 @@ package tcpconnectanalyzer
 import (
 	"context"
 	"sync"
+	"sync/atomic"
 
 	"go.opentelemetry.io/otel/metric"
 
@@ -11,14 +12,19 @@ import (
 
 var once sync.Once
 
+// monitorInUse is the monitor observed by the gauge, which is replaced when the analyzer
+// is rebuilt with a new configuration.
+var monitorInUse atomic.Value
+
 const mapSizeMetric = "kindling_telemetry_tcpconnectanalyzer_map_size"
 
 func newSelfMetrics(meterProvider metric.MeterProvider, monitor *internal.ConnectMonitor) {
+	monitorInUse.Store(monitor)
 	once.Do(func() {
 		meter := metric.Must(meterProvider.Meter("kindling"))
 		meter.NewInt64GaugeObserver(mapSizeMetric,
 			func(ctx context.Context, result metric.Int64ObserverResult) {
-				result.Observe(int64(monitor.GetMapSize()))
+				result.Observe(int64(monitorInUse.Load().(*internal.ConnectMonitor).GetMapSize()))
 			}, metric.WithDescription("The current number of the connections stored in the map."))
 	})
 }
@@ -0,0 +1,362 @@
+package cameraexporter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component"
+	"github.com/Kindling-project/kindling/collector/pkg/filepathhelper"
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constlabels"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constnames"
+)
+
+const (
+	compressedFileSuffix = ".gz"
+	// retentionInterval is how often the files are checked against MaxAge.
+	retentionInterval = time.Minute
+)
+
+const (
+	evictReasonMaxBytes             = "max_bytes"
+	evictReasonMaxBytesEachWorkload = "max_bytes_each_workload"
+	evictReasonMaxAge               = "max_age"
+)
+
+// storedFile is a trace file tracked by compressedFileWriter.
+type storedFile struct {
+	path     string
+	workload string
+	size     int64
+	modTime  time.Time
+	// The elements in the lists of all the files and the files of the workload.
+	allElem      *list.Element
+	workloadElem *list.Element
+}
+
+type workloadFiles struct {
+	files *list.List
+	bytes int64
+}
+
+// compressedFileWriter writes the same directories and files as fileWriter, but the
+// files are compressed with gzip. The CPU events are appended as new gzip members,
+// which can be read as one stream. The disk usage is bounded by the byte quotas and
+// the max age, and the oldest files are evicted first.
+type compressedFileWriter struct {
+	config *compressedFileConfig
+	logger *component.TelemetryLogger
+	leases *fileLeases
+
+	mu    sync.Mutex
+	files map[string]*storedFile
+	// all contains all the files from the oldest one to the newest one.
+	all       *list.List
+	workloads map[string]*workloadFiles
+	bytes     int64
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+func newCompressedFileWriter(cfg *compressedFileConfig, leases *fileLeases, logger *component.TelemetryLogger) (*compressedFileWriter, error) {
+	if !path.IsAbs(cfg.StoragePath) {
+		return nil, fmt.Errorf("storage_path must be an absolute path")
+	}
+	if err := os.MkdirAll(cfg.StoragePath, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("failed to create compressedFileWriter: %w", err)
+	}
+	fw := &compressedFileWriter{
+		config:    cfg,
+		logger:    logger,
+		leases:    leases,
+		files:     make(map[string]*storedFile),
+		all:       list.New(),
+		workloads: make(map[string]*workloadFiles),
+		stopCh:    make(chan struct{}),
+	}
+	if err := fw.load(); err != nil {
+		return nil, fmt.Errorf("failed to load the stored files: %w", err)
+	}
+	fw.mu.Lock()
+	fw.evict()
+	fw.mu.Unlock()
+	if cfg.MaxAge > 0 {
+		fw.wg.Add(1)
+		go fw.runRetention()
+	}
+	return fw, nil
+}
+
+// load tracks the compressed files written before. The uncompressed files are ignored.
+func (fw *compressedFileWriter) load() error {
+	dirs, err := os.ReadDir(fw.config.StoragePath)
+	if err != nil {
+		return err
+	}
+	var files []*storedFile
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		p, ok := parseProcessDir(dir.Name())
+		if !ok {
+			continue
+		}
+		entries, err := os.ReadDir(filepath.Join(fw.config.StoragePath, dir.Name()))
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || !strings.HasSuffix(entry.Name(), compressedFileSuffix) {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			files = append(files, &storedFile{
+				path:     filepath.Join(fw.config.StoragePath, dir.Name(), entry.Name()),
+				workload: p.Workload,
+				size:     info.Size(),
+				modTime:  info.ModTime(),
+			})
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.Before(files[j].modTime)
+	})
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	for _, f := range files {
+		fw.track(f)
+	}
+	return nil
+}
+
+func (fw *compressedFileWriter) write(group *model.DataGroup) {
+	switch group.Name {
+	case constnames.SingleNetRequestMetricGroup, constnames.SpanEvent:
+		fw.writeTrace(group)
+	case constnames.CameraEventGroupName:
+		fw.writeCpuEvents(group)
+	}
+}
+
+func (fw *compressedFileWriter) filePath(elements filepathhelper.FilePathElements) (string, string) {
+	baseDir := filepath.Join(fw.config.StoragePath,
+		pidDirName(elements.WorkloadName, elements.PodName, elements.ContainerName, elements.Pid))
+	fileName := getFileName(elements.Protocol, elements.ContentKey, elements.Timestamp, elements.IsServer) + compressedFileSuffix
+	return baseDir, filepath.Join(baseDir, fileName)
+}
+
+func (fw *compressedFileWriter) writeTrace(group *model.DataGroup) {
+	elements := filepathhelper.GetFilePathElements(group, group.Timestamp)
+	baseDir, filePath := fw.filePath(elements)
+	data, err := compress(nil, group)
+	if err != nil {
+		fw.logger.Errorf("Failed to compress the trace: %v", err)
+		return
+	}
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	if err := os.MkdirAll(baseDir, os.ModePerm); err != nil {
+		fw.logger.Errorf("Failed to create pid directory: %v", err)
+		return
+	}
+	if err := os.WriteFile(filePath, data, 0644); err != nil {
+		fw.logger.Errorf("Failed to write trace to file: %v", err)
+		return
+	}
+	fw.logger.Debugf("Create a trace file at [%s]", filePath)
+	if f, ok := fw.files[filePath]; ok {
+		// The file is overwritten.
+		fw.untrack(f)
+	}
+	fw.track(&storedFile{
+		path:     filePath,
+		workload: elements.WorkloadName,
+		size:     int64(len(data)),
+		modTime:  time.Now(),
+	})
+	fw.evict()
+}
+
+func (fw *compressedFileWriter) writeCpuEvents(group *model.DataGroup) {
+	traceTimestamp := group.Labels.GetIntValue(constlabels.Timestamp)
+	elements := filepathhelper.GetFilePathElements(group, uint64(traceTimestamp))
+	_, filePath := fw.filePath(elements)
+	data, err := compress([]byte(dividingLine), group)
+	if err != nil {
+		fw.logger.Errorf("Failed to compress the CpuEvents: %v", err)
+		return
+	}
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	stored, ok := fw.files[filePath]
+	if !ok {
+		fw.logger.Infof("Couldn't find the trace file %s when append CpuEvents. "+
+			"Maybe the file has been evicted.", filePath)
+		return
+	}
+	f, err := os.OpenFile(filePath, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		fw.logger.Infof("Couldn't open the trace file %s when append CpuEvents: %v", filePath, err)
+		return
+	}
+	defer func(f *os.File) {
+		if err := f.Close(); err != nil {
+			fw.logger.Warnf("Failed to close the file %s, %v", filePath, err)
+		}
+	}(f)
+	if _, err = f.Write(data); err != nil {
+		fw.logger.Errorf("Failed to append CpuEvents to the file %s: %v", filePath, err)
+		return
+	}
+	fw.resize(stored, stored.size+int64(len(data)))
+	fw.evict()
+	fw.logger.Debugf("Write CpuEvents to trace files [%s]", filePath)
+}
+
+// compress encodes the DataGroup following the prefix as a gzip member.
+func compress(prefix []byte, group *model.DataGroup) ([]byte, error) {
+	groupBytes, err := json.Marshal(group)
+	if err != nil {
+		return nil, fmt.Errorf("can't marshal DataGroup: %w", err)
+	}
+	var buf bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buf)
+	if _, err = gzipWriter.Write(prefix); err != nil {
+		return nil, err
+	}
+	if _, err = gzipWriter.Write(groupBytes); err != nil {
+		return nil, err
+	}
+	if err = gzipWriter.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (fw *compressedFileWriter) track(f *storedFile) {
+	fw.files[f.path] = f
+	f.allElem = fw.all.PushBack(f)
+	w, ok := fw.workloads[f.workload]
+	if !ok {
+		w = &workloadFiles{files: list.New()}
+		fw.workloads[f.workload] = w
+	}
+	f.workloadElem = w.files.PushBack(f)
+	w.bytes += f.size
+	fw.bytes += f.size
+}
+
+func (fw *compressedFileWriter) untrack(f *storedFile) {
+	delete(fw.files, f.path)
+	fw.all.Remove(f.allElem)
+	w := fw.workloads[f.workload]
+	w.files.Remove(f.workloadElem)
+	w.bytes -= f.size
+	if w.files.Len() == 0 {
+		delete(fw.workloads, f.workload)
+	}
+	fw.bytes -= f.size
+}
+
+func (fw *compressedFileWriter) resize(f *storedFile, size int64) {
+	fw.workloads[f.workload].bytes += size - f.size
+	fw.bytes += size - f.size
+	f.size = size
+}
+
+// evict removes the oldest files until all the quotas are satisfied. The files being
+// read are skipped. It must be called with fw.mu held.
+func (fw *compressedFileWriter) evict() {
+	if fw.config.MaxAge > 0 {
+		deadline := time.Now().Add(-fw.config.MaxAge)
+		fw.evictFrom(fw.all, evictReasonMaxAge, func(f *storedFile) bool {
+			return f.modTime.Before(deadline)
+		})
+	}
+	if fw.config.MaxBytesEachWorkload > 0 {
+		for _, w := range fw.workloads {
+			w := w
+			fw.evictFrom(w.files, evictReasonMaxBytesEachWorkload, func(*storedFile) bool {
+				return w.bytes > fw.config.MaxBytesEachWorkload
+			})
+		}
+	}
+	if fw.config.MaxBytes > 0 {
+		fw.evictFrom(fw.all, evictReasonMaxBytes, func(*storedFile) bool {
+			return fw.bytes > fw.config.MaxBytes
+		})
+	}
+}
+
+// evictFrom removes the files in the list from the oldest one while shouldEvict returns true.
+func (fw *compressedFileWriter) evictFrom(files *list.List, reason string, shouldEvict func(f *storedFile) bool) {
+	for e := files.Front(); e != nil; {
+		f := e.Value.(*storedFile)
+		if !shouldEvict(f) {
+			return
+		}
+		e = e.Next()
+		removed, err := fw.leases.removeIfUnused(f.path)
+		if !removed {
+			continue
+		}
+		if err != nil {
+			fw.logger.Warnf("Failed to evict the trace file %s: %v", f.path, err)
+			continue
+		}
+		fw.untrack(f)
+		// Remove the directory of the process if it is empty.
+		_ = os.Remove(filepath.Dir(f.path))
+		evictedFilesCounter.Add(context.Background(), 1, attribute.String("reason", reason))
+		fw.logger.Debugf("Evict the trace file [%s] because of %s", f.path, reason)
+	}
+}
+
+func (fw *compressedFileWriter) runRetention() {
+	defer fw.wg.Done()
+	ticker := time.NewTicker(retentionInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			fw.mu.Lock()
+			fw.evict()
+			fw.mu.Unlock()
+		case <-fw.stopCh:
+			return
+		}
+	}
+}
+
+func (fw *compressedFileWriter) storedBytes() int64 {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	return fw.bytes
+}
+
+func (fw *compressedFileWriter) close() {
+	close(fw.stopCh)
+	fw.wg.Wait()
+}
+
+func (fw *compressedFileWriter) name() string {
+	return storageCompressedFile
+}
@@ -0,0 +1,130 @@
+package cameraexporter
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component"
+	"github.com/Kindling-project/kindling/collector/pkg/filepathhelper"
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constlabels"
+)
+
+func newTestCompressedFileWriter(t *testing.T, cfg *compressedFileConfig) (*compressedFileWriter, *fileLeases) {
+	telemetry := component.NewDefaultTelemetryTools()
+	newSelfMetrics(telemetry.MeterProvider)
+	if cfg.StoragePath == "" {
+		cfg.StoragePath = t.TempDir()
+	}
+	leases := newFileLeases()
+	writer, err := newCompressedFileWriter(cfg, leases, telemetry.Logger)
+	assert.NoError(t, err)
+	t.Cleanup(writer.close)
+	return writer, leases
+}
+
+func workloadTrace(workload string, timestamp uint64) *model.DataGroup {
+	trace := queryTraceData(100, timestamp, false)
+	trace.Labels.AddStringValue(constlabels.DstWorkloadName, workload)
+	return trace
+}
+
+func storedPath(writer *compressedFileWriter, trace *model.DataGroup) string {
+	_, filePath := writer.filePath(filepathhelper.GetFilePathElements(trace, trace.Timestamp))
+	return filePath
+}
+
+func TestCompressedFileWriterReadBack(t *testing.T) {
+	writer, leases := newTestCompressedFileWriter(t, &compressedFileConfig{})
+	trace := queryTraceData(100, baseTimestamp, true)
+	writer.write(trace)
+	elements := filepathhelper.GetFilePathElements(trace, trace.Timestamp)
+	for j := 0; j < 3; j++ {
+		events := cpuEvent(int64(baseTimestamp+j*1e9), elements.ToAttributes())
+		events.Labels.AddIntValue(constlabels.Pid, elements.Pid)
+		writer.write(events)
+	}
+	info, err := os.Stat(storedPath(writer, trace))
+	assert.NoError(t, err)
+	assert.Equal(t, info.Size(), writer.storedBytes())
+
+	reader := newFileReader(writer.config.StoragePath, leases)
+	traces, err := reader.traces(context.Background(), &TraceFilter{IsSlow: &[]bool{true}[0]})
+	assert.NoError(t, err)
+	assert.Len(t, traces, 1)
+	detail, err := reader.trace(context.Background(), traces[0].Id)
+	assert.NoError(t, err)
+	assert.Equal(t, trace.Timestamp, detail.Trace.Timestamp)
+	assert.Len(t, detail.CpuEvents, 3)
+}
+
+func TestCompressedFileWriterQuotas(t *testing.T) {
+	writer, _ := newTestCompressedFileWriter(t, &compressedFileConfig{})
+	writer.write(workloadTrace("a", baseTimestamp))
+	// The sizes of the compressed files differ slightly.
+	size := writer.storedBytes()
+	writer.config.MaxBytesEachWorkload = 3*size + size/2
+	writer.config.MaxBytes = 5*size + size/2
+
+	var traces []*model.DataGroup
+	for i := 1; i < 5; i++ {
+		trace := workloadTrace("a", uint64(baseTimestamp+i*1e9))
+		traces = append(traces, trace)
+		writer.write(trace)
+	}
+	// Only the latest 3 files of workload "a" are kept.
+	assert.Equal(t, 3, writer.workloads["a"].files.Len())
+	assert.LessOrEqual(t, writer.storedBytes(), writer.config.MaxBytesEachWorkload)
+	assert.NoFileExists(t, storedPath(writer, traces[0]))
+	assert.FileExists(t, storedPath(writer, traces[1]))
+
+	for i := 0; i < 3; i++ {
+		writer.write(workloadTrace("b", uint64(baseTimestamp+(10+i)*1e9)))
+	}
+	// The oldest file of workload "a" is evicted because of the global quota.
+	assert.LessOrEqual(t, writer.storedBytes(), writer.config.MaxBytes)
+	assert.NoFileExists(t, storedPath(writer, traces[1]))
+	assert.Equal(t, 2, writer.workloads["a"].files.Len())
+	assert.Equal(t, 3, writer.workloads["b"].files.Len())
+}
+
+func TestCompressedFileWriterSkipsFilesBeingRead(t *testing.T) {
+	writer, leases := newTestCompressedFileWriter(t, &compressedFileConfig{})
+	oldest := workloadTrace("a", baseTimestamp)
+	writer.write(oldest)
+	writer.config.MaxBytes = writer.storedBytes() * 3 / 2
+
+	leases.acquire(storedPath(writer, oldest))
+	newer := workloadTrace("a", baseTimestamp+1e9)
+	writer.write(newer)
+	assert.FileExists(t, storedPath(writer, oldest))
+	// The newer file is evicted instead.
+	assert.NoFileExists(t, storedPath(writer, newer))
+
+	leases.release(storedPath(writer, oldest))
+	latest := workloadTrace("a", baseTimestamp+2e9)
+	writer.write(latest)
+	assert.NoFileExists(t, storedPath(writer, oldest))
+	assert.FileExists(t, storedPath(writer, latest))
+}
+
+func TestCompressedFileWriterMaxAgeAndReload(t *testing.T) {
+	writer, _ := newTestCompressedFileWriter(t, &compressedFileConfig{})
+	old := workloadTrace("a", baseTimestamp)
+	writer.write(old)
+	writer.config.MaxAge = 50 * time.Millisecond
+	time.Sleep(100 * time.Millisecond)
+	latest := workloadTrace("a", baseTimestamp+1e9)
+	writer.write(latest)
+	assert.NoFileExists(t, storedPath(writer, old))
+	assert.FileExists(t, storedPath(writer, latest))
+
+	// The files written before are tracked after restarting.
+	reloaded, _ := newTestCompressedFileWriter(t, &compressedFileConfig{StoragePath: writer.config.StoragePath})
+	assert.Equal(t, writer.storedBytes(), reloaded.storedBytes())
+	assert.Len(t, reloaded.files, 1)
+}
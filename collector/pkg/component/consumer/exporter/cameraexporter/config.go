@@ -1,14 +1,21 @@
 package cameraexporter
 
+import "time"
+
 const (
-	storageFile          = "file"
-	storageElasticsearch = "elasticsearch"
+	storageFile           = "file"
+	storageCompressedFile = "compressed_file"
+	storageElasticsearch  = "elasticsearch"
 )
 
 type Config struct {
 	Storage    string      `mapstructure:"storage"`
 	EsConfig   *esConfig   `mapstructure:"es_config"`
 	FileConfig *fileConfig `mapstructure:"file_config"`
+	// CompressedFileConfig is effective when the storage is "compressed_file".
+	CompressedFileConfig *compressedFileConfig `mapstructure:"compressed_file_config"`
+	// QueryConfig is the configuration of the HTTP service querying the stored data.
+	QueryConfig *queryConfig `mapstructure:"query_config"`
 }
 
 type esConfig struct {
@@ -23,6 +30,27 @@ type fileConfig struct {
 	MaxFileCountEachProcess int `mapstructure:"max_file_count_each_process"`
 }
 
+// compressedFileConfig bounds the disk usage by bytes and age instead of the file count.
+// The files are evicted from the oldest one, except the ones being read.
+type compressedFileConfig struct {
+	// StoragePath is the ABSOLUTE path of the directory where the profile file should be saved
+	StoragePath string `mapstructure:"storage_path"`
+	// MaxBytes is the quota of all the files. 0 means no limit.
+	MaxBytes int64 `mapstructure:"max_bytes"`
+	// MaxBytesEachWorkload is the quota of the files of each workload. 0 means no limit.
+	MaxBytesEachWorkload int64 `mapstructure:"max_bytes_each_workload"`
+	// MaxAge is how long the files are kept. 0 means forever.
+	MaxAge time.Duration `mapstructure:"max_age"`
+}
+
+const defaultQueryListenAddress = ":9505"
+
+type queryConfig struct {
+	Enable bool `mapstructure:"enable"`
+	// ListenAddress is the address the query service listens on, e.g. ":9505".
+	ListenAddress string `mapstructure:"listen_address"`
+}
+
 func NewDefaultConfig() *Config {
 	return &Config{
 		Storage: storageFile,
@@ -30,5 +58,15 @@ func NewDefaultConfig() *Config {
 			StoragePath:             "/tmp/kindling/",
 			MaxFileCountEachProcess: 50,
 		},
+		CompressedFileConfig: &compressedFileConfig{
+			StoragePath:          "/tmp/kindling/",
+			MaxBytes:             1 << 30,
+			MaxBytesEachWorkload: 256 << 20,
+			MaxAge:               24 * time.Hour,
+		},
+		QueryConfig: &queryConfig{
+			Enable:        false,
+			ListenAddress: defaultQueryListenAddress,
+		},
 	}
 }
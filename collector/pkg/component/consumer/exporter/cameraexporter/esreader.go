@@ -0,0 +1,217 @@
+package cameraexporter
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/olivere/elastic/v6"
+
+	"github.com/Kindling-project/kindling/collector/pkg/esclient"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constlabels"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constnames"
+)
+
+const (
+	// maxProcesses is the maximum number of the processes returned from Elasticsearch.
+	maxProcesses = 1000
+	// maxCpuEvents is the maximum number of the camera_event_groups of one trace.
+	maxCpuEvents = 1000
+	// defaultTraceLimit is used when the filter doesn't limit the number of traces.
+	defaultTraceLimit = 100
+)
+
+// esReader reads the documents indexed by esWriter. Each DataGroup is a document in
+// the index named after the DataGroup, and its labels are under the field "labels".
+type esReader struct {
+	config   *esConfig
+	esClient *esclient.EsClient
+}
+
+func newEsReader(cfg *esConfig, client *esclient.EsClient) *esReader {
+	return &esReader{
+		config:   cfg,
+		esClient: client,
+	}
+}
+
+func (er *esReader) index(groupName string) string {
+	if er.config.IndexSuffix != "" {
+		return groupName + "_" + er.config.IndexSuffix
+	}
+	return groupName
+}
+
+func (er *esReader) traceIndices() []string {
+	return []string{er.index(constnames.SingleNetRequestMetricGroup), er.index(constnames.SpanEvent)}
+}
+
+func labelField(key string) string {
+	return "labels." + key
+}
+
+// processes returns the processes of the latest trace for each pid. Note the processes
+// with the same pid in different containers are counted as one.
+func (er *esReader) processes(ctx context.Context) ([]Process, error) {
+	agg := elastic.NewTermsAggregation().Field(labelField(constlabels.Pid)).Size(maxProcesses).
+		SubAggregation("latest", elastic.NewTopHitsAggregation().Size(1).Sort("timestamp", false))
+	result, err := er.esClient.Search(er.traceIndices()...).Size(0).Aggregation("pids", agg).Do(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("can't query the processes: %w", err)
+	}
+	ret := make([]Process, 0)
+	pids, ok := result.Aggregations.Terms("pids")
+	if !ok {
+		return ret, nil
+	}
+	for _, bucket := range pids.Buckets {
+		latest, ok := bucket.TopHits("latest")
+		if !ok || latest.Hits == nil || len(latest.Hits.Hits) == 0 || latest.Hits.Hits[0].Source == nil {
+			continue
+		}
+		group, err := decodeDataGroup(*latest.Hits.Hits[0].Source)
+		if err != nil {
+			continue
+		}
+		ret = append(ret, group.process())
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Pid < ret[j].Pid
+	})
+	return ret, nil
+}
+
+func (er *esReader) traces(ctx context.Context, filter *TraceFilter) ([]*TraceSummary, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultTraceLimit
+	}
+	result, err := er.esClient.Search(er.traceIndices()...).
+		Query(traceQuery(filter)).
+		Sort("timestamp", false).
+		Size(limit).
+		Do(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("can't query the traces: %w", err)
+	}
+	ret := make([]*TraceSummary, 0)
+	if result.Hits == nil {
+		return ret, nil
+	}
+	for _, hit := range result.Hits.Hits {
+		if hit.Source == nil {
+			continue
+		}
+		group, err := decodeDataGroup(*hit.Source)
+		if err != nil {
+			continue
+		}
+		ret = append(ret, group.summary(hit.Id))
+	}
+	return ret, nil
+}
+
+// traceQuery converts the filter to the query of Elasticsearch. The strings are matched
+// as phrases so that they work with both the keyword and the text mappings.
+func traceQuery(filter *TraceFilter) elastic.Query {
+	query := elastic.NewBoolQuery()
+	if filter.StartTime != 0 || filter.EndTime != 0 {
+		timeRange := elastic.NewRangeQuery("timestamp")
+		if filter.StartTime != 0 {
+			timeRange.Gte(filter.StartTime)
+		}
+		if filter.EndTime != 0 {
+			timeRange.Lte(filter.EndTime)
+		}
+		query.Filter(timeRange)
+	}
+	if filter.Pid != 0 {
+		query.Filter(elastic.NewTermQuery(labelField(constlabels.Pid), filter.Pid))
+	}
+	if filter.Protocol != "" {
+		query.Filter(elastic.NewMatchPhraseQuery(labelField(constlabels.Protocol), filter.Protocol))
+	}
+	if filter.ContentKey != "" {
+		query.Filter(elastic.NewMatchPhraseQuery(labelField(constlabels.ContentKey), filter.ContentKey))
+	}
+	if filter.IsSlow != nil {
+		query.Filter(elastic.NewTermQuery(labelField(constlabels.IsSlow), *filter.IsSlow))
+	}
+	if filter.IsError != nil {
+		query.Filter(elastic.NewTermQuery(labelField(constlabels.IsError), *filter.IsError))
+	}
+	// The workload, pod and container are the destination ones for the server side and
+	// the source ones for the client side.
+	if filter.Workload != "" {
+		query.Filter(sideQuery(constlabels.DstWorkloadName, constlabels.SrcWorkloadName, filter.Workload))
+	}
+	if filter.Pod != "" {
+		query.Filter(sideQuery(constlabels.DstPod, constlabels.SrcPod, filter.Pod))
+	}
+	if filter.Container != "" {
+		query.Filter(sideQuery(constlabels.DstContainer, constlabels.SrcContainer, filter.Container))
+	}
+	return query
+}
+
+func sideQuery(serverKey string, clientKey string, value string) elastic.Query {
+	return elastic.NewBoolQuery().MinimumNumberShouldMatch(1).Should(
+		elastic.NewBoolQuery().Filter(
+			elastic.NewTermQuery(labelField(constlabels.IsServer), true),
+			elastic.NewMatchPhraseQuery(labelField(serverKey), value)),
+		elastic.NewBoolQuery().Filter(
+			elastic.NewTermQuery(labelField(constlabels.IsServer), false),
+			elastic.NewMatchPhraseQuery(labelField(clientKey), value)),
+	)
+}
+
+func (er *esReader) trace(ctx context.Context, id string) (*TraceDetail, error) {
+	result, err := er.esClient.Search(er.traceIndices()...).Query(elastic.NewIdsQuery().Ids(id)).Size(1).Do(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("can't query the trace: %w", err)
+	}
+	if result.Hits == nil || len(result.Hits.Hits) == 0 || result.Hits.Hits[0].Source == nil {
+		return nil, errTraceNotFound
+	}
+	trace, err := decodeDataGroup(*result.Hits.Hits[0].Source)
+	if err != nil {
+		return nil, fmt.Errorf("can't decode the trace: %w", err)
+	}
+
+	result, err = er.esClient.Search(er.index(constnames.CameraEventGroupName)).
+		Query(cpuEventsQuery(trace)).
+		Sort(labelField("startTime"), true).
+		Size(maxCpuEvents).
+		Do(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("can't query the cpu events: %w", err)
+	}
+	detail := &TraceDetail{
+		Trace:     trace,
+		CpuEvents: make([]map[string]interface{}, 0),
+	}
+	if result.Hits == nil {
+		return detail, nil
+	}
+	for _, hit := range result.Hits.Hits {
+		if hit.Source == nil {
+			continue
+		}
+		group, err := decodeDataGroup(*hit.Source)
+		if err != nil {
+			continue
+		}
+		detail.CpuEvents = append(detail.CpuEvents, cpuEventsOf(group))
+	}
+	return detail, nil
+}
+
+// cpuEventsQuery matches the camera_event_groups of the trace. The CPU events carry the
+// pid and the timestamp of the trace as the file storage does.
+func cpuEventsQuery(trace *storedDataGroup) elastic.Query {
+	return elastic.NewBoolQuery().Filter(
+		elastic.NewTermQuery(labelField(constlabels.Pid), trace.intLabel(constlabels.Pid)),
+		elastic.NewTermQuery(labelField(constlabels.Timestamp), trace.Timestamp),
+		elastic.NewTermQuery(labelField(constlabels.IsServer), trace.boolLabel(constlabels.IsServer)),
+	)
+}
@@ -1,6 +1,7 @@
 package cameraexporter
 
 import (
+	"context"
 	"fmt"
 	"github.com/Kindling-project/kindling/collector/pkg/component"
 	"github.com/Kindling-project/kindling/collector/pkg/component/consumer/exporter"
@@ -15,6 +16,8 @@ const Type = "cameraexporter"
 type CameraExporter struct {
 	config *Config
 	writer writer
+	// queryServer is nil if the query service is not enabled.
+	queryServer *queryServer
 
 	telemetry *component.TelemetryTools
 }
@@ -25,6 +28,7 @@ func New(config interface{}, telemetry *component.TelemetryTools) exporter.Expor
 		config:    cfg,
 		telemetry: telemetry,
 	}
+	var reader reader
 	switch cfg.Storage {
 	case storageElasticsearch:
 		writer, err := newEsWriter(cfg.EsConfig)
@@ -32,12 +36,34 @@ func New(config interface{}, telemetry *component.TelemetryTools) exporter.Expor
 			telemetry.Logger.Panicf("Can't create new cameraexporter with eswriter: %v", err)
 		}
 		ret.writer = writer
+		reader = newEsReader(cfg.EsConfig, writer.esClient)
 	case storageFile:
 		writer, err := newFileWriter(cfg.FileConfig, telemetry.Logger)
 		if err != nil {
 			telemetry.Logger.Panicf("Can't create new cameraexporter with filewriter: %v", err)
 		}
 		ret.writer = writer
+		reader = newFileReader(cfg.FileConfig.StoragePath, newFileLeases())
+	case storageCompressedFile:
+		if cfg.CompressedFileConfig == nil {
+			telemetry.Logger.Panicf("Can't create new cameraexporter: compressed_file_config is required")
+		}
+		newSelfMetrics(telemetry.MeterProvider)
+		leases := newFileLeases()
+		writer, err := newCompressedFileWriter(cfg.CompressedFileConfig, leases, telemetry.Logger)
+		if err != nil {
+			telemetry.Logger.Panicf("Can't create new cameraexporter with compressedfilewriter: %v", err)
+		}
+		registerWriter(writer)
+		ret.writer = writer
+		reader = newFileReader(cfg.CompressedFileConfig.StoragePath, leases)
+	}
+	if cfg.QueryConfig != nil && cfg.QueryConfig.Enable && reader != nil {
+		if cfg.QueryConfig.ListenAddress == "" {
+			cfg.QueryConfig.ListenAddress = defaultQueryListenAddress
+		}
+		ret.queryServer = newQueryServer(cfg.QueryConfig, reader, telemetry)
+		ret.queryServer.start()
 	}
 	return ret
 }
@@ -50,6 +76,19 @@ func (e *CameraExporter) Consume(dataGroup *model.DataGroup) error {
 	return nil
 }
 
+// Shutdown stops the query service if it is enabled and the background goroutines of
+// the writer.
+func (e *CameraExporter) Shutdown() error {
+	if w, ok := e.writer.(*compressedFileWriter); ok {
+		unregisterWriter(w)
+		w.close()
+	}
+	if e.queryServer == nil {
+		return nil
+	}
+	return e.queryServer.shutdown(context.Background())
+}
+
 type writer interface {
 	write(dataGroup *model.DataGroup)
 	name() string
@@ -0,0 +1,331 @@
+package cameraexporter
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fileReader reads the files written by fileWriter and compressedFileWriter. The
+// directory of each process is named "workload_pod_container_pid" and each trace file
+// is named "date_protocol_base64(contentKey)_isServer", with the suffix ".gz" if it is
+// compressed. The file starts with the trace and is followed by the CPU events, which
+// are separated by dividingLine.
+type fileReader struct {
+	storagePath string
+	// leases keeps the files being read from being evicted.
+	leases *fileLeases
+
+	mu sync.Mutex
+	// index caches the summaries of the trace files so each file is only read once.
+	// It maps the directory name to the file names and their summaries.
+	index map[string]map[string]*TraceSummary
+}
+
+func newFileReader(storagePath string, leases *fileLeases) *fileReader {
+	return &fileReader{
+		storagePath: storagePath,
+		leases:      leases,
+		index:       make(map[string]map[string]*TraceSummary),
+	}
+}
+
+func (fr *fileReader) processes(_ context.Context) ([]Process, error) {
+	dirs, err := fr.processDirs()
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]Process, 0, len(dirs))
+	for _, p := range dirs {
+		ret = append(ret, p)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		a, b := ret[i], ret[j]
+		if a.Workload != b.Workload {
+			return a.Workload < b.Workload
+		}
+		if a.Pod != b.Pod {
+			return a.Pod < b.Pod
+		}
+		if a.Container != b.Container {
+			return a.Container < b.Container
+		}
+		return a.Pid < b.Pid
+	})
+	return ret, nil
+}
+
+// processDirs returns the directories of the processes keyed by their names.
+func (fr *fileReader) processDirs() (map[string]Process, error) {
+	entries, err := os.ReadDir(fr.storagePath)
+	if err != nil {
+		return nil, fmt.Errorf("can't read the storage path: %w", err)
+	}
+	ret := make(map[string]Process, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if p, ok := parseProcessDir(entry.Name()); ok {
+			ret[entry.Name()] = p
+		}
+	}
+	return ret, nil
+}
+
+func (fr *fileReader) traces(ctx context.Context, filter *TraceFilter) ([]*TraceSummary, error) {
+	dirs, err := fr.processDirs()
+	if err != nil {
+		return nil, err
+	}
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	// Forget the directories which have been removed.
+	for dir := range fr.index {
+		if _, ok := dirs[dir]; !ok {
+			delete(fr.index, dir)
+		}
+	}
+	ret := make([]*TraceSummary, 0)
+	for dir, p := range dirs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if !p.matches(&filter.Process) {
+			continue
+		}
+		summaries, err := fr.scanDir(dir, filter)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, summaries...)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Timestamp > ret[j].Timestamp
+	})
+	if filter.Limit > 0 && len(ret) > filter.Limit {
+		ret = ret[:filter.Limit]
+	}
+	return ret, nil
+}
+
+// scanDir returns the summaries of the matched traces in the directory. The files are
+// filtered by their names first, so only the files possibly matched are read.
+func (fr *fileReader) scanDir(dir string, filter *TraceFilter) ([]*TraceSummary, error) {
+	entries, err := os.ReadDir(filepath.Join(fr.storagePath, dir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("can't read the directory %s: %w", dir, err)
+	}
+	cached := fr.index[dir]
+	index := make(map[string]*TraceSummary, len(entries))
+	var ret []*TraceSummary
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		if s, ok := cached[name]; ok {
+			index[name] = s
+			if filter.matches(s) {
+				ret = append(ret, s)
+			}
+			continue
+		}
+		if !fileNameMatches(name, filter) {
+			continue
+		}
+		s, err := fr.readSummary(dir, name)
+		if err != nil {
+			// The file may be being written or rotated.
+			continue
+		}
+		index[name] = s
+		if filter.matches(s) {
+			ret = append(ret, s)
+		}
+	}
+	fr.index[dir] = index
+	return ret, nil
+}
+
+func (fr *fileReader) readSummary(dir string, name string) (*TraceSummary, error) {
+	f, err := fr.open(dir, name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := readFirstSection(f)
+	if err != nil {
+		return nil, err
+	}
+	group, err := decodeDataGroup(data)
+	if err != nil {
+		return nil, err
+	}
+	s := group.summary(dir + "/" + name)
+	// The directory name is used in case some labels are missing in the trace.
+	if p, ok := parseProcessDir(dir); ok {
+		s.Process = p
+	}
+	return s, nil
+}
+
+// readFirstSection reads the trace at the beginning of the file without reading the
+// CPU events.
+func readFirstSection(r io.Reader) ([]byte, error) {
+	var buf bytes.Buffer
+	reader := bufio.NewReader(r)
+	separator := []byte(dividingLine)
+	for {
+		line, err := reader.ReadBytes('\n')
+		buf.Write(line)
+		if bytes.HasSuffix(buf.Bytes(), separator) {
+			return buf.Bytes()[:buf.Len()-len(separator)], nil
+		}
+		if err == io.EOF {
+			return buf.Bytes(), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (fr *fileReader) trace(_ context.Context, id string) (*TraceDetail, error) {
+	dir, name, ok := strings.Cut(id, "/")
+	if !ok || !isPlainName(dir) || !isPlainName(name) {
+		return nil, errTraceNotFound
+	}
+	f, err := fr.open(dir, name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errTraceNotFound
+		}
+		return nil, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	// The compressed CPU events may be being appended, in which case the sections
+	// before them are still returned.
+	if err != nil && (len(data) == 0 || !errors.Is(err, io.ErrUnexpectedEOF)) {
+		return nil, fmt.Errorf("can't read the trace: %w", err)
+	}
+	sections := strings.Split(string(data), dividingLine)
+	trace, err := decodeDataGroup([]byte(sections[0]))
+	if err != nil {
+		return nil, fmt.Errorf("can't decode the trace: %w", err)
+	}
+	detail := &TraceDetail{
+		Trace:     trace,
+		CpuEvents: make([]map[string]interface{}, 0, len(sections)-1),
+	}
+	for _, section := range sections[1:] {
+		group, err := decodeDataGroup([]byte(section))
+		if err != nil {
+			// The events may be being appended.
+			continue
+		}
+		detail.CpuEvents = append(detail.CpuEvents, cpuEventsOf(group))
+	}
+	return detail, nil
+}
+
+// traceFile is a trace file being read. The file can't be evicted until it is closed.
+type traceFile struct {
+	io.Reader
+	file   *os.File
+	path   string
+	leases *fileLeases
+}
+
+// open opens the trace file and decompresses it if it is compressed.
+func (fr *fileReader) open(dir string, name string) (*traceFile, error) {
+	path := filepath.Join(fr.storagePath, dir, name)
+	fr.leases.acquire(path)
+	f, err := os.Open(path)
+	if err != nil {
+		fr.leases.release(path)
+		return nil, err
+	}
+	ret := &traceFile{Reader: f, file: f, path: path, leases: fr.leases}
+	if strings.HasSuffix(name, compressedFileSuffix) {
+		gzipReader, err := gzip.NewReader(f)
+		if err != nil {
+			_ = ret.Close()
+			return nil, err
+		}
+		ret.Reader = gzipReader
+	}
+	return ret, nil
+}
+
+func (f *traceFile) Close() error {
+	defer f.leases.release(f.path)
+	return f.file.Close()
+}
+
+// isPlainName checks whether the name is a file name without any path elements.
+func isPlainName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+}
+
+// parseProcessDir parses the directory name created by fileWriter.pidFilePath.
+func parseProcessDir(name string) (Process, bool) {
+	parts := strings.Split(name, "_")
+	if len(parts) != 4 {
+		return Process{}, false
+	}
+	pid, err := strconv.ParseInt(parts[3], 10, 64)
+	if err != nil {
+		return Process{}, false
+	}
+	return Process{Workload: parts[0], Pod: parts[1], Container: parts[2], Pid: pid}, true
+}
+
+// fileNameMatches checks the trace file name created by getFileName against the filter.
+// It returns true if the name can't be parsed so the file is checked after being read.
+func fileNameMatches(name string, filter *TraceFilter) bool {
+	parts := strings.Split(strings.TrimSuffix(name, compressedFileSuffix), "_")
+	if len(parts) < 4 {
+		return true
+	}
+	// The URL-safe base64 encoding may contain '_', so the content key is all the parts
+	// between the protocol and isServer.
+	if filter.Protocol != "" && parts[1] != filter.Protocol {
+		return false
+	}
+	if filter.ContentKey != "" {
+		contentKey, err := base64.URLEncoding.DecodeString(strings.Join(parts[2:len(parts)-1], "_"))
+		if err == nil && string(contentKey) != filter.ContentKey {
+			return false
+		}
+	}
+	// The date is in local time and truncated to seconds.
+	dateString, _, _ := strings.Cut(parts[0], ".")
+	date, err := time.ParseInLocation("20060102150405", dateString, time.Local)
+	if err != nil {
+		return true
+	}
+	if filter.EndTime != 0 && date.UnixNano() > filter.EndTime {
+		return false
+	}
+	if filter.StartTime != 0 && date.Add(time.Second).UnixNano() <= filter.StartTime {
+		return false
+	}
+	return true
+}
@@ -51,8 +51,11 @@ func (fw *fileWriter) write(group *model.DataGroup) {
 }
 
 func (fw *fileWriter) pidFilePath(workloadName string, podName string, containerName string, pid int64) string {
-	dirName := workloadName + "_" + podName + "_" + containerName + "_" + strconv.FormatInt(pid, 10)
-	return path.Join(fw.config.StoragePath, dirName)
+	return path.Join(fw.config.StoragePath, pidDirName(workloadName, podName, containerName, pid))
+}
+
+func pidDirName(workloadName string, podName string, containerName string, pid int64) string {
+	return workloadName + "_" + podName + "_" + containerName + "_" + strconv.FormatInt(pid, 10)
 }
 
 func getFileName(protocol string, contentKey string, timestamp uint64, isServer bool) string {
@@ -0,0 +1,47 @@
+package cameraexporter
+
+import (
+	"os"
+	"sync"
+)
+
+// fileLeases records the files being read, so the writer doesn't evict them until the
+// reader closes them.
+type fileLeases struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func newFileLeases() *fileLeases {
+	return &fileLeases{counts: make(map[string]int)}
+}
+
+func (l *fileLeases) acquire(path string) {
+	l.mu.Lock()
+	l.counts[path]++
+	l.mu.Unlock()
+}
+
+func (l *fileLeases) release(path string) {
+	l.mu.Lock()
+	if l.counts[path] <= 1 {
+		delete(l.counts, path)
+	} else {
+		l.counts[path]--
+	}
+	l.mu.Unlock()
+}
+
+// removeIfUnused removes the file unless it is being read. It returns false if the
+// file is being read.
+func (l *fileLeases) removeIfUnused(path string) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.counts[path] > 0 {
+		return false, nil
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return true, err
+	}
+	return true, nil
+}
@@ -0,0 +1,246 @@
+package cameraexporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/Kindling-project/kindling/collector/pkg/flamegraph"
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constlabels"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constvalues"
+)
+
+var errTraceNotFound = errors.New("trace not found")
+
+// reader reads the profiling data stored by the writer with the same storage.
+type reader interface {
+	// processes returns all the processes which have traces stored.
+	processes(ctx context.Context) ([]Process, error)
+	// traces returns the summaries of the traces matching the filter, the latest first.
+	traces(ctx context.Context, filter *TraceFilter) ([]*TraceSummary, error)
+	// trace returns the trace with the id together with its CPU events.
+	trace(ctx context.Context, id string) (*TraceDetail, error)
+}
+
+// Process is a process whose traces are stored. The names are "NoWorkloadName",
+// "NoPodName" or "NoContainerName" if they are unknown, which is the same as the
+// directory names of the file storage.
+type Process struct {
+	Workload  string `json:"workload"`
+	Pod       string `json:"pod"`
+	Container string `json:"container"`
+	Pid       int64  `json:"pid"`
+}
+
+// matches checks whether the process matches the filter. The empty fields of the
+// filter match any value.
+func (p *Process) matches(filter *Process) bool {
+	return (filter.Workload == "" || filter.Workload == p.Workload) &&
+		(filter.Pod == "" || filter.Pod == p.Pod) &&
+		(filter.Container == "" || filter.Container == p.Container) &&
+		(filter.Pid == 0 || filter.Pid == p.Pid)
+}
+
+// TraceFilter contains the conditions of the traces to be queried. The zero values
+// match all the traces.
+type TraceFilter struct {
+	Process
+	// StartTime and EndTime are the range of the trace timestamps in nanoseconds.
+	StartTime  int64
+	EndTime    int64
+	Protocol   string
+	ContentKey string
+	IsSlow     *bool
+	IsError    *bool
+	// Limit is the maximum number of the traces returned.
+	Limit int
+}
+
+func (f *TraceFilter) matches(s *TraceSummary) bool {
+	return s.Process.matches(&f.Process) &&
+		(f.StartTime == 0 || int64(s.Timestamp) >= f.StartTime) &&
+		(f.EndTime == 0 || int64(s.Timestamp) <= f.EndTime) &&
+		(f.Protocol == "" || f.Protocol == s.Protocol) &&
+		(f.ContentKey == "" || f.ContentKey == s.ContentKey) &&
+		(f.IsSlow == nil || *f.IsSlow == s.IsSlow) &&
+		(f.IsError == nil || *f.IsError == s.IsError)
+}
+
+// TraceSummary contains the fields of a trace used for listing and filtering.
+type TraceSummary struct {
+	// Id is used to get the trace. Its format depends on the storage.
+	Id string `json:"id"`
+	Process
+	Timestamp  uint64 `json:"timestamp"`
+	Protocol   string `json:"protocol"`
+	ContentKey string `json:"contentKey"`
+	IsServer   bool   `json:"isServer"`
+	IsSlow     bool   `json:"isSlow"`
+	IsError    bool   `json:"isError"`
+	// Duration is the total time of the request in nanoseconds.
+	Duration int64 `json:"duration"`
+}
+
+// TraceDetail is a trace together with the CPU events of its threads, which is the
+// same format camera-front uses.
+type TraceDetail struct {
+	Trace *storedDataGroup `json:"trace"`
+	// CpuEvents are the labels of the camera_event_groups. The fields encoded as JSON
+	// strings are decoded.
+	CpuEvents []map[string]interface{} `json:"cpuEvents"`
+}
+
+// storedDataGroup is a DataGroup decoded from the storage. The labels are kept as
+// they are because model.AttributeMap can't be decoded from JSON.
+type storedDataGroup struct {
+	Name      string                 `json:"name"`
+	Metrics   []storedMetric         `json:"metrics"`
+	Labels    map[string]interface{} `json:"labels"`
+	Timestamp uint64                 `json:"timestamp"`
+}
+
+type storedMetric struct {
+	Name string          `json:"Name"`
+	Data json.RawMessage `json:"Data"`
+}
+
+// decodeDataGroup decodes the DataGroup and keeps the numbers as they are, so the
+// timestamps in the labels don't lose precision.
+func decodeDataGroup(data []byte) (*storedDataGroup, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	group := &storedDataGroup{}
+	if err := decoder.Decode(group); err != nil {
+		return nil, err
+	}
+	if group.Labels == nil {
+		group.Labels = make(map[string]interface{})
+	}
+	return group, nil
+}
+
+func (g *storedDataGroup) stringLabel(key string) string {
+	value, _ := g.Labels[key].(string)
+	return value
+}
+
+func (g *storedDataGroup) boolLabel(key string) bool {
+	value, _ := g.Labels[key].(bool)
+	return value
+}
+
+func (g *storedDataGroup) intLabel(key string) int64 {
+	if value, ok := g.Labels[key].(json.Number); ok {
+		ret, _ := value.Int64()
+		return ret
+	}
+	return 0
+}
+
+func (g *storedDataGroup) intMetric(name string) int64 {
+	for _, metric := range g.Metrics {
+		if metric.Name != name {
+			continue
+		}
+		var data struct {
+			Value int64 `json:"Value"`
+		}
+		_ = json.Unmarshal(metric.Data, &data)
+		return data.Value
+	}
+	return 0
+}
+
+// process returns the process the DataGroup belongs to in the same way as
+// filepathhelper.GetFilePathElements.
+func (g *storedDataGroup) process() Process {
+	p := Process{Pid: g.intLabel(constlabels.Pid)}
+	if g.boolLabel(constlabels.IsServer) {
+		p.Workload = g.stringLabel(constlabels.DstWorkloadName)
+		p.Pod = g.stringLabel(constlabels.DstPod)
+		p.Container = g.stringLabel(constlabels.DstContainer)
+	} else {
+		p.Workload = g.stringLabel(constlabels.SrcWorkloadName)
+		p.Pod = g.stringLabel(constlabels.SrcPod)
+		p.Container = g.stringLabel(constlabels.SrcContainer)
+	}
+	if p.Workload == "" {
+		p.Workload = "NoWorkloadName"
+	}
+	if p.Pod == "" {
+		p.Pod = "NoPodName"
+	}
+	if p.Container == "" {
+		p.Container = "NoContainerName"
+	}
+	return p
+}
+
+func (g *storedDataGroup) summary(id string) *TraceSummary {
+	return &TraceSummary{
+		Id:         id,
+		Process:    g.process(),
+		Timestamp:  g.Timestamp,
+		Protocol:   g.stringLabel(constlabels.Protocol),
+		ContentKey: g.stringLabel(constlabels.ContentKey),
+		IsServer:   g.boolLabel(constlabels.IsServer),
+		IsSlow:     g.boolLabel(constlabels.IsSlow),
+		IsError:    g.boolLabel(constlabels.IsError),
+		Duration:   g.intMetric(constvalues.RequestTotalTime),
+	}
+}
+
+// jsonStringLabels are the labels of camera_event_group whose values are JSON strings.
+var jsonStringLabels = []string{"cpuEvents", "javaFutexEvents", "transactionIds", "spans", "innerCalls"}
+
+// cpuEventsOf returns the labels of the camera_event_group with the JSON strings decoded.
+func cpuEventsOf(group *storedDataGroup) map[string]interface{} {
+	for _, key := range jsonStringLabels {
+		value, ok := group.Labels[key].(string)
+		if !ok || !json.Valid([]byte(value)) {
+			continue
+		}
+		group.Labels[key] = json.RawMessage(value)
+	}
+	return group.Labels
+}
+
+// flameGraph aggregates the on-CPU stacks of the thread during the trace. The thread
+// handling the request is used if tid is negative, and all the threads are used if
+// tid is 0. The events which can't be decoded or whose tid can't be parsed are ignored.
+func (d *TraceDetail) flameGraph(tid int64) *flamegraph.Graph {
+	if tid < 0 {
+		tid = d.Trace.intLabel(constlabels.RequestTid)
+	}
+	startTime := d.Trace.Timestamp
+	endTime := startTime + uint64(d.Trace.intMetric(constvalues.RequestTotalTime))
+	fg := flamegraph.New()
+	for _, group := range d.CpuEvents {
+		tidNumber, ok := group[constlabels.Tid].(json.Number)
+		if !ok {
+			continue
+		}
+		eventTid, err := tidNumber.Int64()
+		if err != nil {
+			continue
+		}
+		if tid > 0 && eventTid != tid {
+			continue
+		}
+		raw, ok := group[constlabels.CpuEvents].(json.RawMessage)
+		if !ok {
+			continue
+		}
+		var events []model.CpuEvent
+		if err := json.Unmarshal(raw, &events); err != nil {
+			continue
+		}
+		threadName, _ := group[constlabels.ThreadName].(string)
+		for i := range events {
+			fg.AddCpuEvent(threadName, uint32(eventTid), &events[i], startTime, endTime)
+		}
+	}
+	return fg
+}
@@ -0,0 +1,254 @@
+package cameraexporter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component"
+	"github.com/Kindling-project/kindling/collector/pkg/flamegraph"
+)
+
+const (
+	processesPath  = "/camera/v1/processes"
+	tracesPath     = "/camera/v1/traces"
+	tracePath      = "/camera/v1/trace"
+	flameGraphPath = "/camera/v1/flamegraph"
+
+	// maxTraceLimit is the maximum number of traces returned at once.
+	maxTraceLimit = 1000
+)
+
+// queryServer serves the profiling data stored by cameraexporter over HTTP, so they
+// can be used without deploying camera-front. The responses are in the format of
+// {"success": bool, "data": ..., "message": string}.
+type queryServer struct {
+	reader    reader
+	telemetry *component.TelemetryTools
+	srv       *http.Server
+}
+
+type response struct {
+	Success bool        `json:"success"`
+	Data    interface{} `json:"data,omitempty"`
+	Message string      `json:"message,omitempty"`
+}
+
+func newQueryServer(cfg *queryConfig, reader reader, telemetry *component.TelemetryTools) *queryServer {
+	s := &queryServer{
+		reader:    reader,
+		telemetry: telemetry,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(processesPath, s.handleProcesses)
+	mux.HandleFunc(tracesPath, s.handleTraces)
+	mux.HandleFunc(tracePath, s.handleTrace)
+	mux.HandleFunc(flameGraphPath, s.handleFlameGraph)
+	s.srv = &http.Server{
+		Addr:              cfg.ListenAddress,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return s
+}
+
+func (s *queryServer) start() {
+	go func() {
+		s.telemetry.Logger.Infof("Camera query service listens on %s", s.srv.Addr)
+		if err := s.srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.telemetry.Logger.Error("Camera query service stopped", zap.Error(err))
+		}
+	}()
+}
+
+func (s *queryServer) shutdown(ctx context.Context) error {
+	return s.srv.Shutdown(ctx)
+}
+
+// handleProcesses lists the workloads, pods, containers and pids which have traces.
+func (s *queryServer) handleProcesses(w http.ResponseWriter, r *http.Request) {
+	processes, err := s.reader.processes(r.Context())
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	filter := Process{
+		Workload: r.URL.Query().Get("workload"),
+		Pod:      r.URL.Query().Get("pod"),
+	}
+	ret := make([]Process, 0, len(processes))
+	for _, p := range processes {
+		if p.matches(&filter) {
+			ret = append(ret, p)
+		}
+	}
+	s.writeData(w, ret)
+}
+
+// handleTraces lists the summaries of the traces matching the query parameters.
+func (s *queryServer) handleTraces(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseTraceFilter(r.URL.Query())
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	traces, err := s.reader.traces(r.Context(), filter)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	s.writeData(w, traces)
+}
+
+// handleTrace returns the trace with the id together with its CPU events.
+func (s *queryServer) handleTrace(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		s.writeError(w, http.StatusBadRequest, errors.New("id is required"))
+		return
+	}
+	trace, err := s.reader.trace(r.Context(), id)
+	if errors.Is(err, errTraceNotFound) {
+		s.writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	s.writeData(w, trace)
+}
+
+// handleFlameGraph returns the flame graph of the trace with the id as an attachment
+// in the collapsed format or the pprof format. The thread handling the request is used
+// unless the parameter "tid" is specified, in which 0 means all the threads.
+func (s *queryServer) handleFlameGraph(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	id := query.Get("id")
+	if id == "" {
+		s.writeError(w, http.StatusBadRequest, errors.New("id is required"))
+		return
+	}
+	format, err := flamegraph.ParseFormat(query.Get("format"))
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	tid := int64(-1)
+	if query.Get("tid") != "" {
+		if tid, err = parseIntParam(query, "tid"); err != nil {
+			s.writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	trace, err := s.reader.trace(r.Context(), id)
+	if errors.Is(err, errTraceNotFound) {
+		s.writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	fg := trace.flameGraph(tid)
+	if fg.Empty() {
+		s.writeError(w, http.StatusNotFound, errors.New("no on-CPU stacks found"))
+		return
+	}
+	if err := flamegraph.Serve(w, fg, format, "trace-"+strconv.FormatUint(trace.Trace.Timestamp, 10)); err != nil {
+		s.telemetry.Logger.Warn("Failed to write the flame graph", zap.Error(err))
+	}
+}
+
+// parseTraceFilter parses the query parameters. The times are Unix timestamps in
+// nanoseconds, which is the same as the timestamps of the traces.
+func parseTraceFilter(query url.Values) (*TraceFilter, error) {
+	filter := &TraceFilter{
+		Process: Process{
+			Workload:  query.Get("workload"),
+			Pod:       query.Get("pod"),
+			Container: query.Get("container"),
+		},
+		Protocol:   query.Get("protocol"),
+		ContentKey: query.Get("content_key"),
+		Limit:      defaultTraceLimit,
+	}
+	var err error
+	if filter.Pid, err = parseIntParam(query, "pid"); err != nil {
+		return nil, err
+	}
+	if filter.StartTime, err = parseIntParam(query, "start_time"); err != nil {
+		return nil, err
+	}
+	if filter.EndTime, err = parseIntParam(query, "end_time"); err != nil {
+		return nil, err
+	}
+	if filter.IsSlow, err = parseBoolParam(query, "is_slow"); err != nil {
+		return nil, err
+	}
+	if filter.IsError, err = parseBoolParam(query, "is_error"); err != nil {
+		return nil, err
+	}
+	limit, err := parseIntParam(query, "limit")
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 {
+		filter.Limit = int(limit)
+	}
+	if filter.Limit > maxTraceLimit {
+		filter.Limit = maxTraceLimit
+	}
+	return filter, nil
+}
+
+func parseIntParam(query url.Values, key string) (int64, error) {
+	value := query.Get(key)
+	if value == "" {
+		return 0, nil
+	}
+	ret, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %s", key, value)
+	}
+	return ret, nil
+}
+
+func parseBoolParam(query url.Values, key string) (*bool, error) {
+	value := query.Get(key)
+	if value == "" {
+		return nil, nil
+	}
+	ret, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %s", key, value)
+	}
+	return &ret, nil
+}
+
+func (s *queryServer) writeData(w http.ResponseWriter, data interface{}) {
+	s.write(w, http.StatusOK, &response{Success: true, Data: data})
+}
+
+func (s *queryServer) writeError(w http.ResponseWriter, status int, err error) {
+	s.write(w, status, &response{Success: false, Message: err.Error()})
+}
+
+func (s *queryServer) write(w http.ResponseWriter, status int, resp *response) {
+	body, err := json.Marshal(resp)
+	if err != nil {
+		s.telemetry.Logger.Error("Failed to encode the response", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
@@ -0,0 +1,223 @@
+package cameraexporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component"
+	"github.com/Kindling-project/kindling/collector/pkg/filepathhelper"
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constlabels"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constnames"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constvalues"
+)
+
+const baseTimestamp = 1660790515752561400
+
+func newTestQueryServer(t *testing.T) *httptest.Server {
+	telemetry := component.NewDefaultTelemetryTools()
+	cfg := &fileConfig{StoragePath: t.TempDir()}
+	writer, err := newFileWriter(cfg, telemetry.Logger)
+	assert.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		trace := queryTraceData(int64(100+i%2), uint64(baseTimestamp+i*1e9), i%3 == 0)
+		writer.writeTrace(trace)
+		elements := filepathhelper.GetFilePathElements(trace, trace.Timestamp)
+		for j := 0; j < 2; j++ {
+			events := cpuEvent(int64(baseTimestamp+j*1e9), elements.ToAttributes())
+			events.Labels.AddIntValue(constlabels.Pid, elements.Pid)
+			writer.writeCpuEvents(events)
+		}
+	}
+	server := newQueryServer(&queryConfig{}, newFileReader(cfg.StoragePath, newFileLeases()), telemetry)
+	return httptest.NewServer(server.srv.Handler)
+}
+
+func queryTraceData(pid int64, timestamp uint64, isSlow bool) *model.DataGroup {
+	labels := model.NewAttributeMap()
+	labels.AddIntValue(constlabels.Pid, pid)
+	labels.AddBoolValue(constlabels.IsServer, true)
+	labels.AddStringValue(constlabels.DstWorkloadName, "app")
+	labels.AddStringValue(constlabels.DstPod, "app-"+strconv.FormatInt(pid, 10))
+	labels.AddStringValue(constlabels.DstContainer, "main")
+	labels.AddStringValue(constlabels.Protocol, "http")
+	labels.AddStringValue(constlabels.ContentKey, "/cpu/hot")
+	labels.AddBoolValue(constlabels.IsSlow, isSlow)
+	return model.NewDataGroup(constnames.SingleNetRequestMetricGroup, labels, timestamp,
+		model.NewIntMetric(constvalues.RequestTotalTime, 1e9))
+}
+
+func getData(t *testing.T, rawURL string, data interface{}) int {
+	resp, err := http.Get(rawURL)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	ret := &response{Data: data}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(ret))
+	assert.Equal(t, resp.StatusCode == http.StatusOK, ret.Success)
+	return resp.StatusCode
+}
+
+func TestQueryProcesses(t *testing.T) {
+	server := newTestQueryServer(t)
+	defer server.Close()
+	var processes []Process
+	assert.Equal(t, http.StatusOK, getData(t, server.URL+processesPath, &processes))
+	assert.Equal(t, []Process{
+		{Workload: "app", Pod: "app-100", Container: "main", Pid: 100},
+		{Workload: "app", Pod: "app-101", Container: "main", Pid: 101},
+	}, processes)
+
+	processes = nil
+	assert.Equal(t, http.StatusOK, getData(t, server.URL+processesPath+"?pod=app-101", &processes))
+	assert.Len(t, processes, 1)
+}
+
+func TestQueryTraces(t *testing.T) {
+	server := newTestQueryServer(t)
+	defer server.Close()
+	tests := []struct {
+		name  string
+		query url.Values
+		want  int
+	}{
+		{"all", url.Values{}, 10},
+		{"pid", url.Values{"pid": {"100"}}, 5},
+		{"pod", url.Values{"pod": {"app-101"}}, 5},
+		{"slow", url.Values{"is_slow": {"true"}}, 4},
+		{"not error", url.Values{"is_error": {"false"}}, 10},
+		{"protocol", url.Values{"protocol": {"mysql"}}, 0},
+		{"content key", url.Values{"content_key": {"/cpu/hot"}}, 10},
+		{"time range", url.Values{
+			"start_time": {strconv.FormatInt(baseTimestamp+2e9, 10)},
+			"end_time":   {strconv.FormatInt(baseTimestamp+5e9, 10)},
+		}, 4},
+		{"limit", url.Values{"limit": {"3"}}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var traces []*TraceSummary
+			assert.Equal(t, http.StatusOK, getData(t, server.URL+tracesPath+"?"+tt.query.Encode(), &traces))
+			assert.Len(t, traces, tt.want)
+			for i := 1; i < len(traces); i++ {
+				assert.Greater(t, traces[i-1].Timestamp, traces[i].Timestamp)
+			}
+		})
+	}
+
+	assert.Equal(t, http.StatusBadRequest, getData(t, server.URL+tracesPath+"?pid=abc", nil))
+}
+
+func TestQueryTrace(t *testing.T) {
+	server := newTestQueryServer(t)
+	defer server.Close()
+	var traces []*TraceSummary
+	getData(t, server.URL+tracesPath+"?limit=1", &traces)
+	latest := traces[0]
+	assert.Equal(t, uint64(baseTimestamp+9e9), latest.Timestamp)
+	assert.Equal(t, int64(1e9), latest.Duration)
+	assert.True(t, latest.IsServer)
+
+	var detail struct {
+		Trace     storedDataGroup          `json:"trace"`
+		CpuEvents []map[string]interface{} `json:"cpuEvents"`
+	}
+	assert.Equal(t, http.StatusOK, getData(t, server.URL+tracePath+"?id="+url.QueryEscape(latest.Id), &detail))
+	assert.Equal(t, constnames.SingleNetRequestMetricGroup, detail.Trace.Name)
+	assert.Equal(t, latest.Timestamp, detail.Trace.Timestamp)
+	assert.Len(t, detail.CpuEvents, 2)
+	for _, events := range detail.CpuEvents {
+		// The JSON strings are decoded.
+		assert.IsType(t, []interface{}{}, events["cpuEvents"])
+		assert.IsType(t, []interface{}{}, events["javaFutexEvents"])
+	}
+
+	assert.Equal(t, http.StatusNotFound, getData(t, server.URL+tracePath+"?id="+url.QueryEscape("../"+latest.Id), nil))
+	assert.Equal(t, http.StatusBadRequest, getData(t, server.URL+tracePath, nil))
+}
+
+func TestEsTraceQuery(t *testing.T) {
+	isSlow := true
+	source, err := traceQuery(&TraceFilter{
+		Process:   Process{Pid: 100, Workload: "app"},
+		StartTime: 1,
+		IsSlow:    &isSlow,
+	}).Source()
+	assert.NoError(t, err)
+	body, _ := json.Marshal(source)
+	assert.JSONEq(t, `{"bool":{"filter":[
+		{"range":{"timestamp":{"from":1,"include_lower":true,"include_upper":true,"to":null}}},
+		{"term":{"labels.pid":100}},
+		{"term":{"labels.is_slow":true}},
+		{"bool":{"minimum_should_match":"1","should":[
+			{"bool":{"filter":[{"term":{"labels.is_server":true}},{"match_phrase":{"labels.dst_workload_name":{"query":"app"}}}]}},
+			{"bool":{"filter":[{"term":{"labels.is_server":false}},{"match_phrase":{"labels.src_workload_name":{"query":"app"}}}]}}
+		]}}
+	]}}`, string(body))
+}
+
+func TestQueryFlameGraph(t *testing.T) {
+	telemetry := component.NewDefaultTelemetryTools()
+	cfg := &fileConfig{StoragePath: t.TempDir()}
+	writer, err := newFileWriter(cfg, telemetry.Logger)
+	assert.NoError(t, err)
+	trace := queryTraceData(100, baseTimestamp, true)
+	trace.Labels.AddIntValue(constlabels.RequestTid, 12)
+	writer.writeTrace(trace)
+	elements := filepathhelper.GetFilePathElements(trace, trace.Timestamp)
+	// Each thread is on CPU for 0.6s with the stack main;work during the request.
+	cpuEvents := fmt.Sprintf(`[{"startTime":%d,"endTime":%d,`+
+		`"typeSpecs":[600000000,400000000],"timeType":[0,2],"stack":"main#work|0-0-3-1-0#1-0-3-1-1"}]`,
+		uint64(baseTimestamp), uint64(baseTimestamp+1e9))
+	for _, tid := range []int64{12, 13} {
+		labels := elements.ToAttributes()
+		labels.AddIntValue(constlabels.Pid, elements.Pid)
+		labels.AddIntValue(constlabels.Tid, tid)
+		labels.AddStringValue(constlabels.ThreadName, "worker")
+		labels.AddStringValue(constlabels.CpuEvents, cpuEvents)
+		writer.writeCpuEvents(model.NewDataGroup(constnames.CameraEventGroupName, labels, baseTimestamp))
+	}
+	// The events whose tid can't be parsed are ignored.
+	labels := elements.ToAttributes()
+	labels.AddIntValue(constlabels.Pid, elements.Pid)
+	labels.AddStringValue(constlabels.Tid, "unknown")
+	labels.AddStringValue(constlabels.ThreadName, "worker")
+	labels.AddStringValue(constlabels.CpuEvents, cpuEvents)
+	writer.writeCpuEvents(model.NewDataGroup(constnames.CameraEventGroupName, labels, baseTimestamp))
+	server := httptest.NewServer(newQueryServer(&queryConfig{}, newFileReader(cfg.StoragePath, newFileLeases()), telemetry).srv.Handler)
+	defer server.Close()
+	var traces []*TraceSummary
+	getData(t, server.URL+tracesPath, &traces)
+	id := url.QueryEscape(traces[0].Id)
+
+	get := func(query string) (int, string) {
+		resp, err := http.Get(server.URL + flameGraphPath + "?id=" + id + query)
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+	// The thread handling the request is used by default.
+	status, body := get("")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "worker-12;main;work 600000000\n", body)
+	status, body = get("&tid=0")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "worker-12;main;work 600000000\nworker-13;main;work 600000000\n", body)
+	status, body = get("&format=pprof")
+	assert.Equal(t, http.StatusOK, status)
+	// The pprof profile is gzipped.
+	assert.Equal(t, "\x1f\x8b", body[:2])
+
+	status, _ = get("&tid=99")
+	assert.Equal(t, http.StatusNotFound, status)
+	status, _ = get("&format=svg")
+	assert.Equal(t, http.StatusBadRequest, status)
+}
@@ -0,0 +1,51 @@
+package cameraexporter
+
+import (
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	storedBytesMetric  = "kindling_telemetry_cameraexporter_stored_bytes"
+	evictedFilesMetric = "kindling_telemetry_cameraexporter_evicted_files_total"
+)
+
+var (
+	once      sync.Once
+	writersMu sync.RWMutex
+	writers   = make(map[*compressedFileWriter]struct{})
+
+	evictedFilesCounter metric.Int64Counter
+)
+
+func newSelfMetrics(meterProvider metric.MeterProvider) {
+	once.Do(func() {
+		meter := metric.Must(meterProvider.Meter("kindling"))
+		meter.NewInt64GaugeObserver(storedBytesMetric,
+			func(ctx context.Context, result metric.Int64ObserverResult) {
+				writersMu.RLock()
+				defer writersMu.RUnlock()
+				var bytes int64
+				for w := range writers {
+					bytes += w.storedBytes()
+				}
+				result.Observe(bytes)
+			}, metric.WithDescription("The current bytes of the compressed trace files on disk"))
+		evictedFilesCounter = meter.NewInt64Counter(evictedFilesMetric,
+			metric.WithDescription("The total number of the compressed trace files evicted"))
+	})
+}
+
+func registerWriter(w *compressedFileWriter) {
+	writersMu.Lock()
+	writers[w] = struct{}{}
+	writersMu.Unlock()
+}
+
+func unregisterWriter(w *compressedFileWriter) {
+	writersMu.Lock()
+	delete(writers, w)
+	writersMu.Unlock()
+}
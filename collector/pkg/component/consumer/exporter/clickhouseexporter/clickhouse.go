@@ -0,0 +1,164 @@
+package clickhouseexporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/atomic"
+	"go.uber.org/zap"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component"
+	"github.com/Kindling-project/kindling/collector/pkg/component/consumer/exporter"
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constnames"
+)
+
+const Type = "clickhouseexporter"
+
+type batch struct {
+	table *table
+	rows  bytes.Buffer
+	count int
+}
+
+// ClickHouseExporter writes the per-request records and the per-connection records
+// into ClickHouse tables, one row for each DataGroup. The rows are inserted in the
+// format of JSONEachRow, one INSERT statement for each table in a batch. It doesn't
+// cache the data, and the batching is done by the batcher which wraps it.
+type ClickHouseExporter struct {
+	cfg       *Config
+	telemetry *component.TelemetryTools
+	client    *client
+	// tables maps the name of the DataGroup to the table it is written into.
+	tables map[string]*table
+
+	schemaReady *atomic.Bool
+}
+
+func New(config interface{}, telemetry *component.TelemetryTools) exporter.Exporter {
+	cfg, ok := config.(*Config)
+	if !ok {
+		telemetry.Logger.Panic("Cannot convert Component config", zap.String("componentType", Type))
+	}
+	newSelfMetrics(telemetry.MeterProvider)
+	return &ClickHouseExporter{
+		cfg:       cfg,
+		telemetry: telemetry,
+		client:    newClient(cfg),
+		tables: map[string]*table{
+			constnames.SingleNetRequestMetricGroup: newRequestTable(cfg.RequestTable),
+			constnames.TcpConnectMetricGroupName:   newConnectTable(cfg.ConnectTable),
+		},
+		schemaReady: atomic.NewBool(!cfg.CreateSchema),
+	}
+}
+
+// Start creates the schema in advance. It is created again before inserting if it fails.
+func (e *ClickHouseExporter) Start() error {
+	if err := e.createSchema(); err != nil {
+		e.telemetry.Logger.Warn("Failed to create the schema of ClickHouse, will retry before inserting", zap.Error(err))
+	}
+	return nil
+}
+
+func (e *ClickHouseExporter) Consume(dataGroup *model.DataGroup) error {
+	if dataGroup == nil {
+		// no need consume
+		return nil
+	}
+	return e.ConsumeBatch([]*model.DataGroup{dataGroup})
+}
+
+// ConsumeBatch inserts the rows of the DataGroups. The rows which are refused by ClickHouse
+// are dropped and the error is marked as permanent. If the insert of a table fails because
+// of the network, a 5xx or a 429 response, the error is returned as is to be retried, which
+// may insert the rows of the other tables again.
+func (e *ClickHouseExporter) ConsumeBatch(dataGroups []*model.DataGroup) error {
+	var (
+		batches      []*batch
+		transientErr error
+		permanentErr error
+	)
+	for _, dataGroup := range dataGroups {
+		if dataGroup == nil {
+			continue
+		}
+		t, ok := e.tables[dataGroup.Name]
+		if !ok {
+			continue
+		}
+		row, err := json.Marshal(t.toRow(dataGroup))
+		if err != nil {
+			droppedRowsCounter.Add(context.Background(), 1,
+				attribute.String("table", t.name), attribute.String("reason", dropReasonEncodeFailed))
+			permanentErr = exporter.NewPermanentError(fmt.Errorf("can't encode the row of %s: %w", dataGroup.Name, err))
+			continue
+		}
+		var b *batch
+		for _, existing := range batches {
+			if existing.table == t {
+				b = existing
+				break
+			}
+		}
+		if b == nil {
+			b = &batch{table: t}
+			batches = append(batches, b)
+		}
+		b.rows.Write(row)
+		b.rows.WriteByte('\n')
+		b.count++
+	}
+	for _, b := range batches {
+		err := e.insert(b)
+		if err == nil {
+			insertedRowsCounter.Add(context.Background(), int64(b.count), attribute.String("table", b.table.name))
+			continue
+		}
+		err = fmt.Errorf("failed to insert %d rows into %s: %w", b.count, b.table.name, err)
+		var recoverable recoverableError
+		if errors.As(err, &recoverable) {
+			transientErr = err
+			continue
+		}
+		droppedRowsCounter.Add(context.Background(), int64(b.count),
+			attribute.String("table", b.table.name), attribute.String("reason", dropReasonInsertFailed))
+		permanentErr = exporter.NewPermanentError(err)
+	}
+	// The transient error takes precedence, so the data is retried instead of being dropped.
+	if transientErr != nil {
+		return transientErr
+	}
+	return permanentErr
+}
+
+func (e *ClickHouseExporter) insert(b *batch) error {
+	if err := e.createSchema(); err != nil {
+		return err
+	}
+	query := fmt.Sprintf("INSERT INTO `%s`.`%s` FORMAT JSONEachRow", e.cfg.Database, b.table.name)
+	return e.client.exec(context.Background(), query, b.rows.Bytes())
+}
+
+// createSchema creates the database and the tables if they don't exist. It does
+// nothing once it succeeds.
+func (e *ClickHouseExporter) createSchema() error {
+	if e.schemaReady.Load() {
+		return nil
+	}
+	ctx := context.Background()
+	if err := e.client.exec(ctx, fmt.Sprintf("CREATE DATABASE IF NOT EXISTS `%s`", e.cfg.Database), nil); err != nil {
+		return fmt.Errorf("can't create database %s: %w", e.cfg.Database, err)
+	}
+	for _, t := range e.tables {
+		if err := e.client.exec(ctx, t.createSQL(e.cfg.Database, e.cfg.TTLDays), nil); err != nil {
+			return fmt.Errorf("can't create table %s: %w", t.name, err)
+		}
+	}
+	e.schemaReady.Store(true)
+	return nil
+}
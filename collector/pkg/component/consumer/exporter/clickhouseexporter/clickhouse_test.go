@@ -0,0 +1,148 @@
+package clickhouseexporter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component"
+	"github.com/Kindling-project/kindling/collector/pkg/component/consumer/exporter"
+	"github.com/Kindling-project/kindling/collector/pkg/component/consumer/exporter/internal/testutil"
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constlabels"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constnames"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constvalues"
+)
+
+const requestInsert = "INSERT INTO `kindling`.`single_net_request` FORMAT JSONEachRow"
+
+// splitRequests returns the DDL statements and the rows inserted by each INSERT statement.
+func splitRequests(t *testing.T, requests []*testutil.Request) ([]string, map[string][]map[string]interface{}) {
+	var ddl []string
+	inserts := make(map[string][]map[string]interface{})
+	for _, req := range requests {
+		assert.Equal(t, "default", req.Header.Get("X-ClickHouse-User"))
+		query := req.URL.Query().Get("query")
+		if query == "" {
+			ddl = append(ddl, string(req.Body))
+			continue
+		}
+		scanner := bufio.NewScanner(bytes.NewReader(req.Body))
+		for scanner.Scan() {
+			row := make(map[string]interface{})
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &row))
+			inserts[query] = append(inserts[query], row)
+		}
+	}
+	return ddl, inserts
+}
+
+func newRequestDataGroup(i int64) *model.DataGroup {
+	labels := model.NewAttributeMap()
+	labels.AddIntValue(constlabels.Pid, i)
+	labels.AddStringValue(constlabels.Protocol, "http")
+	labels.AddStringValue(constlabels.ContentKey, "/test")
+	labels.AddBoolValue(constlabels.IsSlow, true)
+	labels.AddIntValue(constlabels.HttpStatusCode, 200)
+	return model.NewDataGroup(constnames.SingleNetRequestMetricGroup, labels, uint64(time.Unix(100, 123).UnixNano()),
+		model.NewIntMetric(constvalues.RequestTotalTime, i*10),
+		model.NewIntMetric("custom_time", 5))
+}
+
+func TestInsertRequests(t *testing.T) {
+	sink := testutil.NewHTTPSink(t)
+	cfg := NewDefaultConfig()
+	cfg.Endpoint = sink.URL
+	e := New(cfg, component.NewDefaultTelemetryTools()).(*ClickHouseExporter)
+
+	dataGroups := make([]*model.DataGroup, 0, 26)
+	for i := 0; i < 25; i++ {
+		dataGroups = append(dataGroups, newRequestDataGroup(int64(i)))
+	}
+	// The DataGroups which are not records are ignored.
+	dataGroups = append(dataGroups, model.NewDataGroup(constnames.AggregatedNetRequestMetricGroup, model.NewAttributeMap(), 0))
+	assert.NoError(t, e.ConsumeBatch(dataGroups))
+
+	ddl, inserts := splitRequests(t, sink.Requests())
+	assert.Len(t, inserts, 1)
+	rows := inserts[requestInsert]
+	require.Len(t, rows, 25)
+	for i, row := range rows {
+		assert.Equal(t, float64(i), row[constlabels.Pid])
+		assert.Equal(t, "http", row[constlabels.Protocol])
+		assert.Equal(t, true, row[constlabels.IsSlow])
+		assert.Equal(t, false, row[constlabels.IsError])
+		assert.Equal(t, "", row[constlabels.DstPod])
+		assert.Equal(t, float64(i*10), row[constvalues.RequestTotalTime])
+		assert.Equal(t, float64(0), row[constvalues.ConnectTime])
+		assert.Equal(t, "1970-01-01 00:01:40.000000123", row[timestampColumn])
+		assert.Equal(t, map[string]interface{}{constlabels.HttpStatusCode: "200"}, row[labelsColumn])
+		assert.Equal(t, map[string]interface{}{"custom_time": float64(5)}, row[metricsColumn])
+	}
+
+	// The schema is created before inserting.
+	require.Len(t, ddl, 3)
+	assert.Equal(t, "CREATE DATABASE IF NOT EXISTS `kindling`", ddl[0])
+	for _, statement := range ddl[1:] {
+		assert.True(t, strings.HasPrefix(statement, "CREATE TABLE IF NOT EXISTS `kindling`."))
+		assert.Contains(t, statement, "`labels` Map(String, String)")
+		assert.Contains(t, statement, "TTL toDateTime(timestamp) + INTERVAL 7 DAY")
+	}
+}
+
+func TestInsertConnects(t *testing.T) {
+	sink := testutil.NewHTTPSink(t)
+	cfg := NewDefaultConfig()
+	cfg.Endpoint = sink.URL
+	cfg.CreateSchema = false
+	e := New(cfg, component.NewDefaultTelemetryTools())
+
+	labels := model.NewAttributeMap()
+	labels.AddStringValue(constlabels.DstIp, "10.0.0.1")
+	labels.AddIntValue(constlabels.DstPort, 3306)
+	labels.AddBoolValue(constlabels.Success, true)
+	assert.NoError(t, e.Consume(model.NewDataGroup(constnames.TcpConnectMetricGroupName, labels, 0,
+		model.NewIntMetric(constnames.TcpConnectTotalMetric, 1),
+		model.NewIntMetric(constnames.TcpConnectDurationMetric, 1000))))
+
+	ddl, inserts := splitRequests(t, sink.Requests())
+	assert.Empty(t, ddl)
+	rows := inserts["INSERT INTO `kindling`.`tcp_connect` FORMAT JSONEachRow"]
+	require.Len(t, rows, 1)
+	assert.Equal(t, "10.0.0.1", rows[0][constlabels.DstIp])
+	assert.Equal(t, float64(3306), rows[0][constlabels.DstPort])
+	assert.Equal(t, true, rows[0][constlabels.Success])
+	assert.Equal(t, float64(1000), rows[0][constnames.TcpConnectDurationMetric])
+}
+
+func TestInsertErrors(t *testing.T) {
+	sink := testutil.NewHTTPSink(t)
+	cfg := NewDefaultConfig()
+	cfg.Endpoint = sink.URL
+	cfg.CreateSchema = false
+	e := New(cfg, component.NewDefaultTelemetryTools())
+
+	// The rows are retried after 5xx, and they are dropped after 4xx.
+	sink.SetResponder(func(*testutil.Request) (int, string) {
+		return http.StatusServiceUnavailable, "overloaded"
+	})
+	err := e.Consume(newRequestDataGroup(0))
+	require.Error(t, err)
+	assert.False(t, exporter.IsPermanentError(err))
+	assert.Contains(t, err.Error(), "503 Service Unavailable: overloaded")
+
+	sink.SetResponder(func(*testutil.Request) (int, string) {
+		return http.StatusBadRequest, "Cannot parse input"
+	})
+	err = e.Consume(newRequestDataGroup(0))
+	require.Error(t, err)
+	assert.True(t, exporter.IsPermanentError(err))
+	assert.Len(t, sink.Requests(), 2)
+}
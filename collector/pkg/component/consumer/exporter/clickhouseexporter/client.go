@@ -0,0 +1,70 @@
+package clickhouseexporter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// recoverableError means the request may succeed if it is retried later.
+type recoverableError struct {
+	error
+}
+
+// client talks to ClickHouse over its HTTP interface.
+type client struct {
+	endpoint   string
+	username   string
+	password   string
+	httpClient *http.Client
+}
+
+func newClient(cfg *Config) *client {
+	return &client{
+		endpoint:   cfg.Endpoint,
+		username:   cfg.Username,
+		password:   cfg.Password,
+		httpClient: &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+// exec runs the query. If data is not empty, it is sent as the body after the query,
+// which is how the rows of an INSERT statement are sent. A recoverableError is returned
+// if the request failed because of the network, a 5xx or a 429 response.
+func (c *client) exec(ctx context.Context, query string, data []byte) error {
+	var body io.Reader
+	target := c.endpoint
+	if len(data) > 0 {
+		target += "/?query=" + url.QueryEscape(query)
+		body = bytes.NewReader(data)
+	} else {
+		target += "/"
+		body = bytes.NewReader([]byte(query))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, body)
+	if err != nil {
+		return err
+	}
+	if c.username != "" {
+		req.Header.Set("X-ClickHouse-User", c.username)
+		req.Header.Set("X-ClickHouse-Key", c.password)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return recoverableError{err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	err = fmt.Errorf("clickhouse returned HTTP status %s: %s", resp.Status, bytes.TrimSpace(msg))
+	if resp.StatusCode/100 == 5 || resp.StatusCode == http.StatusTooManyRequests {
+		return recoverableError{err}
+	}
+	return err
+}
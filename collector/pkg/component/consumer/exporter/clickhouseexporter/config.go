@@ -0,0 +1,35 @@
+package clickhouseexporter
+
+import "time"
+
+type Config struct {
+	// Endpoint is the address of the ClickHouse HTTP interface.
+	Endpoint string        `mapstructure:"endpoint"`
+	Database string        `mapstructure:"database"`
+	Username string        `mapstructure:"username"`
+	Password string        `mapstructure:"password"`
+	Timeout  time.Duration `mapstructure:"timeout"`
+	// CreateSchema controls whether the database and the tables are created automatically.
+	CreateSchema bool `mapstructure:"create_schema"`
+	// TTLDays is the number of days the records are kept. 0 means forever.
+	// It only takes effect when the tables are created.
+	TTLDays int `mapstructure:"ttl_days"`
+	// RequestTable stores the single_net_request_metric_group DataGroups.
+	RequestTable string `mapstructure:"request_table"`
+	// ConnectTable stores the tcp_connect_metric_group DataGroups.
+	ConnectTable string `mapstructure:"connect_table"`
+}
+
+func NewDefaultConfig() *Config {
+	return &Config{
+		Endpoint:     "http://127.0.0.1:8123",
+		Database:     "kindling",
+		Username:     "default",
+		Password:     "",
+		Timeout:      10 * time.Second,
+		CreateSchema: true,
+		TTLDays:      7,
+		RequestTable: "single_net_request",
+		ConnectTable: "tcp_connect",
+	}
+}
@@ -0,0 +1,196 @@
+package clickhouseexporter
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constlabels"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constnames"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constvalues"
+)
+
+type columnType string
+
+const (
+	stringColumn columnType = "String"
+	int64Column  columnType = "Int64"
+	boolColumn   columnType = "Bool"
+)
+
+const (
+	timestampColumn = "timestamp"
+	// labelsColumn stores the labels that don't have their own columns.
+	labelsColumn = "labels"
+	// metricsColumn stores the int metrics that don't have their own columns.
+	metricsColumn = "metrics"
+
+	timestampLayout = "2006-01-02 15:04:05.999999999"
+)
+
+// column is a typed column whose value comes from the label or the metric with the same name.
+type column struct {
+	name string
+	typ  columnType
+}
+
+type table struct {
+	name          string
+	labelColumns  []column
+	metricColumns []column
+	// known contains the names of all the typed columns.
+	known map[string]struct{}
+}
+
+func newTable(name string, labelColumns []column, metricColumns []column) *table {
+	t := &table{
+		name:          name,
+		labelColumns:  labelColumns,
+		metricColumns: metricColumns,
+		known:         make(map[string]struct{}, len(labelColumns)+len(metricColumns)),
+	}
+	for _, c := range labelColumns {
+		t.known[c.name] = struct{}{}
+	}
+	for _, c := range metricColumns {
+		t.known[c.name] = struct{}{}
+	}
+	return t
+}
+
+// commonColumns are the labels shared by the request records and the connection records.
+var commonColumns = []column{
+	{constlabels.Pid, int64Column},
+	{constlabels.Comm, stringColumn},
+	{constlabels.ContainerId, stringColumn},
+	{constlabels.IsServer, boolColumn},
+	{constlabels.SrcIp, stringColumn},
+	{constlabels.SrcPort, int64Column},
+	{constlabels.DstIp, stringColumn},
+	{constlabels.DstPort, int64Column},
+	{constlabels.DnatIp, stringColumn},
+	{constlabels.DnatPort, int64Column},
+	{constlabels.SrcNode, stringColumn},
+	{constlabels.SrcNodeIp, stringColumn},
+	{constlabels.SrcNamespace, stringColumn},
+	{constlabels.SrcPod, stringColumn},
+	{constlabels.SrcWorkloadName, stringColumn},
+	{constlabels.SrcWorkloadKind, stringColumn},
+	{constlabels.SrcService, stringColumn},
+	{constlabels.SrcContainer, stringColumn},
+	{constlabels.SrcContainerId, stringColumn},
+	{constlabels.DstNode, stringColumn},
+	{constlabels.DstNodeIp, stringColumn},
+	{constlabels.DstNamespace, stringColumn},
+	{constlabels.DstPod, stringColumn},
+	{constlabels.DstWorkloadName, stringColumn},
+	{constlabels.DstWorkloadKind, stringColumn},
+	{constlabels.DstService, stringColumn},
+	{constlabels.DstContainer, stringColumn},
+	{constlabels.DstContainerId, stringColumn},
+}
+
+func newRequestTable(name string) *table {
+	labelColumns := append([]column{
+		{constlabels.Protocol, stringColumn},
+		{constlabels.ContentKey, stringColumn},
+		{constlabels.IsError, boolColumn},
+		{constlabels.ErrorType, int64Column},
+		{constlabels.IsSlow, boolColumn},
+		{constlabels.RequestTid, int64Column},
+		{constlabels.ResponseTid, int64Column},
+		{constlabels.EndTimestamp, int64Column},
+	}, commonColumns...)
+	return newTable(name, labelColumns, []column{
+		{constvalues.RequestTotalTime, int64Column},
+		{constvalues.ConnectTime, int64Column},
+		{constvalues.RequestSentTime, int64Column},
+		{constvalues.WaitingTtfbTime, int64Column},
+		{constvalues.ContentDownloadTime, int64Column},
+		{constvalues.RequestIo, int64Column},
+		{constvalues.ResponseIo, int64Column},
+	})
+}
+
+func newConnectTable(name string) *table {
+	labelColumns := append([]column{
+		{constlabels.Errno, int64Column},
+		{constlabels.Success, boolColumn},
+	}, commonColumns...)
+	return newTable(name, labelColumns, []column{
+		{constnames.TcpConnectTotalMetric, int64Column},
+		{constnames.TcpConnectDurationMetric, int64Column},
+	})
+}
+
+func (t *table) createSQL(database string, ttlDays int) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "CREATE TABLE IF NOT EXISTS `%s`.`%s` (\n", database, t.name)
+	fmt.Fprintf(&sb, "  `%s` DateTime64(9, 'UTC'),\n", timestampColumn)
+	for _, c := range t.labelColumns {
+		fmt.Fprintf(&sb, "  `%s` %s,\n", c.name, c.typ)
+	}
+	for _, c := range t.metricColumns {
+		fmt.Fprintf(&sb, "  `%s` %s,\n", c.name, c.typ)
+	}
+	fmt.Fprintf(&sb, "  `%s` Map(String, String),\n", labelsColumn)
+	fmt.Fprintf(&sb, "  `%s` Map(String, Int64)\n", metricsColumn)
+	sb.WriteString(") ENGINE = MergeTree()\n")
+	fmt.Fprintf(&sb, "PARTITION BY toDate(%s)\n", timestampColumn)
+	fmt.Fprintf(&sb, "ORDER BY (%s)", timestampColumn)
+	if ttlDays > 0 {
+		fmt.Fprintf(&sb, "\nTTL toDateTime(%s) + INTERVAL %d DAY", timestampColumn, ttlDays)
+	}
+	return sb.String()
+}
+
+// toRow converts the DataGroup to a row in the format of JSONEachRow. The values are
+// copied because the DataGroup may be reused after returning.
+func (t *table) toRow(dataGroup *model.DataGroup) map[string]interface{} {
+	row := make(map[string]interface{}, len(t.labelColumns)+len(t.metricColumns)+3)
+	row[timestampColumn] = time.Unix(0, int64(dataGroup.Timestamp)).UTC().Format(timestampLayout)
+	for _, c := range t.labelColumns {
+		row[c.name] = columnValue(c, dataGroup.Labels)
+	}
+	labels := make(map[string]string)
+	for k, v := range dataGroup.Labels.GetValues() {
+		if _, ok := t.known[k]; !ok {
+			labels[k] = v.ToString()
+		}
+	}
+	row[labelsColumn] = labels
+
+	for _, c := range t.metricColumns {
+		row[c.name] = int64(0)
+	}
+	metrics := make(map[string]int64)
+	for _, metric := range dataGroup.Metrics {
+		if metric.DataType() != model.IntMetricType {
+			continue
+		}
+		if _, ok := t.known[metric.Name]; ok {
+			row[metric.Name] = metric.GetInt().Value
+		} else {
+			metrics[metric.Name] = metric.GetInt().Value
+		}
+	}
+	row[metricsColumn] = metrics
+	return row
+}
+
+// columnValue converts the label to the type of the column. The zero value is used
+// if the label doesn't exist or has a different type.
+func columnValue(c column, labels *model.AttributeMap) interface{} {
+	switch c.typ {
+	case int64Column:
+		return labels.GetIntValue(c.name)
+	case boolColumn:
+		return labels.GetBoolValue(c.name)
+	default:
+		if value, ok := labels.GetValues()[c.name]; ok {
+			return value.ToString()
+		}
+		return ""
+	}
+}
@@ -0,0 +1,34 @@
+package clickhouseexporter
+
+import (
+	"sync"
+
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	insertedRowsMetric = "kindling_telemetry_clickhouseexporter_inserted_rows_total"
+	droppedRowsMetric  = "kindling_telemetry_clickhouseexporter_dropped_rows_total"
+)
+
+const (
+	dropReasonInsertFailed = "insert_failed"
+	dropReasonEncodeFailed = "encode_failed"
+)
+
+var once sync.Once
+
+var (
+	insertedRowsCounter metric.Int64Counter
+	droppedRowsCounter  metric.Int64Counter
+)
+
+func newSelfMetrics(meterProvider metric.MeterProvider) {
+	once.Do(func() {
+		meter := metric.Must(meterProvider.Meter("kindling"))
+		insertedRowsCounter = meter.NewInt64Counter(insertedRowsMetric,
+			metric.WithDescription("The total number of rows inserted into ClickHouse"))
+		droppedRowsCounter = meter.NewInt64Counter(droppedRowsMetric,
+			metric.WithDescription("The total number of rows dropped by clickhouseexporter"))
+	})
+}
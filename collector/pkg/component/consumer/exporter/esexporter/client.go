@@ -0,0 +1,130 @@
+package esexporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// recoverableError means the request may succeed if it is retried later.
+type recoverableError struct {
+	error
+}
+
+// document is a DataGroup encoded as the source of an Elasticsearch document.
+type document struct {
+	index  string
+	source []byte
+}
+
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int `json:"status"`
+		Error  *struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		} `json:"error"`
+	} `json:"items"`
+}
+
+// client talks to Elasticsearch over its HTTP API.
+type client struct {
+	host       string
+	username   string
+	password   string
+	httpClient *http.Client
+}
+
+func newClient(cfg *Config) *client {
+	return &client{
+		host:       strings.TrimSuffix(cfg.EsHost, "/"),
+		username:   cfg.Username,
+		password:   cfg.Password,
+		httpClient: &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+// putTemplate creates or replaces the legacy index template.
+func (c *client) putTemplate(ctx context.Context, name string, template []byte) error {
+	_, err := c.do(ctx, http.MethodPut, "/_template/"+name, "application/json", template)
+	return err
+}
+
+// bulk indexes the documents with one bulk request. If the request succeeds, the documents
+// failed with 429 or 5xx are returned to be retried, and the number of the documents rejected
+// for other reasons is returned along with the first reason. A recoverableError is returned
+// if the whole request failed because of the network, a 5xx or a 429 response.
+func (c *client) bulk(ctx context.Context, docs []*document) (retry []*document, rejected int, reason string, err error) {
+	var body bytes.Buffer
+	for _, doc := range docs {
+		action, _ := json.Marshal(map[string]interface{}{"index": map[string]string{"_index": doc.index}})
+		body.Write(action)
+		body.WriteByte('\n')
+		body.Write(doc.source)
+		body.WriteByte('\n')
+	}
+	respBody, err := c.do(ctx, http.MethodPost, "/_bulk", "application/x-ndjson", body.Bytes())
+	if err != nil {
+		return nil, 0, "", err
+	}
+	var resp bulkResponse
+	if err = json.Unmarshal(respBody, &resp); err != nil {
+		return nil, 0, "", fmt.Errorf("can't decode the bulk response: %w", err)
+	}
+	if !resp.Errors {
+		return nil, 0, "", nil
+	}
+	if len(resp.Items) != len(docs) {
+		return nil, 0, "", fmt.Errorf("bulk response has %d items, but %d documents are sent", len(resp.Items), len(docs))
+	}
+	for i, item := range resp.Items {
+		for _, result := range item {
+			if result.Status/100 == 2 {
+				continue
+			}
+			if result.Status/100 == 5 || result.Status == http.StatusTooManyRequests {
+				retry = append(retry, docs[i])
+				continue
+			}
+			rejected++
+			if reason == "" && result.Error != nil {
+				reason = result.Error.Type + ": " + result.Error.Reason
+			}
+		}
+	}
+	return retry, rejected, reason, nil
+}
+
+func (c *client) do(ctx context.Context, method string, path string, contentType string, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.host+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	req.Header.Set("Content-Type", contentType)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, recoverableError{err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		respBody, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, recoverableError{err}
+		}
+		return respBody, nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	err = fmt.Errorf("elasticsearch returned HTTP status %s: %s", resp.Status, bytes.TrimSpace(msg))
+	if resp.StatusCode/100 == 5 || resp.StatusCode == http.StatusTooManyRequests {
+		return nil, recoverableError{err}
+	}
+	return nil, err
+}
@@ -1,22 +1,57 @@
 package esexporter
 
+import (
+	"time"
+
+	"github.com/Kindling-project/kindling/collector/pkg/model/constnames"
+)
+
+// DataGroupPlaceholder in EsIndex is replaced with the name of the DataGroup.
+const DataGroupPlaceholder = "%{data_group}"
+
 type Config struct {
-	EsHost  string `mapstructure:"es_host"`
+	// EsHost is the address of the Elasticsearch HTTP API.
+	EsHost string `mapstructure:"es_host"`
+	// EsIndex is the name of the index, or the prefix of the indexes if IndexDateFormat is set.
+	// It can contain DataGroupPlaceholder to write the DataGroups into different indexes.
 	EsIndex string `mapstructure:"es_index"`
+	// IndexDateFormat is the Go layout of the date appended to EsIndex, e.g. "2006.01.02"
+	// rolls the index daily. The date comes from the timestamp of the DataGroup in UTC.
+	// The index doesn't roll if it is empty.
+	IndexDateFormat string `mapstructure:"index_date_format"`
+	// DataGroups are the names of the DataGroups written into Elasticsearch.
+	DataGroups []string      `mapstructure:"data_groups"`
+	Username   string        `mapstructure:"username"`
+	Password   string        `mapstructure:"password"`
+	Timeout    time.Duration `mapstructure:"timeout"`
+	// CreateTemplate controls whether the index template is put on startup, which maps
+	// the string labels as keywords and the metrics as longs.
+	CreateTemplate bool   `mapstructure:"create_template"`
+	TemplateName   string `mapstructure:"template_name"`
+	// Shards and Replicas only take effect when the indexes are created.
+	Shards   int `mapstructure:"shards"`
+	Replicas int `mapstructure:"replicas"`
+	// MaxRetries is the number of times the documents are retried after network errors,
+	// 5xx or 429 responses. The time to wait starts from MinBackoff and is doubled after
+	// each failure until MaxBackoff.
+	MaxRetries int           `mapstructure:"max_retries"`
+	MinBackoff time.Duration `mapstructure:"min_backoff"`
+	MaxBackoff time.Duration `mapstructure:"max_backoff"`
 }
 
-func (cfg *Config) GetEsHost() string {
-	if cfg.EsHost == "" {
-		return "http://39.103.171.51:8080"
-	} else {
-		return cfg.EsHost
-	}
-}
-
-func (cfg *Config) GetEsIndexName() string {
-	if cfg.EsIndex == "" {
-		return "kindling_trace"
-	} else {
-		return cfg.EsIndex
+func NewDefaultConfig() *Config {
+	return &Config{
+		EsHost:          "http://127.0.0.1:9200",
+		EsIndex:         "kindling_trace",
+		IndexDateFormat: "2006.01.02",
+		DataGroups:      []string{constnames.SingleNetRequestMetricGroup},
+		Timeout:         10 * time.Second,
+		CreateTemplate:  true,
+		TemplateName:    "kindling",
+		Shards:          1,
+		Replicas:        1,
+		MaxRetries:      3,
+		MinBackoff:      100 * time.Millisecond,
+		MaxBackoff:      5 * time.Second,
 	}
 }
@@ -0,0 +1,112 @@
+package esexporter
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+)
+
+// TraceData is the source of the document of a DataGroup. The labels keep their types,
+// and the int metrics are kept as numbers so that they can be aggregated.
+type TraceData struct {
+	Name string `json:"name"`
+	// Timestamp is the timestamp of the DataGroup in nanoseconds.
+	Timestamp uint64 `json:"timestamp"`
+	// Time is the timestamp in the date format Elasticsearch recognizes.
+	Time    string                 `json:"@timestamp"`
+	Labels  *model.AttributeMap    `json:"labels"`
+	Metrics map[string]interface{} `json:"metrics"`
+}
+
+type histogramData struct {
+	Sum                int64    `json:"sum"`
+	Count              uint64   `json:"count"`
+	ExplicitBoundaries []int64  `json:"explicit_boundaries"`
+	BucketCounts       []uint64 `json:"bucket_counts"`
+}
+
+func toTraceData(dataGroup *model.DataGroup) *TraceData {
+	trace := &TraceData{
+		Name:      dataGroup.Name,
+		Timestamp: dataGroup.Timestamp,
+		Time:      dataGroupTime(dataGroup).Format(time.RFC3339Nano),
+		Labels:    dataGroup.Labels,
+		Metrics:   make(map[string]interface{}, len(dataGroup.Metrics)),
+	}
+	if trace.Labels == nil {
+		trace.Labels = model.NewAttributeMap()
+	}
+	for _, metric := range dataGroup.Metrics {
+		switch metric.DataType() {
+		case model.IntMetricType:
+			trace.Metrics[metric.Name] = metric.GetInt().Value
+		case model.HistogramMetricType:
+			histogram := metric.GetHistogram()
+			trace.Metrics[metric.Name] = &histogramData{
+				Sum:                histogram.Sum,
+				Count:              histogram.Count,
+				ExplicitBoundaries: histogram.ExplicitBoundaries,
+				BucketCounts:       histogram.BucketCounts,
+			}
+		}
+	}
+	return trace
+}
+
+// dataGroupTime returns the timestamp of the DataGroup, or now if it is not set.
+func dataGroupTime(dataGroup *model.DataGroup) time.Time {
+	if dataGroup.Timestamp == 0 {
+		return time.Now().UTC()
+	}
+	return time.Unix(0, int64(dataGroup.Timestamp)).UTC()
+}
+
+// indexName returns the index the DataGroup is written into.
+func indexName(cfg *Config, dataGroup *model.DataGroup) string {
+	index := strings.ReplaceAll(cfg.EsIndex, DataGroupPlaceholder, dataGroup.Name)
+	if cfg.IndexDateFormat != "" {
+		index += "-" + dataGroupTime(dataGroup).Format(cfg.IndexDateFormat)
+	}
+	// The names of the indexes must be lowercase.
+	return strings.ToLower(index)
+}
+
+// indexTemplate returns the template applied to all the indexes written by the exporter.
+func indexTemplate(cfg *Config) ([]byte, error) {
+	pattern := strings.ToLower(strings.ReplaceAll(cfg.EsIndex, DataGroupPlaceholder, "*"))
+	if !strings.HasSuffix(pattern, "*") {
+		pattern += "*"
+	}
+	return json.Marshal(map[string]interface{}{
+		"index_patterns": []string{pattern},
+		"settings": map[string]interface{}{
+			"number_of_shards":   cfg.Shards,
+			"number_of_replicas": cfg.Replicas,
+		},
+		"mappings": map[string]interface{}{
+			"dynamic_templates": []interface{}{
+				map[string]interface{}{
+					"labels_as_keywords": map[string]interface{}{
+						"path_match":         "labels.*",
+						"match_mapping_type": "string",
+						"mapping":            map[string]interface{}{"type": "keyword", "ignore_above": 1024},
+					},
+				},
+				map[string]interface{}{
+					"metrics_as_longs": map[string]interface{}{
+						"path_match":         "metrics.*",
+						"match_mapping_type": "long",
+						"mapping":            map[string]interface{}{"type": "long"},
+					},
+				},
+			},
+			"properties": map[string]interface{}{
+				"name":       map[string]interface{}{"type": "keyword"},
+				"timestamp":  map[string]interface{}{"type": "long"},
+				"@timestamp": map[string]interface{}{"type": "date_nanos"},
+			},
+		},
+	})
+}
@@ -1,84 +1,176 @@
 package esexporter
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/atomic"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
 	"github.com/Kindling-project/kindling/collector/pkg/component"
 	"github.com/Kindling-project/kindling/collector/pkg/component/consumer/exporter"
-	"github.com/Kindling-project/kindling/collector/pkg/esclient"
 	"github.com/Kindling-project/kindling/collector/pkg/model"
-	"github.com/Kindling-project/kindling/collector/pkg/model/constnames"
-	"go.uber.org/zap"
-	"go.uber.org/zap/zapcore"
 )
 
 const Type = "esexporter"
 
+// EsExporter writes the DataGroups into Elasticsearch, one document for each DataGroup.
+// It doesn't cache the documents, and the batching is done by the batcher which wraps it,
+// so the documents of a batch are sent with one bulk request.
 type EsExporter struct {
-	esClient *esclient.EsClient
-
+	cfg       *Config
 	telemetry *component.TelemetryTools
-	config    *Config
+	client    *client
+	// dataGroups contains the names of the DataGroups written.
+	dataGroups map[string]struct{}
+
+	templateReady *atomic.Bool
 }
 
 func New(config interface{}, telemetry *component.TelemetryTools) exporter.Exporter {
-	cfg, _ := config.(*Config)
-	ret := &EsExporter{
-		config:    cfg,
-		telemetry: telemetry,
+	cfg, ok := config.(*Config)
+	if !ok {
+		telemetry.Logger.Panic("Cannot convert Component config", zap.String("componentType", Type))
 	}
-	client, err := esclient.NewEsClient(cfg.GetEsHost())
-	if err != nil {
-		telemetry.Logger.Errorf("Fail to create elasticsearch client: ", zap.Error(err))
+	newSelfMetrics(telemetry.MeterProvider)
+	defaults := NewDefaultConfig()
+	if cfg.EsIndex == "" {
+		cfg.EsIndex = defaults.EsIndex
+	}
+	if cfg.MinBackoff <= 0 {
+		cfg.MinBackoff = defaults.MinBackoff
+	}
+	if cfg.MaxBackoff < cfg.MinBackoff {
+		cfg.MaxBackoff = cfg.MinBackoff
+	}
+	e := &EsExporter{
+		cfg:           cfg,
+		telemetry:     telemetry,
+		client:        newClient(cfg),
+		dataGroups:    make(map[string]struct{}, len(cfg.DataGroups)),
+		templateReady: atomic.NewBool(!cfg.CreateTemplate),
 	}
-	ret.esClient = client
-	return ret
+	for _, name := range cfg.DataGroups {
+		e.dataGroups[name] = struct{}{}
+	}
+	return e
 }
 
-func (e *EsExporter) Consume(dataGroup *model.DataGroup) error {
-	if ce := e.telemetry.Logger.Check(zapcore.DebugLevel, "Receiver DataGroup"); ce != nil {
-		ce.Write(
-			zap.String("dataGroup", dataGroup.String()),
-		)
+// Start puts the index template in advance. It is put again before indexing if it fails.
+func (e *EsExporter) Start() error {
+	if err := e.createTemplate(); err != nil {
+		e.telemetry.Logger.Warn("Failed to put the index template, will retry before indexing", zap.Error(err))
 	}
+	return nil
+}
+
+func (e *EsExporter) Consume(dataGroup *model.DataGroup) error {
 	if dataGroup == nil {
 		// no need consume
 		return nil
 	}
-
-	switch dataGroup.Name {
-	case constnames.AggregatedNetRequestMetricGroup:
-		// We don't care about metrics now.
-		return nil
-	case constnames.SingleNetRequestMetricGroup:
-		e.sendTrace(dataGroup)
-		return nil
-	}
-	return nil
+	return e.ConsumeBatch([]*model.DataGroup{dataGroup})
 }
 
-func (e *EsExporter) sendTrace(dataGroup *model.DataGroup) {
-	e.telemetry.Logger.Info("Will send a trace to ElasticSearch")
-	if e.esClient == nil {
-		return
+// ConsumeBatch indexes the DataGroups with one bulk request. The documents rejected by
+// Elasticsearch are dropped. If the whole request is rejected, the error is marked as
+// permanent. If some documents still fail after all the retries, the error is returned
+// to be retried later, which may index the other documents of the batch again.
+func (e *EsExporter) ConsumeBatch(dataGroups []*model.DataGroup) error {
+	var (
+		docs      []*document
+		encodeErr error
+	)
+	for _, dataGroup := range dataGroups {
+		if dataGroup == nil {
+			continue
+		}
+		if ce := e.telemetry.Logger.Check(zapcore.DebugLevel, "Receiver DataGroup"); ce != nil {
+			ce.Write(
+				zap.String("dataGroup", dataGroup.String()),
+			)
+		}
+		if _, ok := e.dataGroups[dataGroup.Name]; !ok {
+			continue
+		}
+		source, err := json.Marshal(toTraceData(dataGroup))
+		if err != nil {
+			droppedDocumentsCounter.Add(context.Background(), 1, attribute.String("reason", dropReasonEncodeFailed))
+			encodeErr = exporter.NewPermanentError(fmt.Errorf("can't encode the document of %s: %w", dataGroup.Name, err))
+			continue
+		}
+		docs = append(docs, &document{index: indexName(e.cfg, dataGroup), source: source})
+	}
+	if len(docs) == 0 {
+		return encodeErr
 	}
-	trace := TraceData{
-		Name:      dataGroup.Name,
-		Metrics:   make(map[string]int64),
-		Labels:    dataGroup.Labels.ToStringMap(),
-		Timestamp: dataGroup.Timestamp,
+	if err := e.createTemplate(); err != nil {
+		// The documents are still indexed with the dynamic mappings of Elasticsearch.
+		e.telemetry.Logger.Warn("Failed to put the index template", zap.Error(err))
 	}
-	for _, metric := range dataGroup.Metrics {
-		trace.Metrics[metric.Name] = metric.GetInt().Value
+	if err := e.sendWithRetry(docs); err != nil {
+		return err
 	}
-	if e.esClient != nil {
-		_ = e.esClient.IndexJson(e.config.GetEsIndexName(), trace)
-	} else {
-		e.telemetry.Logger.Infof("EsClient is nil, the trace should have been sent: %v", trace)
+	return encodeErr
+}
+
+// sendWithRetry sends the documents with the bulk API. The documents failed with network
+// errors, 5xx or 429 responses are retried with backoff at most MaxRetries times.
+func (e *EsExporter) sendWithRetry(docs []*document) error {
+	backoff := e.cfg.MinBackoff
+	for i := 0; ; i++ {
+		retry, rejected, reason, err := e.client.bulk(context.Background(), docs)
+		if err != nil {
+			var recoverable recoverableError
+			if !errors.As(err, &recoverable) {
+				droppedDocumentsCounter.Add(context.Background(), int64(len(docs)), attribute.String("reason", dropReasonRejected))
+				return exporter.NewPermanentError(fmt.Errorf("bulk request of %d documents is rejected: %w", len(docs), err))
+			}
+			retry = docs
+		} else {
+			indexedDocumentsCounter.Add(context.Background(), int64(len(docs)-len(retry)-rejected))
+			if rejected > 0 {
+				droppedDocumentsCounter.Add(context.Background(), int64(rejected), attribute.String("reason", dropReasonRejected))
+				e.telemetry.Logger.Warn("Documents are rejected by Elasticsearch",
+					zap.Int("documents", rejected), zap.String("reason", reason))
+			}
+		}
+		if len(retry) == 0 {
+			return nil
+		}
+		if i >= e.cfg.MaxRetries {
+			if err == nil {
+				err = errors.New("documents failed with 429 or 5xx")
+			}
+			return fmt.Errorf("failed to index %d documents: %w", len(retry), err)
+		}
+		retriesCounter.Add(context.Background(), 1)
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > e.cfg.MaxBackoff {
+			backoff = e.cfg.MaxBackoff
+		}
+		docs = retry
 	}
 }
 
-type TraceData struct {
-	Name      string            `json:"name"`
-	Metrics   map[string]int64  `json:"metrics"`
-	Labels    map[string]string `json:"labels"`
-	Timestamp uint64            `json:"timestamp"`
+// createTemplate puts the index template. It does nothing once it succeeds.
+func (e *EsExporter) createTemplate() error {
+	if e.templateReady.Load() {
+		return nil
+	}
+	template, err := indexTemplate(e.cfg)
+	if err != nil {
+		return err
+	}
+	if err = e.client.putTemplate(context.Background(), e.cfg.TemplateName, template); err != nil {
+		return fmt.Errorf("can't put index template %s: %w", e.cfg.TemplateName, err)
+	}
+	e.templateReady.Store(true)
+	return nil
 }
@@ -0,0 +1,223 @@
+package esexporter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component"
+	"github.com/Kindling-project/kindling/collector/pkg/component/consumer/exporter"
+	"github.com/Kindling-project/kindling/collector/pkg/component/consumer/exporter/internal/testutil"
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constlabels"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constnames"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constvalues"
+)
+
+// fakeBulk responds to the bulk requests the way Elasticsearch does.
+type fakeBulk struct {
+	t     *testing.T
+	docs  map[string][]map[string]interface{}
+	bulks int
+	// unavailable is the number of the next bulk requests failed with 503.
+	unavailable int
+	// itemStatus returns the status of each document in the bulk requests.
+	itemStatus func(doc map[string]interface{}) int
+}
+
+func newFakeBulk(t *testing.T, sink *testutil.HTTPSink) *fakeBulk {
+	f := &fakeBulk{t: t, docs: make(map[string][]map[string]interface{})}
+	sink.SetResponder(f.respond)
+	return f
+}
+
+func (f *fakeBulk) respond(req *testutil.Request) (int, string) {
+	user, password, _ := (&http.Request{Header: req.Header}).BasicAuth()
+	assert.Equal(f.t, "elastic", user)
+	assert.Equal(f.t, "changeme", password)
+	if req.URL.Path != "/_bulk" {
+		return http.StatusOK, `{"acknowledged":true}`
+	}
+	f.bulks++
+	if f.unavailable > 0 {
+		f.unavailable--
+		return http.StatusServiceUnavailable, ""
+	}
+	assert.Equal(f.t, "application/x-ndjson", req.Header.Get("Content-Type"))
+	var items []string
+	hasErrors := false
+	scanner := bufio.NewScanner(bytes.NewReader(req.Body))
+	for scanner.Scan() {
+		action := make(map[string]map[string]string)
+		assert.NoError(f.t, json.Unmarshal(scanner.Bytes(), &action))
+		require.True(f.t, scanner.Scan())
+		doc := make(map[string]interface{})
+		assert.NoError(f.t, json.Unmarshal(scanner.Bytes(), &doc))
+		status := http.StatusCreated
+		if f.itemStatus != nil {
+			status = f.itemStatus(doc)
+		}
+		if status == http.StatusCreated {
+			index := action["index"]["_index"]
+			f.docs[index] = append(f.docs[index], doc)
+			items = append(items, `{"index":{"status":201}}`)
+		} else {
+			hasErrors = true
+			items = append(items, fmt.Sprintf(`{"index":{"status":%d,"error":{"type":"error","reason":"status %d"}}}`, status, status))
+		}
+	}
+	return http.StatusOK, fmt.Sprintf(`{"took":1,"errors":%t,"items":[%s]}`, hasErrors, strings.Join(items, ","))
+}
+
+func newTestConfig(sink *testutil.HTTPSink) *Config {
+	cfg := NewDefaultConfig()
+	cfg.EsHost = sink.URL
+	cfg.Username = "elastic"
+	cfg.Password = "changeme"
+	cfg.MinBackoff = time.Millisecond
+	return cfg
+}
+
+func newRequestDataGroup(i int64, timestamp time.Time) *model.DataGroup {
+	labels := model.NewAttributeMap()
+	labels.AddIntValue(constlabels.Pid, i)
+	labels.AddStringValue(constlabels.Protocol, "http")
+	labels.AddBoolValue(constlabels.IsSlow, true)
+	return model.NewDataGroup(constnames.SingleNetRequestMetricGroup, labels, uint64(timestamp.UnixNano()),
+		model.NewIntMetric(constvalues.RequestTotalTime, i*10),
+		model.NewHistogramMetric("request_histogram", &model.Histogram{
+			Sum: 30, Count: 2, ExplicitBoundaries: []int64{10, 100}, BucketCounts: []uint64{0, 2, 0},
+		}))
+}
+
+func TestIndexDocuments(t *testing.T) {
+	sink := testutil.NewHTTPSink(t)
+	fake := newFakeBulk(t, sink)
+	e := New(newTestConfig(sink), component.NewDefaultTelemetryTools()).(*EsExporter)
+
+	day1 := time.Date(2022, 10, 1, 23, 59, 0, 0, time.UTC)
+	day2 := time.Date(2022, 10, 2, 0, 1, 0, 0, time.UTC)
+	dataGroups := make([]*model.DataGroup, 0, 5)
+	for i := int64(0); i < 3; i++ {
+		dataGroups = append(dataGroups, newRequestDataGroup(i, day1))
+	}
+	dataGroups = append(dataGroups, newRequestDataGroup(3, day2),
+		// The DataGroups not configured are ignored.
+		model.NewDataGroup(constnames.TcpConnectMetricGroupName, model.NewAttributeMap(), 1))
+	assert.NoError(t, e.ConsumeBatch(dataGroups))
+
+	// The template is put before the first bulk request.
+	requests := sink.Requests()
+	require.Len(t, requests, 2)
+	assert.Equal(t, http.MethodPut, requests[0].Method)
+	assert.Equal(t, "/_template/kindling", requests[0].URL.Path)
+	template := make(map[string]interface{})
+	require.NoError(t, json.Unmarshal(requests[0].Body, &template))
+	assert.Equal(t, []interface{}{"kindling_trace*"}, template["index_patterns"])
+	assert.Equal(t, 1, fake.bulks)
+
+	docs := fake.docs["kindling_trace-2022.10.01"]
+	require.Len(t, docs, 3)
+	assert.Len(t, fake.docs["kindling_trace-2022.10.02"], 1)
+	doc := docs[1]
+	assert.Equal(t, constnames.SingleNetRequestMetricGroup, doc["name"])
+	assert.Equal(t, float64(day1.UnixNano()), doc["timestamp"])
+	assert.Equal(t, "2022-10-01T23:59:00Z", doc["@timestamp"])
+	assert.Equal(t, map[string]interface{}{
+		constlabels.Pid:      float64(1),
+		constlabels.Protocol: "http",
+		constlabels.IsSlow:   true,
+	}, doc["labels"])
+	metrics := doc["metrics"].(map[string]interface{})
+	assert.Equal(t, float64(10), metrics[constvalues.RequestTotalTime])
+	assert.Equal(t, map[string]interface{}{
+		"sum":                 float64(30),
+		"count":               float64(2),
+		"explicit_boundaries": []interface{}{float64(10), float64(100)},
+		"bucket_counts":       []interface{}{float64(0), float64(2), float64(0)},
+	}, metrics["request_histogram"])
+}
+
+func TestIndexName(t *testing.T) {
+	cfg := NewDefaultConfig()
+	dataGroup := model.NewDataGroup(constnames.TcpConnectMetricGroupName, model.NewAttributeMap(),
+		uint64(time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC).UnixNano()))
+	cfg.EsIndex = "Kindling-" + DataGroupPlaceholder
+	cfg.IndexDateFormat = "2006.01"
+	assert.Equal(t, "kindling-tcp_connect_metric_group-2022.10", indexName(cfg, dataGroup))
+	cfg.IndexDateFormat = ""
+	assert.Equal(t, "kindling-tcp_connect_metric_group", indexName(cfg, dataGroup))
+
+	template, err := indexTemplate(cfg)
+	require.NoError(t, err)
+	assert.Contains(t, string(template), `"index_patterns":["kindling-*"]`)
+}
+
+func TestBulkRetry(t *testing.T) {
+	sink := testutil.NewHTTPSink(t)
+	fake := newFakeBulk(t, sink)
+	cfg := newTestConfig(sink)
+	cfg.IndexDateFormat = ""
+	cfg.CreateTemplate = false
+	e := New(cfg, component.NewDefaultTelemetryTools()).(*EsExporter)
+
+	// The whole request is retried after 503, and the documents failed with 429 are
+	// retried alone. The document rejected with 400 is dropped.
+	fake.unavailable = 1
+	attempts := make(map[float64]int)
+	fake.itemStatus = func(doc map[string]interface{}) int {
+		pid := doc["labels"].(map[string]interface{})[constlabels.Pid].(float64)
+		attempts[pid]++
+		switch {
+		case pid == 1:
+			return http.StatusBadRequest
+		case pid == 2 && attempts[pid] < 2:
+			return http.StatusTooManyRequests
+		}
+		return http.StatusCreated
+	}
+	now := time.Now()
+	assert.NoError(t, e.ConsumeBatch([]*model.DataGroup{
+		newRequestDataGroup(0, now), newRequestDataGroup(1, now), newRequestDataGroup(2, now),
+	}))
+
+	assert.Equal(t, 3, fake.bulks)
+	assert.Len(t, sink.Requests(), 3)
+	docs := fake.docs["kindling_trace"]
+	require.Len(t, docs, 2)
+	assert.Equal(t, float64(2), docs[1]["labels"].(map[string]interface{})[constlabels.Pid])
+}
+
+func TestBulkErrors(t *testing.T) {
+	sink := testutil.NewHTTPSink(t)
+	fake := newFakeBulk(t, sink)
+	cfg := newTestConfig(sink)
+	cfg.CreateTemplate = false
+	cfg.MaxRetries = 2
+	e := New(cfg, component.NewDefaultTelemetryTools())
+
+	// The error is returned to be retried later once all the retries fail.
+	fake.unavailable = 10
+	err := e.Consume(newRequestDataGroup(0, time.Now()))
+	require.Error(t, err)
+	assert.False(t, exporter.IsPermanentError(err))
+	assert.Equal(t, 3, fake.bulks)
+	assert.Empty(t, fake.docs)
+
+	// The documents are dropped if the whole request is rejected.
+	sink.SetResponder(func(*testutil.Request) (int, string) {
+		return http.StatusUnauthorized, "missing authentication credentials"
+	})
+	err = e.Consume(newRequestDataGroup(0, time.Now()))
+	require.Error(t, err)
+	assert.True(t, exporter.IsPermanentError(err))
+	assert.Len(t, sink.Requests(), 4)
+}
@@ -0,0 +1,38 @@
+package esexporter
+
+import (
+	"sync"
+
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	indexedDocumentsMetric = "kindling_telemetry_esexporter_indexed_documents_total"
+	droppedDocumentsMetric = "kindling_telemetry_esexporter_dropped_documents_total"
+	retriesMetric          = "kindling_telemetry_esexporter_retries_total"
+)
+
+const (
+	dropReasonEncodeFailed = "encode_failed"
+	dropReasonRejected     = "rejected"
+)
+
+var once sync.Once
+
+var (
+	indexedDocumentsCounter metric.Int64Counter
+	droppedDocumentsCounter metric.Int64Counter
+	retriesCounter          metric.Int64Counter
+)
+
+func newSelfMetrics(meterProvider metric.MeterProvider) {
+	once.Do(func() {
+		meter := metric.Must(meterProvider.Meter("kindling"))
+		indexedDocumentsCounter = meter.NewInt64Counter(indexedDocumentsMetric,
+			metric.WithDescription("The total number of documents indexed into Elasticsearch successfully"))
+		droppedDocumentsCounter = meter.NewInt64Counter(droppedDocumentsMetric,
+			metric.WithDescription("The total number of documents dropped by esexporter"))
+		retriesCounter = meter.NewInt64Counter(retriesMetric,
+			metric.WithDescription("The total number of the bulk requests retried"))
+	})
+}
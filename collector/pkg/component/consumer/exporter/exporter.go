@@ -1,9 +1,45 @@
 package exporter
 
 import (
+	"errors"
+
 	"github.com/Kindling-project/kindling/collector/pkg/component/consumer"
+	"github.com/Kindling-project/kindling/collector/pkg/model"
 )
 
 type Exporter interface {
 	consumer.Consumer
 }
+
+// BatchConsumer is implemented by the exporters which send many DataGroups in one request.
+// The batcher passes the whole batch to ConsumeBatch instead of calling Consume for each
+// DataGroup, and it is enabled for them by default.
+type BatchConsumer interface {
+	ConsumeBatch(dataGroups []*model.DataGroup) error
+}
+
+// permanentError means the data can never be exported, e.g. it is rejected by the backend,
+// so it is dropped instead of being retried.
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e permanentError) Unwrap() error {
+	return e.err
+}
+
+// NewPermanentError marks err as permanent. The errors returned by the exporters are
+// treated as transient unless they are marked.
+func NewPermanentError(err error) error {
+	return permanentError{err: err}
+}
+
+// IsPermanentError returns true if err or any error it wraps is marked as permanent.
+func IsPermanentError(err error) bool {
+	var permanent permanentError
+	return errors.As(err, &permanent)
+}
@@ -0,0 +1,71 @@
+// Package testutil contains the helpers shared by the tests of the exporters.
+package testutil
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+)
+
+// Request is a request received by HTTPSink.
+type Request struct {
+	Method string
+	URL    *url.URL
+	Header http.Header
+	Body   []byte
+}
+
+// Responder returns the status code and the body of the response to the request.
+type Responder func(req *Request) (int, string)
+
+// HTTPSink is an HTTP server recording the requests sent by the exporter under test.
+// It responds 200 with an empty body unless a Responder is set.
+type HTTPSink struct {
+	URL    string
+	server *httptest.Server
+
+	mu        sync.Mutex
+	requests  []*Request
+	responder Responder
+}
+
+// NewHTTPSink starts an HTTPSink which is closed when the test finishes.
+func NewHTTPSink(t *testing.T) *HTTPSink {
+	s := &HTTPSink{}
+	s.server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	s.URL = s.server.URL
+	t.Cleanup(s.server.Close)
+	return s
+}
+
+func (s *HTTPSink) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r := &Request{Method: req.Method, URL: req.URL, Header: req.Header, Body: body}
+	// The lock is held while responding, so the responder can keep its state without locks.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, r)
+	if s.responder == nil {
+		return
+	}
+	status, respBody := s.responder(r)
+	w.WriteHeader(status)
+	_, _ = io.WriteString(w, respBody)
+}
+
+// SetResponder replaces how the requests are responded.
+func (s *HTTPSink) SetResponder(responder Responder) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responder = responder
+}
+
+// Requests returns the requests received so far, including the ones responded with errors.
+func (s *HTTPSink) Requests() []*Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Request(nil), s.requests...)
+}
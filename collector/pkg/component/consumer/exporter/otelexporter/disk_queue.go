@@ -0,0 +1,165 @@
+package otelexporter
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"go.uber.org/multierr"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component"
+	"github.com/Kindling-project/kindling/collector/pkg/component/consumer/exporter"
+	"github.com/Kindling-project/kindling/collector/pkg/component/consumer/exporter/tools/diskqueue"
+)
+
+var errDiskQueueUnsupported = errors.New("disk queue is only supported by the otlp exporter")
+
+// queuedClient holds the Sender of an OTLP client. The payloads are sent directly
+// until the disk queue is enabled.
+type queuedClient struct {
+	mu     sync.RWMutex
+	sender *diskqueue.Sender
+}
+
+func (c *queuedClient) getSender() *diskqueue.Sender {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.sender
+}
+
+func (c *queuedClient) setSender(sender *diskqueue.Sender) {
+	c.mu.Lock()
+	c.sender = sender
+	c.mu.Unlock()
+}
+
+// queuedMetricsClient uploads the metrics through the disk queue if it is enabled.
+type queuedMetricsClient struct {
+	otlpmetric.Client
+	queuedClient
+}
+
+func (c *queuedMetricsClient) UploadMetrics(ctx context.Context, protoMetrics []*metricpb.ResourceMetrics) error {
+	sender := c.getSender()
+	if sender == nil {
+		return c.Client.UploadMetrics(ctx, protoMetrics)
+	}
+	data, err := proto.Marshal(&colmetricpb.ExportMetricsServiceRequest{ResourceMetrics: protoMetrics})
+	if err != nil {
+		return err
+	}
+	return sender.Send(data)
+}
+
+func (c *queuedMetricsClient) send(data []byte) error {
+	request := &colmetricpb.ExportMetricsServiceRequest{}
+	if err := proto.Unmarshal(data, request); err != nil {
+		return exporter.NewPermanentError(err)
+	}
+	return markPermanent(c.Client.UploadMetrics(context.Background(), request.ResourceMetrics))
+}
+
+// queuedTracesClient uploads the spans through the disk queue if it is enabled.
+type queuedTracesClient struct {
+	otlptrace.Client
+	queuedClient
+}
+
+func (c *queuedTracesClient) UploadTraces(ctx context.Context, protoSpans []*tracepb.ResourceSpans) error {
+	sender := c.getSender()
+	if sender == nil {
+		return c.Client.UploadTraces(ctx, protoSpans)
+	}
+	data, err := proto.Marshal(&coltracepb.ExportTraceServiceRequest{ResourceSpans: protoSpans})
+	if err != nil {
+		return err
+	}
+	return sender.Send(data)
+}
+
+func (c *queuedTracesClient) send(data []byte) error {
+	request := &coltracepb.ExportTraceServiceRequest{}
+	if err := proto.Unmarshal(data, request); err != nil {
+		return exporter.NewPermanentError(err)
+	}
+	return markPermanent(c.Client.UploadTraces(context.Background(), request.ResourceSpans))
+}
+
+// markPermanent marks the errors of the requests the backend will never accept, so they
+// are dropped instead of blocking the queue. The other errors, including the network
+// errors, are retried.
+func markPermanent(err error) error {
+	switch status.Code(err) {
+	case codes.InvalidArgument, codes.Unimplemented:
+		return exporter.NewPermanentError(err)
+	default:
+		return err
+	}
+}
+
+// EnableDiskQueue implements diskqueue.QueueOwner. The metrics and the spans failed to be
+// pushed are persisted to the disk queues named after the name, and retried in order.
+func (e *OtelExporter) EnableDiskQueue(name string, cfg *diskqueue.Config, telemetry *component.TelemetryTools) error {
+	if e.metricsClient == nil || e.tracesClient == nil {
+		return errDiskQueueUnsupported
+	}
+	metricsSender, err := diskqueue.NewSender(name+"/metrics", cfg, telemetry, e.metricsClient.send)
+	if err != nil {
+		return err
+	}
+	tracesSender, err := diskqueue.NewSender(name+"/traces", cfg, telemetry, e.tracesClient.send)
+	if err != nil {
+		_ = metricsSender.Shutdown()
+		return err
+	}
+	e.metricsClient.setSender(metricsSender)
+	e.tracesClient.setSender(tracesSender)
+	return nil
+}
+
+// QueueDepths returns the bytes stored in the disk queues if they are enabled.
+func (e *OtelExporter) QueueDepths() map[string]component.QueueDepth {
+	ret := make(map[string]component.QueueDepth)
+	for prefix, client := range e.queuedClients() {
+		if sender := client.getSender(); sender != nil {
+			for name, depth := range sender.QueueDepths() {
+				ret[prefix+"_"+name] = depth
+			}
+		}
+	}
+	return ret
+}
+
+// shutdownDiskQueues closes the disk queues. It is called after the last metrics and
+// spans are pushed.
+func (e *OtelExporter) shutdownDiskQueues() error {
+	var retErr error
+	for _, client := range e.queuedClients() {
+		if sender := client.getSender(); sender != nil {
+			retErr = multierr.Append(retErr, sender.Shutdown())
+		}
+	}
+	return retErr
+}
+
+// queuedClients returns the OTLP clients by the signals they send. It is empty unless the
+// export kind is otlp.
+func (e *OtelExporter) queuedClients() map[string]*queuedClient {
+	ret := make(map[string]*queuedClient)
+	if e.metricsClient != nil {
+		ret["metrics"] = &e.metricsClient.queuedClient
+	}
+	if e.tracesClient != nil {
+		ret["traces"] = &e.tracesClient.queuedClient
+	}
+	return ret
+}
@@ -0,0 +1,103 @@
+package otelexporter
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component"
+	"github.com/Kindling-project/kindling/collector/pkg/component/consumer/exporter/tools/diskqueue"
+)
+
+// fakeClient is an OTLP client whose backend returns the error code.
+type fakeClient struct {
+	mu    sync.Mutex
+	code  codes.Code
+	spans []string
+}
+
+func (c *fakeClient) Start(ctx context.Context) error { return nil }
+func (c *fakeClient) Stop(ctx context.Context) error  { return nil }
+
+func (c *fakeClient) UploadMetrics(ctx context.Context, protoMetrics []*metricpb.ResourceMetrics) error {
+	return nil
+}
+
+func (c *fakeClient) UploadTraces(ctx context.Context, protoSpans []*tracepb.ResourceSpans) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.code != codes.OK {
+		return status.Error(c.code, "backend error")
+	}
+	for _, resourceSpans := range protoSpans {
+		for _, libSpans := range resourceSpans.InstrumentationLibrarySpans {
+			for _, span := range libSpans.Spans {
+				c.spans = append(c.spans, span.Name)
+			}
+		}
+	}
+	return nil
+}
+
+func (c *fakeClient) setCode(code codes.Code) {
+	c.mu.Lock()
+	c.code = code
+	c.mu.Unlock()
+}
+
+func (c *fakeClient) receivedSpans() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.spans...)
+}
+
+func testSpans(name string) []*tracepb.ResourceSpans {
+	return []*tracepb.ResourceSpans{{
+		InstrumentationLibrarySpans: []*tracepb.InstrumentationLibrarySpans{{
+			Spans: []*tracepb.Span{{Name: name}},
+		}},
+	}}
+}
+
+func TestDiskQueue(t *testing.T) {
+	backend := &fakeClient{}
+	e := &OtelExporter{
+		metricsClient: &queuedMetricsClient{Client: backend},
+		tracesClient:  &queuedTracesClient{Client: backend},
+	}
+	cfg := diskqueue.NewDefaultConfig()
+	cfg.Directory = t.TempDir()
+	cfg.RetryInitialInterval = 10 * time.Millisecond
+	cfg.RetryMaxInterval = 20 * time.Millisecond
+	assert.NoError(t, e.EnableDiskQueue(Otel, cfg, component.NewDefaultTelemetryTools()))
+
+	// The spans the backend never accepts are dropped.
+	ctx := context.Background()
+	backend.setCode(codes.InvalidArgument)
+	assert.Error(t, e.tracesClient.UploadTraces(ctx, testSpans("invalid")))
+
+	// The spans are queued while the backend is unavailable.
+	backend.setCode(codes.Unavailable)
+	assert.NoError(t, e.tracesClient.UploadTraces(ctx, testSpans("first")))
+	assert.NoError(t, e.tracesClient.UploadTraces(ctx, testSpans("second")))
+	assert.Greater(t, e.QueueDepths()["traces_disk_queue_bytes"].Length, int64(0))
+
+	// The spans sent after the backend is back are queued behind the ones left.
+	backend.setCode(codes.OK)
+	assert.NoError(t, e.tracesClient.UploadTraces(ctx, testSpans("third")))
+	assert.Eventually(t, func() bool { return len(backend.receivedSpans()) == 3 }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"first", "second", "third"}, backend.receivedSpans())
+	assert.NoError(t, e.shutdownDiskQueues())
+}
+
+func TestDiskQueueUnsupported(t *testing.T) {
+	e := &OtelExporter{}
+	assert.Error(t, e.EnableDiskQueue(Otel, diskqueue.NewDefaultConfig(), component.NewDefaultTelemetryTools()))
+}
@@ -9,7 +9,9 @@ import (
 	"time"
 
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric"
 	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	"go.opentelemetry.io/otel/exporters/prometheus"
 	"go.opentelemetry.io/otel/exporters/stdout/stdoutmetric"
@@ -49,6 +51,9 @@ var serviceName string
 type OtelOutputExporters struct {
 	metricExporter exportmetric.Exporter
 	traceExporter  sdktrace.SpanExporter
+	// The OTLP clients are kept so the disk queue can be enabled on them.
+	metricsClient *queuedMetricsClient
+	tracesClient  *queuedTracesClient
 }
 
 type OtelExporter struct {
@@ -63,6 +68,9 @@ type OtelExporter struct {
 	exp                  *prometheus.Exporter
 	rs                   *resource.Resource
 	mu                   sync.Mutex
+	// metricsClient and tracesClient are nil unless the export kind is otlp.
+	metricsClient *queuedMetricsClient
+	tracesClient  *queuedTracesClient
 
 	adapters []adapter.Adapter
 }
@@ -149,7 +157,8 @@ func NewExporter(config interface{}, telemetry *component.TelemetryTools) export
 					StoreExternalSrcIP: cfg.AdapterConfig.StoreExternalSrcIP,
 				}),
 				adapter.NewSimpleAdapter([]string{constnames.TcpRttMetricGroupName, constnames.TcpRetransmitMetricGroupName,
-					constnames.TcpDropMetricGroupName, constnames.TcpConnectMetricGroupName, constnames.K8sWorkloadMetricGroupName},
+					constnames.TcpDropMetricGroupName, constnames.TcpConnectMetricGroupName, constnames.K8sWorkloadMetricGroupName,
+					constnames.RequestTimeBreakdownMetricGroupName, constnames.LockContentionMetricGroupName},
 					customLabels),
 			},
 		}
@@ -215,6 +224,8 @@ func NewExporter(config interface{}, telemetry *component.TelemetryTools) export
 			instrumentFactory:    newInstrumentFactory(cont.Meter(MeterName), telemetry, customLabels),
 			metricAggregationMap: cfg.MetricAggregationMap,
 			telemetry:            telemetry,
+			metricsClient:        exporters.metricsClient,
+			tracesClient:         exporters.tracesClient,
 			adapters: []adapter.Adapter{
 				adapter.NewNetAdapter(customLabels, &adapter.NetAdapterConfig{
 					StoreTraceAsMetric: cfg.AdapterConfig.NeedTraceAsMetric,
@@ -223,7 +234,8 @@ func NewExporter(config interface{}, telemetry *component.TelemetryTools) export
 					StoreExternalSrcIP: cfg.AdapterConfig.StoreExternalSrcIP,
 				}),
 				adapter.NewSimpleAdapter([]string{constnames.TcpRttMetricGroupName, constnames.TcpRetransmitMetricGroupName,
-					constnames.TcpDropMetricGroupName, constnames.TcpConnectMetricGroupName, constnames.K8sWorkloadMetricGroupName},
+					constnames.TcpDropMetricGroupName, constnames.TcpConnectMetricGroupName, constnames.K8sWorkloadMetricGroupName,
+					constnames.RequestTimeBreakdownMetricGroupName, constnames.LockContentionMetricGroupName},
 					customLabels),
 			},
 		}
@@ -265,7 +277,7 @@ func newExporters(context context.Context, cfg *Config, telemetry *component.Tel
 			traceExporter:  traceExp,
 		}
 	case OtlpGrpcKindExporter:
-		metricExporter, err := otlpmetricgrpc.New(context,
+		metricsClient := &queuedMetricsClient{Client: otlpmetricgrpc.NewClient(
 			otlpmetricgrpc.WithInsecure(),
 			otlpmetricgrpc.WithEndpoint(cfg.OtlpGrpcCfg.Endpoint),
 			otlpmetricgrpc.WithRetry(otlpmetricgrpc.RetrySettings{
@@ -274,11 +286,12 @@ func newExporters(context context.Context, cfg *Config, telemetry *component.Tel
 				MaxInterval:     5 * time.Second,
 				MaxElapsedTime:  15 * time.Second,
 			}),
-		)
+		)}
+		metricExporter, err := otlpmetric.New(context, metricsClient)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create exporter, %w", err)
 		}
-		traceExporter, err := otlptracegrpc.New(context,
+		tracesClient := &queuedTracesClient{Client: otlptracegrpc.NewClient(
 			otlptracegrpc.WithInsecure(),
 			otlptracegrpc.WithEndpoint(cfg.OtlpGrpcCfg.Endpoint),
 			otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig{
@@ -287,13 +300,16 @@ func newExporters(context context.Context, cfg *Config, telemetry *component.Tel
 				MaxInterval:     5 * time.Second,
 				MaxElapsedTime:  15 * time.Second,
 			}),
-		)
+		)}
+		traceExporter, err := otlptrace.New(context, tracesClient)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create exporter, %w", err)
 		}
 		retExporters = &OtelOutputExporters{
 			metricExporter: metricExporter,
 			traceExporter:  traceExporter,
+			metricsClient:  metricsClient,
+			tracesClient:   tracesClient,
 		}
 	default:
 		return nil, errors.New("failed to create exporter, no exporter kind is provided")
@@ -352,6 +368,24 @@ func (e *OtelExporter) NewMeter(telemetry *component.TelemetryTools) error {
 	return nil
 }
 
+// Shutdown pushes the metrics and the spans not exported yet to the backend. It does nothing
+// to the Prometheus exporter, whose metrics are pulled. The data failed to be pushed is left
+// in the disk queues if they are enabled.
+func (e *OtelExporter) Shutdown() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	ctx := context.Background()
+	if err := e.metricController.Stop(ctx); err != nil {
+		return fmt.Errorf("failed to stop the metric controller: %w", err)
+	}
+	if e.traceProvider != nil {
+		if err := e.traceProvider.Shutdown(ctx); err != nil {
+			return fmt.Errorf("failed to shut down the trace provider: %w", err)
+		}
+	}
+	return e.shutdownDiskQueues()
+}
+
 func registerResetSchedule(cfg *MemCleanUpConfig, exporter *OtelExporter) {
 	if cfg.RestartPeriod != 0 {
 		ticker := time.NewTicker(time.Duration(cfg.RestartPeriod) * time.Hour)
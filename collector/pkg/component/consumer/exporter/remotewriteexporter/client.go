@@ -0,0 +1,62 @@
+package remotewriteexporter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/golang/snappy"
+)
+
+// recoverableError means the request may succeed if it is retried later.
+type recoverableError struct {
+	error
+}
+
+type client struct {
+	endpoint   string
+	headers    map[string]string
+	httpClient *http.Client
+}
+
+func newClient(cfg *Config) *client {
+	return &client{
+		endpoint:   cfg.Endpoint,
+		headers:    cfg.Headers,
+		httpClient: &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+// store sends one WriteRequest. A recoverableError is returned if the request
+// failed because of the network, a 5xx or a 429 response.
+func (c *client) store(ctx context.Context, series []timeSeries) error {
+	body := snappy.Encode(nil, marshalWriteRequest(series))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range c.headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("User-Agent", "kindling-collector")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return recoverableError{err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("server returned HTTP status %s: %s", resp.Status, bytes.TrimSpace(msg))
+	if resp.StatusCode/100 == 5 || resp.StatusCode == http.StatusTooManyRequests {
+		return recoverableError{err}
+	}
+	return err
+}
@@ -0,0 +1,122 @@
+package remotewriteexporter
+
+import "time"
+
+type MetricAggregationKind string
+
+const (
+	// MAGaugeKind writes the last value received.
+	MAGaugeKind MetricAggregationKind = "gauge"
+	// MACounterKind accumulates the values received and writes the sum.
+	MACounterKind MetricAggregationKind = "counter"
+)
+
+type Config struct {
+	// Endpoint is the URL of the remote write receiver, e.g. "http://prometheus:9090/api/v1/write".
+	Endpoint string `mapstructure:"endpoint"`
+	// Timeout is the timeout of each HTTP request.
+	Timeout time.Duration `mapstructure:"timeout"`
+	// Headers are added to each HTTP request, e.g. for the authentication or the tenant ID.
+	Headers map[string]string `mapstructure:"headers"`
+	// ExternalLabels are added to all series. The labels of the series take precedence
+	// if they have the same names.
+	ExternalLabels map[string]string `mapstructure:"external_labels"`
+	// MetricAggregationMap decides how the int metrics are written. The metrics not in
+	// the map are written as gauges. Histograms are always accumulated.
+	MetricAggregationMap map[string]MetricAggregationKind `mapstructure:"metric_aggregation_map"`
+	// SeriesExpiration is the time after which the state of an idle series is removed.
+	SeriesExpiration time.Duration `mapstructure:"series_expiration"`
+	AdapterConfig    AdapterConfig `mapstructure:"adapter_config"`
+	QueueConfig      QueueConfig   `mapstructure:"queue"`
+	WALConfig        WALConfig     `mapstructure:"wal"`
+}
+
+type AdapterConfig struct {
+	NeedTraceAsMetric  bool `mapstructure:"need_trace_as_metric"`
+	NeedPodDetail      bool `mapstructure:"need_pod_detail"`
+	StoreExternalSrcIP bool `mapstructure:"store_external_src_ip"`
+}
+
+type QueueConfig struct {
+	// Shards is the number of concurrent senders. A series is always sent by the
+	// same shard so its samples are written in order.
+	Shards int `mapstructure:"shards"`
+	// MaxSamplesPerSend is the maximum number of samples in one WriteRequest.
+	MaxSamplesPerSend int `mapstructure:"max_samples_per_send"`
+	// BatchSendDeadline is the maximum time the samples wait before being sent.
+	BatchSendDeadline time.Duration `mapstructure:"batch_send_deadline"`
+	// Capacity is the number of batches buffered in memory when the WAL is disabled.
+	Capacity int `mapstructure:"capacity"`
+	// MinBackoff is the time to wait before retrying after the first failure. It is
+	// doubled after each failure until MaxBackoff. Only network errors, 5xx and 429
+	// responses are retried.
+	MinBackoff time.Duration `mapstructure:"min_backoff"`
+	MaxBackoff time.Duration `mapstructure:"max_backoff"`
+	// ShutdownTimeout is how long the batches buffered are sent when shutting down before
+	// the sending is aborted. It should be less than the timeout of the shutdown section.
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+}
+
+type WALConfig struct {
+	// Enable controls whether the batches are written to disk before being sent,
+	// so the data that has not been sent will be replayed after restarting.
+	Enable bool `mapstructure:"enable"`
+	// Directory is the ABSOLUTE path of the directory where the WAL is saved.
+	Directory string `mapstructure:"directory"`
+	// MaxSizeBytes is the maximum bytes the WAL can use. The new batches will be
+	// dropped if the WAL is full.
+	MaxSizeBytes     int64 `mapstructure:"max_size_bytes"`
+	SegmentSizeBytes int64 `mapstructure:"segment_size_bytes"`
+}
+
+func NewDefaultConfig() *Config {
+	return &Config{
+		Endpoint: "http://127.0.0.1:9090/api/v1/write",
+		Timeout:  30 * time.Second,
+		MetricAggregationMap: map[string]MetricAggregationKind{
+			"kindling_entity_request_total":                           MACounterKind,
+			"kindling_entity_request_duration_nanoseconds_total":      MACounterKind,
+			"kindling_entity_request_send_bytes_total":                MACounterKind,
+			"kindling_entity_request_receive_bytes_total":             MACounterKind,
+			"kindling_topology_request_total":                         MACounterKind,
+			"kindling_topology_request_duration_nanoseconds_total":    MACounterKind,
+			"kindling_topology_request_request_bytes_total":           MACounterKind,
+			"kindling_topology_request_response_bytes_total":          MACounterKind,
+			"kindling_trace_request_duration_nanoseconds":             MAGaugeKind,
+			"kindling_tcp_srtt_microseconds":                          MAGaugeKind,
+			"kindling_tcp_retransmit_total":                           MACounterKind,
+			"kindling_tcp_packet_loss_total":                          MACounterKind,
+			"kindling_tcp_connect_total":                              MACounterKind,
+			"kindling_tcp_connect_duration_nanoseconds_total":         MACounterKind,
+			"kindling_k8s_workload_info":                              MAGaugeKind,
+			"kindling_profiling_request_total":                        MACounterKind,
+			"kindling_profiling_oncpu_duration_nanoseconds_total":     MACounterKind,
+			"kindling_profiling_file_duration_nanoseconds_total":      MACounterKind,
+			"kindling_profiling_net_duration_nanoseconds_total":       MACounterKind,
+			"kindling_profiling_futex_duration_nanoseconds_total":     MACounterKind,
+			"kindling_profiling_idle_duration_nanoseconds_total":      MACounterKind,
+			"kindling_profiling_other_duration_nanoseconds_total":     MACounterKind,
+			"kindling_profiling_epoll_duration_nanoseconds_total":     MACounterKind,
+			"kindling_profiling_runq_duration_nanoseconds_total":      MACounterKind,
+			"kindling_profiling_lock_wait_total":                      MACounterKind,
+			"kindling_profiling_lock_wait_duration_nanoseconds_total": MACounterKind,
+			"kindling_profiling_lock_wait_max_duration_nanoseconds":   MAGaugeKind,
+		},
+		SeriesExpiration: 5 * time.Minute,
+		QueueConfig: QueueConfig{
+			Shards:            4,
+			MaxSamplesPerSend: 500,
+			BatchSendDeadline: 5 * time.Second,
+			Capacity:          100,
+			MinBackoff:        30 * time.Millisecond,
+			MaxBackoff:        5 * time.Second,
+			ShutdownTimeout:   10 * time.Second,
+		},
+		WALConfig: WALConfig{
+			Enable:           false,
+			Directory:        "/tmp/kindling/remotewrite",
+			MaxSizeBytes:     256 * 1024 * 1024,
+			SegmentSizeBytes: 16 * 1024 * 1024,
+		},
+	}
+}
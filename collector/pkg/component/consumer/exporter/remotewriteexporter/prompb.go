@@ -0,0 +1,155 @@
+package remotewriteexporter
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The messages below are the subset of the Prometheus remote write protocol
+// (prometheus/prompb) used by this exporter. They are encoded by hand to avoid
+// depending on the whole Prometheus module.
+//
+//	message WriteRequest { repeated TimeSeries timeseries = 1; }
+//	message TimeSeries   { repeated Label labels = 1; repeated Sample samples = 2; }
+//	message Label        { string name = 1; string value = 2; }
+//	message Sample       { double value = 1; int64 timestamp = 2; }
+
+type label struct {
+	Name  string
+	Value string
+}
+
+type sample struct {
+	Value float64
+	// Timestamp is in milliseconds.
+	Timestamp int64
+}
+
+type timeSeries struct {
+	// Labels must be sorted by name.
+	Labels  []label
+	Samples []sample
+}
+
+var errInvalidMessage = errors.New("invalid protobuf message")
+
+func marshalWriteRequest(series []timeSeries) []byte {
+	buf := make([]byte, 0, 64*len(series))
+	var tsBuf, itemBuf []byte
+	for i := range series {
+		tsBuf = tsBuf[:0]
+		for _, l := range series[i].Labels {
+			itemBuf = itemBuf[:0]
+			itemBuf = protowire.AppendTag(itemBuf, 1, protowire.BytesType)
+			itemBuf = protowire.AppendString(itemBuf, l.Name)
+			itemBuf = protowire.AppendTag(itemBuf, 2, protowire.BytesType)
+			itemBuf = protowire.AppendString(itemBuf, l.Value)
+			tsBuf = protowire.AppendTag(tsBuf, 1, protowire.BytesType)
+			tsBuf = protowire.AppendBytes(tsBuf, itemBuf)
+		}
+		for _, s := range series[i].Samples {
+			itemBuf = itemBuf[:0]
+			itemBuf = protowire.AppendTag(itemBuf, 1, protowire.Fixed64Type)
+			itemBuf = protowire.AppendFixed64(itemBuf, math.Float64bits(s.Value))
+			itemBuf = protowire.AppendTag(itemBuf, 2, protowire.VarintType)
+			itemBuf = protowire.AppendVarint(itemBuf, uint64(s.Timestamp))
+			tsBuf = protowire.AppendTag(tsBuf, 2, protowire.BytesType)
+			tsBuf = protowire.AppendBytes(tsBuf, itemBuf)
+		}
+		buf = protowire.AppendTag(buf, 1, protowire.BytesType)
+		buf = protowire.AppendBytes(buf, tsBuf)
+	}
+	return buf
+}
+
+func unmarshalWriteRequest(data []byte) ([]timeSeries, error) {
+	var series []timeSeries
+	err := forEachField(data, func(num protowire.Number, typ protowire.Type, value []byte) error {
+		if num != 1 || typ != protowire.BytesType {
+			return nil
+		}
+		ts, err := unmarshalTimeSeries(value)
+		if err != nil {
+			return err
+		}
+		series = append(series, ts)
+		return nil
+	})
+	return series, err
+}
+
+func unmarshalTimeSeries(data []byte) (timeSeries, error) {
+	var ts timeSeries
+	err := forEachField(data, func(num protowire.Number, typ protowire.Type, value []byte) error {
+		if typ != protowire.BytesType {
+			return nil
+		}
+		switch num {
+		case 1:
+			var l label
+			err := forEachField(value, func(num protowire.Number, typ protowire.Type, value []byte) error {
+				if typ != protowire.BytesType {
+					return nil
+				}
+				switch num {
+				case 1:
+					l.Name = string(value)
+				case 2:
+					l.Value = string(value)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			ts.Labels = append(ts.Labels, l)
+		case 2:
+			var s sample
+			err := forEachField(value, func(num protowire.Number, typ protowire.Type, value []byte) error {
+				switch {
+				case num == 1 && typ == protowire.Fixed64Type:
+					v, _ := protowire.ConsumeFixed64(value)
+					s.Value = math.Float64frombits(v)
+				case num == 2 && typ == protowire.VarintType:
+					v, _ := protowire.ConsumeVarint(value)
+					s.Timestamp = int64(v)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			ts.Samples = append(ts.Samples, s)
+		}
+		return nil
+	})
+	return ts, err
+}
+
+// forEachField calls fn for each field of the message. For the bytes fields the value
+// is the content without the length prefix; for the others it is the raw encoded value.
+func forEachField(data []byte, fn func(num protowire.Number, typ protowire.Type, value []byte) error) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return fmt.Errorf("%w: %v", errInvalidMessage, protowire.ParseError(n))
+		}
+		data = data[n:]
+		n = protowire.ConsumeFieldValue(num, typ, data)
+		if n < 0 {
+			return fmt.Errorf("%w: %v", errInvalidMessage, protowire.ParseError(n))
+		}
+		value := data[:n]
+		if typ == protowire.BytesType {
+			value, _ = protowire.ConsumeBytes(value)
+		}
+		if err := fn(num, typ, value); err != nil {
+			return err
+		}
+		data = data[n:]
+	}
+	return nil
+}
@@ -0,0 +1,412 @@
+package remotewriteexporter
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component"
+	"github.com/Kindling-project/kindling/collector/pkg/component/consumer/exporter"
+	"github.com/Kindling-project/kindling/collector/pkg/component/consumer/exporter/tools/adapter"
+	"github.com/Kindling-project/kindling/collector/pkg/component/consumer/exporter/tools/diskqueue"
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constnames"
+)
+
+const Type = "remotewriteexporter"
+
+// ErrQueueFull is returned when the batch can't be buffered, either in memory or in the WAL.
+var ErrQueueFull = errors.New("queue of remotewriteexporter is full")
+
+var errStopped = errors.New("exporter is stopped")
+
+type shardJob struct {
+	series []timeSeries
+	done   func(ok bool)
+}
+
+// RemoteWriteExporter writes the metrics to a Prometheus remote write endpoint.
+// The series are batched and then buffered in memory, or in a WAL if it is enabled,
+// before being sent. Each batch is split across several shards which send it
+// concurrently, and it is removed from the WAL only after all shards have finished.
+type RemoteWriteExporter struct {
+	cfg       *Config
+	telemetry *component.TelemetryTools
+	adapters  []adapter.Adapter
+	client    *client
+
+	mu      sync.Mutex
+	builder *seriesBuilder
+	pending []timeSeries
+	// closed is true after Shutdown starts, and the data consumed is refused.
+	closed bool
+
+	// wal is nil if the WAL is disabled, and memQueue is used instead.
+	wal      *diskqueue.Queue
+	memQueue chan []byte
+	shards   []chan shardJob
+
+	// stopCh stops the ticker and lets the sender return once the batches buffered are sent.
+	stopCh chan struct{}
+	// ctx is canceled to abort sending if the batches can't be sent before ShutdownTimeout.
+	ctx      context.Context
+	cancel   context.CancelFunc
+	tickerWg sync.WaitGroup
+	senderWg sync.WaitGroup
+	shardWg  sync.WaitGroup
+}
+
+func New(config interface{}, telemetry *component.TelemetryTools) exporter.Exporter {
+	cfg, ok := config.(*Config)
+	if !ok {
+		telemetry.Logger.Panic("Cannot convert Component config", zap.String("componentType", Type))
+	}
+	newSelfMetrics(telemetry.MeterProvider)
+	defaults := NewDefaultConfig().QueueConfig
+	if cfg.QueueConfig.Shards <= 0 {
+		cfg.QueueConfig.Shards = defaults.Shards
+	}
+	if cfg.QueueConfig.MaxSamplesPerSend <= 0 {
+		cfg.QueueConfig.MaxSamplesPerSend = defaults.MaxSamplesPerSend
+	}
+	if cfg.QueueConfig.BatchSendDeadline <= 0 {
+		cfg.QueueConfig.BatchSendDeadline = defaults.BatchSendDeadline
+	}
+	if cfg.QueueConfig.MinBackoff <= 0 {
+		cfg.QueueConfig.MinBackoff = defaults.MinBackoff
+	}
+	if cfg.QueueConfig.MaxBackoff < cfg.QueueConfig.MinBackoff {
+		cfg.QueueConfig.MaxBackoff = cfg.QueueConfig.MinBackoff
+	}
+	if cfg.QueueConfig.ShutdownTimeout <= 0 {
+		cfg.QueueConfig.ShutdownTimeout = defaults.ShutdownTimeout
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	e := &RemoteWriteExporter{
+		cfg:       cfg,
+		telemetry: telemetry,
+		adapters: []adapter.Adapter{
+			adapter.NewNetAdapter(nil, &adapter.NetAdapterConfig{
+				StoreTraceAsMetric: cfg.AdapterConfig.NeedTraceAsMetric,
+				StoreTraceAsSpan:   false,
+				StorePodDetail:     cfg.AdapterConfig.NeedPodDetail,
+				StoreExternalSrcIP: cfg.AdapterConfig.StoreExternalSrcIP,
+			}),
+			adapter.NewSimpleAdapter([]string{constnames.TcpRttMetricGroupName, constnames.TcpRetransmitMetricGroupName,
+				constnames.TcpDropMetricGroupName, constnames.TcpConnectMetricGroupName, constnames.K8sWorkloadMetricGroupName,
+				constnames.RequestTimeBreakdownMetricGroupName, constnames.LockContentionMetricGroupName},
+				nil),
+		},
+		client:  newClient(cfg),
+		builder: newSeriesBuilder(cfg),
+		shards:  make([]chan shardJob, cfg.QueueConfig.Shards),
+		stopCh:  make(chan struct{}),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	if cfg.WALConfig.Enable {
+		wal, err := diskqueue.OpenQueue(cfg.WALConfig.Directory, cfg.WALConfig.MaxSizeBytes, cfg.WALConfig.SegmentSizeBytes)
+		if err != nil {
+			telemetry.Logger.Error("Failed to open the WAL, the data will be buffered in memory", zap.Error(err))
+		} else {
+			e.wal = wal
+			if count, size := wal.Stats(); count > 0 {
+				telemetry.Logger.Infof("WAL of remotewriteexporter has %d batches (%d bytes) left to be replayed", count, size)
+			}
+		}
+	}
+	if e.wal == nil {
+		e.memQueue = make(chan []byte, cfg.QueueConfig.Capacity)
+	}
+
+	for i := range e.shards {
+		e.shards[i] = make(chan shardJob)
+		e.shardWg.Add(1)
+		go e.runShard(e.shards[i])
+	}
+	e.senderWg.Add(1)
+	go e.runSender()
+	e.tickerWg.Add(1)
+	go e.runTicker()
+	return e
+}
+
+func (e *RemoteWriteExporter) Consume(dataGroup *model.DataGroup) error {
+	if dataGroup == nil {
+		// no need consume
+		return nil
+	}
+	if ce := e.telemetry.Logger.Check(zap.DebugLevel, ""); ce != nil {
+		e.telemetry.Logger.Debug("exporter receives a dataGroup:\n" + dataGroup.String())
+	}
+	now := time.Now()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		return errStopped
+	}
+	for i := 0; i < len(e.adapters); i++ {
+		results, err := e.adapters[i].Adapt(dataGroup, adapter.AttributeList)
+		if err != nil {
+			e.telemetry.Logger.Error("Failed to adapt dataGroup", zap.Error(err))
+		}
+		for _, result := range results {
+			if result.ResultType == adapter.Metric {
+				e.pending = append(e.pending, e.builder.build(result, now)...)
+			}
+			result.Free()
+		}
+	}
+	if len(e.pending) >= e.cfg.QueueConfig.Shards*e.cfg.QueueConfig.MaxSamplesPerSend {
+		return e.flushLocked()
+	}
+	return nil
+}
+
+// flushLocked moves the pending series to the queue. e.mu must be held.
+func (e *RemoteWriteExporter) flushLocked() error {
+	if len(e.pending) == 0 {
+		return nil
+	}
+	data := marshalWriteRequest(e.pending)
+	count := int64(len(e.pending))
+	e.pending = nil
+	if e.wal != nil {
+		if err := e.wal.Put(data); err != nil {
+			droppedSamplesCounter.Add(context.Background(), count, attribute.String("reason", dropReasonQueueFull))
+			return err
+		}
+		return nil
+	}
+	select {
+	case e.memQueue <- data:
+		return nil
+	default:
+		droppedSamplesCounter.Add(context.Background(), count, attribute.String("reason", dropReasonQueueFull))
+		return ErrQueueFull
+	}
+}
+
+func (e *RemoteWriteExporter) runTicker() {
+	defer e.tickerWg.Done()
+	ticker := time.NewTicker(e.cfg.QueueConfig.BatchSendDeadline)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			e.mu.Lock()
+			if err := e.flushLocked(); err != nil {
+				e.telemetry.Logger.Warn("Failed to flush the pending series", zap.Error(err))
+			}
+			e.builder.removeExpired(now)
+			e.mu.Unlock()
+		case <-e.stopCh:
+			return
+		}
+	}
+}
+
+// next blocks until a batch is available. The returned offset is used to
+// acknowledge the batch if the WAL is enabled. After stopCh is closed, errStopped is
+// returned once no batches are left.
+func (e *RemoteWriteExporter) next() ([]byte, int64, error) {
+	if e.wal == nil {
+		select {
+		case data := <-e.memQueue:
+			return data, 0, nil
+		case <-e.stopCh:
+			select {
+			case data := <-e.memQueue:
+				return data, 0, nil
+			default:
+				return nil, 0, errStopped
+			}
+		case <-e.ctx.Done():
+			return nil, 0, errStopped
+		}
+	}
+	stopping := false
+	for {
+		data, offset, err := e.wal.Peek()
+		if err == nil {
+			return data, offset, nil
+		}
+		if !errors.Is(err, diskqueue.ErrQueueEmpty) {
+			skipped, skipErr := e.wal.SkipSegment()
+			e.telemetry.Logger.Error("Failed to read the WAL, the rest of the segment is skipped",
+				zap.Int64("skipped", skipped), zap.Error(err), zap.NamedError("skipError", skipErr))
+			continue
+		}
+		if stopping {
+			return nil, 0, errStopped
+		}
+		select {
+		case <-e.wal.Notify():
+		case <-e.stopCh:
+			// The WAL is checked once more for the batches put before stopping.
+			stopping = true
+		case <-e.ctx.Done():
+			return nil, 0, errStopped
+		}
+	}
+}
+
+func (e *RemoteWriteExporter) ack(offset int64) {
+	if e.wal == nil {
+		return
+	}
+	if err := e.wal.Ack(offset); err != nil {
+		e.telemetry.Logger.Warn("Failed to save the cursor of the WAL", zap.Error(err))
+	}
+}
+
+func (e *RemoteWriteExporter) runSender() {
+	defer e.senderWg.Done()
+	for {
+		data, offset, err := e.next()
+		if err != nil {
+			return
+		}
+		series, err := unmarshalWriteRequest(data)
+		if err != nil {
+			droppedSamplesCounter.Add(context.Background(), 1, attribute.String("reason", dropReasonDecodeFailed))
+			e.telemetry.Logger.Error("Failed to decode the batch, it is dropped", zap.Error(err))
+			e.ack(offset)
+			continue
+		}
+		if !e.sendSharded(series) {
+			// Stopped before the batch is sent completely. It will be replayed
+			// next time if the WAL is enabled.
+			return
+		}
+		e.ack(offset)
+	}
+}
+
+// sendSharded splits the series by their labels and sends them concurrently.
+// It returns false if the exporter is stopped before all shards finish.
+func (e *RemoteWriteExporter) sendSharded(series []timeSeries) bool {
+	perShard := make([][]timeSeries, len(e.shards))
+	for _, ts := range series {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(seriesKey(ts.Labels)))
+		i := h.Sum32() % uint32(len(e.shards))
+		perShard[i] = append(perShard[i], ts)
+	}
+	var wg sync.WaitGroup
+	results := make([]bool, len(e.shards))
+	for i := range perShard {
+		if len(perShard[i]) == 0 {
+			results[i] = true
+			continue
+		}
+		i := i
+		wg.Add(1)
+		e.shards[i] <- shardJob{series: perShard[i], done: func(ok bool) {
+			results[i] = ok
+			wg.Done()
+		}}
+	}
+	wg.Wait()
+	for _, ok := range results {
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *RemoteWriteExporter) runShard(jobs chan shardJob) {
+	defer e.shardWg.Done()
+	for job := range jobs {
+		job.done(e.sendSeries(job.series))
+	}
+}
+
+// sendSeries sends the series in WriteRequests of at most MaxSamplesPerSend samples.
+// The recoverable failures are retried with backoff until the exporter is stopped.
+func (e *RemoteWriteExporter) sendSeries(series []timeSeries) bool {
+	for len(series) > 0 {
+		size := e.cfg.QueueConfig.MaxSamplesPerSend
+		if size > len(series) {
+			size = len(series)
+		}
+		chunk := series[:size]
+		series = series[size:]
+		backoff := e.cfg.QueueConfig.MinBackoff
+		for {
+			err := e.client.store(e.ctx, chunk)
+			if err == nil {
+				sentSamplesCounter.Add(context.Background(), int64(len(chunk)))
+				break
+			}
+			var recoverable recoverableError
+			if !errors.As(err, &recoverable) {
+				droppedSamplesCounter.Add(context.Background(), int64(len(chunk)), attribute.String("reason", dropReasonRejected))
+				e.telemetry.Logger.Warn("WriteRequest is rejected, the samples are dropped",
+					zap.Int("samples", len(chunk)), zap.Error(err))
+				break
+			}
+			if e.ctx.Err() != nil {
+				return false
+			}
+			retriesCounter.Add(context.Background(), 1)
+			e.telemetry.Logger.Warn("Failed to send WriteRequest, will retry later",
+				zap.Duration("backoff", backoff), zap.Error(err))
+			select {
+			case <-time.After(backoff):
+			case <-e.ctx.Done():
+				return false
+			}
+			backoff *= 2
+			if backoff > e.cfg.QueueConfig.MaxBackoff {
+				backoff = e.cfg.QueueConfig.MaxBackoff
+			}
+		}
+	}
+	return true
+}
+
+// Shutdown flushes the pending series and waits up to ShutdownTimeout for the batches
+// buffered to be sent. Then the sending is aborted. If the WAL is enabled, the batches
+// that have not been sent will be replayed next time; otherwise they are lost.
+func (e *RemoteWriteExporter) Shutdown() error {
+	e.mu.Lock()
+	err := e.flushLocked()
+	e.closed = true
+	e.mu.Unlock()
+	close(e.stopCh)
+	e.tickerWg.Wait()
+
+	senderDone := make(chan struct{})
+	go func() {
+		e.senderWg.Wait()
+		close(senderDone)
+	}()
+	timer := time.NewTimer(e.cfg.QueueConfig.ShutdownTimeout)
+	defer timer.Stop()
+	select {
+	case <-senderDone:
+	case <-timer.C:
+		e.telemetry.Logger.Warn("Timed out sending the batches buffered, the rest is aborted",
+			zap.Duration("timeout", e.cfg.QueueConfig.ShutdownTimeout), zap.Int("memQueue", len(e.memQueue)))
+	}
+	e.cancel()
+	<-senderDone
+	for _, shard := range e.shards {
+		close(shard)
+	}
+	e.shardWg.Wait()
+	if e.wal != nil {
+		if closeErr := e.wal.Close(); closeErr != nil {
+			return closeErr
+		}
+	}
+	return err
+}
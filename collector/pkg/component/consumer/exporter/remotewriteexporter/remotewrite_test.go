@@ -0,0 +1,269 @@
+package remotewriteexporter
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component"
+	"github.com/Kindling-project/kindling/collector/pkg/component/consumer/exporter/tools/adapter"
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constlabels"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constnames"
+)
+
+// fakeReceiver is a stand-in of the remote write endpoint.
+type fakeReceiver struct {
+	t *testing.T
+
+	mu       sync.Mutex
+	status   int
+	requests int
+	series   []timeSeries
+}
+
+func newFakeReceiver(t *testing.T) (*fakeReceiver, *httptest.Server) {
+	r := &fakeReceiver{t: t, status: http.StatusNoContent}
+	return r, httptest.NewServer(r)
+}
+
+func (r *fakeReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests++
+	if r.status/100 != 2 {
+		w.WriteHeader(r.status)
+		return
+	}
+	assert.Equal(r.t, "snappy", req.Header.Get("Content-Encoding"))
+	assert.Equal(r.t, "application/x-protobuf", req.Header.Get("Content-Type"))
+	assert.Equal(r.t, "0.1.0", req.Header.Get("X-Prometheus-Remote-Write-Version"))
+	assert.Equal(r.t, "tenant-a", req.Header.Get("X-Scope-OrgID"))
+	compressed, err := io.ReadAll(req.Body)
+	assert.NoError(r.t, err)
+	data, err := snappy.Decode(nil, compressed)
+	assert.NoError(r.t, err)
+	series, err := unmarshalWriteRequest(data)
+	assert.NoError(r.t, err)
+	r.series = append(r.series, series...)
+	w.WriteHeader(r.status)
+}
+
+func (r *fakeReceiver) setStatus(status int) {
+	r.mu.Lock()
+	r.status = status
+	r.mu.Unlock()
+}
+
+func (r *fakeReceiver) received() ([]timeSeries, int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]timeSeries(nil), r.series...), r.requests
+}
+
+func testConfig(endpoint string) *Config {
+	cfg := NewDefaultConfig()
+	cfg.Endpoint = endpoint
+	cfg.Headers = map[string]string{"X-Scope-OrgID": "tenant-a"}
+	cfg.ExternalLabels = map[string]string{"cluster": "test-cluster", "dst_ip": "overridden"}
+	cfg.QueueConfig.Shards = 2
+	cfg.QueueConfig.MaxSamplesPerSend = 5
+	cfg.QueueConfig.BatchSendDeadline = 20 * time.Millisecond
+	cfg.QueueConfig.MinBackoff = 10 * time.Millisecond
+	cfg.QueueConfig.MaxBackoff = 20 * time.Millisecond
+	cfg.QueueConfig.ShutdownTimeout = 100 * time.Millisecond
+	return cfg
+}
+
+func newRetransmitDataGroup(dstPort int64, value int64) *model.DataGroup {
+	labels := model.NewAttributeMap()
+	labels.AddStringValue(constlabels.DstIp, "10.0.0.1")
+	labels.AddIntValue(constlabels.DstPort, dstPort)
+	return model.NewDataGroup(constnames.TcpRetransmitMetricGroupName, labels, uint64(time.Now().UnixNano()),
+		model.NewIntMetric(constnames.TcpRetransmitMetricName, value))
+}
+
+func labelValue(ts timeSeries, name string) string {
+	for _, l := range ts.Labels {
+		if l.Name == name {
+			return l.Value
+		}
+	}
+	return ""
+}
+
+func TestWriteRequest(t *testing.T) {
+	receiver, server := newFakeReceiver(t)
+	defer server.Close()
+	e := New(testConfig(server.URL), component.NewDefaultTelemetryTools()).(*RemoteWriteExporter)
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, e.Consume(newRetransmitDataGroup(80, 2)))
+	}
+	assert.Eventually(t, func() bool {
+		series, _ := receiver.received()
+		return len(series) == 3
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.NoError(t, e.Shutdown())
+
+	series, _ := receiver.received()
+	for i, ts := range series {
+		assert.Equal(t, constnames.TcpRetransmitMetricName, labelValue(ts, metricNameLabel))
+		assert.Equal(t, "test-cluster", labelValue(ts, "cluster"))
+		// The labels of the series take precedence over the external labels.
+		assert.Equal(t, "10.0.0.1", labelValue(ts, constlabels.DstIp))
+		assert.Equal(t, "80", labelValue(ts, constlabels.DstPort))
+		// kindling_tcp_retransmit_total is a counter, so the values are accumulated.
+		assert.Equal(t, float64(2*(i+1)), ts.Samples[0].Value)
+		for j := 1; j < len(ts.Labels); j++ {
+			assert.Less(t, ts.Labels[j-1].Name, ts.Labels[j].Name)
+		}
+	}
+}
+
+func TestShardsAndMaxSamplesPerSend(t *testing.T) {
+	receiver, server := newFakeReceiver(t)
+	defer server.Close()
+	e := New(testConfig(server.URL), component.NewDefaultTelemetryTools()).(*RemoteWriteExporter)
+	for i := 0; i < 50; i++ {
+		assert.NoError(t, e.Consume(newRetransmitDataGroup(int64(i%10), 1)))
+	}
+	assert.Eventually(t, func() bool {
+		series, _ := receiver.received()
+		return len(series) == 50
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.NoError(t, e.Shutdown())
+
+	series, requests := receiver.received()
+	assert.GreaterOrEqual(t, requests, 10)
+	// The samples of the same series are sent in order by the same shard.
+	last := make(map[string]float64)
+	for _, ts := range series {
+		key := seriesKey(ts.Labels)
+		assert.Greater(t, ts.Samples[0].Value, last[key])
+		last[key] = ts.Samples[0].Value
+	}
+	assert.Len(t, last, 10)
+}
+
+func TestRetry(t *testing.T) {
+	receiver, server := newFakeReceiver(t)
+	defer server.Close()
+	receiver.setStatus(http.StatusServiceUnavailable)
+	e := New(testConfig(server.URL), component.NewDefaultTelemetryTools()).(*RemoteWriteExporter)
+	assert.NoError(t, e.Consume(newRetransmitDataGroup(80, 1)))
+	assert.Eventually(t, func() bool {
+		_, requests := receiver.received()
+		return requests >= 3
+	}, 5*time.Second, 10*time.Millisecond)
+
+	receiver.setStatus(http.StatusNoContent)
+	assert.Eventually(t, func() bool {
+		series, _ := receiver.received()
+		return len(series) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.NoError(t, e.Shutdown())
+}
+
+func TestNoRetryOnClientError(t *testing.T) {
+	receiver, server := newFakeReceiver(t)
+	defer server.Close()
+	receiver.setStatus(http.StatusBadRequest)
+	e := New(testConfig(server.URL), component.NewDefaultTelemetryTools()).(*RemoteWriteExporter)
+	assert.NoError(t, e.Consume(newRetransmitDataGroup(80, 1)))
+	assert.Eventually(t, func() bool {
+		_, requests := receiver.received()
+		return requests == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	_, requests := receiver.received()
+	assert.Equal(t, 1, requests)
+	assert.NoError(t, e.Shutdown())
+}
+
+func TestWALReplayAfterRestart(t *testing.T) {
+	receiver, server := newFakeReceiver(t)
+	defer server.Close()
+	receiver.setStatus(http.StatusServiceUnavailable)
+	cfg := testConfig(server.URL)
+	cfg.WALConfig.Enable = true
+	cfg.WALConfig.Directory = t.TempDir()
+	cfg.WALConfig.SegmentSizeBytes = 1024
+	e := New(cfg, component.NewDefaultTelemetryTools()).(*RemoteWriteExporter)
+	for i := 0; i < 20; i++ {
+		assert.NoError(t, e.Consume(newRetransmitDataGroup(int64(i), 1)))
+	}
+	assert.NoError(t, e.Shutdown())
+	series, _ := receiver.received()
+	assert.Empty(t, series)
+
+	receiver.setStatus(http.StatusNoContent)
+	e = New(cfg, component.NewDefaultTelemetryTools()).(*RemoteWriteExporter)
+	assert.Eventually(t, func() bool {
+		series, _ := receiver.received()
+		return len(series) == 20
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.NoError(t, e.Shutdown())
+	count, _ := e.wal.Stats()
+	assert.Equal(t, int64(0), count)
+}
+
+func TestShutdownSendsBufferedBatches(t *testing.T) {
+	receiver, server := newFakeReceiver(t)
+	defer server.Close()
+	cfg := testConfig(server.URL)
+	cfg.QueueConfig.BatchSendDeadline = time.Hour
+	cfg.QueueConfig.ShutdownTimeout = 5 * time.Second
+	e := New(cfg, component.NewDefaultTelemetryTools()).(*RemoteWriteExporter)
+	for i := 0; i < 30; i++ {
+		assert.NoError(t, e.Consume(newRetransmitDataGroup(int64(i), 1)))
+	}
+	// The batches in the memory queue and the pending series are all sent.
+	assert.NoError(t, e.Shutdown())
+	series, _ := receiver.received()
+	assert.Len(t, series, 30)
+	assert.ErrorIs(t, e.Consume(newRetransmitDataGroup(0, 1)), errStopped)
+}
+
+func TestHistogram(t *testing.T) {
+	builder := newSeriesBuilder(&Config{SeriesExpiration: time.Minute})
+	histogram := &model.Histogram{Sum: 30, Count: 3, ExplicitBoundaries: []int64{10, 20}, BucketCounts: []uint64{1, 2}}
+	result := &adapter.AdaptedResult{
+		ResultType: adapter.Metric,
+		Metrics:    []*model.Metric{model.NewHistogramMetric("request.duration", histogram)},
+		Timestamp:  uint64(2 * time.Second),
+	}
+	now := time.Now()
+	builder.build(result, now)
+	series := builder.build(result, now)
+	values := make(map[string]float64)
+	for _, ts := range series {
+		assert.Equal(t, int64(2000), ts.Samples[0].Timestamp)
+		values[labelValue(ts, metricNameLabel)+"{"+labelValue(ts, bucketLabel)+"}"] = ts.Samples[0].Value
+	}
+	assert.Equal(t, map[string]float64{
+		"request_duration_bucket{10}":   2,
+		"request_duration_bucket{20}":   4,
+		"request_duration_bucket{+Inf}": 6,
+		"request_duration_sum{}":        60,
+		"request_duration_count{}":      6,
+	}, values)
+
+	builder.removeExpired(now.Add(2 * time.Minute))
+	assert.Empty(t, builder.cumulative)
+}
+
+func TestProtobufRoundTrip(t *testing.T) {
+	series := []timeSeries{
+		{Labels: []label{{Name: "__name__", Value: "up"}, {Name: "job", Value: "kindling"}}, Samples: []sample{{Value: 1.5, Timestamp: 1000}}},
+		{Labels: []label{{Name: "__name__", Value: "down"}}, Samples: []sample{{Value: -3, Timestamp: -1}}},
+	}
+	decoded, err := unmarshalWriteRequest(marshalWriteRequest(series))
+	assert.NoError(t, err)
+	assert.Equal(t, series, decoded)
+}
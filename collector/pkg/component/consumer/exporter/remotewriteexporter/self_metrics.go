@@ -0,0 +1,39 @@
+package remotewriteexporter
+
+import (
+	"sync"
+
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	sentSamplesMetric    = "kindling_telemetry_remotewriteexporter_sent_samples_total"
+	droppedSamplesMetric = "kindling_telemetry_remotewriteexporter_dropped_samples_total"
+	retriesMetric        = "kindling_telemetry_remotewriteexporter_retries_total"
+)
+
+const (
+	dropReasonQueueFull    = "queue_full"
+	dropReasonRejected     = "rejected"
+	dropReasonDecodeFailed = "decode_failed"
+)
+
+var once sync.Once
+
+var (
+	sentSamplesCounter    metric.Int64Counter
+	droppedSamplesCounter metric.Int64Counter
+	retriesCounter        metric.Int64Counter
+)
+
+func newSelfMetrics(meterProvider metric.MeterProvider) {
+	once.Do(func() {
+		meter := metric.Must(meterProvider.Meter("kindling"))
+		sentSamplesCounter = meter.NewInt64Counter(sentSamplesMetric,
+			metric.WithDescription("The total number of samples sent to the remote write endpoint successfully"))
+		droppedSamplesCounter = meter.NewInt64Counter(droppedSamplesMetric,
+			metric.WithDescription("The total number of samples dropped by remotewriteexporter"))
+		retriesCounter = meter.NewInt64Counter(retriesMetric,
+			metric.WithDescription("The total number of the WriteRequests retried"))
+	})
+}
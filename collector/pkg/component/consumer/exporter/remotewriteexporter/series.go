@@ -0,0 +1,176 @@
+package remotewriteexporter
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component/consumer/exporter/tools/adapter"
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+)
+
+const (
+	metricNameLabel = "__name__"
+	bucketLabel     = "le"
+	// labelSeparator can't be a part of the label names and is unlikely to be a part of
+	// the label values, so it is used to build the key of a series.
+	labelSeparator = "\xff"
+)
+
+type cumulativeValue struct {
+	value      float64
+	lastUpdate time.Time
+}
+
+// seriesBuilder converts the adapted results into time series. The counters and
+// the histograms are accumulated because the values received are increments.
+type seriesBuilder struct {
+	externalLabels []label
+	aggregationMap map[string]MetricAggregationKind
+	expiration     time.Duration
+	cumulative     map[string]*cumulativeValue
+}
+
+func newSeriesBuilder(cfg *Config) *seriesBuilder {
+	externalLabels := make([]label, 0, len(cfg.ExternalLabels))
+	for k, v := range cfg.ExternalLabels {
+		externalLabels = append(externalLabels, label{Name: sanitize(k), Value: v})
+	}
+	return &seriesBuilder{
+		externalLabels: externalLabels,
+		aggregationMap: cfg.MetricAggregationMap,
+		expiration:     cfg.SeriesExpiration,
+		cumulative:     make(map[string]*cumulativeValue),
+	}
+}
+
+func (b *seriesBuilder) build(result *adapter.AdaptedResult, now time.Time) []timeSeries {
+	timestamp := int64(result.Timestamp / uint64(time.Millisecond))
+	if timestamp == 0 {
+		timestamp = now.UnixMilli()
+	}
+	baseLabels := b.baseLabels(result.AttrsList)
+	series := make([]timeSeries, 0, len(result.Metrics))
+	for _, metric := range result.Metrics {
+		name := sanitize(metric.Name)
+		switch metric.DataType() {
+		case model.IntMetricType:
+			value := float64(metric.GetInt().Value)
+			labels := withLabels(baseLabels, label{Name: metricNameLabel, Value: name})
+			if b.aggregationMap[metric.Name] == MACounterKind {
+				value = b.accumulate(labels, value, now)
+			}
+			series = append(series, newSeries(labels, value, timestamp))
+		case model.HistogramMetricType:
+			series = append(series, b.buildHistogram(name, baseLabels, metric.GetHistogram(), timestamp, now)...)
+		}
+	}
+	return series
+}
+
+func (b *seriesBuilder) buildHistogram(name string, baseLabels []label, histogram *model.Histogram, timestamp int64, now time.Time) []timeSeries {
+	series := make([]timeSeries, 0, len(histogram.ExplicitBoundaries)+3)
+	bucketName := label{Name: metricNameLabel, Value: name + "_bucket"}
+	for i, bound := range histogram.ExplicitBoundaries {
+		if i >= len(histogram.BucketCounts) {
+			break
+		}
+		labels := withLabels(baseLabels, bucketName, label{Name: bucketLabel, Value: strconv.FormatInt(bound, 10)})
+		series = append(series, newSeries(labels, b.accumulate(labels, float64(histogram.BucketCounts[i]), now), timestamp))
+	}
+	labels := withLabels(baseLabels, bucketName, label{Name: bucketLabel, Value: "+Inf"})
+	series = append(series, newSeries(labels, b.accumulate(labels, float64(histogram.Count), now), timestamp))
+	labels = withLabels(baseLabels, label{Name: metricNameLabel, Value: name + "_sum"})
+	series = append(series, newSeries(labels, b.accumulate(labels, float64(histogram.Sum), now), timestamp))
+	labels = withLabels(baseLabels, label{Name: metricNameLabel, Value: name + "_count"})
+	series = append(series, newSeries(labels, b.accumulate(labels, float64(histogram.Count), now), timestamp))
+	return series
+}
+
+// baseLabels merges the labels of the result and the external labels.
+func (b *seriesBuilder) baseLabels(attrs []attribute.KeyValue) []label {
+	labels := make([]label, 0, len(attrs)+len(b.externalLabels)+2)
+	seen := make(map[string]struct{}, len(attrs)+len(b.externalLabels))
+	for _, kv := range attrs {
+		name := sanitize(string(kv.Key))
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+		labels = append(labels, label{Name: name, Value: kv.Value.Emit()})
+	}
+	for _, l := range b.externalLabels {
+		if _, ok := seen[l.Name]; ok {
+			continue
+		}
+		labels = append(labels, l)
+	}
+	return labels
+}
+
+func (b *seriesBuilder) accumulate(labels []label, delta float64, now time.Time) float64 {
+	key := seriesKey(labels)
+	v, ok := b.cumulative[key]
+	if !ok {
+		v = &cumulativeValue{}
+		b.cumulative[key] = v
+	}
+	v.value += delta
+	v.lastUpdate = now
+	return v.value
+}
+
+// removeExpired removes the idle series. Their values will restart from zero, which
+// is regarded as a counter reset by Prometheus.
+func (b *seriesBuilder) removeExpired(now time.Time) {
+	for key, v := range b.cumulative {
+		if now.Sub(v.lastUpdate) > b.expiration {
+			delete(b.cumulative, key)
+		}
+	}
+}
+
+func newSeries(labels []label, value float64, timestamp int64) timeSeries {
+	return timeSeries{Labels: labels, Samples: []sample{{Value: value, Timestamp: timestamp}}}
+}
+
+// withLabels returns a sorted copy of the base labels with the extra labels added.
+func withLabels(base []label, extra ...label) []label {
+	labels := make([]label, 0, len(base)+len(extra))
+	labels = append(labels, base...)
+	labels = append(labels, extra...)
+	sort.Slice(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name })
+	return labels
+}
+
+func seriesKey(labels []label) string {
+	var sb strings.Builder
+	for _, l := range labels {
+		sb.WriteString(l.Name)
+		sb.WriteString(labelSeparator)
+		sb.WriteString(l.Value)
+		sb.WriteString(labelSeparator)
+	}
+	return sb.String()
+}
+
+// sanitize replaces the characters not allowed in Prometheus names with underscores.
+func sanitize(s string) string {
+	if len(s) == 0 {
+		return s
+	}
+	s = strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return r
+		}
+		return '_'
+	}, s)
+	if unicode.IsDigit(rune(s[0])) {
+		s = "key_" + s
+	}
+	return s
+}
@@ -0,0 +1,31 @@
+package batcher
+
+import "time"
+
+type Config struct {
+	// Enable controls whether the exporter is wrapped by a batching queue.
+	Enable bool `mapstructure:"enable"`
+	// BatchSize is the number of DataGroups sent together. A batch is sent once it is full
+	// or FlushInterval elapses.
+	BatchSize     int           `mapstructure:"batch_size"`
+	FlushInterval time.Duration `mapstructure:"flush_interval"`
+	// Workers is the number of goroutines sending the batches to the exporter concurrently.
+	Workers int `mapstructure:"workers"`
+	// QueueSize is the maximum number of batches waiting to be sent.
+	QueueSize int `mapstructure:"queue_size"`
+	// BlockTimeout is how long a full batch waits for room in the queue before it is
+	// dropped, which slows down the processors instead. It is dropped immediately if
+	// BlockTimeout is not positive.
+	BlockTimeout time.Duration `mapstructure:"block_timeout"`
+}
+
+func NewDefaultConfig() *Config {
+	return &Config{
+		Enable:        false,
+		BatchSize:     500,
+		FlushInterval: time.Second,
+		Workers:       2,
+		QueueSize:     100,
+		BlockTimeout:  0,
+	}
+}
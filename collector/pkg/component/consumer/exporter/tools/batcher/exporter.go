@@ -0,0 +1,283 @@
+package batcher
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/atomic"
+	"go.uber.org/zap"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component"
+	"github.com/Kindling-project/kindling/collector/pkg/component/consumer/exporter"
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+)
+
+var (
+	// ErrMemoryLimitExceeded is returned when the memory used by all the batchers hits the limit.
+	// The caller should treat it as backpressure and the data is not accepted.
+	ErrMemoryLimitExceeded = errors.New("memory limit of the batcher is exceeded")
+	// ErrQueueFull is returned when there are too many batches waiting to be sent.
+	ErrQueueFull = errors.New("queue of the batcher is full")
+	// ErrShutdown is returned when the data is consumed after the batcher is shut down.
+	ErrShutdown = errors.New("batcher is shut down")
+)
+
+const (
+	dropReasonMemoryLimit  = "memory_limit"
+	dropReasonQueueFull    = "queue_full"
+	dropReasonExportFailed = "export_failed"
+	dropReasonShutdown     = "shutdown"
+)
+
+type batch struct {
+	dataGroups []*model.DataGroup
+	size       int64
+}
+
+// BatchExporter decouples the exporter from the processor chain. The DataGroups
+// are cached in batches and sent to the wrapped exporter by several workers, so a
+// slow exporter won't block the analyzers. The memory held by all the batchers is
+// limited by the MemoryLimiter they share, and the data will be refused instead of
+// blocking the caller when the limit is hit.
+type BatchExporter struct {
+	name      string
+	cfg       *Config
+	limiter   *MemoryLimiter
+	next      exporter.Exporter
+	telemetry *component.TelemetryTools
+
+	mu      sync.Mutex
+	current *batch
+	// closeMu is held for reading while the DataGroups are added and the batches are
+	// enqueued, and for writing when the batcher is closed.
+	closeMu sync.RWMutex
+	closed  bool
+	batches chan *batch
+
+	// memoryUsage is the part of the memory counted by the limiter held by this batcher.
+	memoryUsage *atomic.Int64
+	dropped     map[string]*atomic.Int64
+
+	stopCh  chan struct{}
+	stopped sync.Once
+	flushWg sync.WaitGroup
+	workWg  sync.WaitGroup
+}
+
+// NewBatchExporter creates a BatchExporter and starts its workers. The limiter should be
+// shared by all the batchers of the application.
+func NewBatchExporter(name string, cfg *Config, limiter *MemoryLimiter, telemetry *component.TelemetryTools, next exporter.Exporter) *BatchExporter {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 1
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = 1
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = time.Second
+	}
+	e := &BatchExporter{
+		name:        name,
+		cfg:         cfg,
+		limiter:     limiter,
+		next:        next,
+		telemetry:   telemetry,
+		current:     newBatch(cfg.BatchSize),
+		batches:     make(chan *batch, cfg.QueueSize),
+		memoryUsage: atomic.NewInt64(0),
+		dropped: map[string]*atomic.Int64{
+			dropReasonMemoryLimit:  atomic.NewInt64(0),
+			dropReasonQueueFull:    atomic.NewInt64(0),
+			dropReasonExportFailed: atomic.NewInt64(0),
+			dropReasonShutdown:     atomic.NewInt64(0),
+		},
+		stopCh: make(chan struct{}),
+	}
+	registerBatcher(telemetry.MeterProvider, e)
+	for i := 0; i < cfg.Workers; i++ {
+		e.workWg.Add(1)
+		go e.work()
+	}
+	e.flushWg.Add(1)
+	go e.runTicker()
+	return e
+}
+
+func newBatch(capacity int) *batch {
+	return &batch{dataGroups: make([]*model.DataGroup, 0, capacity)}
+}
+
+func (e *BatchExporter) Consume(dataGroup *model.DataGroup) error {
+	if dataGroup == nil {
+		return nil
+	}
+	// The read lock is held until the DataGroup is added, so Shutdown sends the last batch
+	// after all the DataGroups accepted are in it.
+	e.closeMu.RLock()
+	defer e.closeMu.RUnlock()
+	if e.closed {
+		e.dropped[dropReasonShutdown].Inc()
+		return ErrShutdown
+	}
+	size := estimateSize(dataGroup)
+	if !e.acquire(size) {
+		e.dropped[dropReasonMemoryLimit].Inc()
+		return ErrMemoryLimitExceeded
+	}
+	// Clone the data because it may be reused by the previous components after returning.
+	cloned := dataGroup.Clone()
+	e.mu.Lock()
+	e.current.dataGroups = append(e.current.dataGroups, cloned)
+	e.current.size += size
+	var full *batch
+	if len(e.current.dataGroups) >= e.cfg.BatchSize {
+		full = e.current
+		e.current = newBatch(e.cfg.BatchSize)
+	}
+	e.mu.Unlock()
+	if full != nil {
+		return e.enqueue(full)
+	}
+	return nil
+}
+
+func (e *BatchExporter) acquire(size int64) bool {
+	if !e.limiter.acquire(size) {
+		return false
+	}
+	e.memoryUsage.Add(size)
+	return true
+}
+
+func (e *BatchExporter) release(size int64) {
+	e.memoryUsage.Sub(size)
+	e.limiter.release(size)
+}
+
+// enqueue passes the batch to the workers. It waits up to BlockTimeout for a free slot
+// before the batch is refused, so the caller is slowed down instead of losing the data
+// when the exporter falls behind for a moment. It must be called with closeMu held.
+func (e *BatchExporter) enqueue(b *batch) error {
+	select {
+	case e.batches <- b:
+		return nil
+	default:
+	}
+	if e.cfg.BlockTimeout > 0 {
+		timer := time.NewTimer(e.cfg.BlockTimeout)
+		defer timer.Stop()
+		select {
+		case e.batches <- b:
+			return nil
+		case <-timer.C:
+		}
+	}
+	e.release(b.size)
+	e.dropped[dropReasonQueueFull].Add(int64(len(b.dataGroups)))
+	return ErrQueueFull
+}
+
+// takeCurrent replaces the current batch with an empty one. It returns nil if the current
+// batch is empty.
+func (e *BatchExporter) takeCurrent() *batch {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.current.dataGroups) == 0 {
+		return nil
+	}
+	b := e.current
+	e.current = newBatch(e.cfg.BatchSize)
+	return b
+}
+
+// flush sends the current batch even if it is not full.
+func (e *BatchExporter) flush() {
+	e.closeMu.RLock()
+	defer e.closeMu.RUnlock()
+	if e.closed {
+		return
+	}
+	b := e.takeCurrent()
+	if b == nil {
+		return
+	}
+	if err := e.enqueue(b); err != nil {
+		e.telemetry.Logger.Warn("Failed to flush the batch", zap.String("exporter", e.name),
+			zap.Int("size", len(b.dataGroups)), zap.Error(err))
+	}
+}
+
+func (e *BatchExporter) runTicker() {
+	defer e.flushWg.Done()
+	ticker := time.NewTicker(e.cfg.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			e.flush()
+		case <-e.stopCh:
+			return
+		}
+	}
+}
+
+func (e *BatchExporter) work() {
+	defer e.workWg.Done()
+	for b := range e.batches {
+		e.export(b)
+	}
+}
+
+func (e *BatchExporter) export(b *batch) {
+	var failed int64
+	if batchConsumer, ok := e.next.(exporter.BatchConsumer); ok {
+		if err := batchConsumer.ConsumeBatch(b.dataGroups); err != nil {
+			failed = int64(len(b.dataGroups))
+			e.telemetry.Logger.Debug("Failed to export the batch", zap.String("exporter", e.name), zap.Error(err))
+		}
+	} else {
+		for _, dataGroup := range b.dataGroups {
+			if err := e.next.Consume(dataGroup); err != nil {
+				failed++
+				e.telemetry.Logger.Debug("Failed to export DataGroup", zap.String("exporter", e.name), zap.Error(err))
+			}
+		}
+	}
+	e.release(b.size)
+	if failed > 0 {
+		e.dropped[dropReasonExportFailed].Add(failed)
+		e.telemetry.Logger.Warn("Some DataGroups of the batch failed to be exported", zap.String("exporter", e.name),
+			zap.Int64("failed", failed), zap.Int("size", len(b.dataGroups)))
+	}
+}
+
+// QueueDepths returns the fill levels of the batches waiting to be sent and the memory held.
+// The capacity of the memory is the global limit shared with the other batchers.
+func (e *BatchExporter) QueueDepths() map[string]component.QueueDepth {
+	return map[string]component.QueueDepth{
+		"batches":      {Length: int64(len(e.batches)), Capacity: int64(cap(e.batches))},
+		"memory_bytes": {Length: e.memoryUsage.Load(), Capacity: e.limiter.Limit()},
+	}
+}
+
+// Shutdown sends all the cached data and stops the workers. The data consumed after it
+// starts is refused with ErrShutdown. It can be called more than once.
+func (e *BatchExporter) Shutdown() error {
+	e.stopped.Do(func() {
+		close(e.stopCh)
+		e.flushWg.Wait()
+		e.closeMu.Lock()
+		e.closed = true
+		// No DataGroups are being added now, so the last batch is complete. It waits for a
+		// free slot instead of being dropped because the workers are still sending.
+		if b := e.takeCurrent(); b != nil {
+			e.batches <- b
+		}
+		close(e.batches)
+		e.closeMu.Unlock()
+		e.workWg.Wait()
+		unregisterBatcher(e)
+	})
+	return nil
+}
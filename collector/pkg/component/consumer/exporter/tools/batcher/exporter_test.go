@@ -0,0 +1,182 @@
+package batcher
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component"
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constlabels"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constnames"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constvalues"
+)
+
+type blockingExporter struct {
+	mu       sync.Mutex
+	received int
+	unblock  chan struct{}
+}
+
+func (b *blockingExporter) Consume(dataGroup *model.DataGroup) error {
+	<-b.unblock
+	b.mu.Lock()
+	b.received++
+	b.mu.Unlock()
+	return nil
+}
+
+func (b *blockingExporter) count() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.received
+}
+
+func newTestDataGroup(pid int64) *model.DataGroup {
+	labels := model.NewAttributeMap()
+	labels.AddIntValue(constlabels.Pid, pid)
+	labels.AddStringValue(constlabels.ContentKey, "/test")
+	return model.NewDataGroup(constnames.SingleNetRequestMetricGroup, labels, 0,
+		model.NewIntMetric(constvalues.RequestTotalTime, 100))
+}
+
+func TestFlushOnBatchSizeAndInterval(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.BatchSize = 10
+	cfg.FlushInterval = 50 * time.Millisecond
+	next := &blockingExporter{unblock: make(chan struct{})}
+	close(next.unblock)
+	e := NewBatchExporter("test", cfg, NewMemoryLimiter(NewDefaultLimiterConfig()), component.NewDefaultTelemetryTools(), next)
+	for i := 0; i < 25; i++ {
+		assert.NoError(t, e.Consume(newTestDataGroup(int64(i))))
+	}
+	// Two full batches are sent immediately and the rest are sent by the ticker.
+	assert.Eventually(t, func() bool { return next.count() == 25 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, int64(0), e.memoryUsage.Load())
+	assert.NoError(t, e.Shutdown())
+}
+
+func TestMemoryLimit(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.BatchSize = 1
+	cfg.Workers = 1
+	// The limit is shared by the batchers of the two exporters.
+	limiter := NewMemoryLimiter(&LimiterConfig{MemoryLimitBytes: 10 * estimateSize(newTestDataGroup(0))})
+	next := &blockingExporter{unblock: make(chan struct{})}
+	e1 := NewBatchExporter("test1", cfg, limiter, component.NewDefaultTelemetryTools(), next)
+	e2 := NewBatchExporter("test2", cfg, limiter, component.NewDefaultTelemetryTools(), next)
+	var refused int
+	for i := 0; i < 10; i++ {
+		for _, e := range []*BatchExporter{e1, e2} {
+			if err := e.Consume(newTestDataGroup(int64(i))); err != nil {
+				assert.ErrorIs(t, err, ErrMemoryLimitExceeded)
+				refused++
+			}
+		}
+	}
+	assert.Equal(t, 10, refused)
+	assert.Equal(t, int64(5), e1.dropped[dropReasonMemoryLimit].Load())
+	assert.Equal(t, int64(5), e2.dropped[dropReasonMemoryLimit].Load())
+	assert.Equal(t, limiter.Usage(), e1.memoryUsage.Load()+e2.memoryUsage.Load())
+
+	// The memory is released after the data is sent.
+	close(next.unblock)
+	assert.Eventually(t, func() bool { return next.count() == 10 }, time.Second, 10*time.Millisecond)
+	assert.Eventually(t, func() bool { return limiter.Usage() == 0 }, time.Second, 10*time.Millisecond)
+	assert.NoError(t, e1.Consume(newTestDataGroup(0)))
+	assert.NoError(t, e1.Shutdown())
+	assert.NoError(t, e2.Shutdown())
+	assert.Equal(t, 11, next.count())
+}
+
+func TestDataGroupIsCloned(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.BatchSize = 2
+	next := &blockingExporter{unblock: make(chan struct{})}
+	close(next.unblock)
+	e := NewBatchExporter("test", cfg, NewMemoryLimiter(NewDefaultLimiterConfig()), component.NewDefaultTelemetryTools(), next)
+	dataGroup := newTestDataGroup(1)
+	assert.NoError(t, e.Consume(dataGroup))
+	dataGroup.Reset()
+	e.mu.Lock()
+	assert.Equal(t, int64(1), e.current.dataGroups[0].Labels.GetIntValue(constlabels.Pid))
+	e.mu.Unlock()
+	assert.NoError(t, e.Shutdown())
+}
+
+func TestConsumeAfterShutdown(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.BatchSize = 10
+	cfg.FlushInterval = time.Hour
+	next := &blockingExporter{unblock: make(chan struct{})}
+	close(next.unblock)
+	e := NewBatchExporter("test", cfg, NewMemoryLimiter(NewDefaultLimiterConfig()), component.NewDefaultTelemetryTools(), next)
+	for i := 0; i < 25; i++ {
+		assert.NoError(t, e.Consume(newTestDataGroup(int64(i))))
+	}
+	// The last batch, which is not full, is sent when shutting down.
+	assert.NoError(t, e.Shutdown())
+	assert.Equal(t, 25, next.count())
+	assert.ErrorIs(t, e.Consume(newTestDataGroup(0)), ErrShutdown)
+	assert.Equal(t, int64(1), e.dropped[dropReasonShutdown].Load())
+	// It can be shut down again, e.g. when the pipeline is drained after a reload.
+	assert.NoError(t, e.Shutdown())
+}
+
+func TestBlockTimeout(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.BatchSize = 1
+	cfg.Workers = 1
+	cfg.QueueSize = 1
+	cfg.BlockTimeout = time.Second
+	next := &blockingExporter{unblock: make(chan struct{})}
+	e := NewBatchExporter("test", cfg, NewMemoryLimiter(NewDefaultLimiterConfig()), component.NewDefaultTelemetryTools(), next)
+	// One batch is being sent and the other one fills the queue.
+	assert.NoError(t, e.Consume(newTestDataGroup(0)))
+	assert.Eventually(t, func() bool { return len(e.batches) == 0 }, time.Second, time.Millisecond)
+	assert.NoError(t, e.Consume(newTestDataGroup(1)))
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		close(next.unblock)
+	}()
+	// It waits for the room instead of being dropped.
+	assert.NoError(t, e.Consume(newTestDataGroup(2)))
+	assert.NoError(t, e.Shutdown())
+	assert.Equal(t, 3, next.count())
+	assert.Equal(t, int64(0), e.dropped[dropReasonQueueFull].Load())
+}
+
+type batchRecorder struct {
+	mu      sync.Mutex
+	batches []int
+	err     error
+}
+
+func (b *batchRecorder) Consume(*model.DataGroup) error {
+	panic("the batch should be sent by ConsumeBatch")
+}
+
+func (b *batchRecorder) ConsumeBatch(dataGroups []*model.DataGroup) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.batches = append(b.batches, len(dataGroups))
+	return b.err
+}
+
+func TestConsumeBatch(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.BatchSize = 10
+	cfg.FlushInterval = time.Hour
+	next := &batchRecorder{err: errors.New("backend is unavailable")}
+	e := NewBatchExporter("test", cfg, NewMemoryLimiter(NewDefaultLimiterConfig()), component.NewDefaultTelemetryTools(), next)
+	for i := 0; i < 25; i++ {
+		assert.NoError(t, e.Consume(newTestDataGroup(int64(i))))
+	}
+	assert.NoError(t, e.Shutdown())
+	assert.ElementsMatch(t, []int{10, 10, 5}, next.batches)
+	// The whole batch fails together.
+	assert.Equal(t, int64(25), e.dropped[dropReasonExportFailed].Load())
+}
@@ -0,0 +1,62 @@
+package batcher
+
+import "go.uber.org/atomic"
+
+// LimiterConfig configures the memory limit shared by all the batchers.
+type LimiterConfig struct {
+	// MemoryLimitBytes is the maximum estimated memory of all DataGroups held by the batchers
+	// of all exporters, including the ones waiting in the queues and the ones being sent.
+	// New data is refused once the limit is hit. It is unlimited if not positive.
+	MemoryLimitBytes int64 `mapstructure:"memory_limit_bytes"`
+}
+
+func NewDefaultLimiterConfig() *LimiterConfig {
+	return &LimiterConfig{
+		MemoryLimitBytes: 256 * 1024 * 1024,
+	}
+}
+
+// MemoryLimiter counts the memory held by the batchers sharing it. It is created once per
+// application, so the memory is limited globally however many exporters are wrapped.
+type MemoryLimiter struct {
+	limit int64
+	usage *atomic.Int64
+}
+
+func NewMemoryLimiter(cfg *LimiterConfig) *MemoryLimiter {
+	return &MemoryLimiter{
+		limit: cfg.MemoryLimitBytes,
+		usage: atomic.NewInt64(0),
+	}
+}
+
+// acquire reserves the memory. It returns false if the limit would be exceeded.
+func (l *MemoryLimiter) acquire(size int64) bool {
+	if l.limit <= 0 {
+		l.usage.Add(size)
+		return true
+	}
+	for {
+		used := l.usage.Load()
+		if used+size > l.limit {
+			return false
+		}
+		if l.usage.CAS(used, used+size) {
+			return true
+		}
+	}
+}
+
+func (l *MemoryLimiter) release(size int64) {
+	l.usage.Sub(size)
+}
+
+// Usage returns the memory held by all the batchers.
+func (l *MemoryLimiter) Usage() int64 {
+	return l.usage.Load()
+}
+
+// Limit returns the maximum memory the batchers can hold.
+func (l *MemoryLimiter) Limit() int64 {
+	return l.limit
+}
@@ -0,0 +1,62 @@
+package batcher
+
+import (
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	memoryUsageMetric   = "kindling_telemetry_batcher_memory_usage_bytes"
+	queuedBatchesMetric = "kindling_telemetry_batcher_queued_batches"
+	droppedMetric       = "kindling_telemetry_batcher_dropped_total"
+)
+
+var (
+	once       sync.Once
+	batchersMu sync.RWMutex
+	batchers   = make(map[*BatchExporter]struct{})
+)
+
+func registerBatcher(meterProvider metric.MeterProvider, e *BatchExporter) {
+	batchersMu.Lock()
+	batchers[e] = struct{}{}
+	batchersMu.Unlock()
+	once.Do(func() {
+		meter := metric.Must(meterProvider.Meter("kindling"))
+		meter.NewInt64GaugeObserver(memoryUsageMetric,
+			func(ctx context.Context, result metric.Int64ObserverResult) {
+				batchersMu.RLock()
+				defer batchersMu.RUnlock()
+				for b := range batchers {
+					result.Observe(b.memoryUsage.Load(), attribute.String("exporter", b.name))
+				}
+			}, metric.WithDescription("The estimated memory of the DataGroups held by the batcher"))
+		meter.NewInt64GaugeObserver(queuedBatchesMetric,
+			func(ctx context.Context, result metric.Int64ObserverResult) {
+				batchersMu.RLock()
+				defer batchersMu.RUnlock()
+				for b := range batchers {
+					result.Observe(int64(len(b.batches)), attribute.String("exporter", b.name))
+				}
+			}, metric.WithDescription("The current number of batches waiting to be sent"))
+		meter.NewInt64CounterObserver(droppedMetric,
+			func(ctx context.Context, result metric.Int64ObserverResult) {
+				batchersMu.RLock()
+				defer batchersMu.RUnlock()
+				for b := range batchers {
+					for reason, counter := range b.dropped {
+						result.Observe(counter.Load(), attribute.String("exporter", b.name), attribute.String("reason", reason))
+					}
+				}
+			}, metric.WithDescription("The total number of DataGroups dropped by the batcher"))
+	})
+}
+
+func unregisterBatcher(e *BatchExporter) {
+	batchersMu.Lock()
+	delete(batchers, e)
+	batchersMu.Unlock()
+}
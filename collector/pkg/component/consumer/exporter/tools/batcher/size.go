@@ -0,0 +1,29 @@
+package batcher
+
+import "github.com/Kindling-project/kindling/collector/pkg/model"
+
+// The overheads are rough numbers of the memory used by the structures
+// besides the strings. They don't need to be accurate.
+const (
+	dataGroupOverhead = 64
+	labelOverhead     = 48
+	metricOverhead    = 48
+)
+
+// estimateSize returns the approximate memory used by the DataGroup in bytes.
+func estimateSize(dataGroup *model.DataGroup) int64 {
+	size := int64(dataGroupOverhead + len(dataGroup.Name))
+	for k, v := range dataGroup.Labels.GetValues() {
+		size += int64(labelOverhead + len(k))
+		if v.Type() == model.StringAttributeValueType {
+			size += int64(len(v.ToString()))
+		}
+	}
+	for _, metric := range dataGroup.Metrics {
+		size += int64(metricOverhead + len(metric.Name))
+		if histogram := metric.GetHistogram(); histogram != nil {
+			size += int64(8 * (len(histogram.ExplicitBoundaries) + len(histogram.BucketCounts)))
+		}
+	}
+	return size
+}
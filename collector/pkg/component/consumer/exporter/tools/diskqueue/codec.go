@@ -0,0 +1,92 @@
+package diskqueue
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+)
+
+// encodedDataGroup is the on-disk representation of model.DataGroup.
+// model.DataGroup can't be unmarshalled from JSON directly because both the
+// labels and the metrics are interfaces, so the types are recorded explicitly here.
+type encodedDataGroup struct {
+	Name      string                  `json:"name"`
+	Timestamp uint64                  `json:"timestamp"`
+	Labels    map[string]encodedLabel `json:"labels"`
+	Metrics   []encodedMetric         `json:"metrics"`
+}
+
+type encodedLabel struct {
+	Type  model.AttributeValueType `json:"t"`
+	Value string                   `json:"v"`
+}
+
+type encodedMetric struct {
+	Name      string           `json:"name"`
+	Int       *int64           `json:"int,omitempty"`
+	Histogram *model.Histogram `json:"histogram,omitempty"`
+}
+
+func encodeDataGroup(dataGroup *model.DataGroup) ([]byte, error) {
+	encoded := encodedDataGroup{
+		Name:      dataGroup.Name,
+		Timestamp: dataGroup.Timestamp,
+		Labels:    make(map[string]encodedLabel, dataGroup.Labels.Size()),
+		Metrics:   make([]encodedMetric, 0, len(dataGroup.Metrics)),
+	}
+	for k, v := range dataGroup.Labels.GetValues() {
+		encoded.Labels[k] = encodedLabel{Type: v.Type(), Value: v.ToString()}
+	}
+	for _, metric := range dataGroup.Metrics {
+		m := encodedMetric{Name: metric.Name}
+		switch metric.DataType() {
+		case model.IntMetricType:
+			value := metric.GetInt().Value
+			m.Int = &value
+		case model.HistogramMetricType:
+			m.Histogram = metric.GetHistogram()
+		default:
+			continue
+		}
+		encoded.Metrics = append(encoded.Metrics, m)
+	}
+	return json.Marshal(&encoded)
+}
+
+func decodeDataGroup(data []byte) (*model.DataGroup, error) {
+	var encoded encodedDataGroup
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return nil, err
+	}
+	labels := model.NewAttributeMap()
+	for k, v := range encoded.Labels {
+		switch v.Type {
+		case model.StringAttributeValueType:
+			labels.AddStringValue(k, v.Value)
+		case model.IntAttributeValueType:
+			value, err := strconv.ParseInt(v.Value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid int label %s: %w", k, err)
+			}
+			labels.AddIntValue(k, value)
+		case model.BooleanAttributeValueType:
+			value, err := strconv.ParseBool(v.Value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid bool label %s: %w", k, err)
+			}
+			labels.AddBoolValue(k, value)
+		}
+	}
+	metrics := make([]*model.Metric, 0, len(encoded.Metrics))
+	for _, m := range encoded.Metrics {
+		switch {
+		case m.Int != nil:
+			metrics = append(metrics, model.NewIntMetric(m.Name, *m.Int))
+		case m.Histogram != nil:
+			metrics = append(metrics, model.NewHistogramMetric(m.Name, m.Histogram))
+		}
+	}
+	return model.NewDataGroup(encoded.Name, labels, encoded.Timestamp, metrics...), nil
+}
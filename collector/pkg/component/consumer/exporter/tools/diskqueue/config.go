@@ -0,0 +1,34 @@
+package diskqueue
+
+import "time"
+
+type Config struct {
+	// Enable controls whether the exporter is wrapped by a disk-backed queue. The exporters
+	// implementing QueueOwner, e.g. otelexporter, persist the data they fail to push by
+	// themselves instead.
+	Enable bool `mapstructure:"enable"`
+	// Directory is the ABSOLUTE path of the directory where the segment files are saved.
+	// Each exporter uses a sub-directory named after itself.
+	Directory string `mapstructure:"directory"`
+	// MaxSizeBytes is the maximum bytes the queue can use on disk. The new data will be
+	// dropped if the queue is full.
+	MaxSizeBytes int64 `mapstructure:"max_size_bytes"`
+	// SegmentSizeBytes is the size at which a segment file is sealed and a new one is created.
+	// A segment file is removed once all of its data has been acknowledged by the exporter.
+	SegmentSizeBytes int64 `mapstructure:"segment_size_bytes"`
+	// RetryInitialInterval is the time to wait before retrying to send the data after the
+	// first failure. The interval is doubled after each failure until RetryMaxInterval.
+	RetryInitialInterval time.Duration `mapstructure:"retry_initial_interval"`
+	RetryMaxInterval     time.Duration `mapstructure:"retry_max_interval"`
+}
+
+func NewDefaultConfig() *Config {
+	return &Config{
+		Enable:               false,
+		Directory:            "/tmp/kindling/queue",
+		MaxSizeBytes:         512 * 1024 * 1024,
+		SegmentSizeBytes:     16 * 1024 * 1024,
+		RetryInitialInterval: 500 * time.Millisecond,
+		RetryMaxInterval:     30 * time.Second,
+	}
+}
@@ -0,0 +1,145 @@
+package diskqueue
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.uber.org/atomic"
+	"go.uber.org/zap"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component"
+	"github.com/Kindling-project/kindling/collector/pkg/component/consumer/exporter"
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+)
+
+// QueuedExporter persists the DataGroups to a disk queue before they are sent to the
+// wrapped exporter. The DataGroups are sent in order, and a DataGroup is removed from
+// the queue only after the wrapped exporter consumes it without errors. If the wrapped
+// exporter fails, the same DataGroup is retried until the backend becomes available again,
+// unless the error is marked by exporter.NewPermanentError, in which case it is dropped.
+type QueuedExporter struct {
+	name      string
+	cfg       *Config
+	next      exporter.Exporter
+	queue     *diskQueue
+	telemetry *component.TelemetryTools
+
+	dropped *atomic.Int64
+	stopCh  chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewQueuedExporter creates a QueuedExporter and starts sending the data left by the last run.
+func NewQueuedExporter(name string, cfg *Config, telemetry *component.TelemetryTools, next exporter.Exporter) (*QueuedExporter, error) {
+	queue, err := openDiskQueue(filepath.Join(cfg.Directory, name), cfg.MaxSizeBytes, cfg.SegmentSizeBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open disk queue for %s: %w", name, err)
+	}
+	e := &QueuedExporter{
+		name:      name,
+		cfg:       cfg,
+		next:      next,
+		queue:     queue,
+		telemetry: telemetry,
+		dropped:   atomic.NewInt64(0),
+		stopCh:    make(chan struct{}),
+	}
+	count, size := queue.stats()
+	if count > 0 {
+		telemetry.Logger.Infof("Disk queue of %s has %d DataGroups (%d bytes) left to be replayed", name, count, size)
+	}
+	registerQueue(telemetry.MeterProvider, e)
+	e.wg.Add(1)
+	go e.run()
+	return e, nil
+}
+
+func (e *QueuedExporter) Consume(dataGroup *model.DataGroup) error {
+	if dataGroup == nil {
+		return nil
+	}
+	// The DataGroup is encoded right now because it may be reused by the previous components.
+	data, err := encodeDataGroup(dataGroup)
+	if err != nil {
+		e.dropped.Inc()
+		return fmt.Errorf("can't encode DataGroup: %w", err)
+	}
+	if err = e.queue.put(data); err != nil {
+		e.dropped.Inc()
+		return fmt.Errorf("DataGroup %s is dropped by the queue of %s: %w", dataGroup.Name, e.name, err)
+	}
+	return nil
+}
+
+func (e *QueuedExporter) run() {
+	defer e.wg.Done()
+	retryInterval := e.cfg.RetryInitialInterval
+	for {
+		data, next, err := e.queue.peek()
+		if errors.Is(err, ErrQueueEmpty) {
+			select {
+			case <-e.queue.notify:
+				continue
+			case <-e.stopCh:
+				return
+			}
+		}
+		if err != nil {
+			skipped, skipErr := e.queue.skipSegment()
+			e.dropped.Add(skipped)
+			e.telemetry.Logger.Error("Failed to read the disk queue, the rest of the segment is skipped",
+				zap.String("exporter", e.name), zap.Int64("skipped", skipped), zap.Error(err), zap.NamedError("skipError", skipErr))
+			continue
+		}
+		dataGroup, err := decodeDataGroup(data)
+		if err != nil {
+			e.dropped.Inc()
+			e.telemetry.Logger.Error("Failed to decode DataGroup from the disk queue", zap.String("exporter", e.name), zap.Error(err))
+			_ = e.queue.ack(next)
+			continue
+		}
+		err = e.next.Consume(dataGroup)
+		if err != nil && exporter.IsPermanentError(err) {
+			// The DataGroup can never be sent, so it must not block the ones behind it.
+			e.dropped.Inc()
+			e.telemetry.Logger.Error("Failed to send DataGroup, it is dropped", zap.String("exporter", e.name), zap.Error(err))
+		} else if err != nil {
+			e.telemetry.Logger.Warn("Failed to send DataGroup, will retry later", zap.String("exporter", e.name),
+				zap.Duration("retryInterval", retryInterval), zap.Error(err))
+			select {
+			case <-time.After(retryInterval):
+			case <-e.stopCh:
+				return
+			}
+			retryInterval *= 2
+			if retryInterval > e.cfg.RetryMaxInterval {
+				retryInterval = e.cfg.RetryMaxInterval
+			}
+			continue
+		}
+		retryInterval = e.cfg.RetryInitialInterval
+		if err = e.queue.ack(next); err != nil {
+			e.telemetry.Logger.Warn("Failed to save the cursor of the disk queue", zap.String("exporter", e.name), zap.Error(err))
+		}
+	}
+}
+
+// QueueDepths returns the bytes stored in the disk queue.
+func (e *QueuedExporter) QueueDepths() map[string]component.QueueDepth {
+	_, size := e.queue.stats()
+	return map[string]component.QueueDepth{
+		"disk_queue_bytes": {Length: size, Capacity: e.cfg.MaxSizeBytes},
+	}
+}
+
+// Shutdown stops sending data and closes the queue files. The data that have not
+// been sent will be replayed next time.
+func (e *QueuedExporter) Shutdown() error {
+	close(e.stopCh)
+	e.wg.Wait()
+	unregisterQueue(e)
+	return e.queue.close()
+}
@@ -0,0 +1,182 @@
+package diskqueue
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component"
+	"github.com/Kindling-project/kindling/collector/pkg/component/consumer/exporter"
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constlabels"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constnames"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constvalues"
+)
+
+type mockExporter struct {
+	mu      sync.Mutex
+	failing bool
+	// rejectedPid is the pid of the DataGroup rejected by the backend permanently.
+	rejectedPid int64
+	received    []*model.DataGroup
+}
+
+func (m *mockExporter) Consume(dataGroup *model.DataGroup) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.failing {
+		return errors.New("backend is down")
+	}
+	if m.rejectedPid != 0 && dataGroup.Labels.GetIntValue(constlabels.Pid) == m.rejectedPid {
+		return exporter.NewPermanentError(errors.New("bad request"))
+	}
+	m.received = append(m.received, dataGroup)
+	return nil
+}
+
+func (m *mockExporter) setFailing(failing bool) {
+	m.mu.Lock()
+	m.failing = failing
+	m.mu.Unlock()
+}
+
+func (m *mockExporter) receivedCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.received)
+}
+
+func testConfig(dir string) *Config {
+	cfg := NewDefaultConfig()
+	cfg.Enable = true
+	cfg.Directory = dir
+	cfg.SegmentSizeBytes = 1024
+	cfg.RetryInitialInterval = 10 * time.Millisecond
+	cfg.RetryMaxInterval = 20 * time.Millisecond
+	return cfg
+}
+
+func newTestDataGroup(i int64) *model.DataGroup {
+	labels := model.NewAttributeMap()
+	labels.AddIntValue(constlabels.Pid, i)
+	labels.AddStringValue(constlabels.ContentKey, "/test")
+	labels.AddBoolValue(constlabels.IsSlow, true)
+	histogram := &model.Histogram{Sum: 10, Count: 2, ExplicitBoundaries: []int64{5, 10}, BucketCounts: []uint64{1, 2}}
+	return model.NewDataGroup(constnames.SingleNetRequestMetricGroup, labels, uint64(i),
+		model.NewIntMetric(constvalues.RequestTotalTime, i*10),
+		model.NewHistogramMetric("histogram", histogram))
+}
+
+func TestCodec(t *testing.T) {
+	dataGroup := newTestDataGroup(100)
+	data, err := encodeDataGroup(dataGroup)
+	assert.NoError(t, err)
+	decoded, err := decodeDataGroup(data)
+	assert.NoError(t, err)
+	assert.Equal(t, dataGroup, decoded)
+}
+
+func TestReplayInOrder(t *testing.T) {
+	cfg := testConfig(t.TempDir())
+	next := &mockExporter{failing: true}
+	e, err := NewQueuedExporter("test", cfg, component.NewDefaultTelemetryTools(), next)
+	assert.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		assert.NoError(t, e.Consume(newTestDataGroup(int64(i))))
+	}
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 0, next.receivedCount())
+	count, _ := e.queue.stats()
+	assert.Equal(t, int64(100), count)
+
+	// The backend is back
+	next.setFailing(false)
+	assert.Eventually(t, func() bool { return next.receivedCount() == 100 }, 5*time.Second, 10*time.Millisecond)
+	for i, dataGroup := range next.received {
+		assert.Equal(t, int64(i), dataGroup.Labels.GetIntValue(constlabels.Pid))
+	}
+	count, _ = e.queue.stats()
+	assert.Equal(t, int64(0), count)
+	assert.NoError(t, e.Shutdown())
+}
+
+func TestRecoverAfterRestart(t *testing.T) {
+	cfg := testConfig(t.TempDir())
+	next := &mockExporter{failing: true}
+	e, err := NewQueuedExporter("test", cfg, component.NewDefaultTelemetryTools(), next)
+	assert.NoError(t, err)
+	for i := 0; i < 50; i++ {
+		assert.NoError(t, e.Consume(newTestDataGroup(int64(i))))
+	}
+	assert.NoError(t, e.Shutdown())
+
+	// Acknowledge part of the data, then restart again.
+	next = &mockExporter{}
+	q, err := openDiskQueue(e.queue.dir, cfg.MaxSizeBytes, cfg.SegmentSizeBytes)
+	assert.NoError(t, err)
+	for i := 0; i < 20; i++ {
+		_, offset, err := q.peek()
+		assert.NoError(t, err)
+		assert.NoError(t, q.ack(offset))
+	}
+	assert.NoError(t, q.close())
+
+	e, err = NewQueuedExporter("test", cfg, component.NewDefaultTelemetryTools(), next)
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool { return next.receivedCount() == 30 }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, int64(20), next.received[0].Labels.GetIntValue(constlabels.Pid))
+	assert.Equal(t, int64(49), next.received[29].Labels.GetIntValue(constlabels.Pid))
+	assert.NoError(t, e.Shutdown())
+}
+
+func TestQueueFull(t *testing.T) {
+	cfg := testConfig(t.TempDir())
+	cfg.MaxSizeBytes = 2048
+	next := &mockExporter{failing: true}
+	e, err := NewQueuedExporter("test", cfg, component.NewDefaultTelemetryTools(), next)
+	assert.NoError(t, err)
+	var dropped int
+	for i := 0; i < 100; i++ {
+		if err := e.Consume(newTestDataGroup(int64(i))); err != nil {
+			dropped++
+		}
+	}
+	assert.Greater(t, dropped, 0)
+	assert.Equal(t, int64(dropped), e.dropped.Load())
+	_, size := e.queue.stats()
+	assert.LessOrEqual(t, size, cfg.MaxSizeBytes)
+	assert.NoError(t, e.Shutdown())
+}
+
+func TestPermanentErrorDropped(t *testing.T) {
+	cfg := testConfig(t.TempDir())
+	next := &mockExporter{failing: true, rejectedPid: 1}
+	e, err := NewQueuedExporter("test", cfg, component.NewDefaultTelemetryTools(), next)
+	assert.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, e.Consume(newTestDataGroup(int64(i))))
+	}
+	next.setFailing(false)
+	// The rejected DataGroup doesn't block the ones behind it.
+	assert.Eventually(t, func() bool { return next.receivedCount() == 2 }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, int64(2), next.received[1].Labels.GetIntValue(constlabels.Pid))
+	assert.Equal(t, int64(1), e.dropped.Load())
+	assert.NoError(t, e.Shutdown())
+}
+
+func TestCorruptedRecordLength(t *testing.T) {
+	path := t.TempDir() + "/00000000000000000001" + segmentSuffix
+	header := make([]byte, recordHeaderSize)
+	binary.BigEndian.PutUint32(header[0:4], 0xFFFFFFFF)
+	assert.NoError(t, os.WriteFile(path, append(header, 1, 2, 3), 0644))
+	f, err := os.Open(path)
+	assert.NoError(t, err)
+	defer f.Close()
+	_, err = readRecord(f, 0)
+	assert.ErrorIs(t, err, errCorrupted)
+}
@@ -0,0 +1,470 @@
+package diskqueue
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	segmentSuffix  = ".seg"
+	cursorFileName = "cursor"
+	// recordHeaderSize is the size of the header in front of each record:
+	// 4 bytes payload length + 4 bytes CRC32 checksum of the payload.
+	recordHeaderSize = 8
+)
+
+var (
+	// ErrQueueFull is returned when the queue has no room for the record.
+	ErrQueueFull = errors.New("disk queue is full")
+	// ErrQueueEmpty is returned when there is no record to be read.
+	ErrQueueEmpty = errors.New("disk queue is empty")
+	errCorrupted  = errors.New("corrupted record")
+)
+
+// cursor is the position of the next record to be consumed. It is persisted
+// every time a record is acknowledged, so the records that have not been
+// acknowledged will be replayed after a crash.
+type cursor struct {
+	Segment uint64 `json:"segment"`
+	Offset  int64  `json:"offset"`
+}
+
+// diskQueue is a FIFO queue whose records are appended to segment files.
+// It supports exactly one producer and one consumer.
+type diskQueue struct {
+	dir         string
+	maxSize     int64
+	segmentSize int64
+
+	mu sync.Mutex
+	// segments contains the ids of all segment files in ascending order.
+	// The last one is the segment being written.
+	segments     []uint64
+	segmentSizes map[uint64]int64
+	writeFile    *os.File
+	readFile     *os.File
+	readSegment  uint64
+	readOffset   int64
+
+	size  int64
+	count int64
+
+	notify chan struct{}
+}
+
+func openDiskQueue(dir string, maxSize int64, segmentSize int64) (*diskQueue, error) {
+	if !filepath.IsAbs(dir) {
+		return nil, fmt.Errorf("directory must be an absolute path")
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+	q := &diskQueue{
+		dir:          dir,
+		maxSize:      maxSize,
+		segmentSize:  segmentSize,
+		segmentSizes: make(map[uint64]int64),
+		notify:       make(chan struct{}, 1),
+	}
+	if err := q.recover(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// recover loads the segment files and the cursor left by the last run.
+func (q *diskQueue) recover() error {
+	entries, err := os.ReadDir(q.dir)
+	if err != nil {
+		return fmt.Errorf("can't list the directory: %w", err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimSuffix(name, segmentSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		q.segments = append(q.segments, id)
+	}
+	sort.Slice(q.segments, func(i, j int) bool { return q.segments[i] < q.segments[j] })
+	if len(q.segments) == 0 {
+		q.segments = append(q.segments, 1)
+	}
+
+	c := q.loadCursor()
+	// Remove the segments that have been consumed completely.
+	for len(q.segments) > 1 && q.segments[0] < c.Segment {
+		_ = os.Remove(q.segmentPath(q.segments[0]))
+		q.segments = q.segments[1:]
+	}
+	if c.Segment != q.segments[0] {
+		c = cursor{Segment: q.segments[0], Offset: 0}
+	}
+	q.readSegment = c.Segment
+	q.readOffset = c.Offset
+
+	// The last segment may contain a partial record if the process crashed while writing.
+	last := q.segments[len(q.segments)-1]
+	validSize, err := validateSegment(q.segmentPath(last))
+	if err != nil {
+		return err
+	}
+	if err := os.Truncate(q.segmentPath(last), validSize); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("can't truncate the segment %d: %w", last, err)
+	}
+
+	for _, id := range q.segments {
+		info, err := os.Stat(q.segmentPath(id))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+		q.segmentSizes[id] = info.Size()
+		q.size += info.Size()
+	}
+	if q.readOffset > q.segmentSizes[q.readSegment] {
+		q.readOffset = 0
+	}
+	for _, id := range q.segments {
+		var from int64
+		if id == q.readSegment {
+			from = q.readOffset
+		}
+		n, err := countRecords(q.segmentPath(id), from)
+		if err != nil {
+			return err
+		}
+		q.count += n
+	}
+
+	q.writeFile, err = os.OpenFile(q.segmentPath(last), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("can't open the segment %d: %w", last, err)
+	}
+	return nil
+}
+
+func (q *diskQueue) loadCursor() cursor {
+	var c cursor
+	data, err := os.ReadFile(filepath.Join(q.dir, cursorFileName))
+	if err != nil {
+		return c
+	}
+	// A broken cursor file leads to replaying all the records, which is
+	// better than losing them.
+	if err := json.Unmarshal(data, &c); err != nil {
+		return cursor{}
+	}
+	return c
+}
+
+func (q *diskQueue) saveCursor() error {
+	data, err := json.Marshal(cursor{Segment: q.readSegment, Offset: q.readOffset})
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file and rename it to make the update atomic. The file is
+	// synced first, otherwise the renamed one may be empty after a crash.
+	tmp := filepath.Join(q.dir, cursorFileName+".tmp")
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(data); err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(q.dir, cursorFileName))
+}
+
+func (q *diskQueue) segmentPath(id uint64) string {
+	return filepath.Join(q.dir, fmt.Sprintf("%020d%s", id, segmentSuffix))
+}
+
+// put appends a record to the tail of the queue.
+func (q *diskQueue) put(data []byte) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	recordSize := int64(len(data) + recordHeaderSize)
+	if q.size+recordSize > q.maxSize {
+		return ErrQueueFull
+	}
+	last := q.segments[len(q.segments)-1]
+	if q.segmentSizes[last] >= q.segmentSize {
+		if err := q.rotate(); err != nil {
+			return err
+		}
+		last = q.segments[len(q.segments)-1]
+	}
+	record := make([]byte, recordSize)
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(data)))
+	binary.BigEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(data))
+	copy(record[recordHeaderSize:], data)
+	n, err := q.writeFile.Write(record)
+	q.segmentSizes[last] += int64(n)
+	q.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("can't write the segment %d: %w", last, err)
+	}
+	// The record is synced before put returns, so it is not lost if the process crashes
+	// after the producer considers it stored.
+	if err = q.writeFile.Sync(); err != nil {
+		return fmt.Errorf("can't sync the segment %d: %w", last, err)
+	}
+	q.count++
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (q *diskQueue) rotate() error {
+	if err := q.writeFile.Close(); err != nil {
+		return fmt.Errorf("can't close the segment: %w", err)
+	}
+	next := q.segments[len(q.segments)-1] + 1
+	f, err := os.OpenFile(q.segmentPath(next), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("can't create the segment %d: %w", next, err)
+	}
+	q.writeFile = f
+	q.segments = append(q.segments, next)
+	q.segmentSizes[next] = 0
+	return nil
+}
+
+// peek returns the record at the head of the queue and the offset of the next record.
+// The record is not removed until ack is called with the returned offset.
+func (q *diskQueue) peek() ([]byte, int64, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for {
+		if q.readOffset < q.segmentSizes[q.readSegment] {
+			break
+		}
+		if q.readSegment == q.segments[len(q.segments)-1] {
+			return nil, 0, ErrQueueEmpty
+		}
+		// The sealed segment has been consumed completely.
+		if err := q.removeHeadSegment(); err != nil {
+			return nil, 0, err
+		}
+	}
+	if q.readFile == nil {
+		f, err := os.Open(q.segmentPath(q.readSegment))
+		if err != nil {
+			return nil, 0, fmt.Errorf("can't open the segment %d: %w", q.readSegment, err)
+		}
+		q.readFile = f
+	}
+	data, err := readRecord(q.readFile, q.readOffset)
+	if err != nil {
+		return nil, 0, err
+	}
+	return data, q.readOffset + int64(len(data)+recordHeaderSize), nil
+}
+
+// ack removes the record before the offset from the queue.
+func (q *diskQueue) ack(offset int64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.readOffset = offset
+	q.count--
+	return q.saveCursor()
+}
+
+// skipSegment discards the rest of the segment being read. It is used when a
+// record is corrupted and the following records can't be located anymore.
+func (q *diskQueue) skipSegment() (int64, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	skipped, _ := countRecords(q.segmentPath(q.readSegment), q.readOffset)
+	q.readOffset = q.segmentSizes[q.readSegment]
+	if q.readSegment == q.segments[len(q.segments)-1] {
+		// Start a new segment so that the broken one can be removed.
+		if err := q.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	if err := q.removeHeadSegment(); err != nil {
+		return 0, err
+	}
+	q.count -= skipped
+	if q.count < 0 {
+		q.count = 0
+	}
+	return skipped, nil
+}
+
+func (q *diskQueue) removeHeadSegment() error {
+	if q.readFile != nil {
+		_ = q.readFile.Close()
+		q.readFile = nil
+	}
+	head := q.segments[0]
+	if err := os.Remove(q.segmentPath(head)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("can't remove the segment %d: %w", head, err)
+	}
+	q.size -= q.segmentSizes[head]
+	delete(q.segmentSizes, head)
+	q.segments = q.segments[1:]
+	q.readSegment = q.segments[0]
+	q.readOffset = 0
+	return q.saveCursor()
+}
+
+func (q *diskQueue) stats() (count int64, size int64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.count, q.size
+}
+
+func (q *diskQueue) close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.readFile != nil {
+		_ = q.readFile.Close()
+	}
+	return q.writeFile.Close()
+}
+
+func readRecord(f *os.File, offset int64) ([]byte, error) {
+	header := make([]byte, recordHeaderSize)
+	if _, err := f.ReadAt(header, offset); err != nil {
+		return nil, fmt.Errorf("%w: %v", errCorrupted, err)
+	}
+	length := int64(binary.BigEndian.Uint32(header[0:4]))
+	// A torn or corrupted header may have a huge length, which is rejected before the
+	// buffer is allocated.
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if length > info.Size()-offset-recordHeaderSize {
+		return nil, fmt.Errorf("%w: length %d exceeds the segment", errCorrupted, length)
+	}
+	data := make([]byte, length)
+	if _, err := f.ReadAt(data, offset+recordHeaderSize); err != nil {
+		return nil, fmt.Errorf("%w: %v", errCorrupted, err)
+	}
+	if crc32.ChecksumIEEE(data) != binary.BigEndian.Uint32(header[4:8]) {
+		return nil, fmt.Errorf("%w: checksum mismatch", errCorrupted)
+	}
+	return data, nil
+}
+
+// validateSegment returns the size of the valid records in the segment.
+func validateSegment(path string) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	defer f.Close()
+	var offset int64
+	for {
+		data, err := readRecord(f, offset)
+		if err != nil {
+			return offset, nil
+		}
+		offset += int64(len(data) + recordHeaderSize)
+	}
+}
+
+// countRecords returns the number of records in the segment starting from the offset.
+func countRecords(path string, offset int64) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	defer f.Close()
+	var count int64
+	header := make([]byte, recordHeaderSize)
+	for {
+		if _, err := f.ReadAt(header, offset); err != nil {
+			return count, nil
+		}
+		offset += int64(binary.BigEndian.Uint32(header[0:4])) + recordHeaderSize
+		count++
+	}
+}
+
+// Queue is a persistent FIFO queue of raw records. It is used by the components
+// that manage their own records instead of DataGroups, e.g. as a write-ahead log.
+// It supports exactly one producer and one consumer.
+type Queue struct {
+	q *diskQueue
+}
+
+// OpenQueue opens the queue stored in dir, or creates it if it doesn't exist.
+// The records that have not been acknowledged last time are recovered.
+func OpenQueue(dir string, maxSize int64, segmentSize int64) (*Queue, error) {
+	q, err := openDiskQueue(dir, maxSize, segmentSize)
+	if err != nil {
+		return nil, err
+	}
+	return &Queue{q: q}, nil
+}
+
+// Put appends a record to the tail of the queue. ErrQueueFull is returned if
+// the queue would exceed its max size.
+func (q *Queue) Put(data []byte) error {
+	return q.q.put(data)
+}
+
+// Peek returns the record at the head of the queue and the offset to acknowledge it.
+// ErrQueueEmpty is returned if there is no record.
+func (q *Queue) Peek() ([]byte, int64, error) {
+	return q.q.peek()
+}
+
+// Ack removes the record returned by Peek from the queue.
+func (q *Queue) Ack(offset int64) error {
+	return q.q.ack(offset)
+}
+
+// SkipSegment discards the rest of the segment being read. It should be called
+// when Peek fails with an error other than ErrQueueEmpty.
+func (q *Queue) SkipSegment() (int64, error) {
+	return q.q.skipSegment()
+}
+
+// Notify returns a channel that receives a signal after a record is put.
+func (q *Queue) Notify() <-chan struct{} {
+	return q.q.notify
+}
+
+// Stats returns the number of records and the bytes stored in the queue.
+func (q *Queue) Stats() (count int64, size int64) {
+	return q.q.stats()
+}
+
+// Close closes the queue files. The records left will be recovered next time.
+func (q *Queue) Close() error {
+	return q.q.close()
+}
@@ -0,0 +1,78 @@
+package diskqueue
+
+import (
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	queueItemsMetric   = "kindling_telemetry_diskqueue_items"
+	queueBytesMetric   = "kindling_telemetry_diskqueue_bytes"
+	queueDroppedMetric = "kindling_telemetry_diskqueue_dropped_total"
+)
+
+// observedQueue is a disk queue reported by the self-metrics, which is either a
+// QueuedExporter or a Sender.
+type observedQueue interface {
+	queueName() string
+	queueStats() (count int64, size int64)
+	droppedCount() int64
+}
+
+var (
+	once     sync.Once
+	queuesMu sync.RWMutex
+	queues   = make(map[observedQueue]struct{})
+)
+
+func registerQueue(meterProvider metric.MeterProvider, e observedQueue) {
+	queuesMu.Lock()
+	queues[e] = struct{}{}
+	queuesMu.Unlock()
+	once.Do(func() {
+		meter := metric.Must(meterProvider.Meter("kindling"))
+		meter.NewInt64GaugeObserver(queueItemsMetric,
+			func(ctx context.Context, result metric.Int64ObserverResult) {
+				queuesMu.RLock()
+				defer queuesMu.RUnlock()
+				for q := range queues {
+					count, _ := q.queueStats()
+					result.Observe(count, attribute.String("exporter", q.queueName()))
+				}
+			}, metric.WithDescription("The current number of DataGroups or payloads stored in the disk queue"))
+		meter.NewInt64GaugeObserver(queueBytesMetric,
+			func(ctx context.Context, result metric.Int64ObserverResult) {
+				queuesMu.RLock()
+				defer queuesMu.RUnlock()
+				for q := range queues {
+					_, size := q.queueStats()
+					result.Observe(size, attribute.String("exporter", q.queueName()))
+				}
+			}, metric.WithDescription("The current bytes the disk queue uses on disk"))
+		meter.NewInt64CounterObserver(queueDroppedMetric,
+			func(ctx context.Context, result metric.Int64ObserverResult) {
+				queuesMu.RLock()
+				defer queuesMu.RUnlock()
+				for q := range queues {
+					result.Observe(q.droppedCount(), attribute.String("exporter", q.queueName()))
+				}
+			}, metric.WithDescription("The total number of DataGroups or payloads dropped by the disk queue"))
+	})
+}
+
+func unregisterQueue(e observedQueue) {
+	queuesMu.Lock()
+	delete(queues, e)
+	queuesMu.Unlock()
+}
+
+func (e *QueuedExporter) queueName() string                     { return e.name }
+func (e *QueuedExporter) queueStats() (count int64, size int64) { return e.queue.stats() }
+func (e *QueuedExporter) droppedCount() int64                   { return e.dropped.Load() }
+
+func (s *Sender) queueName() string                     { return s.name }
+func (s *Sender) queueStats() (count int64, size int64) { return s.queue.stats() }
+func (s *Sender) droppedCount() int64                   { return s.dropped.Load() }
@@ -0,0 +1,163 @@
+package diskqueue
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.uber.org/atomic"
+	"go.uber.org/zap"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component"
+	"github.com/Kindling-project/kindling/collector/pkg/component/consumer/exporter"
+)
+
+// SendFunc sends an encoded payload to the backend. The errors marked by
+// exporter.NewPermanentError are not retried.
+type SendFunc func(data []byte) error
+
+// Sender persists the payloads which fail to be sent to a disk queue and retries them
+// in order. It is used by the exporters pushing the data asynchronously, e.g. otelexporter,
+// whose Consume doesn't return the errors of the exports, so QueuedExporter can't retry them.
+// The exporters have to implement QueueOwner so they are not wrapped by QueuedExporter.
+type Sender struct {
+	name      string
+	cfg       *Config
+	send      SendFunc
+	queue     *diskQueue
+	telemetry *component.TelemetryTools
+
+	// mu makes Send the only producer of the queue.
+	mu      sync.Mutex
+	dropped *atomic.Int64
+	stopCh  chan struct{}
+	stopped sync.Once
+	wg      sync.WaitGroup
+}
+
+// QueueOwner is implemented by the exporters which persist the payloads they fail to send
+// with Senders. They are given the disk queue configuration instead of being wrapped by
+// QueuedExporter.
+type QueueOwner interface {
+	EnableDiskQueue(name string, cfg *Config, telemetry *component.TelemetryTools) error
+}
+
+// NewSender creates a Sender whose queue is stored in the sub-directory named after the
+// name, and starts retrying the payloads left by the last run.
+func NewSender(name string, cfg *Config, telemetry *component.TelemetryTools, send SendFunc) (*Sender, error) {
+	queue, err := openDiskQueue(filepath.Join(cfg.Directory, name), cfg.MaxSizeBytes, cfg.SegmentSizeBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open disk queue for %s: %w", name, err)
+	}
+	s := &Sender{
+		name:      name,
+		cfg:       cfg,
+		send:      send,
+		queue:     queue,
+		telemetry: telemetry,
+		dropped:   atomic.NewInt64(0),
+		stopCh:    make(chan struct{}),
+	}
+	count, size := queue.stats()
+	if count > 0 {
+		telemetry.Logger.Infof("Disk queue of %s has %d payloads (%d bytes) left to be replayed", name, count, size)
+	}
+	registerQueue(telemetry.MeterProvider, s)
+	s.wg.Add(1)
+	go s.run()
+	return s, nil
+}
+
+// Send sends the payload directly if no payloads are queued. Otherwise, or if it fails
+// with a transient error, the payload is queued behind the others and nil is returned.
+// An error is returned only if the payload is dropped.
+func (s *Sender) Send(data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// The payload being retried is counted until it is acknowledged, so the payloads
+	// are never sent out of order.
+	if count, _ := s.queue.stats(); count == 0 {
+		err := s.send(data)
+		if err == nil {
+			return nil
+		}
+		if exporter.IsPermanentError(err) {
+			s.dropped.Inc()
+			return err
+		}
+		s.telemetry.Logger.Warn("Failed to send the payload, it is queued to be retried", zap.String("queue", s.name), zap.Error(err))
+	}
+	if err := s.queue.put(data); err != nil {
+		s.dropped.Inc()
+		return fmt.Errorf("payload is dropped by the queue of %s: %w", s.name, err)
+	}
+	return nil
+}
+
+func (s *Sender) run() {
+	defer s.wg.Done()
+	retryInterval := s.cfg.RetryInitialInterval
+	for {
+		data, next, err := s.queue.peek()
+		if errors.Is(err, ErrQueueEmpty) {
+			select {
+			case <-s.queue.notify:
+				continue
+			case <-s.stopCh:
+				return
+			}
+		}
+		if err != nil {
+			skipped, skipErr := s.queue.skipSegment()
+			s.dropped.Add(skipped)
+			s.telemetry.Logger.Error("Failed to read the disk queue, the rest of the segment is skipped",
+				zap.String("queue", s.name), zap.Int64("skipped", skipped), zap.Error(err), zap.NamedError("skipError", skipErr))
+			continue
+		}
+		err = s.send(data)
+		if err != nil && exporter.IsPermanentError(err) {
+			s.dropped.Inc()
+			s.telemetry.Logger.Error("Failed to send the payload, it is dropped", zap.String("queue", s.name), zap.Error(err))
+		} else if err != nil {
+			s.telemetry.Logger.Warn("Failed to send the payload, will retry later", zap.String("queue", s.name),
+				zap.Duration("retryInterval", retryInterval), zap.Error(err))
+			select {
+			case <-time.After(retryInterval):
+			case <-s.stopCh:
+				return
+			}
+			retryInterval *= 2
+			if retryInterval > s.cfg.RetryMaxInterval {
+				retryInterval = s.cfg.RetryMaxInterval
+			}
+			continue
+		}
+		retryInterval = s.cfg.RetryInitialInterval
+		if err = s.queue.ack(next); err != nil {
+			s.telemetry.Logger.Warn("Failed to save the cursor of the disk queue", zap.String("queue", s.name), zap.Error(err))
+		}
+	}
+}
+
+// QueueDepths returns the bytes stored in the disk queue.
+func (s *Sender) QueueDepths() map[string]component.QueueDepth {
+	_, size := s.queue.stats()
+	return map[string]component.QueueDepth{
+		"disk_queue_bytes": {Length: size, Capacity: s.cfg.MaxSizeBytes},
+	}
+}
+
+// Shutdown stops retrying and closes the queue files. The payloads that have not been
+// sent will be replayed next time. Send must not be called after Shutdown.
+func (s *Sender) Shutdown() error {
+	var err error
+	s.stopped.Do(func() {
+		close(s.stopCh)
+		s.wg.Wait()
+		unregisterQueue(s)
+		err = s.queue.close()
+	})
+	return err
+}
@@ -0,0 +1,94 @@
+package diskqueue
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component"
+	"github.com/Kindling-project/kindling/collector/pkg/component/consumer/exporter"
+)
+
+type mockBackend struct {
+	mu      sync.Mutex
+	failing bool
+	// rejected is the payload rejected by the backend permanently.
+	rejected string
+	received []string
+}
+
+func (m *mockBackend) send(data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.failing {
+		return errors.New("backend is down")
+	}
+	if string(data) == m.rejected {
+		return exporter.NewPermanentError(errors.New("bad request"))
+	}
+	m.received = append(m.received, string(data))
+	return nil
+}
+
+func (m *mockBackend) setFailing(failing bool) {
+	m.mu.Lock()
+	m.failing = failing
+	m.mu.Unlock()
+}
+
+func (m *mockBackend) receivedPayloads() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.received...)
+}
+
+func TestSenderReplayInOrder(t *testing.T) {
+	backend := &mockBackend{}
+	s, err := NewSender("test", testConfig(t.TempDir()), component.NewDefaultTelemetryTools(), backend.send)
+	assert.NoError(t, err)
+	// The payloads are sent directly while the backend is available.
+	assert.NoError(t, s.Send([]byte("0")))
+	assert.Equal(t, []string{"0"}, backend.receivedPayloads())
+
+	backend.setFailing(true)
+	for i := 1; i < 50; i++ {
+		assert.NoError(t, s.Send([]byte(strconv.Itoa(i))))
+	}
+	count, _ := s.queue.stats()
+	assert.Equal(t, int64(49), count)
+
+	// The backend is back. The payloads sent now are queued behind the ones left.
+	backend.setFailing(false)
+	assert.NoError(t, s.Send([]byte("50")))
+	assert.Eventually(t, func() bool { return len(backend.receivedPayloads()) == 51 }, 5*time.Second, 10*time.Millisecond)
+	for i, payload := range backend.receivedPayloads() {
+		assert.Equal(t, strconv.Itoa(i), payload)
+	}
+	assert.NoError(t, s.Shutdown())
+	assert.NoError(t, s.Shutdown())
+}
+
+func TestSenderPermanentError(t *testing.T) {
+	backend := &mockBackend{rejected: "bad"}
+	s, err := NewSender("test", testConfig(t.TempDir()), component.NewDefaultTelemetryTools(), backend.send)
+	assert.NoError(t, err)
+	// The payload is dropped rather than queued.
+	assert.Error(t, s.Send([]byte("bad")))
+	count, _ := s.queue.stats()
+	assert.Equal(t, int64(0), count)
+
+	backend.setFailing(true)
+	for _, payload := range []string{"0", "bad", "1"} {
+		assert.NoError(t, s.Send([]byte(payload)))
+	}
+	backend.setFailing(false)
+	// The rejected payload doesn't block the ones behind it.
+	assert.Eventually(t, func() bool { return len(backend.receivedPayloads()) == 2 }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"0", "1"}, backend.receivedPayloads())
+	assert.Equal(t, int64(2), s.dropped.Load())
+	assert.NoError(t, s.Shutdown())
+}
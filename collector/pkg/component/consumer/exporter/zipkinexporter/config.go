@@ -0,0 +1,23 @@
+package zipkinexporter
+
+import "time"
+
+type Config struct {
+	// Endpoint is the URL the Zipkin v2 JSON spans are posted to. Jaeger accepts the
+	// same format when its Zipkin collector is enabled.
+	Endpoint string        `mapstructure:"endpoint"`
+	Timeout  time.Duration `mapstructure:"timeout"`
+	// Headers are added to each HTTP request.
+	Headers map[string]string `mapstructure:"headers"`
+	// MaxRetries is the number of times a batch is retried after network errors, 5xx or 429
+	// responses.
+	MaxRetries int `mapstructure:"max_retries"`
+}
+
+func NewDefaultConfig() *Config {
+	return &Config{
+		Endpoint:   "http://127.0.0.1:9411/api/v2/spans",
+		Timeout:    10 * time.Second,
+		MaxRetries: 3,
+	}
+}
@@ -0,0 +1,31 @@
+package zipkinexporter
+
+import (
+	"sync"
+
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	sentSpansMetric    = "kindling_telemetry_zipkinexporter_sent_spans_total"
+	droppedSpansMetric = "kindling_telemetry_zipkinexporter_dropped_spans_total"
+)
+
+const dropReasonSendFailed = "send_failed"
+
+var once sync.Once
+
+var (
+	sentSpansCounter    metric.Int64Counter
+	droppedSpansCounter metric.Int64Counter
+)
+
+func newSelfMetrics(meterProvider metric.MeterProvider) {
+	once.Do(func() {
+		meter := metric.Must(meterProvider.Meter("kindling"))
+		sentSpansCounter = meter.NewInt64Counter(sentSpansMetric,
+			metric.WithDescription("The total number of spans sent to the Zipkin endpoint successfully"))
+		droppedSpansCounter = meter.NewInt64Counter(droppedSpansMetric,
+			metric.WithDescription("The total number of spans dropped by zipkinexporter"))
+	})
+}
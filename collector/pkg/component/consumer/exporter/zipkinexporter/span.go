@@ -0,0 +1,168 @@
+package zipkinexporter
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"net"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constlabels"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constvalues"
+)
+
+const (
+	kindServer = "SERVER"
+	kindClient = "CLIENT"
+
+	// apmTraceIdTag keeps the original trace id of the APM when it is not a valid Zipkin
+	// trace id and has to be hashed.
+	apmTraceIdTag = "apm.trace_id"
+	errorTag      = "error"
+)
+
+// span is a span in the Zipkin v2 JSON format.
+// See https://zipkin.io/zipkin-api/#/default/post_spans.
+type span struct {
+	TraceId        string            `json:"traceId"`
+	Id             string            `json:"id"`
+	Kind           string            `json:"kind"`
+	Name           string            `json:"name"`
+	Timestamp      int64             `json:"timestamp"`
+	Duration       int64             `json:"duration"`
+	LocalEndpoint  *endpoint         `json:"localEndpoint,omitempty"`
+	RemoteEndpoint *endpoint         `json:"remoteEndpoint,omitempty"`
+	Tags           map[string]string `json:"tags,omitempty"`
+}
+
+type endpoint struct {
+	ServiceName string `json:"serviceName,omitempty"`
+	Ipv4        string `json:"ipv4,omitempty"`
+	Ipv6        string `json:"ipv6,omitempty"`
+	Port        int64  `json:"port,omitempty"`
+}
+
+// toSpan converts the request record to a span. The server side is the destination of
+// the request, so the local endpoint is the destination for the server spans and the
+// source for the client spans.
+func toSpan(dataGroup *model.DataGroup, attrs []attribute.KeyValue) *span {
+	labels := dataGroup.Labels
+	isServer := labels.GetBoolValue(constlabels.IsServer)
+	src := newEndpoint(labels, constlabels.SrcWorkloadName, constlabels.SrcService, constlabels.SrcPod,
+		constlabels.SrcIp, constlabels.SrcPort)
+	dst := newEndpoint(labels, constlabels.DstWorkloadName, constlabels.DstService, constlabels.DstPod,
+		constlabels.DstIp, constlabels.DstPort)
+
+	s := &span{
+		Id:        newSpanId(),
+		Name:      spanName(labels),
+		Timestamp: int64(dataGroup.Timestamp / 1000),
+		Tags:      make(map[string]string, len(attrs)+2),
+	}
+	if isServer {
+		s.Kind, s.LocalEndpoint, s.RemoteEndpoint = kindServer, dst, src
+	} else {
+		s.Kind, s.LocalEndpoint, s.RemoteEndpoint = kindClient, src, dst
+	}
+	if totalTime, ok := dataGroup.GetMetric(constvalues.RequestTotalTime); ok {
+		s.Duration = totalTime.GetInt().Value / 1000
+	}
+	// Zipkin requires the duration to be at least one microsecond.
+	if s.Duration <= 0 {
+		s.Duration = 1
+	}
+	for _, kv := range attrs {
+		if value := kv.Value.Emit(); value != "" {
+			s.Tags[string(kv.Key)] = value
+		}
+	}
+	if labels.GetBoolValue(constlabels.IsError) {
+		s.Tags[errorTag] = strconv.FormatInt(labels.GetIntValue(constlabels.ErrorType), 10)
+	}
+	apmTraceId := labels.GetStringValue(constlabels.HttpApmTraceId)
+	if apmTraceId == "" {
+		s.TraceId = newTraceId()
+	} else if isValidTraceId(apmTraceId) {
+		s.TraceId = strings.ToLower(apmTraceId)
+	} else {
+		s.TraceId = hashTraceId(apmTraceId)
+		s.Tags[apmTraceIdTag] = apmTraceId
+	}
+	return s
+}
+
+func newEndpoint(labels *model.AttributeMap, workloadKey, serviceKey, podKey, ipKey, portKey string) *endpoint {
+	e := &endpoint{Port: labels.GetIntValue(portKey)}
+	for _, key := range []string{workloadKey, serviceKey, podKey} {
+		if name := labels.GetStringValue(key); name != "" {
+			e.ServiceName = name
+			break
+		}
+	}
+	ip := labels.GetStringValue(ipKey)
+	if parsed := net.ParseIP(ip); parsed != nil {
+		if parsed.To4() != nil {
+			e.Ipv4 = ip
+		} else {
+			e.Ipv6 = ip
+		}
+		if e.ServiceName == "" {
+			e.ServiceName = ip
+		}
+	}
+	return e
+}
+
+func spanName(labels *model.AttributeMap) string {
+	protocol := labels.GetStringValue(constlabels.Protocol)
+	contentKey := labels.GetStringValue(constlabels.ContentKey)
+	method := labels.GetStringValue(constlabels.HttpMethod)
+	switch {
+	case method != "" && contentKey != "":
+		return method + " " + contentKey
+	case contentKey != "":
+		return contentKey
+	case protocol != "":
+		return protocol
+	default:
+		return "unknown"
+	}
+}
+
+// isValidTraceId checks whether the id is 16 or 32 hex characters as Zipkin requires.
+func isValidTraceId(id string) bool {
+	if len(id) != 16 && len(id) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
+
+// hashTraceId converts the trace id of the APM, e.g. a SkyWalking segment id, to a
+// 128-bit Zipkin trace id. The same id is always hashed to the same trace id, so the
+// spans of the same request still join each other.
+func hashTraceId(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:16])
+}
+
+func newTraceId() string {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+func newSpanId() string {
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	// The span id must not be zero.
+	if binary.BigEndian.Uint64(id) == 0 {
+		id[7] = 1
+	}
+	return hex.EncodeToString(id)
+}
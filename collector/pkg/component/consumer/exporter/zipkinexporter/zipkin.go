@@ -0,0 +1,142 @@
+package zipkinexporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component"
+	"github.com/Kindling-project/kindling/collector/pkg/component/consumer/exporter"
+	"github.com/Kindling-project/kindling/collector/pkg/component/consumer/exporter/tools/adapter"
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constnames"
+)
+
+const Type = "zipkinexporter"
+
+// retryBackoff is the time to wait before retrying a batch.
+const retryBackoff = 500 * time.Millisecond
+
+// ZipkinExporter converts the request records to Zipkin v2 spans and posts them to a
+// Zipkin-compatible endpoint. It doesn't cache the spans, and the batching is done by
+// the batcher which wraps it, so the spans of a batch are posted in one request.
+type ZipkinExporter struct {
+	cfg        *Config
+	telemetry  *component.TelemetryTools
+	adapter    adapter.Adapter
+	httpClient *http.Client
+}
+
+func New(config interface{}, telemetry *component.TelemetryTools) exporter.Exporter {
+	cfg, ok := config.(*Config)
+	if !ok {
+		telemetry.Logger.Panic("Cannot convert Component config", zap.String("componentType", Type))
+	}
+	newSelfMetrics(telemetry.MeterProvider)
+	return &ZipkinExporter{
+		cfg:       cfg,
+		telemetry: telemetry,
+		adapter: adapter.NewNetAdapter(nil, &adapter.NetAdapterConfig{
+			StoreTraceAsMetric: false,
+			StoreTraceAsSpan:   true,
+		}),
+		httpClient: &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+func (e *ZipkinExporter) Consume(dataGroup *model.DataGroup) error {
+	if dataGroup == nil {
+		return nil
+	}
+	return e.ConsumeBatch([]*model.DataGroup{dataGroup})
+}
+
+// ConsumeBatch posts the spans of the DataGroups in one request. The request is retried
+// after network errors, 5xx or 429 responses up to MaxRetries times, and the error is
+// returned if it still fails. The spans refused by the endpoint are dropped and the error
+// is marked as permanent.
+func (e *ZipkinExporter) ConsumeBatch(dataGroups []*model.DataGroup) error {
+	var spans []*span
+	for _, dataGroup := range dataGroups {
+		if dataGroup == nil || dataGroup.Name != constnames.SingleNetRequestMetricGroup {
+			continue
+		}
+		results, err := e.adapter.Adapt(dataGroup, adapter.AttributeList)
+		if err != nil {
+			e.telemetry.Logger.Error("Failed to adapt dataGroup", zap.Error(err))
+		}
+		for _, result := range results {
+			if result.ResultType == adapter.Trace {
+				spans = append(spans, toSpan(dataGroup, result.AttrsList))
+			}
+			result.Free()
+		}
+	}
+	if len(spans) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(spans)
+	if err != nil {
+		droppedSpansCounter.Add(context.Background(), int64(len(spans)), attribute.String("reason", dropReasonSendFailed))
+		return exporter.NewPermanentError(fmt.Errorf("can't encode the spans: %w", err))
+	}
+	retryable, err := e.sendWithRetry(body)
+	if err != nil {
+		err = fmt.Errorf("failed to send %d spans: %w", len(spans), err)
+		if retryable {
+			return err
+		}
+		droppedSpansCounter.Add(context.Background(), int64(len(spans)), attribute.String("reason", dropReasonSendFailed))
+		return exporter.NewPermanentError(err)
+	}
+	sentSpansCounter.Add(context.Background(), int64(len(spans)))
+	return nil
+}
+
+// sendWithRetry posts the spans and reports whether the last failure can be retried.
+func (e *ZipkinExporter) sendWithRetry(body []byte) (bool, error) {
+	var (
+		retryable bool
+		err       error
+	)
+	for i := 0; i <= e.cfg.MaxRetries; i++ {
+		if i > 0 {
+			time.Sleep(retryBackoff)
+		}
+		if retryable, err = e.send(body); err == nil || !retryable {
+			return retryable, err
+		}
+	}
+	return retryable, err
+}
+
+// send posts the spans and reports whether the request can be retried if it fails.
+func (e *ZipkinExporter) send(body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, e.cfg.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	for k, v := range e.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return false, nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	retryable := resp.StatusCode/100 == 5 || resp.StatusCode == http.StatusTooManyRequests
+	return retryable, fmt.Errorf("server returned HTTP status %s: %s", resp.Status, bytes.TrimSpace(msg))
+}
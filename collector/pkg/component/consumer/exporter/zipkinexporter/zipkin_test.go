@@ -0,0 +1,135 @@
+package zipkinexporter
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component"
+	"github.com/Kindling-project/kindling/collector/pkg/component/consumer/exporter"
+	"github.com/Kindling-project/kindling/collector/pkg/component/consumer/exporter/internal/testutil"
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constlabels"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constnames"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constvalues"
+)
+
+// receivedSpans returns the spans posted to the sink successfully.
+func receivedSpans(t *testing.T, sink *testutil.HTTPSink) []*span {
+	var spans []*span
+	for _, req := range sink.Requests() {
+		assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+		assert.Equal(t, "test", req.Header.Get("X-Tenant"))
+		var received []*span
+		require.NoError(t, json.Unmarshal(req.Body, &received))
+		spans = append(spans, received...)
+	}
+	return spans
+}
+
+func newTestExporter(sink *testutil.HTTPSink) *ZipkinExporter {
+	cfg := NewDefaultConfig()
+	cfg.Endpoint = sink.URL
+	cfg.Headers = map[string]string{"X-Tenant": "test"}
+	return New(cfg, component.NewDefaultTelemetryTools()).(*ZipkinExporter)
+}
+
+func newRequestDataGroup(isServer bool, traceId string) *model.DataGroup {
+	labels := model.NewAttributeMap()
+	labels.AddBoolValue(constlabels.IsServer, isServer)
+	labels.AddStringValue(constlabels.Protocol, "http")
+	labels.AddStringValue(constlabels.HttpMethod, "GET")
+	labels.AddStringValue(constlabels.ContentKey, "/users")
+	labels.AddStringValue(constlabels.SrcIp, "10.0.0.1")
+	labels.AddIntValue(constlabels.SrcPort, 40000)
+	labels.AddStringValue(constlabels.SrcWorkloadName, "frontend")
+	labels.AddStringValue(constlabels.DstIp, "10.0.0.2")
+	labels.AddIntValue(constlabels.DstPort, 8080)
+	labels.AddStringValue(constlabels.DstService, "user-service")
+	labels.AddBoolValue(constlabels.IsError, true)
+	labels.AddIntValue(constlabels.ErrorType, 3)
+	if traceId != "" {
+		labels.AddStringValue(constlabels.HttpApmTraceId, traceId)
+	}
+	return model.NewDataGroup(constnames.SingleNetRequestMetricGroup, labels, uint64(time.Unix(100, 0).UnixNano()),
+		model.NewIntMetric(constvalues.RequestTotalTime, 2500000))
+}
+
+func TestExportSpans(t *testing.T) {
+	sink := testutil.NewHTTPSink(t)
+	e := newTestExporter(sink)
+	assert.NoError(t, e.ConsumeBatch([]*model.DataGroup{
+		newRequestDataGroup(true, "4BF92F3577B34DA6A3CE929D0E0E4736"),
+		newRequestDataGroup(false, "segment-1.2.3"),
+		newRequestDataGroup(false, ""),
+		// The DataGroups which are not request records are ignored.
+		model.NewDataGroup(constnames.AggregatedNetRequestMetricGroup, model.NewAttributeMap(), 0),
+	}))
+	// The spans of a batch are posted together.
+	assert.Len(t, sink.Requests(), 1)
+	spans := receivedSpans(t, sink)
+	require.Len(t, spans, 3)
+
+	server := spans[0]
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.TraceId)
+	assert.Len(t, server.Id, 16)
+	assert.Equal(t, kindServer, server.Kind)
+	assert.Equal(t, "GET /users", server.Name)
+	assert.Equal(t, int64(100000000), server.Timestamp)
+	assert.Equal(t, int64(2500), server.Duration)
+	assert.Equal(t, &endpoint{ServiceName: "user-service", Ipv4: "10.0.0.2", Port: 8080}, server.LocalEndpoint)
+	assert.Equal(t, &endpoint{ServiceName: "frontend", Ipv4: "10.0.0.1", Port: 40000}, server.RemoteEndpoint)
+	assert.Equal(t, "3", server.Tags[errorTag])
+	assert.Equal(t, "http", server.Tags[constlabels.Protocol])
+	assert.NotContains(t, server.Tags, apmTraceIdTag)
+
+	// The trace id which is not valid in Zipkin is hashed and kept in the tags.
+	client := spans[1]
+	assert.Equal(t, kindClient, client.Kind)
+	assert.Equal(t, hashTraceId("segment-1.2.3"), client.TraceId)
+	assert.Len(t, client.TraceId, 32)
+	assert.Equal(t, "segment-1.2.3", client.Tags[apmTraceIdTag])
+	assert.Equal(t, "frontend", client.LocalEndpoint.ServiceName)
+	assert.Equal(t, "user-service", client.RemoteEndpoint.ServiceName)
+
+	assert.True(t, isValidTraceId(spans[2].TraceId))
+}
+
+func TestRetryOnServerError(t *testing.T) {
+	sink := testutil.NewHTTPSink(t)
+	failures := 2
+	sink.SetResponder(func(*testutil.Request) (int, string) {
+		if failures > 0 {
+			failures--
+			return http.StatusServiceUnavailable, ""
+		}
+		return http.StatusAccepted, ""
+	})
+	e := newTestExporter(sink)
+	assert.NoError(t, e.Consume(newRequestDataGroup(true, "")))
+	assert.Len(t, sink.Requests(), 3)
+
+	// The error is returned to be retried later once all the retries fail.
+	e.cfg.MaxRetries = 0
+	failures = 1
+	err := e.Consume(newRequestDataGroup(true, ""))
+	require.Error(t, err)
+	assert.False(t, exporter.IsPermanentError(err))
+}
+
+func TestDropRefusedSpans(t *testing.T) {
+	sink := testutil.NewHTTPSink(t)
+	sink.SetResponder(func(*testutil.Request) (int, string) {
+		return http.StatusBadRequest, "invalid span"
+	})
+	e := newTestExporter(sink)
+	err := e.Consume(newRequestDataGroup(true, ""))
+	require.Error(t, err)
+	assert.True(t, exporter.IsPermanentError(err))
+	assert.Contains(t, err.Error(), "400 Bad Request: invalid span")
+	assert.Len(t, sink.Requests(), 1)
+}
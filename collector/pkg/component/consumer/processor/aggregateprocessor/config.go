@@ -32,6 +32,20 @@ func NewDefaultConfig() *Config {
 			"kindling_tcp_srtt_microseconds": {{Kind: "last"}},
 			"kindling_tcp_retransmit_total":  {{Kind: "sum"}},
 			"kindling_tcp_packet_loss_total": {{Kind: "sum"}},
+			// time breakdown of the profiled requests
+			"oncpu_time": {{Kind: "sum", OutputName: "kindling_profiling_oncpu_duration_nanoseconds_total"},
+				{Kind: "count", OutputName: "kindling_profiling_request_total"}},
+			"file_time":  {{Kind: "sum", OutputName: "kindling_profiling_file_duration_nanoseconds_total"}},
+			"net_time":   {{Kind: "sum", OutputName: "kindling_profiling_net_duration_nanoseconds_total"}},
+			"futex_time": {{Kind: "sum", OutputName: "kindling_profiling_futex_duration_nanoseconds_total"}},
+			"idle_time":  {{Kind: "sum", OutputName: "kindling_profiling_idle_duration_nanoseconds_total"}},
+			"other_time": {{Kind: "sum", OutputName: "kindling_profiling_other_duration_nanoseconds_total"}},
+			"epoll_time": {{Kind: "sum", OutputName: "kindling_profiling_epoll_duration_nanoseconds_total"}},
+			"runq_time":  {{Kind: "sum", OutputName: "kindling_profiling_runq_duration_nanoseconds_total"}},
+			// contended locks of the Java processes
+			"lock_wait_time":     {{Kind: "sum", OutputName: "kindling_profiling_lock_wait_duration_nanoseconds_total"}},
+			"lock_max_wait_time": {{Kind: "max", OutputName: "kindling_profiling_lock_wait_max_duration_nanoseconds"}},
+			"lock_wait_count":    {{Kind: "sum", OutputName: "kindling_profiling_lock_wait_total"}},
 		},
 		SamplingRate: &SampleConfig{
 			NormalData: 0,
@@ -2,6 +2,7 @@ package aggregateprocessor
 
 import (
 	"math/rand"
+	"sync"
 	"time"
 
 	"go.uber.org/zap"
@@ -24,6 +25,10 @@ var exponentialInt64Boundaries = []int64{10e6, 20e6, 50e6, 80e6, 130e6, 200e6, 3
 
 var tcpConnectLabelSelectors = newTcpConnectLabelSelectors()
 
+var timeBreakdownLabelSelectors = newTimeBreakdownLabelSelectors()
+
+var lockContentionLabelSelectors = newLockContentionLabelSelectors()
+
 type AggregateProcessor struct {
 	cfg          *Config
 	telemetry    *component.TelemetryTools
@@ -34,6 +39,7 @@ type AggregateProcessor struct {
 	tcpLabelSelectors        *aggregator.LabelSelectors
 	stopCh                   chan struct{}
 	ticker                   *time.Ticker
+	tickerWg                 sync.WaitGroup
 }
 
 func New(config interface{}, telemetry *component.TelemetryTools, nextConsumer consumer.Consumer) processor.Processor {
@@ -47,12 +53,21 @@ func New(config interface{}, telemetry *component.TelemetryTools, nextConsumer c
 		netRequestLabelSelectors: newNetRequestLabelSelectors(),
 		tcpLabelSelectors:        newTcpLabelSelectors(),
 		stopCh:                   make(chan struct{}),
-		ticker:                   time.NewTicker(time.Duration(cfg.TickerInterval) * time.Second),
 	}
-	go p.runTicker()
 	return p
 }
 
+// Start starts dumping the aggregated data to the next consumer every TickerInterval.
+func (p *AggregateProcessor) Start() error {
+	p.ticker = time.NewTicker(time.Duration(p.cfg.TickerInterval) * time.Second)
+	p.tickerWg.Add(1)
+	go func() {
+		defer p.tickerWg.Done()
+		p.runTicker()
+	}()
+	return nil
+}
+
 func toAggregatedConfig(m map[string][]AggregatedKindConfig) *defaultaggregator.AggregatedConfig {
 	ret := &defaultaggregator.AggregatedConfig{KindMap: make(map[string][]defaultaggregator.KindConfig)}
 	for k, v := range m {
@@ -91,7 +106,6 @@ func newKindConfig(rawConfig *AggregatedKindConfig) (kindConfig defaultaggregato
 	}
 }
 
-// TODO: Graceful shutdown
 func (p *AggregateProcessor) runTicker() {
 	for {
 		select {
@@ -110,6 +124,23 @@ func (p *AggregateProcessor) runTicker() {
 	}
 }
 
+// Shutdown stops the ticker and sends the data aggregated so far to the next consumer,
+// so the last window is not lost.
+func (p *AggregateProcessor) Shutdown() error {
+	close(p.stopCh)
+	p.tickerWg.Wait()
+	if p.ticker != nil {
+		p.ticker.Stop()
+	}
+	for _, agg := range p.aggregator.Dump() {
+		if err := p.nextConsumer.Consume(agg); err != nil {
+			p.telemetry.Logger.Warn("Error happened when consuming aggregated recordersMap",
+				zap.Error(err))
+		}
+	}
+	return nil
+}
+
 func (p *AggregateProcessor) Consume(dataGroup *model.DataGroup) error {
 	switch dataGroup.Name {
 	case constnames.NetRequestMetricGroupName:
@@ -125,6 +156,9 @@ func (p *AggregateProcessor) Consume(dataGroup *model.DataGroup) error {
 		dataGroup.Name = constnames.AggregatedNetRequestMetricGroup
 		p.aggregator.Aggregate(dataGroup, p.netRequestLabelSelectors)
 		return abnormalDataErr
+	case constnames.SingleNetRequestMetricGroup:
+		// The records sampled by the processors before, e.g. tailsamplingprocessor, are passed through.
+		return p.nextConsumer.Consume(dataGroup)
 	case constnames.TcpRttMetricGroupName:
 		fallthrough
 	case constnames.TcpRetransmitMetricGroupName:
@@ -135,6 +169,12 @@ func (p *AggregateProcessor) Consume(dataGroup *model.DataGroup) error {
 	case constnames.TcpConnectMetricGroupName:
 		p.aggregator.Aggregate(dataGroup, tcpConnectLabelSelectors)
 		return nil
+	case constnames.RequestTimeBreakdownMetricGroupName:
+		p.aggregator.Aggregate(dataGroup, timeBreakdownLabelSelectors)
+		return nil
+	case constnames.LockContentionMetricGroupName:
+		p.aggregator.Aggregate(dataGroup, lockContentionLabelSelectors)
+		return nil
 	default:
 		p.aggregator.Aggregate(dataGroup, p.netRequestLabelSelectors)
 		return nil
@@ -246,6 +286,33 @@ func newTcpConnectLabelSelectors() *aggregator.LabelSelectors {
 	)
 }
 
+// newTimeBreakdownLabelSelectors aggregates the time breakdown of the profiled requests
+// for each content key of the server.
+func newTimeBreakdownLabelSelectors() *aggregator.LabelSelectors {
+	return aggregator.NewLabelSelectors(
+		aggregator.LabelSelector{Name: constlabels.Protocol, VType: aggregator.StringType},
+		aggregator.LabelSelector{Name: constlabels.ContentKey, VType: aggregator.StringType},
+		aggregator.LabelSelector{Name: constlabels.DstNamespace, VType: aggregator.StringType},
+		aggregator.LabelSelector{Name: constlabels.DstWorkloadName, VType: aggregator.StringType},
+		aggregator.LabelSelector{Name: constlabels.DstWorkloadKind, VType: aggregator.StringType},
+		aggregator.LabelSelector{Name: constlabels.DstService, VType: aggregator.StringType},
+		aggregator.LabelSelector{Name: constlabels.DstPod, VType: aggregator.StringType},
+		aggregator.LabelSelector{Name: constlabels.DstContainer, VType: aggregator.StringType},
+	)
+}
+
+// newLockContentionLabelSelectors aggregates the waits of each contended lock of the processes.
+// The stacks and the holder threads are not selected because they would make the series
+// unbounded, e.g. the threads of a pool come and go. They are kept in the result of the
+// controller API instead.
+func newLockContentionLabelSelectors() *aggregator.LabelSelectors {
+	return aggregator.NewLabelSelectors(
+		aggregator.LabelSelector{Name: constlabels.Pid, VType: aggregator.IntType},
+		aggregator.LabelSelector{Name: constlabels.LockAddress, VType: aggregator.StringType},
+		aggregator.LabelSelector{Name: constlabels.LockEventType, VType: aggregator.StringType},
+	)
+}
+
 func (p *AggregateProcessor) isSampled(dataGroup *model.DataGroup) bool {
 	randSeed := rand.Intn(100)
 	if isAbnormal(dataGroup) {
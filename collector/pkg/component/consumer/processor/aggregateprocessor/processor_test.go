@@ -0,0 +1,32 @@
+package aggregateprocessor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constlabels"
+)
+
+func TestLockContentionLabelSelectors(t *testing.T) {
+	newLabels := func(stack string, holderTid int64, holderThreadName string) *model.AttributeMap {
+		labels := model.NewAttributeMap()
+		labels.AddIntValue(constlabels.Pid, 100)
+		labels.AddStringValue(constlabels.LockAddress, "d0390ae8")
+		labels.AddStringValue(constlabels.LockEventType, "MonitorEnter")
+		labels.AddStringValue(constlabels.LockStack, stack)
+		labels.AddIntValue(constlabels.HolderTid, holderTid)
+		labels.AddStringValue(constlabels.HolderThreadName, holderThreadName)
+		return labels
+	}
+	// The waits for the same lock are aggregated into one series whatever the stacks and
+	// the holders are.
+	first := lockContentionLabelSelectors.GetLabelKeys(newLabels("a;b", 101, "pool-1-thread-1"))
+	second := lockContentionLabelSelectors.GetLabelKeys(newLabels("a;c", 102, "pool-1-thread-2"))
+	assert.Equal(t, *first, *second)
+	labels := first.GetLabels()
+	assert.Equal(t, "d0390ae8", labels.GetStringValue(constlabels.LockAddress))
+	assert.False(t, labels.HasAttribute(constlabels.LockStack))
+	assert.False(t, labels.HasAttribute(constlabels.HolderTid))
+}
@@ -0,0 +1,197 @@
+package attributesprocessor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+
+	"github.com/Kindling-project/kindling/collector/pkg/metadata/kubernetes"
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constlabels"
+)
+
+// podGetter returns the pod in the namespace with the name.
+type podGetter func(namespace string, name string) (*kubernetes.K8sPodInfo, bool)
+
+// action changes the labels of the DataGroup, which are not nil. The labels are always
+// replaced instead of changed in place, so their types can be changed.
+type action func(labels *model.AttributeMap)
+
+// podLabelKeys maps Pod to the labels of its namespace and name.
+var podLabelKeys = map[string][2]string{
+	PodSrc:  {constlabels.SrcNamespace, constlabels.SrcPod},
+	PodDst:  {constlabels.DstNamespace, constlabels.DstPod},
+	PodSelf: {constlabels.Namespace, constlabels.Pod},
+}
+
+func newAction(cfg *ActionConfig, getPod podGetter) (action, error) {
+	if cfg.Key == "" {
+		return nil, fmt.Errorf("key of %s is required", cfg.Action)
+	}
+	key := cfg.Key
+	switch cfg.Action {
+	case ActionInsert, ActionUpdate, ActionUpsert:
+		set, err := newSetter(cfg)
+		if err != nil {
+			return nil, err
+		}
+		mode := cfg.Action
+		return func(labels *model.AttributeMap) {
+			exists := labels.HasAttribute(key)
+			if (mode == ActionInsert && exists) || (mode == ActionUpdate && !exists) {
+				return
+			}
+			set(labels)
+		}, nil
+	case ActionDelete:
+		return func(labels *model.AttributeMap) {
+			labels.RemoveAttribute(key)
+		}, nil
+	case ActionHash:
+		salt := cfg.Salt
+		return func(labels *model.AttributeMap) {
+			value, ok := labels.GetValues()[key]
+			if !ok {
+				return
+			}
+			sum := sha256.Sum256([]byte(salt + value.ToString()))
+			labels.AddStringValue(key, hex.EncodeToString(sum[:]))
+		}, nil
+	case ActionRename:
+		if cfg.NewKey == "" || cfg.NewKey == cfg.Key {
+			return nil, fmt.Errorf("new_key of rename is required and must differ from key")
+		}
+		newKey := cfg.NewKey
+		return func(labels *model.AttributeMap) {
+			if !labels.HasAttribute(key) {
+				return
+			}
+			copyValue(labels, key, newKey)
+			labels.RemoveAttribute(key)
+		}, nil
+	case ActionExtract:
+		re, err := regexp.Compile(cfg.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern of extract: %w", err)
+		}
+		names := re.SubexpNames()
+		hasNamedGroup := false
+		for _, name := range names {
+			hasNamedGroup = hasNamedGroup || name != ""
+		}
+		if !hasNamedGroup {
+			return nil, fmt.Errorf("pattern of extract has no named groups: %s", cfg.Pattern)
+		}
+		return func(labels *model.AttributeMap) {
+			value, ok := labels.GetValues()[key]
+			if !ok || value.Type() != model.StringAttributeValueType {
+				return
+			}
+			match := re.FindStringSubmatchIndex(value.ToString())
+			if match == nil {
+				return
+			}
+			s := value.ToString()
+			for i, name := range names {
+				// The groups not participating in the match are skipped.
+				if name == "" || match[2*i] < 0 {
+					continue
+				}
+				labels.AddStringValue(name, s[match[2*i]:match[2*i+1]])
+			}
+		}, nil
+	case ActionPodLabel, ActionPodAnnotation:
+		podKeys, ok := podLabelKeys[cfg.Pod]
+		if !ok {
+			return nil, fmt.Errorf("unsupported pod %q of %s, expected %q, %q or %q", cfg.Pod, cfg.Action, PodSrc, PodDst, PodSelf)
+		}
+		if cfg.FromKey == "" {
+			return nil, fmt.Errorf("from_key of %s is required", cfg.Action)
+		}
+		fromKey := cfg.FromKey
+		annotation := cfg.Action == ActionPodAnnotation
+		if annotation {
+			// Only the annotations used are cached.
+			kubernetes.CachePodAnnotations(fromKey)
+		}
+		return func(labels *model.AttributeMap) {
+			pod, ok := getPod(labels.GetStringValue(podKeys[0]), labels.GetStringValue(podKeys[1]))
+			if !ok {
+				return
+			}
+			values := pod.Labels
+			if annotation {
+				values = pod.Annotations
+			}
+			if value, ok := values[fromKey]; ok {
+				labels.AddStringValue(key, value)
+			}
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported action %q", cfg.Action)
+}
+
+// newSetter returns the function setting the label to Value, or to the value of FromKey.
+func newSetter(cfg *ActionConfig) (action, error) {
+	key := cfg.Key
+	switch value := cfg.Value.(type) {
+	case nil:
+		if cfg.FromKey == "" {
+			return nil, fmt.Errorf("either value or from_key of %s is required", cfg.Action)
+		}
+		fromKey := cfg.FromKey
+		return func(labels *model.AttributeMap) {
+			if labels.HasAttribute(fromKey) {
+				copyValue(labels, fromKey, key)
+			}
+		}, nil
+	case string:
+		return func(labels *model.AttributeMap) {
+			labels.AddStringValue(key, value)
+		}, nil
+	case bool:
+		return func(labels *model.AttributeMap) {
+			labels.AddBoolValue(key, value)
+		}, nil
+	case int, int64, float64:
+		i, ok := toInt64(value)
+		if !ok {
+			return nil, fmt.Errorf("value of %s must be an integer: %v", cfg.Action, value)
+		}
+		return func(labels *model.AttributeMap) {
+			labels.AddIntValue(key, i)
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported value of %s: %v", cfg.Action, cfg.Value)
+}
+
+func toInt64(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int64:
+		return v, true
+	case float64:
+		return int64(v), float64(int64(v)) == v
+	}
+	return 0, false
+}
+
+// copyValue sets the label to with the value of the label from, keeping its type.
+func copyValue(labels *model.AttributeMap, from string, to string) {
+	value, ok := labels.GetValues()[from]
+	if !ok {
+		return
+	}
+	// The value is copied instead of shared, because the values are changed in place by
+	// the other components. The existing label is replaced, even if its type is different.
+	switch value.Type() {
+	case model.StringAttributeValueType:
+		labels.AddStringValue(to, labels.GetStringValue(from))
+	case model.IntAttributeValueType:
+		labels.AddIntValue(to, labels.GetIntValue(from))
+	case model.BooleanAttributeValueType:
+		labels.AddBoolValue(to, labels.GetBoolValue(from))
+	}
+}
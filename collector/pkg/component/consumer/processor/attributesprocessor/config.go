@@ -0,0 +1,71 @@
+package attributesprocessor
+
+// The actions on the labels.
+const (
+	// ActionInsert adds the label if it doesn't exist.
+	ActionInsert = "insert"
+	// ActionUpdate changes the label if it exists.
+	ActionUpdate = "update"
+	// ActionUpsert adds the label or changes it.
+	ActionUpsert = "upsert"
+	// ActionDelete removes the label.
+	ActionDelete = "delete"
+	// ActionHash replaces the value of the label with its SHA-256 hash in hex.
+	ActionHash = "hash"
+	// ActionRename moves the label to NewKey, which is overwritten if it exists.
+	ActionRename = "rename"
+	// ActionExtract adds the named groups of Pattern matched in the string label as labels.
+	ActionExtract = "extract"
+	// ActionPodLabel copies the label FromKey of the pod into the label Key.
+	ActionPodLabel = "pod_label"
+	// ActionPodAnnotation copies the annotation FromKey of the pod into the label Key.
+	ActionPodAnnotation = "pod_annotation"
+)
+
+// The pods whose metadata are copied.
+const (
+	// PodSrc is the pod of src_namespace and src_pod.
+	PodSrc = "src"
+	// PodDst is the pod of dst_namespace and dst_pod.
+	PodDst = "dst"
+	// PodSelf is the pod of namespace and pod.
+	PodSelf = "self"
+)
+
+type Config struct {
+	// Rules are applied in order.
+	Rules []RuleConfig `mapstructure:"rules"`
+}
+
+// RuleConfig applies the actions in order to the DataGroups selected.
+type RuleConfig struct {
+	// DataGroups are the names of the DataGroups the actions apply to. The actions
+	// apply to all DataGroups if it is empty.
+	DataGroups []string       `mapstructure:"data_groups"`
+	Actions    []ActionConfig `mapstructure:"actions"`
+}
+
+type ActionConfig struct {
+	Action string `mapstructure:"action"`
+	// Key is the label the action applies to.
+	Key string `mapstructure:"key"`
+	// Value is the value of insert, update and upsert, which can be a string, an integer
+	// or a boolean. FromKey is used instead if Value is not set.
+	Value interface{} `mapstructure:"value"`
+	// FromKey is the label whose value is copied by insert, update and upsert, or the key
+	// of the pod label or annotation copied.
+	FromKey string `mapstructure:"from_key"`
+	// NewKey is the new name of the label renamed.
+	NewKey string `mapstructure:"new_key"`
+	// Pattern is the regular expression of extract with named groups, e.g.
+	// "^/api/(?P<api_version>v[0-9]+)/".
+	Pattern string `mapstructure:"pattern"`
+	// Salt is prepended to the value before it is hashed.
+	Salt string `mapstructure:"salt"`
+	// Pod selects the pod of pod_label and pod_annotation, which is "src", "dst" or "self".
+	Pod string `mapstructure:"pod"`
+}
+
+func NewDefaultConfig() *Config {
+	return &Config{}
+}
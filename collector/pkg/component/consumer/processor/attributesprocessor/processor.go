@@ -0,0 +1,89 @@
+package attributesprocessor
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component"
+	"github.com/Kindling-project/kindling/collector/pkg/component/consumer"
+	"github.com/Kindling-project/kindling/collector/pkg/component/consumer/processor"
+	"github.com/Kindling-project/kindling/collector/pkg/metadata/kubernetes"
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+)
+
+const Type = "attributesprocessor"
+
+type rule struct {
+	// dataGroups contains the names of the DataGroups selected. All are selected if it is nil.
+	dataGroups map[string]struct{}
+	actions    []action
+}
+
+// AttributesProcessor changes the labels of the DataGroups by the rules before passing
+// them to the next consumer. The pod metadata is read from the cache of k8sprocessor, so
+// it should be placed after k8sprocessor.
+type AttributesProcessor struct {
+	telemetry    *component.TelemetryTools
+	nextConsumer consumer.Consumer
+	rules        []*rule
+}
+
+func New(config interface{}, telemetry *component.TelemetryTools, nextConsumer consumer.Consumer) processor.Processor {
+	cfg, ok := config.(*Config)
+	if !ok {
+		telemetry.Logger.Panic("Cannot convert Component config", zap.String("componentType", Type))
+	}
+	p, err := newAttributesProcessor(cfg, telemetry, nextConsumer, kubernetes.GetPodByName)
+	if err != nil {
+		telemetry.Logger.Panic("Invalid configuration of attributesprocessor", zap.Error(err))
+	}
+	return p
+}
+
+func newAttributesProcessor(cfg *Config, telemetry *component.TelemetryTools, nextConsumer consumer.Consumer, getPod podGetter) (*AttributesProcessor, error) {
+	p := &AttributesProcessor{
+		telemetry:    telemetry,
+		nextConsumer: nextConsumer,
+		rules:        make([]*rule, 0, len(cfg.Rules)),
+	}
+	for i := range cfg.Rules {
+		ruleConfig := &cfg.Rules[i]
+		r := &rule{actions: make([]action, 0, len(ruleConfig.Actions))}
+		if len(ruleConfig.DataGroups) > 0 {
+			r.dataGroups = make(map[string]struct{}, len(ruleConfig.DataGroups))
+			for _, name := range ruleConfig.DataGroups {
+				r.dataGroups[name] = struct{}{}
+			}
+		}
+		for j := range ruleConfig.Actions {
+			a, err := newAction(&ruleConfig.Actions[j], getPod)
+			if err != nil {
+				return nil, fmt.Errorf("rule %d action %d: %w", i, j, err)
+			}
+			r.actions = append(r.actions, a)
+		}
+		p.rules = append(p.rules, r)
+	}
+	return p, nil
+}
+
+func (p *AttributesProcessor) Consume(dataGroup *model.DataGroup) error {
+	if dataGroup == nil {
+		return nil
+	}
+	for _, r := range p.rules {
+		if r.dataGroups != nil {
+			if _, ok := r.dataGroups[dataGroup.Name]; !ok {
+				continue
+			}
+		}
+		if dataGroup.Labels == nil {
+			dataGroup.Labels = model.NewAttributeMap()
+		}
+		for _, a := range r.actions {
+			a(dataGroup.Labels)
+		}
+	}
+	return p.nextConsumer.Consume(dataGroup)
+}
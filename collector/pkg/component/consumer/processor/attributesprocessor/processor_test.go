@@ -0,0 +1,126 @@
+package attributesprocessor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component"
+	"github.com/Kindling-project/kindling/collector/pkg/metadata/kubernetes"
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constlabels"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constnames"
+)
+
+type recordingConsumer struct {
+	received []*model.DataGroup
+}
+
+func (c *recordingConsumer) Consume(dataGroup *model.DataGroup) error {
+	c.received = append(c.received, dataGroup)
+	return nil
+}
+
+func getTestPod(namespace string, name string) (*kubernetes.K8sPodInfo, bool) {
+	if namespace != "payments" || name != "checkout-1" {
+		return nil, false
+	}
+	return &kubernetes.K8sPodInfo{
+		Namespace:   namespace,
+		PodName:     name,
+		Labels:      map[string]string{"team": "payments"},
+		Annotations: map[string]string{"example.com/cost-center": "cc-42"},
+	}, true
+}
+
+func newRequestDataGroup(name string) *model.DataGroup {
+	labels := model.NewAttributeMap()
+	labels.AddStringValue(constlabels.SrcIp, "10.0.0.1")
+	labels.AddStringValue(constlabels.DstNamespace, "payments")
+	labels.AddStringValue(constlabels.DstPod, "checkout-1")
+	labels.AddStringValue(constlabels.ContentKey, "/api/v2/orders")
+	labels.AddIntValue(constlabels.DstPort, 8080)
+	labels.AddStringValue("env", "prod")
+	return model.NewDataGroup(name, labels, 0)
+}
+
+func TestAttributesProcessor(t *testing.T) {
+	cfg := &Config{Rules: []RuleConfig{
+		{
+			DataGroups: []string{constnames.NetRequestMetricGroupName},
+			Actions: []ActionConfig{
+				{Action: ActionInsert, Key: "cluster", Value: "dev"},
+				{Action: ActionInsert, Key: "env", Value: "dev"},
+				{Action: ActionUpdate, Key: "region", Value: "cn"},
+				{Action: ActionUpsert, Key: "tier", Value: 1},
+				{Action: ActionUpsert, Key: "canary", Value: true},
+				{Action: ActionInsert, Key: "port", FromKey: constlabels.DstPort},
+				{Action: ActionHash, Key: constlabels.SrcIp, Salt: "salt"},
+				{Action: ActionRename, Key: "env", NewKey: "environment"},
+				{Action: ActionExtract, Key: constlabels.ContentKey, Pattern: `^/api/(?P<api_version>v\d+)/(?P<resource>\w+)(?P<id>/\d+)?`},
+				{Action: ActionPodLabel, Key: "dst_team", FromKey: "team", Pod: PodDst},
+				{Action: ActionPodAnnotation, Key: "dst_cost_center", FromKey: "example.com/cost-center", Pod: PodDst},
+				{Action: ActionPodLabel, Key: "src_team", FromKey: "team", Pod: PodSrc},
+			},
+		},
+		{
+			Actions: []ActionConfig{{Action: ActionDelete, Key: constlabels.DstPod}},
+		},
+	}}
+	next := &recordingConsumer{}
+	p, err := newAttributesProcessor(cfg, component.NewDefaultTelemetryTools(), next, getTestPod)
+	require.NoError(t, err)
+
+	assert.NoError(t, p.Consume(newRequestDataGroup(constnames.NetRequestMetricGroupName)))
+	hash := sha256.Sum256([]byte("salt10.0.0.1"))
+	assert.Equal(t, map[string]string{
+		"cluster":                "dev",
+		"environment":            "prod",
+		"tier":                   "1",
+		"canary":                 "true",
+		"port":                   "8080",
+		constlabels.SrcIp:        hex.EncodeToString(hash[:]),
+		constlabels.DstNamespace: "payments",
+		constlabels.ContentKey:   "/api/v2/orders",
+		constlabels.DstPort:      "8080",
+		"api_version":            "v2",
+		"resource":               "orders",
+		"dst_team":               "payments",
+		"dst_cost_center":        "cc-42",
+	}, next.received[0].Labels.ToStringMap())
+	// The types of the values are kept.
+	assert.Equal(t, int64(1), next.received[0].Labels.GetIntValue("tier"))
+	assert.Equal(t, int64(8080), next.received[0].Labels.GetIntValue("port"))
+	assert.True(t, next.received[0].Labels.GetBoolValue("canary"))
+
+	// Only the rules selecting the DataGroup are applied.
+	assert.NoError(t, p.Consume(newRequestDataGroup(constnames.TcpConnectMetricGroupName)))
+	labels := next.received[1].Labels
+	assert.Equal(t, "10.0.0.1", labels.GetStringValue(constlabels.SrcIp))
+	assert.False(t, labels.HasAttribute(constlabels.DstPod))
+	assert.False(t, labels.HasAttribute("cluster"))
+
+	// The DataGroup without labels is passed as well.
+	assert.NoError(t, p.Consume(&model.DataGroup{Name: constnames.NetRequestMetricGroupName}))
+	assert.Equal(t, "dev", next.received[2].Labels.GetStringValue("cluster"))
+}
+
+func TestInvalidActions(t *testing.T) {
+	for expected, action := range map[string]ActionConfig{
+		`unsupported action "drop"`:                                             {Action: "drop", Key: "a"},
+		"key of insert is required":                                             {Action: ActionInsert, Value: "a"},
+		"either value or from_key of upsert is required":                        {Action: ActionUpsert, Key: "a"},
+		"value of insert must be an integer: 1.5":                               {Action: ActionInsert, Key: "a", Value: 1.5},
+		"new_key of rename is required and must differ from key":                {Action: ActionRename, Key: "a"},
+		"pattern of extract has no named groups: (a)":                           {Action: ActionExtract, Key: "a", Pattern: "(a)"},
+		"from_key of pod_label is required":                                     {Action: ActionPodLabel, Key: "a", Pod: PodDst},
+		`unsupported pod "" of pod_annotation, expected "src", "dst" or "self"`: {Action: ActionPodAnnotation, Key: "a", FromKey: "b"},
+	} {
+		_, err := newAttributesProcessor(&Config{Rules: []RuleConfig{{Actions: []ActionConfig{action}}}},
+			component.NewDefaultTelemetryTools(), &recordingConsumer{}, getTestPod)
+		assert.EqualError(t, err, "rule 0 action 0: "+expected)
+	}
+}
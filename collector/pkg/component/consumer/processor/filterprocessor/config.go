@@ -0,0 +1,30 @@
+package filterprocessor
+
+const (
+	// ModeExclude drops the DataGroups matching any rule.
+	ModeExclude = "exclude"
+	// ModeInclude only keeps the DataGroups matching any rule.
+	ModeInclude = "include"
+)
+
+type Config struct {
+	// Mode is either "exclude" or "include".
+	Mode string `mapstructure:"mode"`
+	// DataGroups are the names of the DataGroups filtered. The others are always kept.
+	// All DataGroups are filtered if it is empty.
+	DataGroups []string     `mapstructure:"data_groups"`
+	Rules      []RuleConfig `mapstructure:"rules"`
+}
+
+type RuleConfig struct {
+	// Name is used as the label of the match counter.
+	Name string `mapstructure:"name"`
+	// Expression is the condition the DataGroups match. See Expression for the syntax.
+	Expression string `mapstructure:"expression"`
+}
+
+func NewDefaultConfig() *Config {
+	return &Config{
+		Mode: ModeExclude,
+	}
+}
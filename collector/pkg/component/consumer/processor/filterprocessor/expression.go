@@ -0,0 +1,519 @@
+package filterprocessor
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+)
+
+// Expression is a compiled boolean expression evaluated against a DataGroup.
+//
+// The operands are:
+//   - name: the name of the DataGroup.
+//   - labels.<key>: the label with its own type, i.e. string, int or bool.
+//   - metrics.<key>: the value of the int metric.
+//   - literals: "string" or 'string', integers, true and false, and lists like ["a", "b"].
+//
+// The operators from the highest precedence are:
+//   - ==, !=, <, <=, >, >=, startsWith, endsWith, contains, matches and in.
+//   - not or !.
+//   - and or &&.
+//   - or or ||.
+//
+// A missing label or metric is null, which only equals nothing. The values of different
+// types never equal each other, and the other comparisons are false unless both sides
+// have the types required by the operator. A bool label can be used as a condition alone.
+type Expression struct {
+	source string
+	root   node
+}
+
+// Compile parses the expression. The regular expressions of "matches" are compiled as well.
+func Compile(source string) (*Expression, error) {
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %w", source, err)
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err == nil && p.peek().kind != eofToken {
+		err = fmt.Errorf("unexpected %s at position %d", p.peek(), p.peek().pos)
+	}
+	if err == nil && !isCondition(root) {
+		err = fmt.Errorf("not a condition")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %w", source, err)
+	}
+	return &Expression{source: source, root: root}, nil
+}
+
+// Match reports whether the DataGroup matches the expression.
+func (e *Expression) Match(dataGroup *model.DataGroup) bool {
+	return e.root.eval(dataGroup).isTrue()
+}
+
+func (e *Expression) String() string {
+	return e.source
+}
+
+type valueKind int
+
+const (
+	nullKind valueKind = iota
+	stringKind
+	intKind
+	boolKind
+	listKind
+)
+
+type value struct {
+	kind valueKind
+	s    string
+	i    int64
+	b    bool
+	list []value
+}
+
+func (v value) isTrue() bool {
+	return v.kind == boolKind && v.b
+}
+
+func (v value) equals(other value) bool {
+	if v.kind != other.kind {
+		return false
+	}
+	switch v.kind {
+	case stringKind:
+		return v.s == other.s
+	case intKind:
+		return v.i == other.i
+	case boolKind:
+		return v.b == other.b
+	}
+	return false
+}
+
+func boolValue(b bool) value {
+	return value{kind: boolKind, b: b}
+}
+
+type node interface {
+	eval(dataGroup *model.DataGroup) value
+}
+
+type literalNode struct {
+	value value
+}
+
+func (n *literalNode) eval(*model.DataGroup) value {
+	return n.value
+}
+
+type fieldSource int
+
+const (
+	nameField fieldSource = iota
+	labelField
+	metricField
+)
+
+type fieldNode struct {
+	source fieldSource
+	key    string
+}
+
+func (n *fieldNode) eval(dataGroup *model.DataGroup) value {
+	switch n.source {
+	case nameField:
+		return value{kind: stringKind, s: dataGroup.Name}
+	case labelField:
+		v, ok := dataGroup.Labels.GetValues()[n.key]
+		if !ok {
+			return value{}
+		}
+		switch v.Type() {
+		case model.StringAttributeValueType:
+			return value{kind: stringKind, s: dataGroup.Labels.GetStringValue(n.key)}
+		case model.IntAttributeValueType:
+			return value{kind: intKind, i: dataGroup.Labels.GetIntValue(n.key)}
+		case model.BooleanAttributeValueType:
+			return boolValue(dataGroup.Labels.GetBoolValue(n.key))
+		}
+	case metricField:
+		if metric, ok := dataGroup.GetMetric(n.key); ok && metric.DataType() == model.IntMetricType {
+			return value{kind: intKind, i: metric.GetInt().Value}
+		}
+	}
+	return value{}
+}
+
+type compareNode struct {
+	op          string
+	left, right node
+	// re is the compiled regular expression of "matches".
+	re *regexp.Regexp
+}
+
+func (n *compareNode) eval(dataGroup *model.DataGroup) value {
+	l := n.left.eval(dataGroup)
+	if n.op == "matches" {
+		return boolValue(l.kind == stringKind && n.re.MatchString(l.s))
+	}
+	r := n.right.eval(dataGroup)
+	switch n.op {
+	case "==":
+		return boolValue(l.equals(r))
+	case "!=":
+		return boolValue(!l.equals(r))
+	case "in":
+		for _, v := range r.list {
+			if l.equals(v) {
+				return boolValue(true)
+			}
+		}
+		return boolValue(false)
+	}
+	if l.kind == intKind && r.kind == intKind {
+		switch n.op {
+		case "<":
+			return boolValue(l.i < r.i)
+		case "<=":
+			return boolValue(l.i <= r.i)
+		case ">":
+			return boolValue(l.i > r.i)
+		case ">=":
+			return boolValue(l.i >= r.i)
+		}
+	}
+	if l.kind == stringKind && r.kind == stringKind {
+		switch n.op {
+		case "startsWith":
+			return boolValue(strings.HasPrefix(l.s, r.s))
+		case "endsWith":
+			return boolValue(strings.HasSuffix(l.s, r.s))
+		case "contains":
+			return boolValue(strings.Contains(l.s, r.s))
+		}
+	}
+	return boolValue(false)
+}
+
+type andNode struct {
+	left, right node
+}
+
+func (n *andNode) eval(dataGroup *model.DataGroup) value {
+	return boolValue(n.left.eval(dataGroup).isTrue() && n.right.eval(dataGroup).isTrue())
+}
+
+type orNode struct {
+	left, right node
+}
+
+func (n *orNode) eval(dataGroup *model.DataGroup) value {
+	return boolValue(n.left.eval(dataGroup).isTrue() || n.right.eval(dataGroup).isTrue())
+}
+
+type notNode struct {
+	operand node
+}
+
+func (n *notNode) eval(dataGroup *model.DataGroup) value {
+	return boolValue(!n.operand.eval(dataGroup).isTrue())
+}
+
+// isCondition reports whether the node can be evaluated as true or false.
+func isCondition(n node) bool {
+	switch n := n.(type) {
+	case *literalNode:
+		return n.value.kind == boolKind
+	case *fieldNode:
+		return n.source != nameField
+	}
+	return true
+}
+
+type tokenKind int
+
+const (
+	eofToken tokenKind = iota
+	identToken
+	stringToken
+	intToken
+	symbolToken
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	if t.kind == eofToken {
+		return "end of expression"
+	}
+	return strconv.Quote(t.text)
+}
+
+var symbols = []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "!", "(", ")", "[", "]", ","}
+
+func isIdentByte(c byte, first bool) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') ||
+		(!first && (c == '.' || isDigit(c)))
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func tokenize(source string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(source) {
+		c := source[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '"' || c == '\'':
+			start := i
+			var sb strings.Builder
+			for i++; ; i++ {
+				if i >= len(source) {
+					return nil, fmt.Errorf("unterminated string at position %d", start)
+				}
+				// Only the quote and the backslash are escaped, so the escapes of the
+				// regular expressions like "\d" are kept as they are.
+				if source[i] == '\\' && i+1 < len(source) && (source[i+1] == c || source[i+1] == '\\') {
+					i++
+					sb.WriteByte(source[i])
+					continue
+				}
+				if source[i] == c {
+					break
+				}
+				sb.WriteByte(source[i])
+			}
+			i++
+			tokens = append(tokens, token{kind: stringToken, text: sb.String(), pos: start})
+		case isDigit(c) || (c == '-' && i+1 < len(source) && isDigit(source[i+1])):
+			start := i
+			for i++; i < len(source) && isDigit(source[i]); i++ {
+			}
+			tokens = append(tokens, token{kind: intToken, text: source[start:i], pos: start})
+		case isIdentByte(c, true):
+			start := i
+			for i++; i < len(source) && isIdentByte(source[i], false); i++ {
+			}
+			tokens = append(tokens, token{kind: identToken, text: source[start:i], pos: start})
+		default:
+			matched := false
+			for _, symbol := range symbols {
+				if strings.HasPrefix(source[i:], symbol) {
+					tokens = append(tokens, token{kind: symbolToken, text: symbol, pos: i})
+					i += len(symbol)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unexpected character %q at position %d", c, i)
+			}
+		}
+	}
+	return append(tokens, token{kind: eofToken, pos: len(source)}), nil
+}
+
+var comparisonOperators = map[string]bool{
+	"==": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true,
+	"startsWith": true, "endsWith": true, "contains": true, "matches": true, "in": true,
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != eofToken {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes the next token if it is one of the texts.
+func (p *parser) accept(texts ...string) bool {
+	t := p.peek()
+	if t.kind != identToken && t.kind != symbolToken {
+		return false
+	}
+	for _, text := range texts {
+		if t.text == text {
+			p.pos++
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("or", "||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		if !isCondition(left) || !isCondition(right) {
+			return nil, fmt.Errorf("operands of \"or\" must be conditions")
+		}
+		left = &orNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("and", "&&") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		if !isCondition(left) || !isCondition(right) {
+			return nil, fmt.Errorf("operands of \"and\" must be conditions")
+		}
+		left = &andNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (node, error) {
+	if p.accept("not", "!") {
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		if !isCondition(operand) {
+			return nil, fmt.Errorf("operand of \"not\" must be a condition")
+		}
+		return &notNode{operand: operand}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	if (t.kind != identToken && t.kind != symbolToken) || !comparisonOperators[t.text] {
+		return left, nil
+	}
+	p.next()
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	n := &compareNode{op: t.text, left: left, right: right}
+	literal, isLiteral := right.(*literalNode)
+	switch t.text {
+	case "in":
+		if !isLiteral || literal.value.kind != listKind {
+			return nil, fmt.Errorf("right operand of \"in\" at position %d must be a list", t.pos)
+		}
+	case "matches":
+		if !isLiteral || literal.value.kind != stringKind {
+			return nil, fmt.Errorf("right operand of \"matches\" at position %d must be a string", t.pos)
+		}
+		if n.re, err = regexp.Compile(literal.value.s); err != nil {
+			return nil, err
+		}
+	case "<", "<=", ">", ">=":
+		if isLiteral && literal.value.kind != intKind {
+			return nil, fmt.Errorf("right operand of %q at position %d must be an integer", t.text, t.pos)
+		}
+	case "startsWith", "endsWith", "contains":
+		if isLiteral && literal.value.kind != stringKind {
+			return nil, fmt.Errorf("right operand of %q at position %d must be a string", t.text, t.pos)
+		}
+	}
+	if l, ok := left.(*literalNode); ok && l.value.kind == listKind {
+		return nil, fmt.Errorf("list can't be the left operand of %q at position %d", t.text, t.pos)
+	}
+	return n, nil
+}
+
+func (p *parser) parseOperand() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case stringToken:
+		return &literalNode{value: value{kind: stringKind, s: t.text}}, nil
+	case intToken:
+		i, err := strconv.ParseInt(t.text, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer %s at position %d", t, t.pos)
+		}
+		return &literalNode{value: value{kind: intKind, i: i}}, nil
+	case identToken:
+		switch {
+		case t.text == "true" || t.text == "false":
+			return &literalNode{value: boolValue(t.text == "true")}, nil
+		case t.text == "name":
+			return &fieldNode{source: nameField}, nil
+		case strings.HasPrefix(t.text, "labels.") && len(t.text) > len("labels."):
+			return &fieldNode{source: labelField, key: strings.TrimPrefix(t.text, "labels.")}, nil
+		case strings.HasPrefix(t.text, "metrics.") && len(t.text) > len("metrics."):
+			return &fieldNode{source: metricField, key: strings.TrimPrefix(t.text, "metrics.")}, nil
+		}
+		return nil, fmt.Errorf("unknown identifier %s at position %d", t, t.pos)
+	case symbolToken:
+		switch t.text {
+		case "(":
+			n, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if !p.accept(")") {
+				return nil, fmt.Errorf("expected \")\" at position %d", p.peek().pos)
+			}
+			return n, nil
+		case "[":
+			return p.parseList()
+		}
+	}
+	return nil, fmt.Errorf("unexpected %s at position %d", t, t.pos)
+}
+
+func (p *parser) parseList() (node, error) {
+	list := value{kind: listKind}
+	for !p.accept("]") {
+		if len(list.list) > 0 && !p.accept(",") {
+			return nil, fmt.Errorf("expected \",\" or \"]\" at position %d", p.peek().pos)
+		}
+		element, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		literal, ok := element.(*literalNode)
+		if !ok || literal.value.kind == listKind {
+			return nil, fmt.Errorf("elements of the list must be strings, integers or booleans")
+		}
+		list.list = append(list.list, literal.value)
+	}
+	return &literalNode{value: list}, nil
+}
@@ -0,0 +1,77 @@
+package filterprocessor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constlabels"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constnames"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constvalues"
+)
+
+func newRequestDataGroup(contentKey string, namespace string, dstIp string) *model.DataGroup {
+	labels := model.NewAttributeMap()
+	labels.AddStringValue(constlabels.ContentKey, contentKey)
+	labels.AddStringValue(constlabels.DstNamespace, namespace)
+	labels.AddStringValue(constlabels.DstIp, dstIp)
+	labels.AddIntValue(constlabels.DstPort, 8080)
+	labels.AddBoolValue(constlabels.IsSlow, false)
+	return model.NewDataGroup(constnames.SingleNetRequestMetricGroup, labels, 0,
+		model.NewIntMetric(constvalues.RequestTotalTime, 2000000))
+}
+
+func TestExpressionMatch(t *testing.T) {
+	health := newRequestDataGroup("/health", "default", "10.0.0.1")
+	system := newRequestDataGroup("/api/users/1", "kube-system", "10.0.0.2")
+	loopback := newRequestDataGroup("/api/users/2", "default", "127.0.0.1")
+
+	for source, expected := range map[string][3]bool{
+		`labels.content_key startsWith "/health" or labels.dst_namespace == "kube-system"`: {true, true, false},
+		`labels.dst_ip startsWith '127.'`:                                                  {false, false, true},
+		`name == "single_net_request_metric_group" && !(labels.dst_ip == "10.0.0.1")`:      {false, true, true},
+		`labels.dst_port == 8080 and metrics.request_total_time >= 2000000`:                {true, true, true},
+		`metrics.request_total_time < -1 or labels.dst_port > 9000`:                        {false, false, false},
+		`labels.dst_namespace in ["kube-system", "monitoring"]`:                            {false, true, false},
+		`labels.content_key matches "^/api/users/\d+$" and not labels.is_slow`:             {false, true, true},
+		`labels.content_key endsWith "1" || labels.content_key contains "ealt"`:            {true, true, false},
+		// Missing values only differ from everything, and the types are never converted.
+		`labels.unknown == "" or metrics.unknown == 0 or labels.dst_port == "8080"`: {false, false, false},
+		`labels.unknown != "x" and labels.dst_port >= 8080`:                         {true, true, true},
+		`labels.is_slow or labels.dst_ip`:                                           {false, false, false},
+		`true`:                                                                      {true, true, true},
+	} {
+		expression, err := Compile(source)
+		require.NoError(t, err, source)
+		assert.Equal(t, expected, [3]bool{expression.Match(health), expression.Match(system), expression.Match(loopback)}, source)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, source := range []string{
+		"",
+		`name`,
+		`"text"`,
+		`labels.a ==`,
+		`labels.a == "b`,
+		`labels.a == "b" and`,
+		`(labels.a == "b"`,
+		`labels.a == "b")`,
+		`labels.a in "b"`,
+		`labels.a in [labels.b]`,
+		`labels.a matches "("`,
+		`labels.a > "1"`,
+		`labels.a startsWith 1`,
+		`unknown == 1`,
+		`labels. == 1`,
+		`labels.a == 1 or "b"`,
+		`not 1`,
+		`labels.a # 1`,
+		`[1] in [1]`,
+	} {
+		_, err := Compile(source)
+		assert.Error(t, err, source)
+	}
+}
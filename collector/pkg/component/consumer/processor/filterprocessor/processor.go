@@ -0,0 +1,114 @@
+package filterprocessor
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component"
+	"github.com/Kindling-project/kindling/collector/pkg/component/consumer"
+	"github.com/Kindling-project/kindling/collector/pkg/component/consumer/processor"
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+)
+
+const Type = "filterprocessor"
+
+type rule struct {
+	name       string
+	expression *Expression
+	// matched is the attribute of the match counter.
+	matched attribute.KeyValue
+}
+
+// FilterProcessor drops the DataGroups by the rules before passing them to the next consumer.
+// In the exclude mode, the DataGroups matching any rule are dropped; in the include mode, only
+// the DataGroups matching any rule are kept. The rules are evaluated in order and only the
+// first rule matched is counted.
+type FilterProcessor struct {
+	telemetry    *component.TelemetryTools
+	nextConsumer consumer.Consumer
+
+	include bool
+	// dataGroups contains the names of the DataGroups filtered. All are filtered if it is nil.
+	dataGroups map[string]struct{}
+	rules      []*rule
+}
+
+func New(config interface{}, telemetry *component.TelemetryTools, nextConsumer consumer.Consumer) processor.Processor {
+	cfg, ok := config.(*Config)
+	if !ok {
+		telemetry.Logger.Panic("Cannot convert Component config", zap.String("componentType", Type))
+	}
+	p, err := newFilterProcessor(cfg, telemetry, nextConsumer)
+	if err != nil {
+		telemetry.Logger.Panic("Invalid configuration of filterprocessor", zap.Error(err))
+	}
+	return p
+}
+
+func newFilterProcessor(cfg *Config, telemetry *component.TelemetryTools, nextConsumer consumer.Consumer) (*FilterProcessor, error) {
+	newSelfMetrics(telemetry.MeterProvider)
+	p := &FilterProcessor{
+		telemetry:    telemetry,
+		nextConsumer: nextConsumer,
+		rules:        make([]*rule, 0, len(cfg.Rules)),
+	}
+	switch cfg.Mode {
+	case ModeInclude:
+		p.include = true
+	case ModeExclude, "":
+	default:
+		return nil, fmt.Errorf("unsupported mode %q, expected %q or %q", cfg.Mode, ModeExclude, ModeInclude)
+	}
+	if len(cfg.DataGroups) > 0 {
+		p.dataGroups = make(map[string]struct{}, len(cfg.DataGroups))
+		for _, name := range cfg.DataGroups {
+			p.dataGroups[name] = struct{}{}
+		}
+	}
+	for i, ruleConfig := range cfg.Rules {
+		name := ruleConfig.Name
+		if name == "" {
+			name = fmt.Sprintf("rule_%d", i)
+		}
+		expression, err := Compile(ruleConfig.Expression)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", name, err)
+		}
+		p.rules = append(p.rules, &rule{
+			name:       name,
+			expression: expression,
+			matched:    attribute.String("rule", name),
+		})
+	}
+	return p, nil
+}
+
+func (p *FilterProcessor) Consume(dataGroup *model.DataGroup) error {
+	if dataGroup == nil {
+		return nil
+	}
+	if p.keep(dataGroup) {
+		return p.nextConsumer.Consume(dataGroup)
+	}
+	droppedDataGroupCounter.Add(context.Background(), 1, attribute.String("data_group", dataGroup.Name))
+	return nil
+}
+
+func (p *FilterProcessor) keep(dataGroup *model.DataGroup) bool {
+	if p.dataGroups != nil {
+		if _, ok := p.dataGroups[dataGroup.Name]; !ok {
+			return true
+		}
+	}
+	for _, r := range p.rules {
+		if r.expression.Match(dataGroup) {
+			ruleMatchedCounter.Add(context.Background(), 1, r.matched)
+			return p.include
+		}
+	}
+	// Nothing is dropped if there are no rules.
+	return !p.include || len(p.rules) == 0
+}
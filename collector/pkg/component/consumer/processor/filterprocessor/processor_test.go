@@ -0,0 +1,56 @@
+package filterprocessor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component"
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constnames"
+)
+
+type recordingConsumer struct {
+	received []*model.DataGroup
+}
+
+func (c *recordingConsumer) Consume(dataGroup *model.DataGroup) error {
+	c.received = append(c.received, dataGroup)
+	return nil
+}
+
+func TestFilterProcessor(t *testing.T) {
+	health := newRequestDataGroup("/health", "default", "10.0.0.1")
+	system := newRequestDataGroup("/api/users/1", "kube-system", "10.0.0.2")
+	loopback := newRequestDataGroup("/api/users/2", "default", "127.0.0.1")
+	connect := model.NewDataGroup(constnames.TcpConnectMetricGroupName, model.NewAttributeMap(), 0)
+	rules := []RuleConfig{
+		{Name: "health", Expression: `labels.content_key startsWith "/health"`},
+		{Expression: `labels.dst_ip == "127.0.0.1"`},
+	}
+
+	for _, c := range []struct {
+		cfg      *Config
+		expected []*model.DataGroup
+	}{
+		{NewDefaultConfig(), []*model.DataGroup{health, system, loopback, connect}},
+		{&Config{Mode: ModeInclude}, []*model.DataGroup{health, system, loopback, connect}},
+		{&Config{Mode: ModeExclude, Rules: rules}, []*model.DataGroup{system, connect}},
+		{&Config{Mode: ModeInclude, Rules: rules}, []*model.DataGroup{health, loopback}},
+		// The DataGroups not listed are always kept.
+		{&Config{Mode: ModeInclude, Rules: rules, DataGroups: []string{constnames.SingleNetRequestMetricGroup}},
+			[]*model.DataGroup{health, loopback, connect}},
+	} {
+		next := &recordingConsumer{}
+		p := New(c.cfg, component.NewDefaultTelemetryTools(), next)
+		for _, dataGroup := range []*model.DataGroup{health, system, loopback, connect} {
+			assert.NoError(t, p.Consume(dataGroup))
+		}
+		assert.Equal(t, c.expected, next.received)
+	}
+
+	_, err := newFilterProcessor(&Config{Mode: "drop"}, component.NewDefaultTelemetryTools(), &recordingConsumer{})
+	assert.EqualError(t, err, `unsupported mode "drop", expected "exclude" or "include"`)
+	_, err = newFilterProcessor(&Config{Rules: []RuleConfig{{Expression: `name ==`}}}, component.NewDefaultTelemetryTools(), &recordingConsumer{})
+	assert.EqualError(t, err, `rule rule_0: invalid expression "name ==": unexpected end of expression at position 7`)
+}
@@ -0,0 +1,29 @@
+package filterprocessor
+
+import (
+	"sync"
+
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	ruleMatchedMetric      = "kindling_telemetry_filterprocessor_rule_matched_total"
+	droppedDataGroupMetric = "kindling_telemetry_filterprocessor_dropped_datagroups_total"
+)
+
+var once sync.Once
+
+var (
+	ruleMatchedCounter      metric.Int64Counter
+	droppedDataGroupCounter metric.Int64Counter
+)
+
+func newSelfMetrics(meterProvider metric.MeterProvider) {
+	once.Do(func() {
+		meter := metric.Must(meterProvider.Meter("kindling"))
+		ruleMatchedCounter = meter.NewInt64Counter(ruleMatchedMetric,
+			metric.WithDescription("The total number of DataGroups matching the rule of filterprocessor"))
+		droppedDataGroupCounter = meter.NewInt64Counter(droppedDataGroupMetric,
+			metric.WithDescription("The total number of DataGroups dropped by filterprocessor"))
+	})
+}
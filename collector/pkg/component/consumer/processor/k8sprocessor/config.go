@@ -32,3 +32,11 @@ var DefaultConfig Config = Config{
 	Enable:                 true,
 	MetaDataProviderConfig: &kubernetes.MetaDataProviderConfig{Enable: false, EnableTrace: false, Endpoint: ""},
 }
+
+// NewDefaultConfig returns a copy of DefaultConfig, which can be modified safely.
+func NewDefaultConfig() *Config {
+	cfg := DefaultConfig
+	providerCfg := *DefaultConfig.MetaDataProviderConfig
+	cfg.MetaDataProviderConfig = &providerCfg
+	return &cfg
+}
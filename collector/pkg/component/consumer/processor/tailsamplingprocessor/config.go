@@ -0,0 +1,47 @@
+package tailsamplingprocessor
+
+import "time"
+
+type Config struct {
+	// Enable controls whether the request records are sampled. All the DataGroups are
+	// passed through if it is false. The sampling_rate of aggregateprocessor should be
+	// set to 0 when it is enabled, otherwise the records are sampled twice.
+	Enable bool `mapstructure:"enable"`
+	// DecisionWait is how long the records of a trace are buffered after the first one
+	// is received, before the trace is sampled.
+	DecisionWait time.Duration `mapstructure:"decision_wait"`
+	// MaxTraces is the maximum number of traces buffered. The oldest trace is sampled
+	// at once if there are more.
+	MaxTraces int            `mapstructure:"max_traces"`
+	Policies  PoliciesConfig `mapstructure:"policies"`
+}
+
+// PoliciesConfig decides which traces are kept. A trace is kept if any of KeepErrors,
+// SlowestPerContentKey and SamplingPercentage keeps it. The traces kept because of the
+// latter two are limited by RateLimitPerWorkload.
+type PoliciesConfig struct {
+	// KeepErrors keeps all the traces containing an error record.
+	KeepErrors bool `mapstructure:"keep_errors"`
+	// SlowestPerContentKey keeps a trace if it is among the N slowest traces of its
+	// content key seen so far in the current minute. It is disabled if it is 0.
+	SlowestPerContentKey int `mapstructure:"slowest_per_content_key"`
+	// SamplingPercentage keeps the traces at random in percent, from 0 to 100.
+	SamplingPercentage float64 `mapstructure:"sampling_percentage"`
+	// RateLimitPerWorkload is the maximum number of traces kept per second for each
+	// server workload. It is unlimited if it is 0.
+	RateLimitPerWorkload int `mapstructure:"rate_limit_per_workload"`
+}
+
+func NewDefaultConfig() *Config {
+	return &Config{
+		Enable:       false,
+		DecisionWait: 5 * time.Second,
+		MaxTraces:    10000,
+		Policies: PoliciesConfig{
+			KeepErrors:           true,
+			SlowestPerContentKey: 3,
+			SamplingPercentage:   0,
+			RateLimitPerWorkload: 10,
+		},
+	}
+}
@@ -0,0 +1,291 @@
+package tailsamplingprocessor
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component"
+	"github.com/Kindling-project/kindling/collector/pkg/component/analyzer/cpuanalyzer"
+	"github.com/Kindling-project/kindling/collector/pkg/component/consumer"
+	"github.com/Kindling-project/kindling/collector/pkg/component/consumer/processor"
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constlabels"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constnames"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constvalues"
+)
+
+const Type = "tailsamplingprocessor"
+
+// trace is the request records buffered with the same trace id or connection.
+type trace struct {
+	firstSeen time.Time
+	records   []*model.DataGroup
+}
+
+// TailSamplingProcessor samples the request records per trace after a decision window.
+// All the DataGroups are passed to the next consumer as they are, so the metrics are
+// aggregated from all the requests. Besides, the records of the sampled traces are sent
+// as SingleNetRequestMetricGroup and as the signals of cpuanalyzer.
+type TailSamplingProcessor struct {
+	cfg          *Config
+	telemetry    *component.TelemetryTools
+	nextConsumer consumer.Consumer
+
+	mu     sync.Mutex
+	traces map[string]*trace
+	// queue contains the keys of traces in the order of being first seen.
+	queue []string
+
+	// slowest contains the latencies of the slowest traces per content key in the minute.
+	slowest       map[string][]int64
+	slowestMinute time.Time
+	// workloadKept is the number of traces kept per workload in the second.
+	workloadKept   map[string]int
+	workloadSecond time.Time
+
+	now    func() time.Time
+	random func() float64
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+func New(config interface{}, telemetry *component.TelemetryTools, nextConsumer consumer.Consumer) processor.Processor {
+	cfg, ok := config.(*Config)
+	if !ok {
+		telemetry.Logger.Panic("Cannot convert Component config", zap.String("componentType", Type))
+	}
+	p, err := newTailSamplingProcessor(cfg, telemetry, nextConsumer)
+	if err != nil {
+		telemetry.Logger.Panic("Invalid configuration of tailsamplingprocessor", zap.Error(err))
+	}
+	return p
+}
+
+func newTailSamplingProcessor(cfg *Config, telemetry *component.TelemetryTools, nextConsumer consumer.Consumer) (*TailSamplingProcessor, error) {
+	if cfg.DecisionWait <= 0 {
+		return nil, fmt.Errorf("decision_wait must be positive: %v", cfg.DecisionWait)
+	}
+	if cfg.MaxTraces <= 0 {
+		return nil, fmt.Errorf("max_traces must be positive: %d", cfg.MaxTraces)
+	}
+	if cfg.Policies.SamplingPercentage < 0 || cfg.Policies.SamplingPercentage > 100 {
+		return nil, fmt.Errorf("sampling_percentage must be between 0 and 100: %v", cfg.Policies.SamplingPercentage)
+	}
+	if cfg.Policies.SlowestPerContentKey < 0 || cfg.Policies.RateLimitPerWorkload < 0 {
+		return nil, fmt.Errorf("slowest_per_content_key and rate_limit_per_workload must not be negative")
+	}
+	newSelfMetrics(telemetry.MeterProvider)
+	return &TailSamplingProcessor{
+		cfg:          cfg,
+		telemetry:    telemetry,
+		nextConsumer: nextConsumer,
+		traces:       make(map[string]*trace),
+		slowest:      make(map[string][]int64),
+		workloadKept: make(map[string]int),
+		now:          time.Now,
+		random:       rand.Float64,
+		stopCh:       make(chan struct{}),
+	}, nil
+}
+
+// Start starts deciding the traces whose decision_wait has expired.
+func (p *TailSamplingProcessor) Start() error {
+	if p.cfg.Enable {
+		p.start()
+	}
+	return nil
+}
+
+func (p *TailSamplingProcessor) start() {
+	interval := time.Second
+	if p.cfg.DecisionWait < interval {
+		interval = p.cfg.DecisionWait
+	}
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.stopCh:
+				return
+			case <-ticker.C:
+				p.emit(p.decideExpired())
+			}
+		}
+	}()
+}
+
+// Shutdown stops the ticker and decides all the traces buffered.
+func (p *TailSamplingProcessor) Shutdown() error {
+	if !p.cfg.Enable {
+		return nil
+	}
+	close(p.stopCh)
+	p.wg.Wait()
+	p.mu.Lock()
+	var sampled []*model.DataGroup
+	for len(p.queue) > 0 {
+		sampled = append(sampled, p.decideOldest()...)
+	}
+	p.mu.Unlock()
+	p.emit(sampled)
+	return nil
+}
+
+func (p *TailSamplingProcessor) Consume(dataGroup *model.DataGroup) error {
+	if !p.cfg.Enable || dataGroup.Name != constnames.NetRequestMetricGroupName {
+		return p.nextConsumer.Consume(dataGroup)
+	}
+	// The record is cloned because the next consumers change it in place.
+	p.add(dataGroup.Clone())
+	return p.nextConsumer.Consume(dataGroup)
+}
+
+func (p *TailSamplingProcessor) add(dataGroup *model.DataGroup) {
+	key := traceKey(dataGroup.Labels)
+	var sampled []*model.DataGroup
+	p.mu.Lock()
+	t, ok := p.traces[key]
+	if !ok {
+		// The oldest trace is decided early to leave room for the new one.
+		if len(p.queue) >= p.cfg.MaxTraces {
+			sampled = p.decideOldest()
+		}
+		t = &trace{firstSeen: p.now()}
+		p.traces[key] = t
+		p.queue = append(p.queue, key)
+	}
+	t.records = append(t.records, dataGroup)
+	p.mu.Unlock()
+	p.emit(sampled)
+}
+
+// traceKey returns the trace id of the record, or its connection if there is no trace id.
+func traceKey(labels *model.AttributeMap) string {
+	if traceId := labels.GetStringValue(constlabels.HttpApmTraceId); traceId != "" {
+		return traceId
+	}
+	return fmt.Sprintf("%s:%d->%s:%d",
+		labels.GetStringValue(constlabels.SrcIp), labels.GetIntValue(constlabels.SrcPort),
+		labels.GetStringValue(constlabels.DstIp), labels.GetIntValue(constlabels.DstPort))
+}
+
+// decideExpired decides the traces whose decision window is over and returns the records sampled.
+func (p *TailSamplingProcessor) decideExpired() []*model.DataGroup {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var sampled []*model.DataGroup
+	deadline := p.now().Add(-p.cfg.DecisionWait)
+	for len(p.queue) > 0 && !p.traces[p.queue[0]].firstSeen.After(deadline) {
+		sampled = append(sampled, p.decideOldest()...)
+	}
+	return sampled
+}
+
+// decideOldest removes the oldest trace and returns its records if it is sampled.
+// The caller must hold the lock and make sure the queue is not empty.
+func (p *TailSamplingProcessor) decideOldest() []*model.DataGroup {
+	key := p.queue[0]
+	p.queue[0] = ""
+	p.queue = p.queue[1:]
+	t := p.traces[key]
+	delete(p.traces, key)
+	policy := p.decide(t)
+	decisionsCounter.Add(context.Background(), 1, attribute.String("policy", policy))
+	switch policy {
+	case policyError, policySlowest, policyProbabilistic:
+		return t.records
+	}
+	return nil
+}
+
+// decide returns the policy deciding the trace.
+func (p *TailSamplingProcessor) decide(t *trace) string {
+	policies := &p.cfg.Policies
+	var slowest *model.DataGroup
+	var latency int64 = -1
+	for _, record := range t.records {
+		if policies.KeepErrors && isError(record.Labels) {
+			return policyError
+		}
+		if l := totalTime(record); l > latency {
+			slowest, latency = record, l
+		}
+	}
+	now := p.now()
+	policy := policyNotSampled
+	if p.enterSlowest(now, slowest.Labels.GetStringValue(constlabels.ContentKey), latency) {
+		policy = policySlowest
+	} else if policies.SamplingPercentage > 0 && p.random()*100 < policies.SamplingPercentage {
+		policy = policyProbabilistic
+	}
+	if policy == policyNotSampled || policies.RateLimitPerWorkload == 0 {
+		return policy
+	}
+	if second := now.Truncate(time.Second); !second.Equal(p.workloadSecond) {
+		p.workloadSecond = second
+		p.workloadKept = make(map[string]int)
+	}
+	workload := slowest.Labels.GetStringValue(constlabels.DstWorkloadName)
+	if p.workloadKept[workload] >= policies.RateLimitPerWorkload {
+		return policyRateLimited
+	}
+	p.workloadKept[workload]++
+	return policy
+}
+
+// enterSlowest returns true if the latency is among the slowest ones of the content key in the minute.
+func (p *TailSamplingProcessor) enterSlowest(now time.Time, contentKey string, latency int64) bool {
+	n := p.cfg.Policies.SlowestPerContentKey
+	if n == 0 {
+		return false
+	}
+	if minute := now.Truncate(time.Minute); !minute.Equal(p.slowestMinute) {
+		p.slowestMinute = minute
+		p.slowest = make(map[string][]int64)
+	}
+	// latencies is sorted in the descending order.
+	latencies := p.slowest[contentKey]
+	i := sort.Search(len(latencies), func(i int) bool { return latencies[i] < latency })
+	if i >= n {
+		return false
+	}
+	if len(latencies) < n {
+		latencies = append(latencies, 0)
+	}
+	copy(latencies[i+1:], latencies[i:])
+	latencies[i] = latency
+	p.slowest[contentKey] = latencies
+	return true
+}
+
+func isError(labels *model.AttributeMap) bool {
+	return labels.GetBoolValue(constlabels.IsError) || labels.GetIntValue(constlabels.ErrorType) > constlabels.NoError
+}
+
+func totalTime(dataGroup *model.DataGroup) int64 {
+	if metric, ok := dataGroup.GetMetric(constvalues.RequestTotalTime); ok {
+		return metric.GetInt().Value
+	}
+	return 0
+}
+
+// emit sends the records sampled to the next consumer and cpuanalyzer.
+func (p *TailSamplingProcessor) emit(sampled []*model.DataGroup) {
+	for _, dataGroup := range sampled {
+		dataGroup.Name = constnames.SingleNetRequestMetricGroup
+		cpuanalyzer.ReceiveDataGroupAsSignal(dataGroup)
+		if err := p.nextConsumer.Consume(dataGroup); err != nil {
+			p.telemetry.Logger.Warn("Error happened when consuming sampled records", zap.Error(err))
+		}
+	}
+}
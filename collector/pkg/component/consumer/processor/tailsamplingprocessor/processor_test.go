@@ -0,0 +1,175 @@
+package tailsamplingprocessor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component"
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constlabels"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constnames"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constvalues"
+)
+
+type recordConsumer struct {
+	dataGroups []*model.DataGroup
+}
+
+func (c *recordConsumer) Consume(dataGroup *model.DataGroup) error {
+	c.dataGroups = append(c.dataGroups, dataGroup)
+	return nil
+}
+
+// sampled returns the pids of the records sampled.
+func (c *recordConsumer) sampled() []int64 {
+	var pids []int64
+	for _, dataGroup := range c.dataGroups {
+		if dataGroup.Name == constnames.SingleNetRequestMetricGroup {
+			pids = append(pids, dataGroup.Labels.GetIntValue(constlabels.Pid))
+		}
+	}
+	return pids
+}
+
+type testRecord struct {
+	pid        int64
+	traceId    string
+	srcPort    int64
+	contentKey string
+	workload   string
+	latency    int64
+	isError    bool
+}
+
+func (r testRecord) dataGroup() *model.DataGroup {
+	labels := model.NewAttributeMap()
+	labels.AddIntValue(constlabels.Pid, r.pid)
+	labels.AddStringValue(constlabels.HttpApmTraceId, r.traceId)
+	labels.AddStringValue(constlabels.SrcIp, "10.0.0.1")
+	labels.AddIntValue(constlabels.SrcPort, r.srcPort)
+	labels.AddStringValue(constlabels.DstIp, "10.0.0.2")
+	labels.AddIntValue(constlabels.DstPort, 80)
+	labels.AddStringValue(constlabels.ContentKey, r.contentKey)
+	labels.AddStringValue(constlabels.DstWorkloadName, r.workload)
+	labels.AddBoolValue(constlabels.IsError, r.isError)
+	return model.NewDataGroup(constnames.NetRequestMetricGroupName, labels, 0,
+		model.NewIntMetric(constvalues.RequestTotalTime, r.latency))
+}
+
+func newTestProcessor(t *testing.T, modify func(cfg *Config)) (*TailSamplingProcessor, *recordConsumer, *time.Time) {
+	cfg := NewDefaultConfig()
+	cfg.Enable = true
+	cfg.Policies.SlowestPerContentKey = 0
+	cfg.Policies.RateLimitPerWorkload = 0
+	if modify != nil {
+		modify(cfg)
+	}
+	next := &recordConsumer{}
+	p, err := newTailSamplingProcessor(cfg, component.NewDefaultTelemetryTools(), next)
+	require.NoError(t, err)
+	now := time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC)
+	p.now = func() time.Time { return now }
+	p.random = func() float64 { return 0.5 }
+	return p, next, &now
+}
+
+func TestDecisionWait(t *testing.T) {
+	p, next, now := newTestProcessor(t, nil)
+	// The records of the same trace id, or of the same connection, are decided together.
+	records := []testRecord{
+		{pid: 1, traceId: "a", srcPort: 1000},
+		{pid: 2, traceId: "a", srcPort: 1001, isError: true},
+		{pid: 3, srcPort: 1002},
+		{pid: 4, srcPort: 1002, isError: true},
+		{pid: 5, srcPort: 1003},
+	}
+	for _, r := range records {
+		assert.NoError(t, p.Consume(r.dataGroup()))
+	}
+	// All the records are passed through at once.
+	require.Len(t, next.dataGroups, 5)
+	assert.Equal(t, constnames.NetRequestMetricGroupName, next.dataGroups[0].Name)
+
+	*now = now.Add(4 * time.Second)
+	p.emit(p.decideExpired())
+	assert.Empty(t, next.sampled())
+	*now = now.Add(time.Second)
+	p.emit(p.decideExpired())
+	assert.Equal(t, []int64{1, 2, 3, 4}, next.sampled())
+	assert.Empty(t, p.traces)
+}
+
+func TestSlowestPerContentKey(t *testing.T) {
+	p, next, now := newTestProcessor(t, func(cfg *Config) {
+		cfg.Policies.SlowestPerContentKey = 2
+	})
+	latencies := []int64{100, 300, 200, 50, 400}
+	for i, latency := range latencies {
+		r := testRecord{pid: int64(i), srcPort: int64(i), contentKey: "/a", latency: latency}
+		assert.NoError(t, p.Consume(r.dataGroup()))
+	}
+	assert.NoError(t, p.Consume(testRecord{pid: 5, srcPort: 5, contentKey: "/b", latency: 1}.dataGroup()))
+	*now = now.Add(5 * time.Second)
+	p.emit(p.decideExpired())
+	// 200 is kept because it is among the 2 slowest ones of /a when it is decided.
+	assert.Equal(t, []int64{0, 1, 2, 4, 5}, next.sampled())
+
+	// The slowest ones are reset in the next minute.
+	assert.NoError(t, p.Consume(testRecord{pid: 6, srcPort: 6, contentKey: "/a", latency: 10}.dataGroup()))
+	*now = now.Add(time.Minute)
+	p.emit(p.decideExpired())
+	assert.Equal(t, []int64{0, 1, 2, 4, 5, 6}, next.sampled())
+}
+
+func TestProbabilisticAndRateLimit(t *testing.T) {
+	p, next, now := newTestProcessor(t, func(cfg *Config) {
+		cfg.Policies.SamplingPercentage = 60
+		cfg.Policies.RateLimitPerWorkload = 2
+	})
+	for i := int64(0); i < 4; i++ {
+		assert.NoError(t, p.Consume(testRecord{pid: i, srcPort: i, workload: "a"}.dataGroup()))
+	}
+	assert.NoError(t, p.Consume(testRecord{pid: 4, srcPort: 4, workload: "b"}.dataGroup()))
+	// The errors are not limited.
+	assert.NoError(t, p.Consume(testRecord{pid: 5, srcPort: 5, workload: "a", isError: true}.dataGroup()))
+	*now = now.Add(5 * time.Second)
+	p.emit(p.decideExpired())
+	assert.Equal(t, []int64{0, 1, 4, 5}, next.sampled())
+
+	p.random = func() float64 { return 0.7 }
+	assert.NoError(t, p.Consume(testRecord{pid: 6, srcPort: 6, workload: "a"}.dataGroup()))
+	*now = now.Add(5 * time.Second)
+	p.emit(p.decideExpired())
+	assert.Equal(t, []int64{0, 1, 4, 5}, next.sampled())
+}
+
+func TestMaxTracesAndShutdown(t *testing.T) {
+	p, next, _ := newTestProcessor(t, func(cfg *Config) {
+		cfg.MaxTraces = 2
+	})
+	for i := int64(0); i < 3; i++ {
+		assert.NoError(t, p.Consume(testRecord{pid: i, srcPort: i, isError: true}.dataGroup()))
+	}
+	// The oldest trace is decided early when there are too many traces.
+	assert.Equal(t, []int64{0}, next.sampled())
+	p.start()
+	assert.NoError(t, p.Shutdown())
+	assert.Equal(t, []int64{0, 1, 2}, next.sampled())
+}
+
+func TestDisabled(t *testing.T) {
+	p, next, _ := newTestProcessor(t, func(cfg *Config) {
+		cfg.Enable = false
+	})
+	assert.NoError(t, p.Consume(testRecord{pid: 1, isError: true}.dataGroup()))
+	assert.Len(t, next.dataGroups, 1)
+	assert.Empty(t, p.traces)
+	assert.NoError(t, p.Shutdown())
+
+	_, err := newTailSamplingProcessor(&Config{DecisionWait: time.Second, MaxTraces: 1,
+		Policies: PoliciesConfig{SamplingPercentage: 101}}, component.NewDefaultTelemetryTools(), next)
+	assert.Error(t, err)
+}
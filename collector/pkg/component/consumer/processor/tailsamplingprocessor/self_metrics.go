@@ -0,0 +1,30 @@
+package tailsamplingprocessor
+
+import (
+	"sync"
+
+	"go.opentelemetry.io/otel/metric"
+)
+
+const decisionsMetric = "kindling_telemetry_tailsamplingprocessor_decisions_total"
+
+// The policies deciding the traces, which are the values of the label "policy".
+const (
+	policyError         = "error"
+	policySlowest       = "slowest"
+	policyProbabilistic = "probabilistic"
+	policyRateLimited   = "rate_limited"
+	policyNotSampled    = "not_sampled"
+)
+
+var once sync.Once
+
+var decisionsCounter metric.Int64Counter
+
+func newSelfMetrics(meterProvider metric.MeterProvider) {
+	once.Do(func() {
+		meter := metric.Must(meterProvider.Meter("kindling"))
+		decisionsCounter = meter.NewInt64Counter(decisionsMetric,
+			metric.WithDescription("The total number of traces decided by tailsamplingprocessor"))
+	})
+}
@@ -0,0 +1,112 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ProfileAuditPath is the path of the API which serves the audit log of the profiling.
+const ProfileAuditPath = "/profile/audit"
+
+const (
+	AuditSourceManual    = "manual"
+	AuditSourceSchedule  = "schedule"
+	AuditSourceThreshold = "threshold"
+
+	AuditResultStarted  = "started"
+	AuditResultStopped  = "stopped"
+	AuditResultSkipped  = "skipped"
+	AuditResultRejected = "rejected"
+	AuditResultFailed   = "failed"
+)
+
+const defaultAuditLogSize = 1000
+
+// AuditEntry records an activation of the profiling.
+type AuditEntry struct {
+	Time time.Time `json:"time"`
+	// Source is who activated the profiling, which is "manual", "schedule" or "threshold".
+	Source string `json:"source"`
+	// Rule is the name of the window or the trigger.
+	Rule   string `json:"rule,omitempty"`
+	Result string `json:"result"`
+	// DurationSeconds is how long the profiling is planned to run. It is 0 if unlimited.
+	DurationSeconds float64 `json:"durationSeconds"`
+	Message         string  `json:"message,omitempty"`
+}
+
+// AuditLog keeps the latest entries in memory.
+type AuditLog struct {
+	lock    sync.RWMutex
+	entries []AuditEntry
+	// next is the index to write when the log is full.
+	next int
+	size int
+}
+
+func NewAuditLog(size int) *AuditLog {
+	if size <= 0 {
+		size = defaultAuditLogSize
+	}
+	return &AuditLog{
+		entries: make([]AuditEntry, 0, size),
+		size:    size,
+	}
+}
+
+func (l *AuditLog) Record(entry AuditEntry) {
+	if l == nil {
+		return
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if len(l.entries) < l.size {
+		l.entries = append(l.entries, entry)
+		return
+	}
+	l.entries[l.next] = entry
+	l.next = (l.next + 1) % l.size
+}
+
+// Query returns the entries of the source from the newest one. All the sources are
+// included if source is empty, and all the entries kept are returned if limit is not
+// positive.
+func (l *AuditLog) Query(source string, limit int) []AuditEntry {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	ret := make([]AuditEntry, 0)
+	for i := len(l.entries) - 1; i >= 0; i-- {
+		entry := l.entries[(l.next+i)%len(l.entries)]
+		if source != "" && entry.Source != source {
+			continue
+		}
+		ret = append(ret, entry)
+		if limit > 0 && len(ret) >= limit {
+			break
+		}
+	}
+	return ret
+}
+
+// Handler serves the entries in JSON with the query parameters "source" and "limit".
+func (l *AuditLog) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		var limit int
+		if value := query.Get("limit"); value != "" {
+			var err error
+			if limit, err = strconv.Atoi(value); err != nil {
+				http.Error(w, "invalid limit: "+value, http.StatusBadRequest)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(l.Query(query.Get("source"), limit))
+	})
+}
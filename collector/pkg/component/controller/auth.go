@@ -0,0 +1,155 @@
+package controller
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// ReadOperation is the operation of the GET requests, such as the flame graphs or the audit logs.
+const ReadOperation = "read"
+
+// anyMatch matches all the subjects, modules or operations in the rules.
+const anyMatch = "*"
+
+// TLSConfig serves the controller over HTTPS. The clients can authenticate themselves
+// with certificates signed by ClientCAFile, whose common names are used as the subjects.
+type TLSConfig struct {
+	CertFile     string `mapstructure:"cert_file"`
+	KeyFile      string `mapstructure:"key_file"`
+	ClientCAFile string `mapstructure:"client_ca_file"`
+}
+
+// AuthConfig enables the authentication and the authorization of the controller. All the
+// requests are allowed if it is not set.
+type AuthConfig struct {
+	Tokens []TokenConfig `mapstructure:"tokens"`
+	// Rules grant the operations to the subjects. A request is denied if no rule allows it.
+	Rules []AuthRuleConfig `mapstructure:"rules"`
+}
+
+// TokenConfig is a bearer token of the subject Name. The token is read from TokenFile if
+// Token is empty, which is the case of the Kubernetes secrets.
+type TokenConfig struct {
+	Name      string `mapstructure:"name"`
+	Token     string `mapstructure:"token"`
+	TokenFile string `mapstructure:"token_file"`
+}
+
+// AuthRuleConfig allows the subjects to execute the operations of the modules. The
+// operations are the ones of ControlRequest or "read" for the GET APIs, and "*" matches all.
+type AuthRuleConfig struct {
+	Subjects   []string `mapstructure:"subjects"`
+	Modules    []string `mapstructure:"modules"`
+	Operations []string `mapstructure:"operations"`
+}
+
+type subjectKey struct{}
+
+// subjectFromContext returns the authenticated subject of the request.
+func subjectFromContext(ctx context.Context) string {
+	subject, _ := ctx.Value(subjectKey{}).(string)
+	return subject
+}
+
+type tokenSubject struct {
+	name  string
+	token []byte
+}
+
+type httpAuth struct {
+	tokens []tokenSubject
+	rules  []AuthRuleConfig
+}
+
+func newHttpAuth(cfg *AuthConfig) (*httpAuth, error) {
+	auth := &httpAuth{rules: cfg.Rules}
+	for _, t := range cfg.Tokens {
+		token := t.Token
+		if token == "" && t.TokenFile != "" {
+			b, err := os.ReadFile(t.TokenFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read the token of %s: %w", t.Name, err)
+			}
+			token = strings.TrimSpace(string(b))
+		}
+		if t.Name == "" || token == "" {
+			return nil, errors.New("the name and the token of the subject must be set")
+		}
+		auth.tokens = append(auth.tokens, tokenSubject{name: t.Name, token: []byte(token)})
+	}
+	return auth, nil
+}
+
+// authenticate returns the subject of the bearer token, or the common name of the
+// verified client certificate. It returns an empty subject if neither is present.
+func (a *httpAuth) authenticate(r *http.Request) (string, error) {
+	if header := r.Header.Get("Authorization"); header != "" {
+		token := strings.TrimPrefix(header, "Bearer ")
+		if token == header {
+			return "", errors.New("unsupported authorization scheme")
+		}
+		for _, t := range a.tokens {
+			if subtle.ConstantTimeCompare(t.token, []byte(token)) == 1 {
+				return t.name, nil
+			}
+		}
+		return "", errors.New("invalid token")
+	}
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+		return r.TLS.VerifiedChains[0][0].Subject.CommonName, nil
+	}
+	return "", nil
+}
+
+func (a *httpAuth) authorize(subject string, module string, operation string) bool {
+	for _, rule := range a.rules {
+		if matchAny(rule.Subjects, subject) && matchAny(rule.Modules, module) && matchAny(rule.Operations, operation) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if pattern == anyMatch || pattern == value {
+			return true
+		}
+	}
+	return false
+}
+
+// moduleOfPath returns the module of the API, which is the first segment of the path.
+func moduleOfPath(path string) string {
+	path = strings.TrimPrefix(path, PathPrefix)
+	if i := strings.Index(path, "/"); i >= 0 {
+		return path[:i]
+	}
+	return path
+}
+
+func newTLSConfig(cfg *TLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.ClientCAFile == "" {
+		return tlsConfig, nil
+	}
+	ca, err := os.ReadFile(cfg.ClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the client CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.New("no certificates found in the client CA file")
+	}
+	tlsConfig.ClientCAs = pool
+	// The clients can still use the bearer tokens without certificates.
+	tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	return tlsConfig, nil
+}
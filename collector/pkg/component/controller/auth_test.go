@@ -0,0 +1,74 @@
+package controller
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component"
+)
+
+func TestHttpAPIAuth(t *testing.T) {
+	api := NewHttpAPI(component.NewDefaultTelemetryTools())
+	api.RegistController(NewProfileController(component.NewDefaultTelemetryTools()))
+	err := api.EnableAuth(&AuthConfig{
+		Tokens: []TokenConfig{{Name: "ops", Token: "ops-token"}, {Name: "viewer", Token: "viewer-token"}},
+		Rules: []AuthRuleConfig{
+			{Subjects: []string{"ops"}, Modules: []string{"*"}, Operations: []string{"*"}},
+			{Subjects: []string{"*"}, Modules: []string{ProfileModule}, Operations: []string{"status", ReadOperation}},
+		},
+	})
+	assert.NoError(t, err)
+
+	request := func(token string, operation string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/profile", strings.NewReader(`{"Operation": "`+operation+`"}`))
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		api.ServeHTTP(w, r)
+		return w
+	}
+	assert.Equal(t, http.StatusUnauthorized, request("", "status").Code)
+	assert.Equal(t, http.StatusUnauthorized, request("wrong", "status").Code)
+	assert.Equal(t, http.StatusOK, request("viewer-token", "status").Code)
+	assert.Equal(t, http.StatusForbidden, request("viewer-token", "start_attach_agent").Code)
+	w := request("ops-token", "status")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp ControlResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "stopped", resp.Msg)
+
+	// The audit trail is a "read" of the module "controller", which only ops can do.
+	r := httptest.NewRequest(http.MethodGet, ControlAuditPath+"?subject=viewer", nil)
+	r.Header.Set("Authorization", "Bearer viewer-token")
+	w = httptest.NewRecorder()
+	api.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	entries := api.requests.Query("viewer", 0)
+	assert.Len(t, entries, 3)
+	assert.False(t, entries[1].Allowed)
+	assert.Equal(t, "start_attach_agent", entries[1].Operation)
+	assert.True(t, entries[2].Allowed)
+	assert.Equal(t, NoError, entries[2].Code)
+	assert.Len(t, api.requests.Query("", 0), 6)
+
+	// The common name of the verified client certificate is the subject.
+	r = httptest.NewRequest(http.MethodGet, ControlAuditPath, nil)
+	r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: "ops"}}}}}
+	w = httptest.NewRecorder()
+	api.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &entries))
+	assert.Len(t, entries, 6)
+
+	_, err = newHttpAuth(&AuthConfig{Tokens: []TokenConfig{{Name: "empty"}}})
+	assert.Error(t, err)
+}
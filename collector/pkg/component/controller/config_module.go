@@ -0,0 +1,116 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component"
+)
+
+// NetworkModule reconfigures the network analyzer at runtime.
+const NetworkModule = "network"
+
+// GetConfigOperation returns the configuration in effect of the module.
+const GetConfigOperation = "get_config"
+
+// Reconfigurable is implemented by the components which can be reconfigured at runtime.
+type Reconfigurable interface {
+	// EffectiveConfig returns the configuration in effect, which is encoded in JSON.
+	EffectiveConfig() interface{}
+	// Reconfigure applies the operation atomically. Nothing is changed if an error is returned.
+	Reconfigure(operation string, options json.RawMessage) error
+}
+
+// ConfigController is a module which passes the operations to the Reconfigurable
+// component. It also serves the configuration in effect at "/<module>/config".
+type ConfigController struct {
+	name  string
+	tools *component.TelemetryTools
+
+	lock   sync.RWMutex
+	target Reconfigurable
+}
+
+func NewConfigController(name string, tools *component.TelemetryTools) *ConfigController {
+	return &ConfigController{name: name, tools: tools}
+}
+
+func (c *ConfigController) GetModuleKey() string {
+	return c.name
+}
+
+// RegistSubModules does nothing because the module has no state to start or stop.
+func (c *ConfigController) RegistSubModules(...ExportSubModule) {}
+
+func (c *ConfigController) GetOptions(*json.RawMessage) []Option {
+	return nil
+}
+
+// RegistReconfigurable sets the component to reconfigure.
+func (c *ConfigController) RegistReconfigurable(target Reconfigurable) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.target = target
+}
+
+func (c *ConfigController) getTarget() Reconfigurable {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.target
+}
+
+func (c *ConfigController) HandRequest(req *ControlRequest) *ControlResponse {
+	target := c.getTarget()
+	if target == nil {
+		return &ControlResponse{
+			Code: NoOperation,
+			Msg:  fmt.Sprintf("module %s is not available", c.name),
+		}
+	}
+	if req.Operation == GetConfigOperation {
+		return &ControlResponse{
+			Code:   NoError,
+			Msg:    "success",
+			Config: target.EffectiveConfig(),
+		}
+	}
+	var options json.RawMessage
+	if req.Options != nil {
+		options = *req.Options
+	}
+	if err := target.Reconfigure(req.Operation, options); err != nil {
+		return &ControlResponse{
+			Code: ReconfigureWithError,
+			Msg:  err.Error(),
+		}
+	}
+	c.tools.Logger.Info("module is reconfigured", zap.String("module", c.name),
+		zap.String("operation", req.Operation))
+	return &ControlResponse{
+		Code:   NoError,
+		Msg:    "reconfigure success",
+		Config: target.EffectiveConfig(),
+	}
+}
+
+// ConfigPath is the path of the API which serves the configuration in effect of the module.
+func ConfigPath(module string) string {
+	return fmt.Sprintf("/%s/config", module)
+}
+
+// ConfigHandler serves the configuration in effect in JSON.
+func (c *ConfigController) ConfigHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		target := c.getTarget()
+		if target == nil {
+			http.Error(w, fmt.Sprintf("module %s is not available", c.name), http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(target.EffectiveConfig())
+	})
+}
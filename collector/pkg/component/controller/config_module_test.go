@@ -0,0 +1,60 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component"
+)
+
+type fakeReconfigurable struct {
+	threshold int
+}
+
+func (f *fakeReconfigurable) EffectiveConfig() interface{} {
+	return map[string]int{"threshold": f.threshold}
+}
+
+func (f *fakeReconfigurable) Reconfigure(operation string, options json.RawMessage) error {
+	if operation != "set_threshold" {
+		return errors.New("unexpected operation")
+	}
+	var v struct{ Threshold int }
+	if err := json.Unmarshal(options, &v); err != nil {
+		return err
+	}
+	f.threshold = v.Threshold
+	return nil
+}
+
+func TestConfigController(t *testing.T) {
+	api := NewHttpAPI(component.NewDefaultTelemetryTools())
+	c := NewConfigController(NetworkModule, component.NewDefaultTelemetryTools())
+	api.RegistController(c)
+	api.RegistHandler(ConfigPath(NetworkModule), c.ConfigHandler())
+
+	resp := c.HandRequest(&ControlRequest{Operation: GetConfigOperation})
+	assert.Equal(t, NoOperation, resp.Code)
+
+	api.RegistReconfigurable(NetworkModule, &fakeReconfigurable{threshold: 500})
+	r := httptest.NewRequest(http.MethodPost, "/network",
+		strings.NewReader(`{"Operation": "set_threshold", "Options": {"Threshold": 100}}`))
+	w := httptest.NewRecorder()
+	api.ServeHTTP(w, r)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+	assert.Equal(t, NoError, resp.Code)
+	assert.Equal(t, map[string]interface{}{"threshold": float64(100)}, resp.Config)
+
+	resp = c.HandRequest(&ControlRequest{Operation: "restart"})
+	assert.Equal(t, ReconfigureWithError, resp.Code)
+
+	w = httptest.NewRecorder()
+	api.ServeHTTP(w, httptest.NewRequest(http.MethodGet, ConfigPath(NetworkModule), nil))
+	assert.JSONEq(t, `{"threshold": 100}`, w.Body.String())
+}
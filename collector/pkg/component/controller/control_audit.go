@@ -0,0 +1,97 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ControlAuditPath is the path of the API which serves the audit trail of the control requests.
+const ControlAuditPath = "/controller/audit"
+
+// ControlAuditEntry records a control request, or a request denied by the authentication
+// or the authorization.
+type ControlAuditEntry struct {
+	Time       time.Time `json:"time"`
+	Subject    string    `json:"subject,omitempty"`
+	RemoteAddr string    `json:"remoteAddr"`
+	Path       string    `json:"path"`
+	Operation  string    `json:"operation,omitempty"`
+	Pid        int       `json:"pid,omitempty"`
+	Allowed    bool      `json:"allowed"`
+	// Code is the code of ControlResponse if the request is allowed, or the HTTP status otherwise.
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// ControlAuditLog keeps the latest entries in memory.
+type ControlAuditLog struct {
+	lock    sync.RWMutex
+	entries []ControlAuditEntry
+	// next is the index to write when the log is full.
+	next int
+	size int
+}
+
+func NewControlAuditLog(size int) *ControlAuditLog {
+	if size <= 0 {
+		size = defaultAuditLogSize
+	}
+	return &ControlAuditLog{
+		entries: make([]ControlAuditEntry, 0, size),
+		size:    size,
+	}
+}
+
+func (l *ControlAuditLog) Record(entry ControlAuditEntry) {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if len(l.entries) < l.size {
+		l.entries = append(l.entries, entry)
+		return
+	}
+	l.entries[l.next] = entry
+	l.next = (l.next + 1) % l.size
+}
+
+// Query returns the entries of the subject from the newest one. All the subjects are
+// included if subject is empty, and all the entries kept are returned if limit is not
+// positive.
+func (l *ControlAuditLog) Query(subject string, limit int) []ControlAuditEntry {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	ret := make([]ControlAuditEntry, 0)
+	for i := len(l.entries) - 1; i >= 0; i-- {
+		entry := l.entries[(l.next+i)%len(l.entries)]
+		if subject != "" && entry.Subject != subject {
+			continue
+		}
+		ret = append(ret, entry)
+		if limit > 0 && len(ret) >= limit {
+			break
+		}
+	}
+	return ret
+}
+
+// Handler serves the entries in JSON with the query parameters "subject" and "limit".
+func (l *ControlAuditLog) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		var limit int
+		if value := query.Get("limit"); value != "" {
+			var err error
+			if limit, err = strconv.Atoi(value); err != nil {
+				http.Error(w, "invalid limit: "+value, http.StatusBadRequest)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(l.Query(query.Get("subject"), limit))
+	})
+}
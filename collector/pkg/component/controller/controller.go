@@ -13,6 +13,12 @@ import (
 type ControllerAPI interface {
 	RegistController(c Controller)
 	RegistModule(module string, subModules ...ExportSubModule)
+	// RegistHandler serves the data of the components, such as the flame graphs.
+	RegistHandler(path string, handler http.Handler)
+	// RegistSessionModule registers the sub-modules which support the scoped sessions.
+	RegistSessionModule(module string, sessionModules ...SessionModule)
+	// RegistReconfigurable registers the component reconfigured by the module.
+	RegistReconfigurable(module string, target Reconfigurable)
 }
 
 type Controller interface {
@@ -38,15 +44,24 @@ type ControlRequest struct {
 type ControlResponse struct {
 	Code int
 	Msg  string
+
+	// Session is the id of the profiling session started or stopped.
+	Session  string               `json:",omitempty"`
+	Sessions []ProfileSessionInfo `json:",omitempty"`
+	// Config is the configuration in effect of the reconfigurable modules.
+	Config interface{} `json:",omitempty"`
 }
 
 type ControllerFactory struct {
 	Controller ControllerAPI
+	// Scheduler is nil if the profile module or the scheduler is not enabled.
+	Scheduler *ProfileScheduler
 }
 
 type ControllerConfig struct {
-	Http    *HttpControllerConfig
-	Modules []string
+	Http      *HttpControllerConfig
+	Modules   []string
+	Scheduler *SchedulerConfig
 }
 
 func (cf *ControllerFactory) ConstructConfig(viper *viper.Viper, tools *component.TelemetryTools) error {
@@ -60,19 +75,93 @@ func (cf *ControllerFactory) ConstructConfig(viper *viper.Viper, tools *componen
 	}
 	if controllerConfig.Http != nil {
 		httpAPI := NewHttpAPI(tools)
+		if controllerConfig.Http.AuditLogSize > 0 {
+			httpAPI.requests = NewControlAuditLog(controllerConfig.Http.AuditLogSize)
+		}
+		if controllerConfig.Http.Auth != nil {
+			if err := httpAPI.EnableAuth(controllerConfig.Http.Auth); err != nil {
+				tools.Logger.Errorf("Error happened when enabling the authentication of controller, will disable all controller: %v", err)
+				return nil
+			}
+		}
 		for _, module := range controllerConfig.Modules {
 			switch module {
 			case ProfileModule:
 				profileController := NewProfileController(tools)
 				httpAPI.RegistController(profileController)
+				auditLogSize := 0
+				if controllerConfig.Scheduler != nil {
+					auditLogSize = controllerConfig.Scheduler.AuditLogSize
+				}
+				profileController.audit = NewAuditLog(auditLogSize)
+				httpAPI.RegistHandler(ProfileAuditPath, profileController.audit.Handler())
+				if controllerConfig.Scheduler == nil {
+					continue
+				}
+				scheduler, err := NewProfileScheduler(profileController, controllerConfig.Scheduler, profileController.audit, tools)
+				if err != nil {
+					tools.Logger.Errorf("Error happened when creating the profile scheduler, will disable it: %v", err)
+					continue
+				}
+				cf.Scheduler = scheduler
+			case NetworkModule:
+				configController := NewConfigController(module, tools)
+				httpAPI.RegistController(configController)
+				httpAPI.RegistHandler(ConfigPath(module), configController.ConfigHandler())
 			}
 		}
-		go http.ListenAndServe(controllerConfig.Http.Port, httpAPI)
+		if err := serveHttpAPI(controllerConfig.Http, httpAPI, tools); err != nil {
+			tools.Logger.Errorf("Error happened when serving controller, will disable all controller: %v", err)
+			cf.Scheduler = nil
+			return nil
+		}
 		cf.Controller = httpAPI
 	}
 	return nil
 }
 
 func (cf *ControllerFactory) RegistModule(module string, subModules ...ExportSubModule) {
+	if cf.Controller == nil {
+		return
+	}
 	cf.Controller.RegistModule(module, subModules...)
 }
+
+// StartScheduler starts the profile scheduler if it is enabled. It should be called
+// after the sub-modules are registered.
+func (cf *ControllerFactory) StartScheduler() {
+	if cf.Scheduler == nil {
+		return
+	}
+	cf.Scheduler.Start()
+}
+
+// ShutdownScheduler stops the profile scheduler so no profiling is triggered when the
+// pipelines are shut down.
+func (cf *ControllerFactory) ShutdownScheduler() {
+	if cf.Scheduler == nil {
+		return
+	}
+	cf.Scheduler.Shutdown()
+}
+
+func (cf *ControllerFactory) RegistHandler(path string, handler http.Handler) {
+	if cf.Controller == nil {
+		return
+	}
+	cf.Controller.RegistHandler(path, handler)
+}
+
+func (cf *ControllerFactory) RegistReconfigurable(module string, target Reconfigurable) {
+	if cf.Controller == nil {
+		return
+	}
+	cf.Controller.RegistReconfigurable(module, target)
+}
+
+func (cf *ControllerFactory) RegistSessionModule(module string, sessionModules ...SessionModule) {
+	if cf.Controller == nil {
+		return
+	}
+	cf.Controller.RegistSessionModule(module, sessionModules...)
+}
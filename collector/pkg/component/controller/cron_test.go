@@ -0,0 +1,39 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCron(t *testing.T) {
+	// Every 15 minutes during the working hours on weekdays.
+	schedule, err := parseCron("*/15 9-17 * * 1-5")
+	assert.NoError(t, err)
+	// 2022-08-01 is Monday.
+	assert.True(t, schedule.matches(time.Date(2022, 8, 1, 9, 30, 0, 0, time.Local)))
+	assert.False(t, schedule.matches(time.Date(2022, 8, 1, 9, 31, 0, 0, time.Local)))
+	assert.False(t, schedule.matches(time.Date(2022, 8, 1, 18, 0, 0, 0, time.Local)))
+	assert.False(t, schedule.matches(time.Date(2022, 8, 6, 9, 30, 0, 0, time.Local)))
+
+	// Either the first day of the month or Sunday, as both day fields are restricted.
+	schedule, err = parseCron("0 3 1 * 7")
+	assert.NoError(t, err)
+	assert.True(t, schedule.matches(time.Date(2022, 8, 1, 3, 0, 0, 0, time.Local)))
+	assert.True(t, schedule.matches(time.Date(2022, 8, 7, 3, 0, 0, 0, time.Local)))
+	assert.False(t, schedule.matches(time.Date(2022, 8, 8, 3, 0, 0, 0, time.Local)))
+
+	schedule, err = parseCron("5/20,1 0 * 1,6 *")
+	assert.NoError(t, err)
+	for _, minute := range []int{1, 5, 25, 45} {
+		assert.True(t, schedule.matches(time.Date(2022, 6, 3, 0, minute, 0, 0, time.Local)))
+	}
+	assert.False(t, schedule.matches(time.Date(2022, 6, 3, 0, 6, 0, 0, time.Local)))
+	assert.False(t, schedule.matches(time.Date(2022, 7, 3, 0, 5, 0, 0, time.Local)))
+
+	for _, expr := range []string{"* * * *", "60 * * * *", "*/0 * * * *", "5-1 * * * *", "* * 0 * *", "a * * * *"} {
+		_, err = parseCron(expr)
+		assert.Error(t, err, expr)
+	}
+}
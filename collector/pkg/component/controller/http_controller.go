@@ -1,7 +1,10 @@
 package controller
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -17,32 +20,122 @@ type HttpAPI struct {
 
 	controllerMap map[string]Controller
 	tools         *component.TelemetryTools
+
+	// auth is nil if the authentication is not enabled, in which case all the requests
+	// are allowed.
+	auth     *httpAuth
+	requests *ControlAuditLog
 }
 
 type HttpControllerConfig struct {
 	Enable bool
 	Port   string
+	// TLS serves the controller over HTTPS if it is set.
+	TLS  *TLSConfig  `mapstructure:"tls"`
+	Auth *AuthConfig `mapstructure:"auth"`
+	// AuditLogSize is how many control requests are kept in the audit trail.
+	AuditLogSize int `mapstructure:"audit_log_size"`
 }
 
 func NewHttpAPI(tools *component.TelemetryTools) *HttpAPI {
-	return &HttpAPI{
+	hc := &HttpAPI{
 		controllerMap: make(map[string]Controller),
 		ServeMux:      http.NewServeMux(),
 		tools:         tools,
+		requests:      NewControlAuditLog(0),
+	}
+	// The audit log may be replaced with another size after the API is created.
+	hc.RegistHandler(ControlAuditPath, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hc.requests.Handler().ServeHTTP(w, r)
+	}))
+	return hc
+}
+
+// EnableAuth requires the requests to be authenticated by the bearer tokens or the client
+// certificates, and to be allowed by the rules.
+func (hc *HttpAPI) EnableAuth(cfg *AuthConfig) error {
+	auth, err := newHttpAuth(cfg)
+	if err != nil {
+		return err
 	}
+	hc.auth = auth
+	return nil
 }
 
 func (hc *HttpAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if hc.auth != nil {
+		subject, err := hc.auth.authenticate(r)
+		if err != nil || subject == "" {
+			msg := "unauthorized"
+			if err != nil {
+				msg = err.Error()
+			}
+			hc.requests.Record(ControlAuditEntry{RemoteAddr: r.RemoteAddr, Path: r.URL.Path,
+				Code: http.StatusUnauthorized, Message: msg})
+			w.Header().Set("WWW-Authenticate", `Bearer realm="kindling"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		r = r.WithContext(context.WithValue(r.Context(), subjectKey{}, subject))
+	}
 	h, _ := hc.Handler(r)
 	h.ServeHTTP(w, r)
 }
 
+// allowed checks whether the subject of the request can execute the operation of the
+// module. The denied requests are recorded in the audit trail.
+func (hc *HttpAPI) allowed(w http.ResponseWriter, r *http.Request, module string, req *ControlRequest) bool {
+	if hc.auth == nil {
+		return true
+	}
+	subject := subjectFromContext(r.Context())
+	if hc.auth.authorize(subject, module, req.Operation) {
+		return true
+	}
+	hc.requests.Record(ControlAuditEntry{Subject: subject, RemoteAddr: r.RemoteAddr, Path: r.URL.Path,
+		Operation: req.Operation, Pid: req.Pid, Code: http.StatusForbidden, Message: "forbidden"})
+	hc.tools.Logger.Warn("control request is forbidden", zap.String("subject", subject),
+		zap.String("module", module), zap.String("operation", req.Operation))
+	http.Error(w, "forbidden", http.StatusForbidden)
+	return false
+}
+
 func (hc *HttpAPI) RegistModule(module string, subModules ...ExportSubModule) {
 	if c, ok := hc.controllerMap[module]; ok {
 		c.RegistSubModules(subModules...)
 	}
 }
 
+func (hc *HttpAPI) RegistSessionModule(module string, sessionModules ...SessionModule) {
+	c, ok := hc.controllerMap[module]
+	if !ok {
+		return
+	}
+	if sc, ok := c.(interface {
+		RegistSessionModules(sessionModules ...SessionModule)
+	}); ok {
+		sc.RegistSessionModules(sessionModules...)
+	}
+}
+
+func (hc *HttpAPI) RegistReconfigurable(module string, target Reconfigurable) {
+	if c, ok := hc.controllerMap[module].(*ConfigController); ok {
+		c.RegistReconfigurable(target)
+	}
+}
+
+// RegistHandler serves the handler as the "read" operation of the module, which is the
+// first segment of the path.
+func (hc *HttpAPI) RegistHandler(path string, handler http.Handler) {
+	module := moduleOfPath(path)
+	hc.Handle(path, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !hc.allowed(w, r, module, &ControlRequest{Operation: ReadOperation}) {
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+}
+
 func (hc *HttpAPI) RegistController(c Controller) {
 	hc.controllerMap[c.GetModuleKey()] = c
 	hc.HandleFunc(fmt.Sprintf("/%s", c.GetModuleKey()), func(w http.ResponseWriter, r *http.Request) {
@@ -60,7 +153,12 @@ func (hc *HttpAPI) RegistController(c Controller) {
 			hc.tools.Logger.Error("parse request failed", zap.Error(err))
 			return
 		}
+		if !hc.allowed(w, r, c.GetModuleKey(), req) {
+			return
+		}
 		resp := c.HandRequest(req)
+		hc.requests.Record(ControlAuditEntry{Subject: subjectFromContext(r.Context()), RemoteAddr: r.RemoteAddr,
+			Path: r.URL.Path, Operation: req.Operation, Pid: req.Pid, Allowed: true, Code: resp.Code, Message: resp.Msg})
 
 		msg, err := json.Marshal(resp)
 		if err != nil {
@@ -83,3 +181,35 @@ func parseRequest(body []byte) (*ControlRequest, error) {
 	}
 	return &req, nil
 }
+
+// serveHttpAPI serves the controller over HTTPS if TLS is configured, or HTTP otherwise.
+func serveHttpAPI(cfg *HttpControllerConfig, api *HttpAPI, tools *component.TelemetryTools) error {
+	srv := &http.Server{Addr: cfg.Port, Handler: api}
+	if cfg.TLS != nil {
+		tlsConfig, err := newTLSConfig(cfg.TLS)
+		if err != nil {
+			return err
+		}
+		srv.TLSConfig = tlsConfig
+		// The certificates are loaded before serving so that the errors can be returned.
+		cert, err := tls.LoadX509KeyPair(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		if err != nil {
+			return fmt.Errorf("failed to load the certificate of controller: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	} else if cfg.Auth != nil && len(cfg.Auth.Tokens) > 0 {
+		tools.Logger.Warn("The bearer tokens of controller are sent in plain text because TLS is not enabled")
+	}
+	go func() {
+		var err error
+		if srv.TLSConfig != nil {
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			tools.Logger.Error("controller http server stopped", zap.Error(err))
+		}
+	}()
+	return nil
+}
@@ -24,12 +24,18 @@ const (
 	StartWithError = 2
 	StopWithError  = 3
 	NoOperation    = 4
+	// ReconfigureWithError means the module is not changed because of the error.
+	ReconfigureWithError = 5
 )
 
 const ProfileModule = "profile"
 
 type Profile struct {
 	Module
+	// audit records the manual activations.
+	audit    *AuditLog
+	sessions *profileSessions
+	tools    *component.TelemetryTools
 }
 
 type ProfileOption struct {
@@ -38,6 +44,14 @@ type ProfileOption struct {
 
 	// bytes
 	FileWatch *FileWatchOption
+
+	// Selector limits the profiling to the selected processes, e.g. {"pids": [1234]},
+	// {"containerIds": ["..."]}, {"pods": [{"namespace": "default", "name": "..."}]} or
+	// {"namespace": "default", "labelSelector": "app=business"}. A session is started if
+	// either Selector or Session is set, and several sessions can run at the same time.
+	Selector *json.RawMessage
+	// Session is the id of the session to start or stop. It is generated if empty.
+	Session string
 }
 
 type FileWatchOption struct {
@@ -49,9 +63,13 @@ type ExportSubModule func() (name string, start func() error, stop func() error)
 
 func NewProfileController(tools *component.TelemetryTools) *Profile {
 	profile := NewModule(ProfileModule, tools, Stopped)
-	return &Profile{
-		Module: profile,
+	p := &Profile{
+		Module:   profile,
+		sessions: newProfileSessions(),
+		tools:    tools,
 	}
+	profile.RegisterSubModule("sessions", func() error { return nil }, p.sessions.clear)
+	return p
 }
 
 func (p *Profile) RegistSubModules(subModules ...ExportSubModule) {
@@ -64,12 +82,22 @@ func (o *ProfileOption) UnmarshalJSON(b []byte) error {
 	var v struct {
 		FileWatch *FileWatchOption
 		Duration  interface{}
+		Selector  *json.RawMessage
+		Session   string
 	}
 	if err := json.Unmarshal(b, &v); err != nil {
 		return err
 	}
 	o.FileWatch = v.FileWatch
+	o.Selector = v.Selector
+	o.Session = v.Session
 	switch value := v.Duration.(type) {
+	case nil:
+		// The sessions can run without a duration.
+		if o.Selector != nil || o.Session != "" {
+			return nil
+		}
+		return errors.New("invalid duration")
 	case float64:
 		o.Duration = time.Duration(value)
 		return nil
@@ -122,24 +150,54 @@ func stopDebug() error {
 func (p *Profile) HandRequest(req *ControlRequest) *ControlResponse {
 	switch req.Operation {
 	case "start":
+		var pOption ProfileOption
+		if req.Options != nil {
+			_ = json.Unmarshal(*req.Options, &pOption)
+		}
+		if pOption.Selector != nil || pOption.Session != "" {
+			return p.handleStartSession(&pOption)
+		}
 		opts := p.GetOptions(req.Options)
+		entry := AuditEntry{Source: AuditSourceManual, Result: AuditResultStarted, DurationSeconds: pOption.Duration.Seconds()}
 		if err := p.Start(opts...); err != nil {
+			entry.Result = AuditResultFailed
+			entry.Message = err.Error()
+			p.audit.Record(entry)
 			return &ControlResponse{
 				Code: StartWithError,
 				Msg:  err.Error(),
 			}
 		}
+		p.audit.Record(entry)
 		return &ControlResponse{
 			Code: NoError,
 			Msg:  "start success",
 		}
 	case "stop":
+		if req.Options != nil {
+			var pOption ProfileOption
+			if _ = json.Unmarshal(*req.Options, &pOption); pOption.Session != "" {
+				if err := p.stopSession(pOption.Session, "profiling session stop by manual"); err != nil {
+					return &ControlResponse{
+						Code:    StopWithError,
+						Msg:     err.Error(),
+						Session: pOption.Session,
+					}
+				}
+				return &ControlResponse{
+					Code:    NoError,
+					Msg:     "stop success",
+					Session: pOption.Session,
+				}
+			}
+		}
 		if err := p.Stop("module stop by manual"); err != nil {
 			return &ControlResponse{
 				Code: StopWithError,
 				Msg:  err.Error(),
 			}
 		}
+		p.audit.Record(AuditEntry{Source: AuditSourceManual, Result: AuditResultStopped})
 		return &ControlResponse{
 			Code: NoError,
 			Msg:  "stop success",
@@ -175,8 +233,15 @@ func (p *Profile) HandRequest(req *ControlRequest) *ControlResponse {
 			status = "stopped"
 		}
 		return &ControlResponse{
-			Code: NoError,
-			Msg:  status,
+			Code:     NoError,
+			Msg:      status,
+			Sessions: p.sessions.list(),
+		}
+	case "sessions":
+		return &ControlResponse{
+			Code:     NoError,
+			Msg:      "success",
+			Sessions: p.sessions.list(),
 		}
 	case "start_debug":
 		if err := startDebug(req.Pid, req.Tid); err != nil {
@@ -204,6 +269,36 @@ func (p *Profile) HandRequest(req *ControlRequest) *ControlResponse {
 	}
 }
 
+func (p *Profile) handleStartSession(option *ProfileOption) *ControlResponse {
+	entry := AuditEntry{
+		Source:          AuditSourceManual,
+		Rule:            option.Session,
+		Result:          AuditResultStarted,
+		DurationSeconds: option.Duration.Seconds(),
+	}
+	if option.Selector != nil {
+		entry.Message = "selector: " + string(*option.Selector)
+	}
+	info, err := p.startSession(option)
+	if err != nil {
+		entry.Result = AuditResultFailed
+		entry.Message = joinMessage(entry.Message, err.Error())
+		p.audit.Record(entry)
+		return &ControlResponse{
+			Code:    StartWithError,
+			Msg:     err.Error(),
+			Session: option.Session,
+		}
+	}
+	entry.Rule = info.ID
+	p.audit.Record(entry)
+	return &ControlResponse{
+		Code:    NoError,
+		Msg:     "start success",
+		Session: info.ID,
+	}
+}
+
 func (p *Profile) GetOptions(raw_opts *json.RawMessage) []Option {
 	if raw_opts == nil {
 		return nil
@@ -0,0 +1,191 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/multierr"
+)
+
+// SessionModule is implemented by the sub-modules which can limit the profiling to the
+// processes selected by the sessions.
+type SessionModule interface {
+	// StartSession adds the processes selected by selector to the profiling. All the
+	// processes are selected if selector is empty.
+	StartSession(id string, selector json.RawMessage) error
+	StopSession(id string) error
+}
+
+// globalSessionID is the session which keeps profiling all the processes when a scoped
+// session is started while the profiling has been started without sessions.
+const globalSessionID = "global"
+
+// ProfileSessionInfo describes a running profiling session.
+type ProfileSessionInfo struct {
+	ID        string           `json:"id"`
+	Selector  *json.RawMessage `json:"selector,omitempty"`
+	StartTime time.Time        `json:"startTime"`
+	// DurationSeconds is how long the session is planned to run. It is 0 if unlimited.
+	DurationSeconds float64 `json:"durationSeconds"`
+}
+
+type profileSession struct {
+	ProfileSessionInfo
+	timer *time.Timer
+}
+
+// profileSessions keeps the running sessions of the profile module. The operations are
+// serialized by opLock, which is always acquired before the lock of the module, while
+// lock only guards the map and is acquired after the lock of the module when the module
+// is stopping.
+type profileSessions struct {
+	opLock   sync.Mutex
+	lock     sync.Mutex
+	sessions map[string]*profileSession
+	modules  []SessionModule
+	seq      int
+}
+
+func newProfileSessions() *profileSessions {
+	return &profileSessions{sessions: make(map[string]*profileSession)}
+}
+
+func (p *Profile) RegistSessionModules(sessionModules ...SessionModule) {
+	p.sessions.modules = append(p.sessions.modules, sessionModules...)
+}
+
+// clear removes all the sessions when the module is stopped. The sub-modules reset
+// their scopes by themselves.
+func (s *profileSessions) clear() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, session := range s.sessions {
+		if session.timer != nil {
+			session.timer.Stop()
+		}
+	}
+	s.sessions = make(map[string]*profileSession)
+	return nil
+}
+
+func (s *profileSessions) count() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return len(s.sessions)
+}
+
+func (s *profileSessions) list() []ProfileSessionInfo {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	ret := make([]ProfileSessionInfo, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		ret = append(ret, session.ProfileSessionInfo)
+	}
+	return ret
+}
+
+// startSession starts the profile module if it is stopped and adds the session to the
+// sub-modules. The session is stopped after the duration if it is positive.
+func (p *Profile) startSession(option *ProfileOption) (*ProfileSessionInfo, error) {
+	s := p.sessions
+	s.opLock.Lock()
+	defer s.opLock.Unlock()
+	id := option.Session
+	if id == "" {
+		s.seq++
+		id = fmt.Sprintf("session-%d", s.seq)
+	}
+	s.lock.Lock()
+	_, exist := s.sessions[id]
+	s.lock.Unlock()
+	if exist {
+		return nil, fmt.Errorf("profiling session %s already exists", id)
+	}
+
+	if p.Status() == Stopped {
+		if err := p.Start(); err != nil {
+			return nil, err
+		}
+	} else if s.count() == 0 {
+		// The profiling was started without sessions, so it still profiles all the
+		// processes until it is stopped.
+		if err := p.addSession(ProfileSessionInfo{ID: globalSessionID, StartTime: time.Now()}, 0); err != nil {
+			return nil, err
+		}
+	}
+	info := ProfileSessionInfo{
+		ID:              id,
+		Selector:        option.Selector,
+		StartTime:       time.Now(),
+		DurationSeconds: option.Duration.Seconds(),
+	}
+	if err := p.addSession(info, option.Duration); err != nil {
+		if s.count() == 0 {
+			_ = p.Stop("module stop because the profiling session failed to start")
+		}
+		return nil, err
+	}
+	return &info, nil
+}
+
+func (p *Profile) addSession(info ProfileSessionInfo, duration time.Duration) error {
+	s := p.sessions
+	var selector json.RawMessage
+	if info.Selector != nil {
+		selector = *info.Selector
+	}
+	for i, module := range s.modules {
+		if err := module.StartSession(info.ID, selector); err != nil {
+			for _, started := range s.modules[:i] {
+				_ = started.StopSession(info.ID)
+			}
+			return err
+		}
+	}
+	session := &profileSession{ProfileSessionInfo: info}
+	if duration > 0 {
+		id := info.ID
+		session.timer = time.AfterFunc(duration, func() {
+			_ = p.stopSession(id, fmt.Sprintf("profiling session %s stopped after running %v seconds", id, duration.Seconds()))
+		})
+	}
+	s.lock.Lock()
+	s.sessions[info.ID] = session
+	s.lock.Unlock()
+	return nil
+}
+
+// stopSession removes the session from the sub-modules, and stops the profile module
+// when no sessions are left.
+func (p *Profile) stopSession(id string, msg string) error {
+	s := p.sessions
+	s.opLock.Lock()
+	defer s.opLock.Unlock()
+	s.lock.Lock()
+	session, ok := s.sessions[id]
+	if ok {
+		delete(s.sessions, id)
+	}
+	s.lock.Unlock()
+	if !ok {
+		return errors.New("profiling session not found: " + id)
+	}
+	if session.timer != nil {
+		session.timer.Stop()
+	}
+
+	var err error
+	if s.count() == 0 {
+		err = p.Stop(msg)
+	} else {
+		for _, module := range s.modules {
+			err = multierr.Append(err, module.StopSession(id))
+		}
+		p.tools.Logger.Info(msg)
+	}
+	p.audit.Record(AuditEntry{Source: AuditSourceManual, Rule: id, Result: AuditResultStopped, Message: msg})
+	return err
+}
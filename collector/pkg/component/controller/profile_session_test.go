@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component"
+)
+
+type fakeSessionModule struct {
+	lock     sync.Mutex
+	sessions map[string]string
+}
+
+func (m *fakeSessionModule) StartSession(id string, selector json.RawMessage) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.sessions[id] = string(selector)
+	return nil
+}
+
+func (m *fakeSessionModule) StopSession(id string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.sessions, id)
+	return nil
+}
+
+func (m *fakeSessionModule) ids() []string {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	ret := make([]string, 0, len(m.sessions))
+	for id := range m.sessions {
+		ret = append(ret, id)
+	}
+	return ret
+}
+
+func newProfileRequest(operation string, options string) *ControlRequest {
+	raw := json.RawMessage(options)
+	return &ControlRequest{Operation: operation, Options: &raw}
+}
+
+func TestProfileSessions(t *testing.T) {
+	profile := NewProfileController(component.NewDefaultTelemetryTools())
+	profile.audit = NewAuditLog(10)
+	module := &fakeSessionModule{sessions: make(map[string]string)}
+	profile.RegistSessionModules(module)
+
+	resp := profile.HandRequest(newProfileRequest("start", `{"Selector": {"pids": [1]}, "Session": "a"}`))
+	assert.Equal(t, NoError, resp.Code)
+	assert.Equal(t, "a", resp.Session)
+	assert.Equal(t, Started, profile.Status())
+	resp = profile.HandRequest(newProfileRequest("start", `{"Selector": {"pids": [2]}, "Duration": "100ms"}`))
+	assert.Equal(t, NoError, resp.Code)
+	assert.Equal(t, "session-1", resp.Session)
+	assert.Len(t, module.ids(), 2)
+	resp = profile.HandRequest(newProfileRequest("start", `{"Selector": {"pids": [3]}, "Session": "a"}`))
+	assert.Equal(t, StartWithError, resp.Code)
+
+	// The session is stopped after the duration while the module keeps running.
+	time.Sleep(300 * time.Millisecond)
+	assert.Len(t, module.ids(), 1)
+	resp = profile.HandRequest(&ControlRequest{Operation: "sessions"})
+	assert.Len(t, resp.Sessions, 1)
+	assert.Equal(t, `{"pids": [1]}`, string(*resp.Sessions[0].Selector))
+	assert.Equal(t, Started, profile.Status())
+
+	// The module is stopped with the last session.
+	resp = profile.HandRequest(newProfileRequest("stop", `{"Session": "a"}`))
+	assert.Equal(t, NoError, resp.Code)
+	assert.Equal(t, Stopped, profile.Status())
+	resp = profile.HandRequest(newProfileRequest("stop", `{"Session": "a"}`))
+	assert.Equal(t, StopWithError, resp.Code)
+
+	// A scoped session doesn't narrow the profiling started without sessions.
+	resp = profile.HandRequest(newProfileRequest("start", `{"Duration": "1m"}`))
+	assert.Equal(t, NoError, resp.Code)
+	resp = profile.HandRequest(newProfileRequest("start", `{"Selector": {"pids": [1]}, "Session": "b"}`))
+	assert.Equal(t, NoError, resp.Code)
+	assert.Contains(t, module.ids(), globalSessionID)
+	resp = profile.HandRequest(newProfileRequest("stop", `{"Session": "b"}`))
+	assert.Equal(t, Started, profile.Status())
+	resp = profile.HandRequest(&ControlRequest{Operation: "stop"})
+	assert.Equal(t, NoError, resp.Code)
+	assert.Empty(t, profile.sessions.list())
+
+	entries := profile.audit.Query(AuditSourceManual, 0)
+	assert.Equal(t, AuditResultStopped, entries[0].Result)
+	assert.Equal(t, "b", entries[1].Rule)
+}
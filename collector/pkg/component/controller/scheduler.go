@@ -0,0 +1,341 @@
+package controller
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component"
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constlabels"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constnames"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constvalues"
+)
+
+const (
+	// TriggerMetricP99Latency is the 99th percentile of the request latency in milliseconds.
+	TriggerMetricP99Latency = "p99_latency"
+	// TriggerMetricErrorRate is the percentage of the error requests.
+	TriggerMetricErrorRate = "error_rate"
+)
+
+const (
+	schedulerTickInterval   = 10 * time.Second
+	defaultProfileDuration  = time.Minute
+	defaultTriggerWindow    = time.Minute
+	defaultTriggerCooldown  = 10 * time.Minute
+	defaultTriggerMinCount  = 10
+	maxTriggerWindowSamples = 10000
+)
+
+// SchedulerConfig is the configuration of ProfileScheduler, which activates the profiling
+// automatically.
+type SchedulerConfig struct {
+	// DailyBudget is the maximum profiling time per day the scheduler can activate on
+	// this node. It is unlimited if it is 0. The manual activations are not limited.
+	DailyBudget time.Duration `mapstructure:"daily_budget"`
+	// AuditLogSize is how many audit entries are kept in memory.
+	AuditLogSize int                      `mapstructure:"audit_log_size"`
+	Windows      []ScheduleWindowConfig   `mapstructure:"windows"`
+	Triggers     []ThresholdTriggerConfig `mapstructure:"triggers"`
+}
+
+// ScheduleWindowConfig starts the profiling at the times matching Cron and stops it after Duration.
+type ScheduleWindowConfig struct {
+	Name     string        `mapstructure:"name"`
+	Cron     string        `mapstructure:"cron"`
+	Duration time.Duration `mapstructure:"duration"`
+}
+
+// ThresholdTriggerConfig starts the profiling when the metric of the requests to the
+// workload crosses the threshold in the last Window.
+type ThresholdTriggerConfig struct {
+	Name string `mapstructure:"name"`
+	// Namespace and Workload select the server workload. Empty values match all.
+	Namespace string `mapstructure:"namespace"`
+	Workload  string `mapstructure:"workload"`
+	// Metric is "p99_latency" in milliseconds or "error_rate" in percent.
+	Metric    string        `mapstructure:"metric"`
+	Threshold float64       `mapstructure:"threshold"`
+	Window    time.Duration `mapstructure:"window"`
+	// MinRequests is the minimum number of requests in the window to evaluate the metric.
+	MinRequests int           `mapstructure:"min_requests"`
+	Duration    time.Duration `mapstructure:"duration"`
+	// Cooldown is the minimum interval between two activations of the trigger.
+	Cooldown time.Duration `mapstructure:"cooldown"`
+}
+
+type scheduleWindow struct {
+	name     string
+	schedule *cronSchedule
+	duration time.Duration
+}
+
+type requestSample struct {
+	time    time.Time
+	latency int64
+	isError bool
+}
+
+type thresholdTrigger struct {
+	ThresholdTriggerConfig
+	samples        []requestSample
+	lastActivation time.Time
+}
+
+// ProfileScheduler starts the profile module in the cron-like windows or when the
+// metrics of the watched workloads cross the thresholds. It consumes the request
+// records of the network analyzer to evaluate the metrics.
+type ProfileScheduler struct {
+	module      Module
+	dailyBudget time.Duration
+	windows     []*scheduleWindow
+	triggers    []*thresholdTrigger
+	audit       *AuditLog
+	tools       *component.TelemetryTools
+
+	lock       sync.Mutex
+	budgetDay  string
+	budgetUsed time.Duration
+	lastMinute time.Time
+
+	now    func() time.Time
+	stopCh chan struct{}
+}
+
+func NewProfileScheduler(module Module, cfg *SchedulerConfig, audit *AuditLog, tools *component.TelemetryTools) (*ProfileScheduler, error) {
+	s := &ProfileScheduler{
+		module:      module,
+		dailyBudget: cfg.DailyBudget,
+		audit:       audit,
+		tools:       tools,
+		now:         time.Now,
+		stopCh:      make(chan struct{}),
+	}
+	for i, w := range cfg.Windows {
+		schedule, err := parseCron(w.Cron)
+		if err != nil {
+			return nil, err
+		}
+		if w.Name == "" {
+			w.Name = fmt.Sprintf("window-%d", i)
+		}
+		if w.Duration <= 0 {
+			w.Duration = defaultProfileDuration
+		}
+		s.windows = append(s.windows, &scheduleWindow{name: w.Name, schedule: schedule, duration: w.Duration})
+	}
+	for i, t := range cfg.Triggers {
+		if t.Metric != TriggerMetricP99Latency && t.Metric != TriggerMetricErrorRate {
+			return nil, fmt.Errorf("unsupported metric of the trigger: %s", t.Metric)
+		}
+		if t.Name == "" {
+			t.Name = fmt.Sprintf("trigger-%d", i)
+		}
+		if t.Window <= 0 {
+			t.Window = defaultTriggerWindow
+		}
+		if t.MinRequests <= 0 {
+			t.MinRequests = defaultTriggerMinCount
+		}
+		if t.Duration <= 0 {
+			t.Duration = defaultProfileDuration
+		}
+		if t.Cooldown <= 0 {
+			t.Cooldown = defaultTriggerCooldown
+		}
+		s.triggers = append(s.triggers, &thresholdTrigger{ThresholdTriggerConfig: t})
+	}
+	return s, nil
+}
+
+// HasTriggers returns true if the scheduler needs the request records.
+func (s *ProfileScheduler) HasTriggers() bool {
+	return len(s.triggers) > 0
+}
+
+func (s *ProfileScheduler) Start() {
+	go func() {
+		ticker := time.NewTicker(schedulerTickInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.tick()
+			case <-s.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+func (s *ProfileScheduler) Shutdown() {
+	close(s.stopCh)
+}
+
+func (s *ProfileScheduler) tick() {
+	now := s.now()
+	s.checkWindows(now)
+	s.evaluateTriggers(now)
+}
+
+// checkWindows activates the windows matching the current minute. Each minute is
+// checked once.
+func (s *ProfileScheduler) checkWindows(now time.Time) {
+	minute := now.Truncate(time.Minute)
+	s.lock.Lock()
+	if !minute.After(s.lastMinute) {
+		s.lock.Unlock()
+		return
+	}
+	s.lastMinute = minute
+	s.lock.Unlock()
+	for _, w := range s.windows {
+		if w.schedule.matches(minute) {
+			s.activate(now, AuditSourceSchedule, w.name, w.duration, "")
+		}
+	}
+}
+
+// Consume records the requests to the watched workloads. It only accepts the request
+// records of the network analyzer, which must have been enriched with the Kubernetes
+// metadata.
+func (s *ProfileScheduler) Consume(dataGroup *model.DataGroup) error {
+	if dataGroup.Name != constnames.NetRequestMetricGroupName {
+		return nil
+	}
+	latency, ok := dataGroup.GetMetric(constvalues.RequestTotalTime)
+	if !ok {
+		return nil
+	}
+	namespace := dataGroup.Labels.GetStringValue(constlabels.DstNamespace)
+	workload := dataGroup.Labels.GetStringValue(constlabels.DstWorkloadName)
+	sample := requestSample{
+		time:    s.now(),
+		latency: latency.GetInt().Value,
+		isError: dataGroup.Labels.GetBoolValue(constlabels.IsError),
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, t := range s.triggers {
+		if (t.Namespace != "" && t.Namespace != namespace) || (t.Workload != "" && t.Workload != workload) {
+			continue
+		}
+		if len(t.samples) >= maxTriggerWindowSamples {
+			t.samples = t.samples[1:]
+		}
+		t.samples = append(t.samples, sample)
+	}
+	return nil
+}
+
+func (s *ProfileScheduler) evaluateTriggers(now time.Time) {
+	type firing struct {
+		trigger *thresholdTrigger
+		value   float64
+	}
+	var firings []firing
+	s.lock.Lock()
+	for _, t := range s.triggers {
+		// Remove the samples out of the window.
+		i := sort.Search(len(t.samples), func(i int) bool {
+			return !t.samples[i].time.Before(now.Add(-t.Window))
+		})
+		t.samples = t.samples[i:]
+		if len(t.samples) < t.MinRequests {
+			continue
+		}
+		value := t.value()
+		if value < t.Threshold || (!t.lastActivation.IsZero() && now.Sub(t.lastActivation) < t.Cooldown) {
+			continue
+		}
+		t.lastActivation = now
+		firings = append(firings, firing{trigger: t, value: value})
+	}
+	s.lock.Unlock()
+	for _, f := range firings {
+		s.activate(now, AuditSourceThreshold, f.trigger.Name, f.trigger.Duration,
+			fmt.Sprintf("%s of %s/%s is %.2f, threshold is %.2f", f.trigger.Metric,
+				f.trigger.Namespace, f.trigger.Workload, f.value, f.trigger.Threshold))
+	}
+}
+
+func (t *thresholdTrigger) value() float64 {
+	switch t.Metric {
+	case TriggerMetricP99Latency:
+		latencies := make([]int64, len(t.samples))
+		for i, sample := range t.samples {
+			latencies[i] = sample.latency
+		}
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		index := int(math.Ceil(float64(len(latencies))*0.99)) - 1
+		return float64(latencies[index]) / float64(time.Millisecond)
+	case TriggerMetricErrorRate:
+		var errors int
+		for _, sample := range t.samples {
+			if sample.isError {
+				errors++
+			}
+		}
+		return float64(errors) * 100 / float64(len(t.samples))
+	default:
+		return 0
+	}
+}
+
+// activate starts the profiling if it is not running and the daily budget is not used up.
+// The duration is shortened to the remaining budget.
+func (s *ProfileScheduler) activate(now time.Time, source string, rule string, duration time.Duration, reason string) {
+	entry := AuditEntry{Time: now, Source: source, Rule: rule, Message: reason}
+	if s.module.Status() == Started {
+		entry.Result = AuditResultSkipped
+		entry.Message = joinMessage(reason, "profiling is already running")
+		s.audit.Record(entry)
+		return
+	}
+	s.lock.Lock()
+	day := now.Format("2006-01-02")
+	if day != s.budgetDay {
+		s.budgetDay = day
+		s.budgetUsed = 0
+	}
+	if s.dailyBudget > 0 {
+		remaining := s.dailyBudget - s.budgetUsed
+		if remaining <= 0 {
+			s.lock.Unlock()
+			entry.Result = AuditResultRejected
+			entry.Message = joinMessage(reason, "daily profiling budget is used up")
+			s.audit.Record(entry)
+			return
+		}
+		if duration > remaining {
+			duration = remaining
+		}
+	}
+	s.lock.Unlock()
+
+	entry.DurationSeconds = duration.Seconds()
+	if err := s.module.Start(WithStopInterval(duration)); err != nil {
+		entry.Result = AuditResultFailed
+		entry.Message = joinMessage(reason, err.Error())
+	} else {
+		entry.Result = AuditResultStarted
+		s.lock.Lock()
+		s.budgetUsed += duration
+		s.lock.Unlock()
+	}
+	s.audit.Record(entry)
+	s.tools.Logger.Info("Profiling is activated by the scheduler", zap.String("source", source),
+		zap.String("rule", rule), zap.String("result", entry.Result), zap.Duration("duration", duration))
+}
+
+func joinMessage(reason string, msg string) string {
+	if reason == "" {
+		return msg
+	}
+	return reason + "; " + msg
+}
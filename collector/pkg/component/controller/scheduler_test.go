@@ -0,0 +1,131 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component"
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constlabels"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constnames"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constvalues"
+)
+
+func TestProfileSchedulerWindows(t *testing.T) {
+	tools := component.NewDefaultTelemetryTools()
+	module := NewModule(ProfileModule, tools, Stopped)
+	audit := NewAuditLog(10)
+	scheduler, err := NewProfileScheduler(module, &SchedulerConfig{
+		DailyBudget: 90 * time.Second,
+		Windows:     []ScheduleWindowConfig{{Name: "nightly", Cron: "0 3 * * *", Duration: time.Minute}},
+	}, audit, tools)
+	assert.NoError(t, err)
+
+	now := time.Date(2022, 8, 1, 3, 0, 5, 0, time.Local)
+	scheduler.now = func() time.Time { return now }
+	scheduler.tick()
+	assert.Equal(t, Started, module.Status())
+	// The same minute is only checked once.
+	now = now.Add(10 * time.Second)
+	_ = module.Stop("test")
+	scheduler.tick()
+	assert.Equal(t, Stopped, module.Status())
+
+	// The budget is reset every day.
+	now = now.Add(24 * time.Hour).Add(-10 * time.Second)
+	scheduler.tick()
+	_ = module.Stop("test")
+	entries := audit.Query(AuditSourceSchedule, 0)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, AuditResultStarted, entries[0].Result)
+	assert.Equal(t, float64(60), entries[0].DurationSeconds)
+
+	// The duration is limited by the remaining budget. The minute is checked again by
+	// resetting the last one.
+	scheduler.lastMinute = time.Time{}
+	scheduler.tick()
+	_ = module.Stop("test")
+	entries = audit.Query(AuditSourceSchedule, 1)
+	assert.Equal(t, float64(30), entries[0].DurationSeconds)
+
+	scheduler.lastMinute = time.Time{}
+	scheduler.tick()
+	assert.Equal(t, Stopped, module.Status())
+	entries = audit.Query("", 1)
+	assert.Equal(t, AuditResultRejected, entries[0].Result)
+	assert.Equal(t, "nightly", entries[0].Rule)
+}
+
+func newRequestDataGroup(workload string, latency int64, isError bool) *model.DataGroup {
+	labels := model.NewAttributeMap()
+	labels.AddStringValue(constlabels.DstNamespace, "default")
+	labels.AddStringValue(constlabels.DstWorkloadName, workload)
+	labels.AddBoolValue(constlabels.IsError, isError)
+	return model.NewDataGroup(constnames.NetRequestMetricGroupName, labels, 0,
+		model.NewIntMetric(constvalues.RequestTotalTime, latency))
+}
+
+func TestProfileSchedulerTriggers(t *testing.T) {
+	tools := component.NewDefaultTelemetryTools()
+	module := NewModule(ProfileModule, tools, Stopped)
+	audit := NewAuditLog(10)
+	scheduler, err := NewProfileScheduler(module, &SchedulerConfig{
+		Triggers: []ThresholdTriggerConfig{
+			{Name: "errors", Namespace: "default", Workload: "business", Metric: TriggerMetricErrorRate,
+				Threshold: 50, MinRequests: 4, Window: time.Minute, Duration: time.Minute},
+			{Name: "latency", Workload: "business", Metric: TriggerMetricP99Latency,
+				Threshold: 1000, MinRequests: 4, Window: time.Minute, Duration: time.Minute},
+		},
+	}, audit, tools)
+	assert.NoError(t, err)
+	assert.True(t, scheduler.HasTriggers())
+
+	now := time.Date(2022, 8, 1, 3, 0, 5, 0, time.Local)
+	scheduler.now = func() time.Time { return now }
+	for i := 0; i < 4; i++ {
+		_ = scheduler.Consume(newRequestDataGroup("business", int64(100*time.Millisecond), i > 0))
+		// The other workloads are ignored.
+		_ = scheduler.Consume(newRequestDataGroup("other", int64(5*time.Second), false))
+	}
+	scheduler.evaluateTriggers(now)
+	assert.Equal(t, Started, module.Status())
+	entries := audit.Query(AuditSourceThreshold, 0)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "errors", entries[0].Rule)
+	assert.Contains(t, entries[0].Message, "error_rate of default/business is 75.00")
+	_ = module.Stop("test")
+
+	// The error trigger is cooling down, and the samples out of the window are removed.
+	now = now.Add(2 * time.Minute)
+	_ = scheduler.Consume(newRequestDataGroup("business", int64(2*time.Second), false))
+	scheduler.evaluateTriggers(now)
+	assert.Equal(t, Stopped, module.Status())
+	for i := 0; i < 3; i++ {
+		_ = scheduler.Consume(newRequestDataGroup("business", int64(10*time.Millisecond), false))
+	}
+	scheduler.evaluateTriggers(now)
+	assert.Equal(t, Started, module.Status())
+	entries = audit.Query(AuditSourceThreshold, 1)
+	assert.Equal(t, "latency", entries[0].Rule)
+	_ = module.Stop("test")
+
+	_, err = NewProfileScheduler(module, &SchedulerConfig{
+		Triggers: []ThresholdTriggerConfig{{Metric: "p50_latency"}},
+	}, audit, tools)
+	assert.Error(t, err)
+}
+
+func TestAuditLog(t *testing.T) {
+	audit := NewAuditLog(2)
+	audit.Record(AuditEntry{Source: AuditSourceManual, Result: AuditResultStarted})
+	audit.Record(AuditEntry{Source: AuditSourceSchedule, Result: AuditResultStarted})
+	audit.Record(AuditEntry{Source: AuditSourceManual, Result: AuditResultStopped})
+	entries := audit.Query("", 0)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, AuditResultStopped, entries[0].Result)
+	assert.Equal(t, AuditSourceSchedule, entries[1].Source)
+	assert.False(t, entries[0].Time.IsZero())
+	assert.Len(t, audit.Query(AuditSourceManual, 0), 1)
+}
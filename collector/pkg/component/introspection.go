@@ -0,0 +1,22 @@
+package component
+
+// HealthChecker is implemented by the components which can tell whether they work,
+// e.g. a receiver reports that its probe is not running.
+type HealthChecker interface {
+	// CheckHealth returns nil if the component is healthy.
+	CheckHealth() error
+}
+
+// QueueDepth is the fill level of a queue in a component. The unit depends on the queue,
+// which is the number of items for the channels.
+type QueueDepth struct {
+	Length   int64 `json:"length"`
+	Capacity int64 `json:"capacity"`
+}
+
+// QueueReporter is implemented by the components buffering the data in queues, so the
+// backlog can be inspected.
+type QueueReporter interface {
+	// QueueDepths returns the fill levels of the queues by their names.
+	QueueDepths() map[string]QueueDepth
+}
@@ -12,6 +12,7 @@ import "C"
 import (
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 	"unsafe"
 
@@ -42,6 +43,10 @@ type CgoReceiver struct {
 	stats             eventCounter
 	probeCounter      *probeCounter
 	probeCounterMutex sync.RWMutex
+	// recorder is only accessed in consumeEvents. It is nil if the record mode is disabled.
+	recorder *recorder
+	// probeRunning is true after the probe is initialized until the receiver is shut down.
+	probeRunning atomic.Bool
 }
 
 func NewCgoReceiver(config interface{}, telemetry *component.TelemetryTools, analyzerManager *analyzerpackage.Manager) receiver.Receiver {
@@ -64,10 +69,19 @@ func NewCgoReceiver(config interface{}, telemetry *component.TelemetryTools, ana
 
 func (r *CgoReceiver) Start() error {
 	r.telemetry.Logger.Info("Start CgoReceiver")
+	if r.cfg.Record.Enable {
+		rec, err := newRecorder(&r.cfg.Record)
+		if err != nil {
+			return fmt.Errorf("fail to start recording events: %w", err)
+		}
+		r.recorder = rec
+		r.telemetry.Logger.Infof("Recording the events to %s", r.cfg.Record.Path)
+	}
 	res := int(C.runForGo())
 	if res == 1 {
 		return fmt.Errorf("fail to init probe")
 	}
+	r.probeRunning.Store(true)
 	go r.catchSignalUp()
 	time.Sleep(2 * time.Second)
 	r.suppressEventsComm()
@@ -111,6 +125,7 @@ func (r *CgoReceiver) consumeEvents() {
 			r.shutdownWG.Done()
 			return
 		case ev := <-r.eventChannel:
+			r.recordEvent(ev)
 			err := r.sendToNextConsumer(ev)
 			if err != nil {
 				r.telemetry.Logger.Info("Failed to send KindlingEvent: ", zap.Error(err))
@@ -121,12 +136,48 @@ func (r *CgoReceiver) consumeEvents() {
 
 func (r *CgoReceiver) Shutdown() error {
 	// TODO stop the C routine
+	r.probeRunning.Store(false)
 	C.stopProfile()
 	close(r.stopCh)
 	r.shutdownWG.Wait()
+	r.drainEvents()
+	if r.recorder != nil {
+		return r.recorder.close()
+	}
 	return nil
 }
 
+// drainEvents sends the events left in the channel to the analyzers, which process them
+// before they are shut down.
+func (r *CgoReceiver) drainEvents() {
+	count := 0
+	for {
+		select {
+		case ev := <-r.eventChannel:
+			r.recordEvent(ev)
+			if err := r.sendToNextConsumer(ev); err != nil {
+				r.telemetry.Logger.Info("Failed to send KindlingEvent: ", zap.Error(err))
+			}
+			count++
+		default:
+			r.telemetry.Logger.Infof("%d events left in the channel are sent to the analyzers", count)
+			return
+		}
+	}
+}
+
+// recordEvent tees the event to the file. The recording is stopped if the file can't be written.
+func (r *CgoReceiver) recordEvent(evt *model.KindlingEvent) {
+	if r.recorder == nil {
+		return
+	}
+	if err := r.recorder.record(evt); err != nil {
+		r.telemetry.Logger.Warn("Stop recording events because of the error", zap.Error(err))
+		_ = r.recorder.close()
+		r.recorder = nil
+	}
+}
+
 func convertEvent(cgoEvent *CKindlingEventForGo) *model.KindlingEvent {
 	ev := new(model.KindlingEvent)
 	ev.Timestamp = uint64(cgoEvent.timestamp)
@@ -1,8 +1,11 @@
 package cgoreceiver
 
+import "github.com/Kindling-project/kindling/collector/pkg/component/receiver/eventfile"
+
 type Config struct {
 	SubscribeInfo     []SubEvent    `mapstructure:"subscribe"`
 	ProcessFilterInfo ProcessFilter `mapstructure:"process_filter"`
+	Record            RecordConfig  `mapstructure:"record"`
 }
 
 type SubEvent struct {
@@ -15,6 +18,20 @@ type ProcessFilter struct {
 	Comms []string `mapstructure:"comms"`
 }
 
+// RecordConfig tees the events received to a file, which can be replayed by filereceiver.
+// An event is recorded if its pid is in Pids or its comm is in Comms. All the events are
+// recorded if both are empty.
+type RecordConfig struct {
+	Enable bool `mapstructure:"enable"`
+	// Path is the file to write. It is compressed with gzip if the name ends with ".gz".
+	Path string `mapstructure:"path"`
+	// Format is either "json" for JSON lines or "protobuf" for length-delimited messages.
+	// It is "json" if empty.
+	Format eventfile.Format `mapstructure:"format"`
+	Pids   []uint32         `mapstructure:"pids"`
+	Comms  []string         `mapstructure:"comms"`
+}
+
 func NewDefaultConfig() *Config {
 	return &Config{
 		SubscribeInfo: []SubEvent{
@@ -0,0 +1,23 @@
+package cgoreceiver
+
+import (
+	"errors"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component"
+)
+
+// CheckHealth returns an error if the probe is not running.
+func (r *CgoReceiver) CheckHealth() error {
+	if !r.probeRunning.Load() {
+		return errors.New("the probe is not running")
+	}
+	return nil
+}
+
+// QueueDepths returns the fill level of the events received from the probe and waiting
+// to be sent to the analyzers.
+func (r *CgoReceiver) QueueDepths() map[string]component.QueueDepth {
+	return map[string]component.QueueDepth{
+		"event_channel": {Length: int64(len(r.eventChannel)), Capacity: int64(cap(r.eventChannel))},
+	}
+}
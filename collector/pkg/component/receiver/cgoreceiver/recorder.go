@@ -0,0 +1,61 @@
+package cgoreceiver
+
+import (
+	"errors"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component/receiver/eventfile"
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+)
+
+// recorder writes the events matching the filters to the file. It is not safe for concurrent use.
+type recorder struct {
+	writer *eventfile.Writer
+	pids   map[uint32]struct{}
+	comms  map[string]struct{}
+}
+
+func newRecorder(cfg *RecordConfig) (*recorder, error) {
+	if cfg.Path == "" {
+		return nil, errors.New("path of record is required")
+	}
+	writer, err := eventfile.Create(cfg.Path, cfg.Format)
+	if err != nil {
+		return nil, err
+	}
+	r := &recorder{writer: writer}
+	if len(cfg.Pids) > 0 {
+		r.pids = make(map[uint32]struct{}, len(cfg.Pids))
+		for _, pid := range cfg.Pids {
+			r.pids[pid] = struct{}{}
+		}
+	}
+	if len(cfg.Comms) > 0 {
+		r.comms = make(map[string]struct{}, len(cfg.Comms))
+		for _, comm := range cfg.Comms {
+			r.comms[comm] = struct{}{}
+		}
+	}
+	return r, nil
+}
+
+func (r *recorder) matches(evt *model.KindlingEvent) bool {
+	if r.pids == nil && r.comms == nil {
+		return true
+	}
+	if _, ok := r.pids[evt.Ctx.ThreadInfo.Pid]; ok {
+		return true
+	}
+	_, ok := r.comms[evt.Ctx.ThreadInfo.Comm]
+	return ok
+}
+
+func (r *recorder) record(evt *model.KindlingEvent) error {
+	if !r.matches(evt) {
+		return nil
+	}
+	return r.writer.Write(evt)
+}
+
+func (r *recorder) close() error {
+	return r.writer.Close()
+}
@@ -0,0 +1,56 @@
+package cgoreceiver
+
+import (
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component/receiver/eventfile"
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+)
+
+func TestRecorder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.pb.gz")
+	r, err := newRecorder(&RecordConfig{
+		Enable: true,
+		Path:   path,
+		Format: eventfile.FormatProtobuf,
+		Pids:   []uint32{1},
+		Comms:  []string{"java"},
+	})
+	require.NoError(t, err)
+	events := []*model.KindlingEvent{
+		{Timestamp: 1, Ctx: model.Context{ThreadInfo: model.Thread{Pid: 1, Comm: "nginx"}}},
+		{Timestamp: 2, Ctx: model.Context{ThreadInfo: model.Thread{Pid: 2, Comm: "java"}}},
+		{Timestamp: 3, Ctx: model.Context{ThreadInfo: model.Thread{Pid: 3, Comm: "nginx"}}},
+	}
+	for _, evt := range events {
+		assert.NoError(t, r.record(evt))
+	}
+	require.NoError(t, r.close())
+
+	reader, err := eventfile.Open(path, eventfile.FormatProtobuf)
+	require.NoError(t, err)
+	defer reader.Close()
+	var timestamps []uint64
+	for {
+		evt, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		timestamps = append(timestamps, evt.Timestamp)
+	}
+	assert.Equal(t, []uint64{1, 2}, timestamps)
+
+	// All the events are recorded without the filters.
+	r, err = newRecorder(&RecordConfig{Path: filepath.Join(t.TempDir(), "events.json")})
+	require.NoError(t, err)
+	assert.True(t, r.matches(events[2]))
+	assert.NoError(t, r.close())
+	_, err = newRecorder(&RecordConfig{})
+	assert.Error(t, err)
+}
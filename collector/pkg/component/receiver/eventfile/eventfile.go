@@ -0,0 +1,186 @@
+// Package eventfile reads and writes the streams of KindlingEvents recorded by cgoreceiver,
+// which are replayed by filereceiver. A stream is either JSON lines or length-delimited
+// protobuf messages, and is optionally compressed with gzip.
+package eventfile
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+)
+
+type Format string
+
+const (
+	FormatJSON     Format = "json"
+	FormatProtobuf Format = "protobuf"
+)
+
+// Writer encodes the events to the underlying writer. It is not safe for concurrent use.
+type Writer struct {
+	buf     *bufio.Writer
+	gz      *gzip.Writer
+	closer  io.Closer
+	encoder encoder
+}
+
+type encoder interface {
+	encode(w *bufio.Writer, evt *model.KindlingEvent) error
+}
+
+// NewWriter returns a Writer encoding the events to w in the format, compressed with gzip if compress is true.
+func NewWriter(w io.Writer, format Format, compress bool) (*Writer, error) {
+	e, err := newEncoder(format)
+	if err != nil {
+		return nil, err
+	}
+	writer := &Writer{encoder: e}
+	if compress {
+		writer.gz = gzip.NewWriter(w)
+		w = writer.gz
+	}
+	writer.buf = bufio.NewWriter(w)
+	return writer, nil
+}
+
+// Create creates or truncates the file and returns a Writer of it. The file is compressed
+// with gzip if its name ends with ".gz".
+func Create(path string, format Format) (*Writer, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w, err := NewWriter(f, format, strings.HasSuffix(path, ".gz"))
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	w.closer = f
+	return w, nil
+}
+
+func (w *Writer) Write(evt *model.KindlingEvent) error {
+	return w.encoder.encode(w.buf, evt)
+}
+
+// Flush writes the buffered events to the underlying writer.
+func (w *Writer) Flush() error {
+	if err := w.buf.Flush(); err != nil {
+		return err
+	}
+	if w.gz != nil {
+		return w.gz.Flush()
+	}
+	return nil
+}
+
+// Close flushes the buffered events and closes the file if it is created by Create.
+func (w *Writer) Close() error {
+	if err := w.buf.Flush(); err != nil {
+		return err
+	}
+	if w.gz != nil {
+		if err := w.gz.Close(); err != nil {
+			return err
+		}
+	}
+	if w.closer != nil {
+		return w.closer.Close()
+	}
+	return nil
+}
+
+// Reader decodes the events from the underlying reader.
+type Reader struct {
+	closers []io.Closer
+	decoder decoder
+}
+
+type decoder interface {
+	decode() (*model.KindlingEvent, error)
+}
+
+// NewReader returns a Reader decoding the events in the format from r. The stream
+// compressed with gzip is detected by its magic number.
+func NewReader(r io.Reader, format Format) (*Reader, error) {
+	reader := &Reader{}
+	buf := bufio.NewReader(r)
+	if magic, err := buf.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(buf)
+		if err != nil {
+			return nil, err
+		}
+		reader.closers = append(reader.closers, gz)
+		buf = bufio.NewReader(gz)
+	}
+	d, err := newDecoder(buf, format)
+	if err != nil {
+		return nil, err
+	}
+	reader.decoder = d
+	return reader, nil
+}
+
+// Open returns a Reader of the file.
+func Open(path string, format Format) (*Reader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := NewReader(f, format)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	r.closers = append(r.closers, f)
+	return r, nil
+}
+
+// Read returns the next event, or io.EOF if there are no more events.
+func (r *Reader) Read() (*model.KindlingEvent, error) {
+	return r.decoder.decode()
+}
+
+func (r *Reader) Close() error {
+	var retErr error
+	for _, c := range r.closers {
+		if err := c.Close(); err != nil && retErr == nil {
+			retErr = err
+		}
+	}
+	return retErr
+}
+
+func newEncoder(format Format) (encoder, error) {
+	switch format {
+	case FormatJSON, "":
+		return jsonEncoder{}, nil
+	case FormatProtobuf:
+		return protobufEncoder{}, nil
+	}
+	return nil, fmt.Errorf("unsupported format %q, expected %q or %q", format, FormatJSON, FormatProtobuf)
+}
+
+func newDecoder(r *bufio.Reader, format Format) (decoder, error) {
+	switch format {
+	case FormatJSON, "":
+		return newJSONDecoder(r), nil
+	case FormatProtobuf:
+		return &protobufDecoder{r: r}, nil
+	}
+	return nil, fmt.Errorf("unsupported format %q, expected %q or %q", format, FormatJSON, FormatProtobuf)
+}
+
+// userAttributes returns the attributes set of the event.
+func userAttributes(evt *model.KindlingEvent) []model.KeyValue {
+	n := int(evt.ParamsNumber)
+	if n > len(evt.UserAttributes) {
+		n = len(evt.UserAttributes)
+	}
+	return evt.UserAttributes[:n]
+}
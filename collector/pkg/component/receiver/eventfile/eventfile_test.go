@@ -0,0 +1,97 @@
+package eventfile
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+)
+
+func newTestEvents() []*model.KindlingEvent {
+	evt := &model.KindlingEvent{
+		Source:       model.Source_SYSCALL_EXIT,
+		Timestamp:    1664582400000000000,
+		Name:         "syscall_exit-read",
+		Category:     model.Category_CAT_NET,
+		ParamsNumber: 2,
+		Latency:      1000,
+		Ctx: model.Context{
+			ThreadInfo: model.Thread{Pid: 1234, Tid: 1235, Comm: "java", ContainerId: "abc"},
+			FdInfo: model.Fd{
+				Num:      -1,
+				TypeFd:   model.FDType_FD_IPV4_SOCK,
+				Protocol: model.L4Proto_TCP,
+				Role:     true,
+				Sip:      []uint32{16777343},
+				Dip:      []uint32{33554442},
+				Sport:    8080,
+				Dport:    34567,
+			},
+		},
+	}
+	evt.UserAttributes[0] = model.KeyValue{Key: "res", ValueType: model.ValueType_INT64, Value: []byte{5, 0, 0, 0, 0, 0, 0, 0}}
+	evt.UserAttributes[1] = model.KeyValue{Key: "data", ValueType: model.ValueType_BYTEBUF, Value: []byte("GET /")}
+	return []*model.KindlingEvent{evt, {Timestamp: 1664582400000000001, Name: "tracepoint-procexit"}}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, format := range []Format{FormatJSON, FormatProtobuf} {
+		for _, compress := range []bool{false, true} {
+			var buf bytes.Buffer
+			w, err := NewWriter(&buf, format, compress)
+			require.NoError(t, err)
+			events := newTestEvents()
+			for _, evt := range events {
+				require.NoError(t, w.Write(evt))
+			}
+			require.NoError(t, w.Close())
+
+			r, err := NewReader(&buf, format)
+			require.NoError(t, err)
+			for _, want := range events {
+				got, err := r.Read()
+				require.NoError(t, err, "format %s, compress %t", format, compress)
+				assert.Equal(t, want, got, "format %s, compress %t", format, compress)
+			}
+			_, err = r.Read()
+			assert.Equal(t, io.EOF, err)
+			assert.NoError(t, r.Close())
+		}
+	}
+}
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.pb.gz")
+	w, err := Create(path, FormatProtobuf)
+	require.NoError(t, err)
+	require.NoError(t, w.Write(newTestEvents()[0]))
+	require.NoError(t, w.Close())
+
+	r, err := Open(path, FormatProtobuf)
+	require.NoError(t, err)
+	defer r.Close()
+	evt, err := r.Read()
+	require.NoError(t, err)
+	assert.Equal(t, "GET /", string(evt.GetData()))
+}
+
+func TestTruncated(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, FormatProtobuf, false)
+	require.NoError(t, err)
+	require.NoError(t, w.Write(newTestEvents()[0]))
+	require.NoError(t, w.Close())
+
+	r, err := NewReader(bytes.NewReader(buf.Bytes()[:buf.Len()-1]), FormatProtobuf)
+	require.NoError(t, err)
+	_, err = r.Read()
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+
+	_, err = NewWriter(&buf, "csv", false)
+	assert.Error(t, err)
+}
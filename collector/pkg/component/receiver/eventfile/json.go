@@ -0,0 +1,156 @@
+package eventfile
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+)
+
+// jsonEvent is a line of the JSON lines format. The values of the user attributes are
+// encoded in base64.
+type jsonEvent struct {
+	Source         model.Source   `json:"source,omitempty"`
+	Timestamp      uint64         `json:"timestamp"`
+	Name           string         `json:"name"`
+	Category       model.Category `json:"category,omitempty"`
+	Latency        uint64         `json:"latency,omitempty"`
+	UserAttributes []jsonKeyValue `json:"user_attributes,omitempty"`
+	Thread         jsonThread     `json:"thread"`
+	Fd             jsonFd         `json:"fd"`
+}
+
+type jsonKeyValue struct {
+	Key       string          `json:"key"`
+	ValueType model.ValueType `json:"value_type"`
+	Value     []byte          `json:"value"`
+}
+
+type jsonThread struct {
+	Pid           uint32 `json:"pid,omitempty"`
+	Tid           uint32 `json:"tid,omitempty"`
+	Uid           uint32 `json:"uid,omitempty"`
+	Gid           uint32 `json:"gid,omitempty"`
+	Comm          string `json:"comm,omitempty"`
+	ContainerId   string `json:"container_id,omitempty"`
+	ContainerName string `json:"container_name,omitempty"`
+}
+
+type jsonFd struct {
+	Num         int32         `json:"num,omitempty"`
+	TypeFd      model.FDType  `json:"type_fd,omitempty"`
+	Filename    string        `json:"filename,omitempty"`
+	Directory   string        `json:"directory,omitempty"`
+	Protocol    model.L4Proto `json:"protocol,omitempty"`
+	Role        bool          `json:"role,omitempty"`
+	Sip         []uint32      `json:"sip,omitempty"`
+	Dip         []uint32      `json:"dip,omitempty"`
+	Sport       uint32        `json:"sport,omitempty"`
+	Dport       uint32        `json:"dport,omitempty"`
+	Source      uint64        `json:"source,omitempty"`
+	Destination uint64        `json:"destination,omitempty"`
+}
+
+type jsonEncoder struct{}
+
+func (jsonEncoder) encode(w *bufio.Writer, evt *model.KindlingEvent) error {
+	thread := &evt.Ctx.ThreadInfo
+	fd := &evt.Ctx.FdInfo
+	line := jsonEvent{
+		Source:    evt.Source,
+		Timestamp: evt.Timestamp,
+		Name:      evt.Name,
+		Category:  evt.Category,
+		Latency:   evt.Latency,
+		Thread: jsonThread{
+			Pid:           thread.Pid,
+			Tid:           thread.Tid,
+			Uid:           thread.Uid,
+			Gid:           thread.Gid,
+			Comm:          thread.Comm,
+			ContainerId:   thread.ContainerId,
+			ContainerName: thread.ContainerName,
+		},
+		Fd: jsonFd{
+			Num:         fd.Num,
+			TypeFd:      fd.TypeFd,
+			Filename:    fd.Filename,
+			Directory:   fd.Directory,
+			Protocol:    fd.Protocol,
+			Role:        fd.Role,
+			Sip:         fd.Sip,
+			Dip:         fd.Dip,
+			Sport:       fd.Sport,
+			Dport:       fd.Dport,
+			Source:      fd.Source,
+			Destination: fd.Destination,
+		},
+	}
+	for _, kv := range userAttributes(evt) {
+		line.UserAttributes = append(line.UserAttributes, jsonKeyValue{Key: kv.Key, ValueType: kv.ValueType, Value: kv.Value})
+	}
+	data, err := json.Marshal(&line)
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(data); err != nil {
+		return err
+	}
+	return w.WriteByte('\n')
+}
+
+type jsonDecoder struct {
+	d *json.Decoder
+}
+
+func newJSONDecoder(r *bufio.Reader) *jsonDecoder {
+	return &jsonDecoder{d: json.NewDecoder(r)}
+}
+
+func (d *jsonDecoder) decode() (*model.KindlingEvent, error) {
+	var line jsonEvent
+	if err := d.d.Decode(&line); err != nil {
+		return nil, err
+	}
+	if len(line.UserAttributes) > len(model.KindlingEvent{}.UserAttributes) {
+		return nil, fmt.Errorf("too many user attributes of event %s: %d", line.Name, len(line.UserAttributes))
+	}
+	evt := &model.KindlingEvent{
+		Source:       line.Source,
+		Timestamp:    line.Timestamp,
+		Name:         line.Name,
+		Category:     line.Category,
+		ParamsNumber: uint16(len(line.UserAttributes)),
+		Latency:      line.Latency,
+		Ctx: model.Context{
+			ThreadInfo: model.Thread{
+				Pid:           line.Thread.Pid,
+				Tid:           line.Thread.Tid,
+				Uid:           line.Thread.Uid,
+				Gid:           line.Thread.Gid,
+				Comm:          line.Thread.Comm,
+				ContainerId:   line.Thread.ContainerId,
+				ContainerName: line.Thread.ContainerName,
+			},
+			FdInfo: model.Fd{
+				Num:         line.Fd.Num,
+				TypeFd:      line.Fd.TypeFd,
+				Filename:    line.Fd.Filename,
+				Directory:   line.Fd.Directory,
+				Protocol:    line.Fd.Protocol,
+				Role:        line.Fd.Role,
+				Sip:         line.Fd.Sip,
+				Dip:         line.Fd.Dip,
+				Sport:       line.Fd.Sport,
+				Dport:       line.Fd.Dport,
+				Source:      line.Fd.Source,
+				Destination: line.Fd.Destination,
+			},
+		},
+	}
+	for i, kv := range line.UserAttributes {
+		evt.UserAttributes[i] = model.KeyValue{Key: kv.Key, ValueType: kv.ValueType, Value: kv.Value}
+	}
+	return evt, nil
+}
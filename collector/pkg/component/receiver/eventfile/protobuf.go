@@ -0,0 +1,314 @@
+package eventfile
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+)
+
+// maxMessageSize is the maximum size of an event message, which guards against the corrupted length.
+const maxMessageSize = 16 << 20
+
+// The protobuf format is a stream of messages, each prefixed with its size in varint.
+// The messages are encoded following the schema below, which keeps the field numbers of
+// the former kindling_event.proto:
+//
+//	message KindlingEvent {
+//	  Source source = 1;
+//	  uint64 timestamp = 2;
+//	  string name = 3;
+//	  Category category = 4;
+//	  repeated KeyValue user_attributes = 5;
+//	  Context ctx = 6;
+//	  uint64 latency = 7;
+//	}
+//	message KeyValue { string key = 1; ValueType value_type = 2; bytes value = 3; }
+//	message Context { Thread thread_info = 1; Fd fd_info = 2; }
+//	message Thread {
+//	  uint32 pid = 1; uint32 tid = 2; uint32 uid = 3; uint32 gid = 4;
+//	  string comm = 5; string container_id = 6; string container_name = 7;
+//	}
+//	message Fd {
+//	  int32 num = 1; FDType type_fd = 2; string filename = 3; string directory = 4;
+//	  L4Proto protocol = 5; bool role = 6; repeated uint32 sip = 7; repeated uint32 dip = 8;
+//	  uint32 sport = 9; uint32 dport = 10; uint64 source = 11; uint64 destination = 12;
+//	}
+type protobufEncoder struct{}
+
+func (protobufEncoder) encode(w *bufio.Writer, evt *model.KindlingEvent) error {
+	var b []byte
+	b = appendVarint(b, 1, uint64(evt.Source))
+	b = appendVarint(b, 2, evt.Timestamp)
+	b = appendString(b, 3, evt.Name)
+	b = appendVarint(b, 4, uint64(evt.Category))
+	for _, kv := range userAttributes(evt) {
+		var m []byte
+		m = appendString(m, 1, kv.Key)
+		m = appendVarint(m, 2, uint64(kv.ValueType))
+		m = appendBytes(m, 3, kv.Value)
+		b = protowire.AppendTag(b, 5, protowire.BytesType)
+		b = protowire.AppendBytes(b, m)
+	}
+	b = appendBytes(b, 6, encodeContext(&evt.Ctx))
+	b = appendVarint(b, 7, evt.Latency)
+
+	var size [binary.MaxVarintLen64]byte
+	if _, err := w.Write(size[:binary.PutUvarint(size[:], uint64(len(b)))]); err != nil {
+		return err
+	}
+	_, err := w.Write(b)
+	return err
+}
+
+func encodeContext(ctx *model.Context) []byte {
+	thread := &ctx.ThreadInfo
+	var t []byte
+	t = appendVarint(t, 1, uint64(thread.Pid))
+	t = appendVarint(t, 2, uint64(thread.Tid))
+	t = appendVarint(t, 3, uint64(thread.Uid))
+	t = appendVarint(t, 4, uint64(thread.Gid))
+	t = appendString(t, 5, thread.Comm)
+	t = appendString(t, 6, thread.ContainerId)
+	t = appendString(t, 7, thread.ContainerName)
+
+	fd := &ctx.FdInfo
+	var f []byte
+	// The negative int32 is encoded in 10 bytes as protobuf does.
+	f = appendVarint(f, 1, uint64(int64(fd.Num)))
+	f = appendVarint(f, 2, uint64(fd.TypeFd))
+	f = appendString(f, 3, fd.Filename)
+	f = appendString(f, 4, fd.Directory)
+	f = appendVarint(f, 5, uint64(fd.Protocol))
+	f = appendVarint(f, 6, protowire.EncodeBool(fd.Role))
+	f = appendPackedUint32(f, 7, fd.Sip)
+	f = appendPackedUint32(f, 8, fd.Dip)
+	f = appendVarint(f, 9, uint64(fd.Sport))
+	f = appendVarint(f, 10, uint64(fd.Dport))
+	f = appendVarint(f, 11, fd.Source)
+	f = appendVarint(f, 12, fd.Destination)
+
+	var b []byte
+	b = appendBytes(b, 1, t)
+	b = appendBytes(b, 2, f)
+	return b
+}
+
+// appendVarint appends the field unless it is zero, as proto3 does.
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func appendBytes(b []byte, num protowire.Number, v []byte) []byte {
+	if len(v) == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, v)
+}
+
+func appendPackedUint32(b []byte, num protowire.Number, values []uint32) []byte {
+	if len(values) == 0 {
+		return b
+	}
+	var packed []byte
+	for _, v := range values {
+		packed = protowire.AppendVarint(packed, uint64(v))
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, packed)
+}
+
+type protobufDecoder struct {
+	r *bufio.Reader
+}
+
+func (d *protobufDecoder) decode() (*model.KindlingEvent, error) {
+	size, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		// ReadUvarint returns io.EOF only if no bytes are read, which is the end of the stream.
+		return nil, err
+	}
+	if size > maxMessageSize {
+		return nil, fmt.Errorf("event message is too large: %d bytes", size)
+	}
+	b := make([]byte, size)
+	if _, err = io.ReadFull(d.r, b); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	evt := &model.KindlingEvent{}
+	err = consumeFields(b, func(num protowire.Number, typ protowire.Type, v uint64, m []byte) error {
+		switch num {
+		case 1:
+			evt.Source = model.Source(v)
+		case 2:
+			evt.Timestamp = v
+		case 3:
+			evt.Name = string(m)
+		case 4:
+			evt.Category = model.Category(v)
+		case 5:
+			if int(evt.ParamsNumber) >= len(evt.UserAttributes) {
+				return fmt.Errorf("too many user attributes of event")
+			}
+			kv := &evt.UserAttributes[evt.ParamsNumber]
+			evt.ParamsNumber++
+			return consumeFields(m, func(num protowire.Number, typ protowire.Type, v uint64, m []byte) error {
+				switch num {
+				case 1:
+					kv.Key = string(m)
+				case 2:
+					kv.ValueType = model.ValueType(v)
+				case 3:
+					kv.Value = append([]byte(nil), m...)
+				}
+				return nil
+			})
+		case 6:
+			return decodeContext(m, &evt.Ctx)
+		case 7:
+			evt.Latency = v
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid event message: %w", err)
+	}
+	return evt, nil
+}
+
+func decodeContext(b []byte, ctx *model.Context) error {
+	return consumeFields(b, func(num protowire.Number, typ protowire.Type, v uint64, m []byte) error {
+		switch num {
+		case 1:
+			thread := &ctx.ThreadInfo
+			return consumeFields(m, func(num protowire.Number, typ protowire.Type, v uint64, m []byte) error {
+				switch num {
+				case 1:
+					thread.Pid = uint32(v)
+				case 2:
+					thread.Tid = uint32(v)
+				case 3:
+					thread.Uid = uint32(v)
+				case 4:
+					thread.Gid = uint32(v)
+				case 5:
+					thread.Comm = string(m)
+				case 6:
+					thread.ContainerId = string(m)
+				case 7:
+					thread.ContainerName = string(m)
+				}
+				return nil
+			})
+		case 2:
+			fd := &ctx.FdInfo
+			return consumeFields(m, func(num protowire.Number, typ protowire.Type, v uint64, m []byte) error {
+				var err error
+				switch num {
+				case 1:
+					fd.Num = int32(v)
+				case 2:
+					fd.TypeFd = model.FDType(v)
+				case 3:
+					fd.Filename = string(m)
+				case 4:
+					fd.Directory = string(m)
+				case 5:
+					fd.Protocol = model.L4Proto(v)
+				case 6:
+					fd.Role = protowire.DecodeBool(v)
+				case 7:
+					fd.Sip, err = appendUint32s(fd.Sip, typ, v, m)
+				case 8:
+					fd.Dip, err = appendUint32s(fd.Dip, typ, v, m)
+				case 9:
+					fd.Sport = uint32(v)
+				case 10:
+					fd.Dport = uint32(v)
+				case 11:
+					fd.Source = v
+				case 12:
+					fd.Destination = v
+				}
+				return err
+			})
+		}
+		return nil
+	})
+}
+
+// consumeFields calls fn with each field in b. The value of a varint field is passed in v,
+// and the value of a length-delimited field is passed in m. The other fields are skipped.
+func consumeFields(b []byte, fn func(num protowire.Number, typ protowire.Type, v uint64, m []byte) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		var v uint64
+		var m []byte
+		switch typ {
+		case protowire.VarintType:
+			v, n = protowire.ConsumeVarint(b)
+		case protowire.BytesType:
+			m, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			b = b[n:]
+			continue
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		if err := fn(num, typ, v, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appendUint32s appends the repeated uint32 field, which is either packed or not.
+func appendUint32s(values []uint32, typ protowire.Type, v uint64, m []byte) ([]uint32, error) {
+	if typ == protowire.VarintType {
+		return append(values, uint32(v)), nil
+	}
+	for len(m) > 0 {
+		v, n := protowire.ConsumeVarint(m)
+		if n < 0 {
+			return values, protowire.ParseError(n)
+		}
+		values = append(values, uint32(v))
+		m = m[n:]
+	}
+	return values, nil
+}
+
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,23 @@
+package filereceiver
+
+import "github.com/Kindling-project/kindling/collector/pkg/component/receiver/eventfile"
+
+type Config struct {
+	// Path is the file of the events recorded, e.g. by the record mode of cgoreceiver.
+	// The file compressed with gzip is detected automatically.
+	Path string `mapstructure:"path"`
+	// Format is either "json" for JSON lines or "protobuf" for length-delimited messages.
+	Format eventfile.Format `mapstructure:"format"`
+	// Speed is the ratio of the replay speed to the real time. The events are replayed
+	// in real time if it is 1, faster if it is greater than 1, and as fast as possible if it is 0.
+	Speed float64 `mapstructure:"speed"`
+	// StartTime skips the events before it, in RFC3339 format. All are replayed if it is empty.
+	StartTime string `mapstructure:"start_time"`
+}
+
+func NewDefaultConfig() *Config {
+	return &Config{
+		Format: eventfile.FormatJSON,
+		Speed:  1,
+	}
+}
@@ -0,0 +1,167 @@
+package filereceiver
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component"
+	analyzerpackage "github.com/Kindling-project/kindling/collector/pkg/component/analyzer"
+	"github.com/Kindling-project/kindling/collector/pkg/component/receiver"
+	"github.com/Kindling-project/kindling/collector/pkg/component/receiver/eventfile"
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+)
+
+const Type = "filereceiver"
+
+// FileReceiver replays the events recorded in a file to the analyzers, so the issues can be
+// reproduced without the probe. The events are replayed in the pace they were recorded,
+// scaled by the speed configured.
+type FileReceiver struct {
+	cfg             *Config
+	telemetry       *component.TelemetryTools
+	analyzerManager *analyzerpackage.Manager
+
+	seekCh chan uint64
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+func New(config interface{}, telemetry *component.TelemetryTools, analyzerManager *analyzerpackage.Manager) receiver.Receiver {
+	cfg, ok := config.(*Config)
+	if !ok {
+		telemetry.Logger.Panicf("Cannot convert [%s] config", Type)
+	}
+	return &FileReceiver{
+		cfg:             cfg,
+		telemetry:       telemetry,
+		analyzerManager: analyzerManager,
+		seekCh:          make(chan uint64, 1),
+		stopCh:          make(chan struct{}),
+	}
+}
+
+func (r *FileReceiver) Start() error {
+	if r.cfg.Path == "" {
+		return errors.New("path of filereceiver is required")
+	}
+	if r.cfg.Speed < 0 {
+		return fmt.Errorf("speed of filereceiver must not be negative: %v", r.cfg.Speed)
+	}
+	var from uint64
+	if r.cfg.StartTime != "" {
+		startTime, err := time.Parse(time.RFC3339Nano, r.cfg.StartTime)
+		if err != nil {
+			return fmt.Errorf("invalid start_time of filereceiver: %w", err)
+		}
+		from = uint64(startTime.UnixNano())
+	}
+	reader, err := eventfile.Open(r.cfg.Path, r.cfg.Format)
+	if err != nil {
+		return fmt.Errorf("failed to open the events file: %w", err)
+	}
+	r.telemetry.Logger.Infof("Start FileReceiver to replay %s at speed %v", r.cfg.Path, r.cfg.Speed)
+	r.wg.Add(1)
+	go r.run(reader, from)
+	return nil
+}
+
+func (r *FileReceiver) Shutdown() error {
+	close(r.stopCh)
+	r.wg.Wait()
+	return nil
+}
+
+// Seek replays the events again from the timestamp, either forward or backward.
+// The replay is restarted even if all the events have been replayed.
+func (r *FileReceiver) Seek(timestamp time.Time) {
+	select {
+	case r.seekCh <- uint64(timestamp.UnixNano()):
+	case <-r.stopCh:
+	}
+}
+
+func (r *FileReceiver) run(reader *eventfile.Reader, from uint64) {
+	defer r.wg.Done()
+	for {
+		seekTo, seeked, stopped := r.replay(reader, from)
+		_ = reader.Close()
+		if stopped {
+			return
+		}
+		if !seeked {
+			select {
+			case <-r.stopCh:
+				return
+			case seekTo = <-r.seekCh:
+			}
+		}
+		from = seekTo
+		var err error
+		if reader, err = eventfile.Open(r.cfg.Path, r.cfg.Format); err != nil {
+			r.telemetry.Logger.Error("Failed to reopen the events file", zap.String("path", r.cfg.Path), zap.Error(err))
+			return
+		}
+	}
+}
+
+// replay sends the events from the timestamp until the end of the file. It returns early
+// if Seek or Shutdown is called.
+func (r *FileReceiver) replay(reader *eventfile.Reader, from uint64) (seekTo uint64, seeked bool, stopped bool) {
+	var count int
+	var base uint64
+	var wallStart time.Time
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	<-timer.C
+	for {
+		select {
+		case <-r.stopCh:
+			return 0, false, true
+		case seekTo = <-r.seekCh:
+			return seekTo, true, false
+		default:
+		}
+		evt, err := reader.Read()
+		if err != nil {
+			if err != io.EOF {
+				r.telemetry.Logger.Warn("Stop replaying the events file because of the error", zap.Error(err))
+			}
+			r.telemetry.Logger.Infof("Finished replaying %d events of %s", count, r.cfg.Path)
+			return 0, false, false
+		}
+		if evt.Timestamp < from {
+			continue
+		}
+		if count == 0 {
+			base, wallStart = evt.Timestamp, time.Now()
+		}
+		if r.cfg.Speed > 0 && evt.Timestamp > base {
+			delay := time.Duration(float64(evt.Timestamp-base)/r.cfg.Speed) - time.Since(wallStart)
+			if delay > 0 {
+				timer.Reset(delay)
+				select {
+				case <-r.stopCh:
+					return 0, false, true
+				case seekTo = <-r.seekCh:
+					return seekTo, true, false
+				case <-timer.C:
+				}
+			}
+		}
+		r.sendToNextConsumer(evt)
+		count++
+	}
+}
+
+func (r *FileReceiver) sendToNextConsumer(evt *model.KindlingEvent) {
+	for _, analyzer := range r.analyzerManager.GetConsumableAnalyzers(evt.Name) {
+		if err := analyzer.ConsumeEvent(evt); err != nil {
+			r.telemetry.Logger.Warn("Error sending event to next consumer: ", zap.Error(err))
+		}
+	}
+}
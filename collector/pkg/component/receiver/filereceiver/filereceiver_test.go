@@ -0,0 +1,102 @@
+package filereceiver
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component"
+	"github.com/Kindling-project/kindling/collector/pkg/component/receiver/eventfile"
+	"github.com/Kindling-project/kindling/collector/pkg/component/receiver/internal/testutil"
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+)
+
+var baseTime = time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC)
+
+// writeEvents writes the events one second apart, and an event not consumed by the analyzer.
+func writeEvents(t *testing.T, n int) (string, []uint64) {
+	path := filepath.Join(t.TempDir(), "events.json.gz")
+	w, err := eventfile.Create(path, eventfile.FormatJSON)
+	require.NoError(t, err)
+	var timestamps []uint64
+	for i := 0; i < n; i++ {
+		ts := uint64(baseTime.Add(time.Duration(i) * time.Second).UnixNano())
+		timestamps = append(timestamps, ts)
+		require.NoError(t, w.Write(&model.KindlingEvent{Name: "syscall_exit-read", Timestamp: ts}))
+	}
+	require.NoError(t, w.Write(&model.KindlingEvent{Name: "tracepoint-procexit", Timestamp: timestamps[n-1]}))
+	require.NoError(t, w.Close())
+	return path, timestamps
+}
+
+// receivedTimestamps returns the timestamps of the events received.
+func receivedTimestamps(recorder *testutil.EventRecorder) []uint64 {
+	var ret []uint64
+	for _, evt := range recorder.Events() {
+		ret = append(ret, evt.Timestamp)
+	}
+	return ret
+}
+
+func TestReplayAsFastAsPossible(t *testing.T) {
+	path, timestamps := writeEvents(t, 5)
+	cfg := NewDefaultConfig()
+	cfg.Path = path
+	cfg.Speed = 0
+	cfg.StartTime = baseTime.Add(2 * time.Second).Format(time.RFC3339)
+	a := testutil.NewEventRecorder("syscall_exit-read")
+	r := New(cfg, component.NewDefaultTelemetryTools(), testutil.NewManager(t, a)).(*FileReceiver)
+	require.NoError(t, r.Start())
+	assert.Eventually(t, func() bool { return len(a.Events()) == 3 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, timestamps[2:], receivedTimestamps(a))
+
+	// Seek backward after all the events are replayed.
+	r.Seek(baseTime.Add(time.Second))
+	assert.Eventually(t, func() bool { return len(a.Events()) == 7 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, timestamps[1:], receivedTimestamps(a)[3:])
+	assert.NoError(t, r.Shutdown())
+}
+
+func TestReplayAccelerated(t *testing.T) {
+	path, timestamps := writeEvents(t, 3)
+	cfg := NewDefaultConfig()
+	cfg.Path = path
+	cfg.Speed = 20
+	a := testutil.NewEventRecorder("syscall_exit-read")
+	r := New(cfg, component.NewDefaultTelemetryTools(), testutil.NewManager(t, a))
+	start := time.Now()
+	require.NoError(t, r.Start())
+	assert.Eventually(t, func() bool { return len(a.Events()) == 3 }, 2*time.Second, 5*time.Millisecond)
+	// The events one second apart are replayed 50ms apart.
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+	assert.Equal(t, timestamps, receivedTimestamps(a))
+	assert.NoError(t, r.Shutdown())
+}
+
+func TestShutdownWhileWaiting(t *testing.T) {
+	path, _ := writeEvents(t, 2)
+	cfg := NewDefaultConfig()
+	cfg.Path = path
+	a := testutil.NewEventRecorder("syscall_exit-read")
+	r := New(cfg, component.NewDefaultTelemetryTools(), testutil.NewManager(t, a))
+	require.NoError(t, r.Start())
+	assert.Eventually(t, func() bool { return len(a.Events()) == 1 }, time.Second, 5*time.Millisecond)
+	// The second event is due in one second, but Shutdown returns at once.
+	start := time.Now()
+	assert.NoError(t, r.Shutdown())
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	assert.Len(t, receivedTimestamps(a), 1)
+}
+
+func TestInvalidConfig(t *testing.T) {
+	manager := testutil.NewManager(t, testutil.NewEventRecorder("syscall_exit-read"))
+	r := New(NewDefaultConfig(), component.NewDefaultTelemetryTools(), manager)
+	assert.Error(t, r.Start())
+	cfg := NewDefaultConfig()
+	cfg.Path = filepath.Join(t.TempDir(), "not_exist.json")
+	r = New(cfg, component.NewDefaultTelemetryTools(), manager)
+	assert.Error(t, r.Start())
+}
@@ -0,0 +1,67 @@
+// Package testutil contains the helpers shared by the tests of the receivers.
+package testutil
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component/analyzer"
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+)
+
+// EventRecorder is an analyzer recording the events sent by the receiver under test.
+type EventRecorder struct {
+	// CountOnly makes the recorder count the events without keeping them, for the
+	// receivers generating too many events to keep.
+	CountOnly bool
+	names     []string
+
+	mu     sync.Mutex
+	events []*model.KindlingEvent
+	counts map[string]int
+}
+
+// NewEventRecorder creates an EventRecorder consuming the events with the names.
+func NewEventRecorder(names ...string) *EventRecorder {
+	return &EventRecorder{names: names, counts: make(map[string]int)}
+}
+
+// NewManager returns the analyzer manager passed to the receiver under test.
+func NewManager(t *testing.T, analyzers ...analyzer.Analyzer) *analyzer.Manager {
+	manager, err := analyzer.NewManager(analyzers...)
+	require.NoError(t, err)
+	return manager
+}
+
+func (r *EventRecorder) Start() error        { return nil }
+func (r *EventRecorder) Shutdown() error     { return nil }
+func (r *EventRecorder) Type() analyzer.Type { return "eventrecorder" }
+func (r *EventRecorder) ConsumableEvents() []string {
+	return r.names
+}
+
+func (r *EventRecorder) ConsumeEvent(evt *model.KindlingEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.counts[evt.Name]++
+	if !r.CountOnly {
+		r.events = append(r.events, evt)
+	}
+	return nil
+}
+
+// Events returns the events received so far.
+func (r *EventRecorder) Events() []*model.KindlingEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*model.KindlingEvent(nil), r.events...)
+}
+
+// Count returns the number of the events received with the name.
+func (r *EventRecorder) Count(name string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.counts[name]
+}
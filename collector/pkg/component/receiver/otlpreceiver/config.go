@@ -0,0 +1,22 @@
+package otlpreceiver
+
+const defaultProcRoot = "/proc"
+
+type Config struct {
+	// GrpcEndpoint is the address OTLP/gRPC listens on. OTLP/gRPC is disabled if it is empty.
+	GrpcEndpoint string `mapstructure:"grpc_endpoint"`
+	// HttpEndpoint is the address OTLP/HTTP listens on. OTLP/HTTP is disabled if it is empty.
+	// Only the binary protobuf encoding is accepted.
+	HttpEndpoint string `mapstructure:"http_endpoint"`
+	// ProcRoot is where the proc filesystem of the host is mounted. It is used to map the
+	// pids and the tids in the containers to the ones on the host.
+	ProcRoot string `mapstructure:"proc_root"`
+}
+
+func NewDefaultConfig() *Config {
+	return &Config{
+		GrpcEndpoint: "0.0.0.0:4317",
+		HttpEndpoint: "0.0.0.0:4318",
+		ProcRoot:     defaultProcRoot,
+	}
+}
@@ -0,0 +1,123 @@
+package otlpreceiver
+
+import (
+	"encoding/hex"
+	"strconv"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constnames"
+)
+
+// The attributes of the resource or the span mapping the span to the thread. process.pid
+// and thread.id are the ids in the pid namespace of the application, which are mapped to
+// the ones on the host through container.id.
+const (
+	pidAttribute         = "process.pid"
+	tidAttribute         = "thread.id"
+	threadNameAttribute  = "thread.name"
+	executableAttribute  = "process.executable.name"
+	containerIdAttribute = "container.id"
+)
+
+// threadInfo is the thread a span runs on. The pid and the tid are the ones reported until
+// they are mapped to the host.
+type threadInfo struct {
+	pid         uint32
+	tid         uint32
+	comm        string
+	containerId string
+}
+
+// update overrides the fields with the attributes present.
+func (t *threadInfo) update(attributes []*commonpb.KeyValue) {
+	for _, kv := range attributes {
+		switch kv.Key {
+		case pidAttribute:
+			if v, ok := intValue(kv.Value); ok {
+				t.pid = uint32(v)
+			}
+		case tidAttribute:
+			if v, ok := intValue(kv.Value); ok {
+				t.tid = uint32(v)
+			}
+		case threadNameAttribute:
+			t.comm = kv.Value.GetStringValue()
+		case executableAttribute:
+			// The name of the thread is preferred.
+			if t.comm == "" {
+				t.comm = kv.Value.GetStringValue()
+			}
+		case containerIdAttribute:
+			t.containerId = kv.Value.GetStringValue()
+		}
+	}
+}
+
+// intValue returns the integer of the value, which may be a string for the SDKs that
+// don't support integer attributes.
+func intValue(value *commonpb.AnyValue) (int64, bool) {
+	switch v := value.GetValue().(type) {
+	case *commonpb.AnyValue_IntValue:
+		return v.IntValue, true
+	case *commonpb.AnyValue_StringValue:
+		i, err := strconv.ParseInt(v.StringValue, 10, 64)
+		return i, err == nil
+	}
+	return 0, false
+}
+
+// convertSpans converts the spans to the span events the probe generates. The spans whose
+// pid or tid is unknown are dropped, and so are the ones whose threads are not found on the
+// host. The numbers of them are returned.
+func convertSpans(request *coltracepb.ExportTraceServiceRequest, mapper *pidMapper) (events []*model.KindlingEvent, dropped int, unmapped int) {
+	for _, resourceSpans := range request.ResourceSpans {
+		var resourceThread threadInfo
+		resourceThread.update(resourceSpans.GetResource().GetAttributes())
+		for _, librarySpans := range resourceSpans.InstrumentationLibrarySpans {
+			for _, span := range librarySpans.Spans {
+				thread := resourceThread
+				thread.update(span.Attributes)
+				if thread.pid == 0 || thread.tid == 0 {
+					dropped++
+					continue
+				}
+				var ok bool
+				if thread.pid, thread.tid, ok = mapper.mapThread(thread.containerId, thread.pid, thread.tid); !ok {
+					unmapped++
+					continue
+				}
+				events = append(events, newSpanEvent(span, &thread))
+			}
+		}
+	}
+	return events, dropped, unmapped
+}
+
+// newSpanEvent returns the event in the same format as the span events from the probe,
+// which has the user attributes end_time, trace_id and span.
+func newSpanEvent(span *tracepb.Span, thread *threadInfo) *model.KindlingEvent {
+	evt := &model.KindlingEvent{
+		Source:    model.Source_SYSCALL_EXIT,
+		Timestamp: span.StartTimeUnixNano,
+		Name:      constnames.SpanEvent,
+		Ctx: model.Context{
+			ThreadInfo: model.Thread{
+				Pid:         thread.pid,
+				Tid:         thread.tid,
+				Comm:        thread.comm,
+				ContainerId: thread.containerId,
+			},
+		},
+	}
+	attributes := []model.KeyValue{
+		{Key: "end_time", ValueType: model.ValueType_CHARBUF, Value: []byte(strconv.FormatUint(span.EndTimeUnixNano, 10))},
+		{Key: "trace_id", ValueType: model.ValueType_CHARBUF, Value: []byte(hex.EncodeToString(span.TraceId))},
+		{Key: "span", ValueType: model.ValueType_CHARBUF, Value: []byte(span.Name)},
+	}
+	evt.ParamsNumber = uint16(copy(evt.UserAttributes[:], attributes))
+	return evt
+}
@@ -0,0 +1,203 @@
+package otlpreceiver
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	// Register the gzip compressor used by the OTLP exporters.
+	_ "google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component"
+	analyzerpackage "github.com/Kindling-project/kindling/collector/pkg/component/analyzer"
+	"github.com/Kindling-project/kindling/collector/pkg/component/receiver"
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+)
+
+const Type = "otlpreceiver"
+
+const (
+	tracesPath          = "/v1/traces"
+	protobufContentType = "application/x-protobuf"
+	// maxRequestSize is the maximum size of an OTLP/HTTP request body after decompression.
+	maxRequestSize  = 16 << 20
+	shutdownTimeout = 5 * time.Second
+)
+
+// OtlpReceiver receives the spans from the instrumented applications on the node over
+// OTLP/gRPC and OTLP/HTTP. The spans are mapped to the threads on the host through the
+// attributes process.pid, thread.id and container.id, and sent to the analyzers as the span
+// events from the probe,
+// so the profiles have the exact boundaries of the spans and the records can be correlated
+// to the traces.
+type OtlpReceiver struct {
+	coltracepb.UnimplementedTraceServiceServer
+
+	cfg             *Config
+	telemetry       *component.TelemetryTools
+	analyzerManager *analyzerpackage.Manager
+	pidMapper       *pidMapper
+
+	grpcServer   *grpc.Server
+	grpcListener net.Listener
+	httpServer   *http.Server
+	httpListener net.Listener
+	wg           sync.WaitGroup
+}
+
+func New(config interface{}, telemetry *component.TelemetryTools, analyzerManager *analyzerpackage.Manager) receiver.Receiver {
+	cfg, ok := config.(*Config)
+	if !ok {
+		telemetry.Logger.Panicf("Cannot convert [%s] config", Type)
+	}
+	if cfg.ProcRoot == "" {
+		cfg.ProcRoot = defaultProcRoot
+	}
+	newSelfMetrics(telemetry.MeterProvider)
+	return &OtlpReceiver{
+		cfg:             cfg,
+		telemetry:       telemetry,
+		analyzerManager: analyzerManager,
+		pidMapper:       newPidMapper(cfg.ProcRoot),
+	}
+}
+
+func (r *OtlpReceiver) Start() error {
+	if r.cfg.GrpcEndpoint == "" && r.cfg.HttpEndpoint == "" {
+		return errors.New("either grpc_endpoint or http_endpoint of otlpreceiver is required")
+	}
+	if r.cfg.GrpcEndpoint != "" {
+		listener, err := net.Listen("tcp", r.cfg.GrpcEndpoint)
+		if err != nil {
+			return fmt.Errorf("failed to listen on %s for OTLP/gRPC: %w", r.cfg.GrpcEndpoint, err)
+		}
+		r.grpcListener = listener
+		r.grpcServer = grpc.NewServer(grpc.MaxRecvMsgSize(maxRequestSize))
+		coltracepb.RegisterTraceServiceServer(r.grpcServer, r)
+		r.serve("OTLP/gRPC", listener.Addr(), func() error { return r.grpcServer.Serve(listener) })
+	}
+	if r.cfg.HttpEndpoint != "" {
+		listener, err := net.Listen("tcp", r.cfg.HttpEndpoint)
+		if err != nil {
+			return multierr.Append(fmt.Errorf("failed to listen on %s for OTLP/HTTP: %w", r.cfg.HttpEndpoint, err), r.Shutdown())
+		}
+		r.httpListener = listener
+		mux := http.NewServeMux()
+		mux.HandleFunc(tracesPath, r.handleTraces)
+		r.httpServer = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+		r.serve("OTLP/HTTP", listener.Addr(), func() error { return r.httpServer.Serve(listener) })
+	}
+	return nil
+}
+
+func (r *OtlpReceiver) serve(protocol string, addr net.Addr, serve func() error) {
+	r.telemetry.Logger.Infof("Start OtlpReceiver to receive %s on %s", protocol, addr)
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		if err := serve(); err != nil && !errors.Is(err, http.ErrServerClosed) && !errors.Is(err, grpc.ErrServerStopped) {
+			r.telemetry.Logger.Error("OtlpReceiver stopped", zap.String("protocol", protocol), zap.Error(err))
+		}
+	}()
+}
+
+func (r *OtlpReceiver) Shutdown() error {
+	var retErr error
+	if r.httpServer != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		retErr = r.httpServer.Shutdown(ctx)
+		cancel()
+	}
+	if r.grpcServer != nil {
+		r.grpcServer.GracefulStop()
+	}
+	r.wg.Wait()
+	return retErr
+}
+
+// Export implements the OTLP/gRPC trace service.
+func (r *OtlpReceiver) Export(_ context.Context, request *coltracepb.ExportTraceServiceRequest) (*coltracepb.ExportTraceServiceResponse, error) {
+	r.consumeSpans(request)
+	return &coltracepb.ExportTraceServiceResponse{}, nil
+}
+
+// handleTraces implements OTLP/HTTP with the binary protobuf encoding.
+func (r *OtlpReceiver) handleTraces(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if contentType := req.Header.Get("Content-Type"); contentType != protobufContentType {
+		http.Error(w, fmt.Sprintf("unsupported content type %q, only %s is supported", contentType, protobufContentType),
+			http.StatusUnsupportedMediaType)
+		return
+	}
+	var body io.Reader = req.Body
+	if req.Header.Get("Content-Encoding") == "gzip" {
+		gzipReader, err := gzip.NewReader(req.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer gzipReader.Close()
+		body = gzipReader
+	}
+	data, err := io.ReadAll(io.LimitReader(body, maxRequestSize+1))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(data) > maxRequestSize {
+		http.Error(w, "request too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	request := &coltracepb.ExportTraceServiceRequest{}
+	if err = proto.Unmarshal(data, request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	r.consumeSpans(request)
+	response, _ := proto.Marshal(&coltracepb.ExportTraceServiceResponse{})
+	w.Header().Set("Content-Type", protobufContentType)
+	_, _ = w.Write(response)
+}
+
+func (r *OtlpReceiver) consumeSpans(request *coltracepb.ExportTraceServiceRequest) {
+	events, dropped, unmapped := convertSpans(request, r.pidMapper)
+	for _, evt := range events {
+		r.sendToNextConsumer(evt)
+	}
+	spansCounter.Add(context.Background(), int64(len(events)), attribute.String("result", resultAccepted))
+	if dropped > 0 {
+		spansCounter.Add(context.Background(), int64(dropped), attribute.String("result", resultDropped))
+		if ce := r.telemetry.Logger.Check(zap.DebugLevel, "Drop the spans without process.pid or thread.id"); ce != nil {
+			ce.Write(zap.Int("dropped", dropped))
+		}
+	}
+	if unmapped > 0 {
+		spansCounter.Add(context.Background(), int64(unmapped), attribute.String("result", resultUnmapped))
+		if ce := r.telemetry.Logger.Check(zap.DebugLevel, "Drop the spans whose threads are not found on the host"); ce != nil {
+			ce.Write(zap.Int("unmapped", unmapped))
+		}
+	}
+}
+
+func (r *OtlpReceiver) sendToNextConsumer(evt *model.KindlingEvent) {
+	for _, analyzer := range r.analyzerManager.GetConsumableAnalyzers(evt.Name) {
+		if err := analyzer.ConsumeEvent(evt); err != nil {
+			r.telemetry.Logger.Warn("Error sending event to next consumer: ", zap.Error(err))
+		}
+	}
+}
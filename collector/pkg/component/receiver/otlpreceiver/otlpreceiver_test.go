@@ -0,0 +1,165 @@
+package otlpreceiver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component"
+	"github.com/Kindling-project/kindling/collector/pkg/component/receiver/internal/testutil"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constnames"
+)
+
+func stringAttribute(key string, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}}}
+}
+
+func intAttribute(key string, value int64) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: value}}}
+}
+
+// newRequest returns the spans of a process: one on the thread 101, one on the thread
+// given as a string, and one without thread.id.
+func newRequest() *coltracepb.ExportTraceServiceRequest {
+	traceId := []byte{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36}
+	return &coltracepb.ExportTraceServiceRequest{
+		ResourceSpans: []*tracepb.ResourceSpans{{
+			Resource: &resourcepb.Resource{Attributes: []*commonpb.KeyValue{
+				intAttribute(pidAttribute, 100),
+				stringAttribute(executableAttribute, "java"),
+				stringAttribute(containerIdAttribute, "abc"),
+			}},
+			InstrumentationLibrarySpans: []*tracepb.InstrumentationLibrarySpans{{
+				Spans: []*tracepb.Span{
+					{
+						TraceId: traceId, Name: "GET /orders", StartTimeUnixNano: 1000, EndTimeUnixNano: 2000,
+						Attributes: []*commonpb.KeyValue{intAttribute(tidAttribute, 101), stringAttribute(threadNameAttribute, "http-nio-1")},
+					},
+					{
+						TraceId: traceId, Name: "SELECT", StartTimeUnixNano: 1200, EndTimeUnixNano: 1500,
+						Attributes: []*commonpb.KeyValue{stringAttribute(tidAttribute, "102")},
+					},
+					{TraceId: traceId, Name: "async", StartTimeUnixNano: 1300, EndTimeUnixNano: 1400},
+				},
+			}},
+		}},
+	}
+}
+
+func TestConvertSpans(t *testing.T) {
+	mapper := newPidMapper(newFakeProc(t))
+	events, dropped, unmapped := convertSpans(newRequest(), mapper)
+	assert.Equal(t, 1, dropped)
+	assert.Equal(t, 0, unmapped)
+	require.Len(t, events, 2)
+
+	evt := events[0]
+	assert.Equal(t, constnames.SpanEvent, evt.Name)
+	assert.Equal(t, uint64(1000), evt.Timestamp)
+	assert.Equal(t, uint32(2100), evt.GetPid())
+	assert.Equal(t, uint32(2101), evt.GetTid())
+	assert.Equal(t, "http-nio-1", evt.GetComm())
+	assert.Equal(t, "abc", evt.GetContainerId())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", evt.GetStringUserAttribute("trace_id"))
+	assert.Equal(t, "GET /orders", evt.GetStringUserAttribute("span"))
+	assert.Equal(t, strconv.Itoa(2000), evt.GetStringUserAttribute("end_time"))
+
+	assert.Equal(t, uint32(2102), events[1].GetTid())
+	assert.Equal(t, "java", events[1].GetComm())
+
+	// The spans are dropped if their threads are not found on the host.
+	request := newRequest()
+	request.ResourceSpans[0].Resource.Attributes[2] = stringAttribute(containerIdAttribute, "unknown")
+	events, dropped, unmapped = convertSpans(request, mapper)
+	assert.Empty(t, events)
+	assert.Equal(t, 1, dropped)
+	assert.Equal(t, 2, unmapped)
+}
+
+// startReceiver starts the receiver and shuts it down when the test finishes.
+func startReceiver(t *testing.T, cfg *Config, recorder *testutil.EventRecorder) *OtlpReceiver {
+	r := New(cfg, component.NewDefaultTelemetryTools(), testutil.NewManager(t, recorder)).(*OtlpReceiver)
+	require.NoError(t, r.Start())
+	t.Cleanup(func() { assert.NoError(t, r.Shutdown()) })
+	return r
+}
+
+func TestGrpc(t *testing.T) {
+	a := testutil.NewEventRecorder(constnames.SpanEvent)
+	r := startReceiver(t, &Config{GrpcEndpoint: "127.0.0.1:0", ProcRoot: newFakeProc(t)}, a)
+	conn, err := grpc.Dial(r.grpcListener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = coltracepb.NewTraceServiceClient(conn).Export(ctx, newRequest())
+	require.NoError(t, err)
+	assert.Len(t, a.Events(), 2)
+}
+
+func TestHttp(t *testing.T) {
+	a := testutil.NewEventRecorder(constnames.SpanEvent)
+	r := startReceiver(t, &Config{HttpEndpoint: "127.0.0.1:0", ProcRoot: newFakeProc(t)}, a)
+	url := "http://" + r.httpListener.Addr().String() + tracesPath
+	data, err := proto.Marshal(newRequest())
+	require.NoError(t, err)
+	// The connections are closed before the receiver is shut down, otherwise the one dialed
+	// but not used yet delays the shutdown until it times out.
+	client := &http.Client{Transport: &http.Transport{}}
+	defer client.CloseIdleConnections()
+
+	resp, err := client.Post(url, protobufContentType, bytes.NewReader(data))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Len(t, a.Events(), 2)
+
+	var compressed bytes.Buffer
+	gzipWriter := gzip.NewWriter(&compressed)
+	_, _ = gzipWriter.Write(data)
+	require.NoError(t, gzipWriter.Close())
+	req, _ := http.NewRequest(http.MethodPost, url, &compressed)
+	req.Header.Set("Content-Type", protobufContentType)
+	req.Header.Set("Content-Encoding", "gzip")
+	resp, err = client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Len(t, a.Events(), 4)
+
+	resp, err = client.Post(url, "application/json", bytes.NewReader([]byte("{}")))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+	resp, err = client.Post(url, protobufContentType, bytes.NewReader([]byte{0xff}))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestInvalidConfig(t *testing.T) {
+	recorder := testutil.NewEventRecorder(constnames.SpanEvent)
+	manager := testutil.NewManager(t, recorder)
+	r := New(&Config{}, component.NewDefaultTelemetryTools(), manager)
+	assert.Error(t, r.Start())
+
+	// The gRPC server is stopped if OTLP/HTTP fails to listen.
+	used := startReceiver(t, &Config{HttpEndpoint: "127.0.0.1:0"}, recorder)
+	r = New(&Config{GrpcEndpoint: "127.0.0.1:0", HttpEndpoint: used.httpListener.Addr().String()},
+		component.NewDefaultTelemetryTools(), manager)
+	assert.Error(t, r.Start())
+}
@@ -0,0 +1,164 @@
+package otlpreceiver
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// mappingTTL is how long a mapping or a failed lookup is cached. The pids are reused
+	// after the processes exit, so the mappings are checked again after it.
+	mappingTTL = 30 * time.Second
+	// maxCachedMappings bounds the cache in case the spans carry random ids.
+	maxCachedMappings = 65536
+)
+
+// nsKey is a pid or a tid in the pid namespace of a container or a process.
+type nsKey struct {
+	// scope is the container id of a pid, or the host pid of the process of a tid.
+	scope string
+	id    uint32
+}
+
+type mapping struct {
+	// hostId is 0 if the id can't be mapped.
+	hostId   uint32
+	expireAt time.Time
+}
+
+// pidMapper maps the pids and the tids the applications report, which are in the pid
+// namespaces of their containers, to the ones on the host carried by the events from
+// the probe. The processes are found by the container ids in /proc/<pid>/cgroup, and
+// the ids in the namespaces are read from the NSpid of /proc/<pid>/status.
+type pidMapper struct {
+	procRoot string
+	now      func() time.Time
+
+	mu       sync.Mutex
+	mappings map[nsKey]mapping
+}
+
+func newPidMapper(procRoot string) *pidMapper {
+	return &pidMapper{
+		procRoot: procRoot,
+		now:      time.Now,
+		mappings: make(map[nsKey]mapping),
+	}
+}
+
+// mapThread returns the host pid and the host tid of the thread. The pid is the one on the
+// host if containerId is empty. False is returned if the process or the thread is not found,
+// e.g. the SDK reports the id of the thread in the language runtime rather than the kernel.
+func (m *pidMapper) mapThread(containerId string, pid uint32, tid uint32) (uint32, uint32, bool) {
+	hostPid, ok := m.mapPid(containerId, pid)
+	if !ok {
+		return 0, 0, false
+	}
+	hostTid, ok := m.lookup(nsKey{scope: strconv.FormatUint(uint64(hostPid), 10), id: tid}, func() uint32 {
+		return m.findTask(hostPid, tid)
+	})
+	return hostPid, hostTid, ok
+}
+
+func (m *pidMapper) mapPid(containerId string, pid uint32) (uint32, bool) {
+	if containerId == "" {
+		// The process runs on the host.
+		if _, err := os.Stat(filepath.Join(m.procRoot, strconv.FormatUint(uint64(pid), 10))); err != nil {
+			return 0, false
+		}
+		return pid, true
+	}
+	return m.lookup(nsKey{scope: containerId, id: pid}, func() uint32 {
+		return m.findProcess(containerId, pid)
+	})
+}
+
+// lookup returns the cached mapping of the key, or caches the one found by find.
+func (m *pidMapper) lookup(key nsKey, find func() uint32) (uint32, bool) {
+	now := m.now()
+	m.mu.Lock()
+	cached, ok := m.mappings[key]
+	m.mu.Unlock()
+	if ok && now.Before(cached.expireAt) {
+		return cached.hostId, cached.hostId != 0
+	}
+	hostId := find()
+	m.mu.Lock()
+	if len(m.mappings) >= maxCachedMappings {
+		m.mappings = make(map[nsKey]mapping)
+	}
+	m.mappings[key] = mapping{hostId: hostId, expireAt: now.Add(mappingTTL)}
+	m.mu.Unlock()
+	return hostId, hostId != 0
+}
+
+// findProcess scans the processes for the one of the container whose pid in the container
+// is pid. It returns 0 if not found.
+func (m *pidMapper) findProcess(containerId string, pid uint32) uint32 {
+	entries, err := os.ReadDir(m.procRoot)
+	if err != nil {
+		return 0
+	}
+	for _, entry := range entries {
+		hostPid, err := strconv.ParseUint(entry.Name(), 10, 32)
+		if err != nil {
+			continue
+		}
+		dir := filepath.Join(m.procRoot, entry.Name())
+		if nsId, ok := readNsId(dir); !ok || nsId != pid {
+			continue
+		}
+		cgroup, err := os.ReadFile(filepath.Join(dir, "cgroup"))
+		if err == nil && bytes.Contains(cgroup, []byte(containerId)) {
+			return uint32(hostPid)
+		}
+	}
+	return 0
+}
+
+// findTask returns the host tid of the thread of the process whose tid in the namespace
+// of the process is tid. It returns 0 if not found.
+func (m *pidMapper) findTask(hostPid uint32, tid uint32) uint32 {
+	taskRoot := filepath.Join(m.procRoot, strconv.FormatUint(uint64(hostPid), 10), "task")
+	entries, err := os.ReadDir(taskRoot)
+	if err != nil {
+		return 0
+	}
+	for _, entry := range entries {
+		hostTid, err := strconv.ParseUint(entry.Name(), 10, 32)
+		if err != nil {
+			continue
+		}
+		if nsId, ok := readNsId(filepath.Join(taskRoot, entry.Name())); ok && nsId == tid {
+			return uint32(hostTid)
+		}
+	}
+	return 0
+}
+
+// readNsId returns the id of the process or the thread in its innermost pid namespace,
+// which is the last field of NSpid in the status.
+func readNsId(dir string) (uint32, bool) {
+	f, err := os.Open(filepath.Join(dir, "status"))
+	if err != nil {
+		return 0, false
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "NSpid:") {
+			continue
+		}
+		fields := strings.Fields(line)
+		id, err := strconv.ParseUint(fields[len(fields)-1], 10, 32)
+		return uint32(id), err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,90 @@
+package otlpreceiver
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTask writes the status of the thread, whose ids are from the host to the innermost
+// pid namespace.
+func writeTask(t *testing.T, root string, pid int, tid int, nsIds ...int) {
+	nsPid := "NSpid:"
+	for _, id := range append([]int{tid}, nsIds...) {
+		nsPid += "\t" + strconv.Itoa(id)
+	}
+	dir := filepath.Join(root, strconv.Itoa(pid), "task", strconv.Itoa(tid))
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	status := "Name:\tjava\nTgid:\t" + strconv.Itoa(pid) + "\n" + nsPid + "\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "status"), []byte(status), 0644))
+	if pid == tid {
+		require.NoError(t, os.WriteFile(filepath.Join(root, strconv.Itoa(pid), "status"), []byte(status), 0644))
+	}
+}
+
+func writeCgroup(t *testing.T, root string, pid int, cgroup string) {
+	require.NoError(t, os.WriteFile(filepath.Join(root, strconv.Itoa(pid), "cgroup"), []byte(cgroup), 0644))
+}
+
+// newFakeProc creates a proc filesystem where the process 100 of the container "abc" is the
+// process 2100 on the host, whose threads 101 and 102 are 2101 and 2102. The process 100 of
+// the container "def" is 3100, and the process 500 runs on the host.
+func newFakeProc(t *testing.T) string {
+	root := t.TempDir()
+	writeTask(t, root, 1, 1)
+	writeCgroup(t, root, 1, "0::/init.scope\n")
+	for _, tid := range []int{2100, 2101, 2102} {
+		writeTask(t, root, 2100, tid, tid-2000)
+	}
+	writeCgroup(t, root, 2100, "0::/kubepods/burstable/pod1/cri-containerd-abc.scope\n")
+	writeTask(t, root, 3100, 3100, 100)
+	writeCgroup(t, root, 3100, "0::/kubepods/burstable/pod2/cri-containerd-def.scope\n")
+	writeTask(t, root, 500, 500)
+	writeTask(t, root, 500, 501)
+	writeCgroup(t, root, 500, "0::/system.slice/app.service\n")
+	return root
+}
+
+func TestPidMapper(t *testing.T) {
+	root := newFakeProc(t)
+	m := newPidMapper(root)
+	pid, tid, ok := m.mapThread("abc", 100, 101)
+	assert.True(t, ok)
+	assert.Equal(t, uint32(2100), pid)
+	assert.Equal(t, uint32(2101), tid)
+	pid, tid, ok = m.mapThread("def", 100, 100)
+	assert.True(t, ok)
+	assert.Equal(t, uint32(3100), pid)
+	assert.Equal(t, uint32(3100), tid)
+	// The ids of the processes on the host are kept.
+	pid, tid, ok = m.mapThread("", 500, 501)
+	assert.True(t, ok)
+	assert.Equal(t, uint32(500), pid)
+	assert.Equal(t, uint32(501), tid)
+
+	// The threads not found, e.g. the ids of the Java threads, are not mapped.
+	_, _, ok = m.mapThread("abc", 100, 25)
+	assert.False(t, ok)
+	_, _, ok = m.mapThread("abc", 1, 1)
+	assert.False(t, ok)
+	_, _, ok = m.mapThread("", 600, 600)
+	assert.False(t, ok)
+
+	// The failed lookups are cached until they expire.
+	now := time.Now()
+	m.now = func() time.Time { return now }
+	_, _, ok = m.mapThread("abc", 100, 103)
+	assert.False(t, ok)
+	writeTask(t, root, 2100, 2103, 103)
+	_, _, ok = m.mapThread("abc", 100, 103)
+	assert.False(t, ok)
+	now = now.Add(mappingTTL)
+	_, tid, ok = m.mapThread("abc", 100, 103)
+	assert.True(t, ok)
+	assert.Equal(t, uint32(2103), tid)
+}
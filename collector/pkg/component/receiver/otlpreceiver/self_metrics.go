@@ -0,0 +1,31 @@
+package otlpreceiver
+
+import (
+	"sync"
+
+	"go.opentelemetry.io/otel/metric"
+)
+
+const spansReceivedMetric = "kindling_telemetry_otlpreceiver_spans_total"
+
+// The results of the spans received, which are the values of the label "result".
+const (
+	resultAccepted = "accepted"
+	// resultDropped means the span is dropped because the pid or the tid is unknown.
+	resultDropped = "dropped"
+	// resultUnmapped means the span is dropped because its thread is not found on the host,
+	// e.g. container.id is missing or thread.id is not the id of the kernel thread.
+	resultUnmapped = "unmapped"
+)
+
+var once sync.Once
+
+var spansCounter metric.Int64Counter
+
+func newSelfMetrics(meterProvider metric.MeterProvider) {
+	once.Do(func() {
+		meter := metric.Must(meterProvider.Meter("kindling"))
+		spansCounter = meter.NewInt64Counter(spansReceivedMetric,
+			metric.WithDescription("The total number of the spans received by otlpreceiver"))
+	})
+}
@@ -0,0 +1,120 @@
+package syntheticreceiver
+
+import "time"
+
+// The protocols of the scenarios.
+const (
+	ProtocolHttp  = "http"
+	ProtocolMysql = "mysql"
+	ProtocolRedis = "redis"
+	ProtocolKafka = "kafka"
+)
+
+// The roles of the process observed in the scenarios.
+const (
+	RoleServer = "server"
+	RoleClient = "client"
+)
+
+// The latency distributions.
+const (
+	DistributionConstant    = "constant"
+	DistributionUniform     = "uniform"
+	DistributionNormal      = "normal"
+	DistributionExponential = "exponential"
+)
+
+type Config struct {
+	// Duration stops generating the events after it. The events are generated until
+	// shutdown if it is 0.
+	Duration time.Duration `mapstructure:"duration"`
+	// Seed makes the events generated reproducible. A random seed is used if it is 0.
+	Seed int64 `mapstructure:"seed"`
+	// Scenarios are the traffic generated at the same time.
+	Scenarios []ScenarioConfig `mapstructure:"scenarios"`
+	// ScenarioFiles are the YAML files containing more scenarios under the key "scenarios".
+	ScenarioFiles []string `mapstructure:"scenario_files"`
+}
+
+// ScenarioConfig is the traffic between a group of clients and a server.
+type ScenarioConfig struct {
+	Name     string `mapstructure:"name"`
+	Protocol string `mapstructure:"protocol"`
+	// Role is the side observed, either "server" or "client". The server receives the
+	// requests with read and sends the responses with write; the client does the opposite.
+	Role string `mapstructure:"role"`
+	// Rate is the number of requests per second. They are generated as fast as possible if it is 0.
+	Rate float64 `mapstructure:"rate"`
+
+	Pid         uint32 `mapstructure:"pid"`
+	Comm        string `mapstructure:"comm"`
+	ContainerId string `mapstructure:"container_id"`
+	ClientIp    string `mapstructure:"client_ip"`
+	ServerIp    string `mapstructure:"server_ip"`
+	ServerPort  uint32 `mapstructure:"server_port"`
+
+	// Connections is the number of connections used in turn.
+	Connections int `mapstructure:"connections"`
+	// RequestsPerConnection is the number of requests before a connection is closed and
+	// reconnected with a new client port. The connections are never closed if it is 0.
+	RequestsPerConnection int `mapstructure:"requests_per_connection"`
+	// ConnectEvents controls whether the tcp_connect, connect, tcp_set_state and tcp_close
+	// events are generated when the connections are opened and closed.
+	ConnectEvents bool `mapstructure:"connect_events"`
+
+	Latency LatencyConfig `mapstructure:"latency"`
+	// ErrorRate is the probability from 0 to 1 of responding with ErrorResponse.
+	ErrorRate float64 `mapstructure:"error_rate"`
+	// SplitRate is the probability from 0 to 1 of splitting a payload into two events.
+	SplitRate float64 `mapstructure:"split_rate"`
+
+	// Request, Response and ErrorResponse are the payloads in the format of the files
+	// under protocol/testdata, e.g. "hex|14000000" or "03|SELECT 1". The placeholder
+	// ${seq} is replaced by the sequence number of the request. The defaults of the
+	// protocol are used if they are empty.
+	Request       []string `mapstructure:"request"`
+	Response      []string `mapstructure:"response"`
+	ErrorResponse []string `mapstructure:"error_response"`
+}
+
+// LatencyConfig is the distribution of the time between a request and its response.
+type LatencyConfig struct {
+	Distribution string        `mapstructure:"distribution"`
+	Mean         time.Duration `mapstructure:"mean"`
+	// Stddev is only used by the normal distribution.
+	Stddev time.Duration `mapstructure:"stddev"`
+	// Min and Max bound the latency. They are the range of the uniform distribution.
+	// Max is unbounded if it is 0.
+	Min time.Duration `mapstructure:"min"`
+	Max time.Duration `mapstructure:"max"`
+}
+
+func NewDefaultConfig() *Config {
+	return &Config{
+		Duration: 0,
+		Seed:     0,
+		Scenarios: []ScenarioConfig{
+			{
+				Name:                  "http-server",
+				Protocol:              ProtocolHttp,
+				Role:                  RoleServer,
+				Rate:                  100,
+				Pid:                   10000,
+				Comm:                  "synthetic",
+				ClientIp:              "10.0.0.1",
+				ServerIp:              "10.0.0.2",
+				ServerPort:            8080,
+				Connections:           10,
+				RequestsPerConnection: 100,
+				ConnectEvents:         true,
+				Latency: LatencyConfig{
+					Distribution: DistributionExponential,
+					Mean:         20 * time.Millisecond,
+					Max:          2 * time.Second,
+				},
+				ErrorRate: 0.01,
+				SplitRate: 0.1,
+			},
+		},
+	}
+}
@@ -0,0 +1,142 @@
+package syntheticreceiver
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const seqPlaceholder = "${seq}"
+
+// payload is a payload template parsed.
+type payload struct {
+	data []byte
+	// hasSeq is true if the data contains the placeholder.
+	hasSeq bool
+}
+
+func newPayload(lines []string) (*payload, error) {
+	data, err := parsePayload(lines)
+	if err != nil {
+		return nil, err
+	}
+	return &payload{data: data, hasSeq: bytes.Contains(data, []byte(seqPlaceholder))}, nil
+}
+
+// render returns the data with the placeholder replaced by the sequence number.
+func (p *payload) render(seq uint64) []byte {
+	if !p.hasSeq {
+		return p.data
+	}
+	return bytes.ReplaceAll(p.data, []byte(seqPlaceholder), []byte(strconv.FormatUint(seq, 10)))
+}
+
+// parsePayload converts the lines in the format of the files under protocol/testdata to bytes.
+//
+// There are the following formats supported:
+//  1. {hex number}|{string}
+//     The first part is a number in hexadecimal, e.g. the length of the string.
+//  2. hex|{hex value}
+//  3. string|{string value}
+//  4. {string value}
+//     If there is no separator "|", the line is a string.
+func parsePayload(lines []string) ([]byte, error) {
+	data := make([]byte, 0)
+	for _, line := range lines {
+		index := strings.Index(line, "|")
+		if index <= 0 {
+			data = append(data, line...)
+			continue
+		}
+		prefix := strings.TrimSpace(line[:index])
+		suffix := strings.TrimSpace(line[index+1:])
+		switch prefix {
+		case "hex":
+			value, err := hex.DecodeString(suffix)
+			if err != nil {
+				return nil, fmt.Errorf("invalid hex value %q: %w", suffix, err)
+			}
+			data = append(data, value...)
+		case "string":
+			data = append(data, suffix...)
+		default:
+			value, err := hex.DecodeString(prefix)
+			if err != nil {
+				return nil, fmt.Errorf("invalid hex prefix %q: %w", prefix, err)
+			}
+			data = append(data, value...)
+			data = append(data, suffix...)
+		}
+	}
+	return data, nil
+}
+
+// protocolDefaults are the default server port and payloads of the protocols.
+type protocolDefaults struct {
+	port          uint32
+	request       []string
+	response      []string
+	errorResponse []string
+}
+
+var defaults = map[string]protocolDefaults{
+	ProtocolHttp: {
+		port: 80,
+		request: []string{
+			"GET /api/orders/${seq} HTTP/1.1\r\n",
+			"Host: synthetic\r\n",
+			"User-Agent: kindling-synthetic\r\n\r\n",
+		},
+		response: []string{
+			"HTTP/1.1 200 OK\r\n",
+			"Content-Type: application/json\r\n",
+			"Content-Length: 11\r\n\r\n",
+			"{\"ok\":true}",
+		},
+		errorResponse: []string{
+			"HTTP/1.1 500 Internal Server Error\r\n",
+			"Content-Length: 0\r\n\r\n",
+		},
+	},
+	ProtocolMysql: {
+		port: 3306,
+		// COM_QUERY "SELECT * FROM dummy"
+		request: []string{"hex|14000000", "03|SELECT * FROM dummy"},
+		// OK packet
+		response: []string{"hex|0700000100000002000000"},
+		// ERR packet 1146 with the SQL state 42S02
+		errorResponse: []string{"hex|27000001ff7a04", "string|#42S02Table 'db.dummy' doesn't exist"},
+	},
+	ProtocolRedis: {
+		port:          6379,
+		request:       []string{"*2\r\n$3\r\nget\r\n$3\r\nkey\r\n"},
+		response:      []string{"$3\r\nabc\r\n"},
+		errorResponse: []string{"-ERR unknown command\r\n"},
+	},
+	ProtocolKafka: {
+		port: 9092,
+		// Produce v7 to the topic "container-monitor"
+		request: []string{
+			"hex|0000004c0000000700000040",
+			"0007|rdkafka",
+			"hex|ffff00010000753000000001",
+			"0011|container-monitor",
+			"hex|00000001000000000000004f00000000000000000000004300000000",
+		},
+		response: []string{
+			"hex|0000004100000040",
+			"hex|00000001",
+			"0011|container-monitor",
+			"hex|000000010000000000000000000000000175ffffffffffffffff000000000000000000000000",
+		},
+		// The error code 6 is NOT_LEADER_FOR_PARTITION.
+		errorResponse: []string{
+			"hex|0000004100000040",
+			"hex|00000001",
+			"0011|container-monitor",
+			"hex|000000010000000000060000000000000175ffffffffffffffff000000000000000000000000",
+		},
+	},
+}
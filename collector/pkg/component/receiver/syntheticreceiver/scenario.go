@@ -0,0 +1,348 @@
+package syntheticreceiver
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constnames"
+)
+
+const (
+	// syscallLatency is the latency of the read and write syscalls generated.
+	syscallLatency = 5 * time.Microsecond
+	// splitGap is the time between the two events of a payload split.
+	splitGap = 10 * time.Microsecond
+	// connectGap is the time between the events of a connection opened or closed.
+	connectGap = 20 * time.Microsecond
+	// tcpRtt is the smoothed rtt in microseconds reported by tcp_close.
+	tcpRtt = 100
+	// firstClientPort is where the client ports start from. They wrap around at 65535.
+	firstClientPort = 30000
+	// firstFd is the fd number of the first connection.
+	firstFd = 10
+	// defaultLatency is the constant latency if the latency is not configured.
+	defaultLatency = 10 * time.Millisecond
+)
+
+// The TCP states used by tcp_set_state.
+const (
+	tcpEstablished = 1
+	tcpSynSent     = 2
+	tcpSynRecv     = 3
+	tcpFinWait1    = 4
+	tcpCloseWait   = 8
+)
+
+type connection struct {
+	fd    int32
+	tid   uint32
+	sport uint32
+	open  bool
+	// requests is the number of requests sent through the connection since it is opened.
+	requests int
+	// busyUntil is when the last response of the connection ends, so the next request
+	// on the connection starts after it.
+	busyUntil uint64
+}
+
+// scenario generates the events of the requests and the connections of a ScenarioConfig.
+// It is not safe for concurrent use.
+type scenario struct {
+	cfg *ScenarioConfig
+	rnd *rand.Rand
+
+	clientIp     uint32
+	serverIp     uint32
+	serverPort   uint32
+	server       bool
+	requestName  string
+	responseName string
+
+	request       *payload
+	response      *payload
+	errorResponse *payload
+
+	conns    []*connection
+	next     int
+	nextPort uint32
+	seq      uint64
+}
+
+func newScenario(cfg *ScenarioConfig, seed int64) (*scenario, error) {
+	d, ok := defaults[cfg.Protocol]
+	if !ok {
+		return nil, fmt.Errorf("unsupported protocol %q, expected %q, %q, %q or %q",
+			cfg.Protocol, ProtocolHttp, ProtocolMysql, ProtocolRedis, ProtocolKafka)
+	}
+	s := &scenario{
+		cfg:        cfg,
+		rnd:        rand.New(rand.NewSource(seed)),
+		serverPort: cfg.ServerPort,
+		nextPort:   firstClientPort,
+	}
+	switch cfg.Role {
+	case RoleServer, "":
+		s.server = true
+		s.requestName, s.responseName = constnames.ReadEvent, constnames.WriteEvent
+	case RoleClient:
+		s.requestName, s.responseName = constnames.WriteEvent, constnames.ReadEvent
+	default:
+		return nil, fmt.Errorf("unsupported role %q, expected %q or %q", cfg.Role, RoleServer, RoleClient)
+	}
+	if s.serverPort == 0 {
+		s.serverPort = d.port
+	}
+	var err error
+	if s.clientIp, err = ipToLong(cfg.ClientIp, "10.0.0.1"); err != nil {
+		return nil, err
+	}
+	if s.serverIp, err = ipToLong(cfg.ServerIp, "10.0.0.2"); err != nil {
+		return nil, err
+	}
+	if cfg.Rate < 0 || cfg.ErrorRate < 0 || cfg.ErrorRate > 1 || cfg.SplitRate < 0 || cfg.SplitRate > 1 {
+		return nil, fmt.Errorf("rate must not be negative and error_rate and split_rate must be between 0 and 1")
+	}
+	if cfg.Latency == (LatencyConfig{}) {
+		cfg.Latency.Mean = defaultLatency
+	}
+	if err = validateLatency(&cfg.Latency); err != nil {
+		return nil, err
+	}
+	if s.request, err = newPayloadOrDefault(cfg.Request, d.request); err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+	if s.response, err = newPayloadOrDefault(cfg.Response, d.response); err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+	if s.errorResponse, err = newPayloadOrDefault(cfg.ErrorResponse, d.errorResponse); err != nil {
+		return nil, fmt.Errorf("invalid error_response: %w", err)
+	}
+	connections := cfg.Connections
+	if connections <= 0 {
+		connections = 1
+	}
+	for i := 0; i < connections; i++ {
+		s.conns = append(s.conns, &connection{fd: int32(firstFd + i), tid: cfg.Pid + uint32(i) + 1})
+	}
+	return s, nil
+}
+
+func newPayloadOrDefault(lines []string, defaultLines []string) (*payload, error) {
+	if len(lines) == 0 {
+		lines = defaultLines
+	}
+	return newPayload(lines)
+}
+
+func validateLatency(cfg *LatencyConfig) error {
+	switch cfg.Distribution {
+	case DistributionConstant, DistributionUniform, DistributionNormal, DistributionExponential, "":
+	default:
+		return fmt.Errorf("unsupported latency distribution %q", cfg.Distribution)
+	}
+	if cfg.Mean < 0 || cfg.Stddev < 0 || cfg.Min < 0 || cfg.Max < 0 || (cfg.Max > 0 && cfg.Max < cfg.Min) {
+		return fmt.Errorf("invalid latency: mean %v, stddev %v, min %v, max %v", cfg.Mean, cfg.Stddev, cfg.Min, cfg.Max)
+	}
+	return nil
+}
+
+// ipToLong converts the IPv4 address to the integer in the fd of KindlingEvent.
+func ipToLong(s string, defaultIp string) (uint32, error) {
+	if s == "" {
+		s = defaultIp
+	}
+	ip := net.ParseIP(s).To4()
+	if ip == nil {
+		return 0, fmt.Errorf("invalid IPv4 address %q", s)
+	}
+	return uint32(ip[0]) | uint32(ip[1])<<8 | uint32(ip[2])<<16 | uint32(ip[3])<<24, nil
+}
+
+// latency returns a latency sampled from the distribution.
+func (s *scenario) latency() time.Duration {
+	cfg := &s.cfg.Latency
+	var latency float64
+	switch cfg.Distribution {
+	case DistributionUniform:
+		latency = float64(cfg.Min) + s.rnd.Float64()*float64(cfg.Max-cfg.Min)
+	case DistributionNormal:
+		latency = float64(cfg.Mean) + s.rnd.NormFloat64()*float64(cfg.Stddev)
+	case DistributionExponential:
+		latency = s.rnd.ExpFloat64() * float64(cfg.Mean)
+	default:
+		latency = float64(cfg.Mean)
+	}
+	latency = math.Max(latency, float64(cfg.Min))
+	if cfg.Max > 0 {
+		latency = math.Min(latency, float64(cfg.Max))
+	}
+	return time.Duration(latency)
+}
+
+// generate emits the events of a request and its response starting from now, as well as
+// the events of the connection opened or closed. The events of a connection are emitted in
+// the order of their timestamps, but the response may be later than now.
+func (s *scenario) generate(now time.Time, emit func(evt *model.KindlingEvent)) {
+	conn := s.conns[s.next]
+	s.next = (s.next + 1) % len(s.conns)
+	ts := uint64(now.UnixNano())
+	if ts < conn.busyUntil {
+		ts = conn.busyUntil
+	}
+	if !conn.open {
+		ts = s.open(conn, ts, emit)
+	}
+	s.seq++
+	ts = s.emitPayload(conn, ts, s.requestName, s.request.render(s.seq), emit)
+	response := s.response
+	if s.cfg.ErrorRate > 0 && s.rnd.Float64() < s.cfg.ErrorRate {
+		response = s.errorResponse
+	}
+	ts = s.emitPayload(conn, ts+uint64(s.latency()), s.responseName, response.render(s.seq), emit)
+	conn.busyUntil = ts
+	conn.requests++
+	if s.cfg.RequestsPerConnection > 0 && conn.requests >= s.cfg.RequestsPerConnection {
+		conn.busyUntil = s.close(conn, ts+uint64(connectGap), emit)
+	}
+}
+
+// emitPayload emits the payload at ts, split into two events by chance. It returns the
+// timestamp of the last event.
+func (s *scenario) emitPayload(conn *connection, ts uint64, name string, data []byte, emit func(evt *model.KindlingEvent)) uint64 {
+	if len(data) > 1 && s.cfg.SplitRate > 0 && s.rnd.Float64() < s.cfg.SplitRate {
+		half := len(data) / 2
+		emit(s.newSyscallEvent(conn, ts, name, data[:half]))
+		ts += uint64(splitGap)
+		data = data[half:]
+	}
+	emit(s.newSyscallEvent(conn, ts, name, data))
+	return ts
+}
+
+// open emits the events of the connection established and returns the timestamp after them.
+func (s *scenario) open(conn *connection, ts uint64, emit func(evt *model.KindlingEvent)) uint64 {
+	conn.sport = s.nextPort
+	if s.nextPort++; s.nextPort > math.MaxUint16 {
+		s.nextPort = firstClientPort
+	}
+	conn.open = true
+	conn.requests = 0
+	if !s.cfg.ConnectEvents {
+		return ts
+	}
+	if s.server {
+		emit(s.newTcpEvent(conn, ts, constnames.TcpSetStateEvent, stateAttributes(tcpSynRecv, tcpEstablished)...))
+		return ts + uint64(connectGap)
+	}
+	emit(s.newTcpEvent(conn, ts, constnames.TcpConnectEvent,
+		model.KeyValue{Key: "retval", ValueType: model.ValueType_UINT64, Value: uint64Bytes(0)}))
+	ts += uint64(connectGap)
+	connect := s.newEvent(conn, ts, constnames.ConnectEvent)
+	connect.Category = model.Category_CAT_NET
+	setUserAttributes(connect, model.KeyValue{Key: "res", ValueType: model.ValueType_INT64, Value: uint64Bytes(0)})
+	emit(connect)
+	ts += uint64(connectGap)
+	emit(s.newTcpEvent(conn, ts, constnames.TcpSetStateEvent, stateAttributes(tcpSynSent, tcpEstablished)...))
+	return ts + uint64(connectGap)
+}
+
+// close emits the events of the connection closed and returns the timestamp after them.
+func (s *scenario) close(conn *connection, ts uint64, emit func(evt *model.KindlingEvent)) uint64 {
+	conn.open = false
+	if !s.cfg.ConnectEvents {
+		return ts
+	}
+	newState := uint64(tcpFinWait1)
+	if s.server {
+		newState = tcpCloseWait
+	}
+	emit(s.newTcpEvent(conn, ts, constnames.TcpSetStateEvent, stateAttributes(tcpEstablished, newState)...))
+	ts += uint64(connectGap)
+	emit(s.newTcpEvent(conn, ts, constnames.TcpCloseEvent,
+		model.KeyValue{Key: "rtt", ValueType: model.ValueType_UINT32, Value: uint32Bytes(tcpRtt)}))
+	return ts + uint64(connectGap)
+}
+
+func (s *scenario) newEvent(conn *connection, ts uint64, name string) *model.KindlingEvent {
+	return &model.KindlingEvent{
+		Source:    model.Source_SYSCALL_EXIT,
+		Timestamp: ts,
+		Name:      name,
+		Ctx: model.Context{
+			ThreadInfo: model.Thread{
+				Pid:         s.cfg.Pid,
+				Tid:         conn.tid,
+				Comm:        s.cfg.Comm,
+				ContainerId: s.cfg.ContainerId,
+			},
+			FdInfo: model.Fd{
+				Num:      conn.fd,
+				TypeFd:   model.FDType_FD_IPV4_SOCK,
+				Protocol: model.L4Proto_TCP,
+				Role:     s.server,
+				Sip:      []uint32{s.clientIp},
+				Dip:      []uint32{s.serverIp},
+				Sport:    conn.sport,
+				Dport:    s.serverPort,
+			},
+		},
+	}
+}
+
+func (s *scenario) newSyscallEvent(conn *connection, ts uint64, name string, data []byte) *model.KindlingEvent {
+	evt := s.newEvent(conn, ts, name)
+	evt.Category = model.Category_CAT_NET
+	evt.Latency = uint64(syscallLatency)
+	setUserAttributes(evt,
+		model.KeyValue{Key: "res", ValueType: model.ValueType_INT64, Value: uint64Bytes(uint64(len(data)))},
+		model.KeyValue{Key: "data", ValueType: model.ValueType_BYTEBUF, Value: data})
+	return evt
+}
+
+// newTcpEvent returns the kprobe event with the tuple of the connection in the user attributes.
+func (s *scenario) newTcpEvent(conn *connection, ts uint64, name string, attributes ...model.KeyValue) *model.KindlingEvent {
+	evt := s.newEvent(conn, ts, name)
+	evt.Source = model.Source_KRPOBE
+	tuple := []model.KeyValue{
+		{Key: "sip", ValueType: model.ValueType_UINT32, Value: uint32Bytes(s.clientIp)},
+		{Key: "sport", ValueType: model.ValueType_UINT16, Value: uint16Bytes(conn.sport)},
+		{Key: "dip", ValueType: model.ValueType_UINT32, Value: uint32Bytes(s.serverIp)},
+		{Key: "dport", ValueType: model.ValueType_UINT16, Value: uint16Bytes(s.serverPort)},
+	}
+	setUserAttributes(evt, append(tuple, attributes...)...)
+	return evt
+}
+
+func stateAttributes(oldState uint64, newState uint64) []model.KeyValue {
+	return []model.KeyValue{
+		{Key: "old_state", ValueType: model.ValueType_INT32, Value: uint32Bytes(uint32(oldState))},
+		{Key: "new_state", ValueType: model.ValueType_INT32, Value: uint32Bytes(uint32(newState))},
+	}
+}
+
+func setUserAttributes(evt *model.KindlingEvent, attributes ...model.KeyValue) {
+	evt.ParamsNumber = uint16(copy(evt.UserAttributes[:], attributes))
+}
+
+func uint64Bytes(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, v)
+	return b
+}
+
+func uint32Bytes(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, v)
+	return b
+}
+
+func uint16Bytes(v uint32) []byte {
+	b := make([]byte, 2)
+	binary.LittleEndian.PutUint16(b, uint16(v))
+	return b
+}
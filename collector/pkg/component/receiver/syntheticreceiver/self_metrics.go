@@ -0,0 +1,21 @@
+package syntheticreceiver
+
+import (
+	"sync"
+
+	"go.opentelemetry.io/otel/metric"
+)
+
+const eventsGeneratedMetric = "kindling_telemetry_syntheticreceiver_events_total"
+
+var once sync.Once
+
+var eventsCounter metric.Int64Counter
+
+func newSelfMetrics(meterProvider metric.MeterProvider) {
+	once.Do(func() {
+		meter := metric.Must(meterProvider.Meter("kindling"))
+		eventsCounter = meter.NewInt64Counter(eventsGeneratedMetric,
+			metric.WithDescription("The total number of the events generated by syntheticreceiver"))
+	})
+}
@@ -0,0 +1,179 @@
+package syntheticreceiver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component"
+	analyzerpackage "github.com/Kindling-project/kindling/collector/pkg/component/analyzer"
+	"github.com/Kindling-project/kindling/collector/pkg/component/receiver"
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+)
+
+const Type = "syntheticreceiver"
+
+const (
+	// tickInterval is how often the requests due are generated for the scenarios with a rate.
+	tickInterval = time.Millisecond
+	// maxBatch is the maximum number of requests of a scenario generated in a tick, so the
+	// scenarios without a rate don't starve the others.
+	maxBatch = 1000
+)
+
+// SyntheticReceiver generates the events of the scenarios configured instead of receiving
+// them from the probe, so the whole pipeline can be benchmarked without real traffic.
+type SyntheticReceiver struct {
+	cfg             *Config
+	telemetry       *component.TelemetryTools
+	analyzerManager *analyzerpackage.Manager
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+func New(config interface{}, telemetry *component.TelemetryTools, analyzerManager *analyzerpackage.Manager) receiver.Receiver {
+	cfg, ok := config.(*Config)
+	if !ok {
+		telemetry.Logger.Panicf("Cannot convert [%s] config", Type)
+	}
+	newSelfMetrics(telemetry.MeterProvider)
+	return &SyntheticReceiver{
+		cfg:             cfg,
+		telemetry:       telemetry,
+		analyzerManager: analyzerManager,
+		stopCh:          make(chan struct{}),
+	}
+}
+
+func (r *SyntheticReceiver) Start() error {
+	if r.cfg.Duration < 0 {
+		return fmt.Errorf("duration of syntheticreceiver must not be negative: %v", r.cfg.Duration)
+	}
+	scenarioConfigs := append([]ScenarioConfig(nil), r.cfg.Scenarios...)
+	for _, path := range r.cfg.ScenarioFiles {
+		fileScenarios, err := loadScenarioFile(path)
+		if err != nil {
+			return err
+		}
+		scenarioConfigs = append(scenarioConfigs, fileScenarios...)
+	}
+	if len(scenarioConfigs) == 0 {
+		return errors.New("no scenario of syntheticreceiver is configured")
+	}
+	seed := r.cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	scenarios := make([]*scenario, 0, len(scenarioConfigs))
+	for i := range scenarioConfigs {
+		cfg := &scenarioConfigs[i]
+		if cfg.Name == "" {
+			cfg.Name = fmt.Sprintf("%s-%d", cfg.Protocol, i)
+		}
+		s, err := newScenario(cfg, seed+int64(i))
+		if err != nil {
+			return fmt.Errorf("invalid scenario %s of syntheticreceiver: %w", cfg.Name, err)
+		}
+		scenarios = append(scenarios, s)
+	}
+	r.telemetry.Logger.Infof("Start SyntheticReceiver with %d scenarios and the seed %d", len(scenarios), seed)
+	r.wg.Add(1)
+	go r.run(scenarios)
+	return nil
+}
+
+func (r *SyntheticReceiver) Shutdown() error {
+	close(r.stopCh)
+	r.wg.Wait()
+	return nil
+}
+
+func loadScenarioFile(path string) ([]ScenarioConfig, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read the scenario file %s: %w", path, err)
+	}
+	var scenarios []ScenarioConfig
+	if err := v.UnmarshalKey("scenarios", &scenarios); err != nil {
+		return nil, fmt.Errorf("failed to parse the scenario file %s: %w", path, err)
+	}
+	return scenarios, nil
+}
+
+// run generates the requests due of every scenario on each tick until the duration
+// elapses or Shutdown is called.
+func (r *SyntheticReceiver) run(scenarios []*scenario) {
+	defer r.wg.Done()
+	start := time.Now()
+	sent := make([]uint64, len(scenarios))
+	events := make([]int64, len(scenarios))
+	defer func() {
+		elapsed := time.Since(start)
+		for i, s := range scenarios {
+			r.telemetry.Logger.Info("SyntheticReceiver finished the scenario", zap.String("scenario", s.cfg.Name),
+				zap.Uint64("requests", sent[i]), zap.Int64("events", events[i]), zap.Duration("elapsed", elapsed),
+				zap.Float64("requestsPerSecond", float64(sent[i])/elapsed.Seconds()))
+		}
+	}()
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+	for {
+		now := time.Now()
+		elapsed := now.Sub(start)
+		if r.cfg.Duration > 0 && elapsed >= r.cfg.Duration {
+			return
+		}
+		unbounded := false
+		for i, s := range scenarios {
+			batch := maxBatch
+			if s.cfg.Rate > 0 {
+				if due := int(elapsed.Seconds()*s.cfg.Rate) - int(sent[i]); due < batch {
+					batch = due
+				}
+			} else {
+				unbounded = true
+			}
+			var count int64
+			for j := 0; j < batch; j++ {
+				s.generate(now, func(evt *model.KindlingEvent) {
+					r.sendToNextConsumer(evt)
+					count++
+				})
+			}
+			if batch > 0 {
+				sent[i] += uint64(batch)
+				events[i] += count
+				eventsCounter.Add(context.Background(), count, attribute.String("scenario", s.cfg.Name))
+			}
+		}
+		if unbounded {
+			select {
+			case <-r.stopCh:
+				return
+			default:
+			}
+			continue
+		}
+		select {
+		case <-r.stopCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *SyntheticReceiver) sendToNextConsumer(evt *model.KindlingEvent) {
+	for _, analyzer := range r.analyzerManager.GetConsumableAnalyzers(evt.Name) {
+		if err := analyzer.ConsumeEvent(evt); err != nil {
+			r.telemetry.Logger.Warn("Error sending event to next consumer: ", zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package syntheticreceiver
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Kindling-project/kindling/collector/pkg/component"
+	"github.com/Kindling-project/kindling/collector/pkg/component/analyzer/network"
+	"github.com/Kindling-project/kindling/collector/pkg/component/consumer"
+	"github.com/Kindling-project/kindling/collector/pkg/component/receiver/internal/testutil"
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constlabels"
+	"github.com/Kindling-project/kindling/collector/pkg/model/constnames"
+)
+
+func generateEvents(t *testing.T, cfg *ScenarioConfig, requests int) []*model.KindlingEvent {
+	s, err := newScenario(cfg, 1)
+	require.NoError(t, err)
+	var events []*model.KindlingEvent
+	for i := 0; i < requests; i++ {
+		s.generate(time.Now(), func(evt *model.KindlingEvent) {
+			events = append(events, evt)
+		})
+	}
+	return events
+}
+
+func TestConnectionEvents(t *testing.T) {
+	cfg := &ScenarioConfig{
+		Protocol:              ProtocolRedis,
+		Role:                  RoleClient,
+		Connections:           1,
+		RequestsPerConnection: 2,
+		ConnectEvents:         true,
+	}
+	events := generateEvents(t, cfg, 3)
+	var names []string
+	for i, evt := range events {
+		names = append(names, evt.Name)
+		if i > 0 {
+			assert.GreaterOrEqual(t, evt.Timestamp, events[i-1].Timestamp)
+		}
+	}
+	assert.Equal(t, []string{
+		constnames.TcpConnectEvent, constnames.ConnectEvent, constnames.TcpSetStateEvent,
+		constnames.WriteEvent, constnames.ReadEvent, constnames.WriteEvent, constnames.ReadEvent,
+		constnames.TcpSetStateEvent, constnames.TcpCloseEvent,
+		constnames.TcpConnectEvent, constnames.ConnectEvent, constnames.TcpSetStateEvent,
+		constnames.WriteEvent, constnames.ReadEvent,
+	}, names)
+
+	connect := events[0]
+	assert.Equal(t, "10.0.0.1", model.IPLong2String(uint32(connect.GetUintUserAttribute("sip"))))
+	assert.Equal(t, uint64(6379), connect.GetUintUserAttribute("dport"))
+	assert.Equal(t, uint64(firstClientPort), connect.GetUintUserAttribute("sport"))
+	assert.Equal(t, int64(tcpEstablished), events[2].GetIntUserAttribute("new_state"))
+	assert.Equal(t, int64(tcpFinWait1), events[7].GetIntUserAttribute("new_state"))
+	assert.Equal(t, uint64(tcpRtt), events[8].GetUintUserAttribute("rtt"))
+	// The connection is reopened with a new client port.
+	assert.Equal(t, uint64(firstClientPort+1), events[9].GetUintUserAttribute("sport"))
+
+	request := events[3]
+	assert.Equal(t, "10.0.0.2", request.GetDip())
+	assert.Equal(t, uint32(6379), request.GetDport())
+	assert.False(t, request.Ctx.FdInfo.Role)
+	assert.Equal(t, int64(len(request.GetData())), request.GetResVal())
+}
+
+func TestPayloadTemplate(t *testing.T) {
+	cfg := &ScenarioConfig{
+		Protocol:  ProtocolHttp,
+		SplitRate: 1,
+		Latency:   LatencyConfig{Distribution: DistributionUniform, Min: time.Millisecond, Max: 2 * time.Millisecond},
+	}
+	events := generateEvents(t, cfg, 2)
+	// The request and the response are both split into two events.
+	require.Len(t, events, 8)
+	assert.Equal(t, "GET /api/orders/2 HTTP/1.1\r\nHost: synthetic\r\nUser-Agent: kindling-synthetic\r\n\r\n",
+		string(events[4].GetData())+string(events[5].GetData()))
+	latency := time.Duration(events[2].Timestamp - events[1].Timestamp)
+	assert.GreaterOrEqual(t, latency, time.Millisecond)
+	assert.LessOrEqual(t, latency, 2*time.Millisecond)
+}
+
+type dataGroupRecorder struct {
+	mu         sync.Mutex
+	dataGroups []*model.DataGroup
+}
+
+func (r *dataGroupRecorder) Consume(dataGroup *model.DataGroup) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.dataGroups = append(r.dataGroups, dataGroup.Clone())
+	return nil
+}
+
+func (r *dataGroupRecorder) received() []*model.DataGroup {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*model.DataGroup(nil), r.dataGroups...)
+}
+
+// TestParsedByNetworkAnalyzer checks the default payloads of the protocols are parsed, even
+// if they are split.
+func TestParsedByNetworkAnalyzer(t *testing.T) {
+	for _, protocol := range []string{ProtocolHttp, ProtocolMysql, ProtocolRedis, ProtocolKafka} {
+		for _, isError := range []bool{false, true} {
+			t.Run(protocol, func(t *testing.T) {
+				cfg := network.NewDefaultConfig()
+				cfg.EnableConntrack = false
+				cfg.EnableTimeoutCheck = false
+				recorder := &dataGroupRecorder{}
+				na := network.NewNetworkAnalyzer(cfg, component.NewDefaultTelemetryTools(), []consumer.Consumer{recorder})
+				require.NoError(t, na.Start())
+				defer na.Shutdown()
+
+				scenarioCfg := &ScenarioConfig{Protocol: protocol, SplitRate: 0.5}
+				if isError {
+					scenarioCfg.ErrorRate = 1
+				}
+				// The previous request is sent to the next consumer when the next one arrives.
+				for _, evt := range generateEvents(t, scenarioCfg, 3) {
+					require.NoError(t, na.ConsumeEvent(evt))
+				}
+				require.Eventually(t, func() bool { return len(recorder.received()) == 2 }, time.Second, 5*time.Millisecond)
+				for _, dataGroup := range recorder.received() {
+					assert.Equal(t, protocol, dataGroup.Labels.GetStringValue(constlabels.Protocol))
+					if protocol == ProtocolKafka {
+						// The error code of kafka doesn't mark the request as an error.
+						assert.Equal(t, isError, dataGroup.Labels.GetIntValue(constlabels.KafkaErrorCode) == 6)
+					} else {
+						assert.Equal(t, isError, dataGroup.Labels.GetBoolValue(constlabels.IsError))
+					}
+					assert.Equal(t, "10.0.0.2", dataGroup.Labels.GetStringValue(constlabels.DstIp))
+				}
+			})
+		}
+	}
+}
+
+func TestScenarioFileWithRate(t *testing.T) {
+	cfg := &Config{
+		Duration:      500 * time.Millisecond,
+		Seed:          1,
+		ScenarioFiles: []string{"testdata/scenarios.yml"},
+	}
+	a := testutil.NewEventRecorder(constnames.ReadEvent, constnames.TcpCloseEvent)
+	a.CountOnly = true
+	r := New(cfg, component.NewDefaultTelemetryTools(), testutil.NewManager(t, a))
+	require.NoError(t, r.Start())
+	// The receiver stops generating after the duration.
+	time.Sleep(700 * time.Millisecond)
+	reads := a.Count(constnames.ReadEvent)
+	// 100 requests at the rate of 200 per second, whose responses may be split.
+	assert.GreaterOrEqual(t, reads, 90)
+	assert.LessOrEqual(t, reads, 200)
+	assert.GreaterOrEqual(t, a.Count(constnames.TcpCloseEvent), 8)
+	assert.NoError(t, r.Shutdown())
+	assert.Equal(t, reads, a.Count(constnames.ReadEvent))
+}
+
+func TestShutdownWithoutRate(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Scenarios[0].Rate = 0
+	a := testutil.NewEventRecorder(constnames.ReadEvent)
+	a.CountOnly = true
+	r := New(cfg, component.NewDefaultTelemetryTools(), testutil.NewManager(t, a))
+	require.NoError(t, r.Start())
+	assert.Eventually(t, func() bool { return a.Count(constnames.ReadEvent) > 10000 }, time.Second, 5*time.Millisecond)
+	assert.NoError(t, r.Shutdown())
+}
+
+func TestInvalidConfig(t *testing.T) {
+	for name, modify := range map[string]func(cfg *Config){
+		"no scenario":          func(cfg *Config) { cfg.Scenarios = nil },
+		"unsupported protocol": func(cfg *Config) { cfg.Scenarios[0].Protocol = "dns" },
+		"invalid role":         func(cfg *Config) { cfg.Scenarios[0].Role = "proxy" },
+		"invalid ip":           func(cfg *Config) { cfg.Scenarios[0].ServerIp = "::1" },
+		"invalid error rate":   func(cfg *Config) { cfg.Scenarios[0].ErrorRate = 2 },
+		"invalid distribution": func(cfg *Config) { cfg.Scenarios[0].Latency.Distribution = "poisson" },
+		"invalid payload":      func(cfg *Config) { cfg.Scenarios[0].Request = []string{"hex|zz"} },
+		"file not found":       func(cfg *Config) { cfg.ScenarioFiles = []string{"testdata/not_exist.yml"} },
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg := NewDefaultConfig()
+			modify(cfg)
+			r := New(cfg, component.NewDefaultTelemetryTools(), testutil.NewManager(t, testutil.NewEventRecorder()))
+			assert.Error(t, r.Start())
+		})
+	}
+}
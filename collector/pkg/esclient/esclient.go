@@ -57,3 +57,8 @@ func (e *EsClient) AddIndexRequestWithParams(index string, doc interface{}) {
 	request := elastic.NewBulkIndexRequest().Index(index).Type("_doc").Doc(doc)
 	e.bulkProcessor.Add(request)
 }
+
+// Search returns a search request on the indices. The indices that don't exist are ignored.
+func (e *EsClient) Search(indices ...string) *elastic.SearchService {
+	return e.client.Search(indices...).IgnoreUnavailable(true).AllowNoIndices(true)
+}
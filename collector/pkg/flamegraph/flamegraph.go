@@ -0,0 +1,325 @@
+// Package flamegraph builds the flame graphs from the on-CPU stacks of the CPU events
+// sent by the cpuanalyzer.
+package flamegraph
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+)
+
+const (
+	// FormatCollapsed is the collapsed-stack format used by flamegraph.pl and speedscope.
+	// Each line is "thread;frame1;frame2 value" in which the value is in nanoseconds.
+	FormatCollapsed = "collapsed"
+	// FormatPprof is the gzipped profile.proto which can be opened by "go tool pprof".
+	FormatPprof = "pprof"
+)
+
+// unknownFrame is used for the on-CPU time without stacks.
+const unknownFrame = "[unknown]"
+
+// ParseFormat validates the format. The collapsed format is the default one.
+func ParseFormat(format string) (string, error) {
+	switch format {
+	case "":
+		return FormatCollapsed, nil
+	case FormatCollapsed, FormatPprof:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unsupported flame graph format: %s", format)
+	}
+}
+
+// Graph aggregates the on-CPU stacks of CpuEvents.
+//
+// The field Stack of CpuEvent is in the format of "functions|interval1|interval2|...".
+// The first element is the function names separated by '#', and each of the others
+// is the flame graph of an on-CPU interval. The graph is a list of rectangles in the
+// format of "depth-index-width-color-function" separated by '#', where the width is the
+// number of stack samples and a rectangle is the child of the one at the upper depth
+// whose range contains its index. The duration of an interval is distributed to its
+// stacks by their number of samples.
+type Graph struct {
+	samples map[flameSampleKey]*flameSample
+	// seen contains the events added. The same event can be stored in multiple segments.
+	seen map[flameEventKey]struct{}
+	// The time range of the on-CPU intervals added.
+	startTime uint64
+	endTime   uint64
+}
+
+type flameEventKey struct {
+	tid       uint32
+	startTime uint64
+}
+
+type flameSampleKey struct {
+	tid   uint32
+	stack string
+}
+
+type flameSample struct {
+	tid        uint32
+	threadName string
+	// frames are ordered from the root to the leaf.
+	frames []string
+	count  int64
+	nanos  int64
+}
+
+func New() *Graph {
+	return &Graph{
+		samples: make(map[flameSampleKey]*flameSample),
+		seen:    make(map[flameEventKey]struct{}),
+	}
+}
+
+// AddCpuEvent adds the on-CPU intervals of the event in the time range [startTime, endTime].
+// The intervals partly in the range are counted proportionally. The whole event is added
+// if endTime is 0.
+func (fg *Graph) AddCpuEvent(threadName string, tid uint32, ev *model.CpuEvent, startTime uint64, endTime uint64) {
+	key := flameEventKey{tid: tid, startTime: ev.StartTime}
+	if _, ok := fg.seen[key]; ok {
+		return
+	}
+	fg.seen[key] = struct{}{}
+	if endTime == 0 {
+		endTime = ^uint64(0)
+	}
+	var functions, layouts []string
+	if ev.Stack != "" {
+		elements := strings.Split(ev.Stack, "|")
+		functions = strings.Split(elements[0], "#")
+		layouts = elements[1:]
+	}
+	onIndex := 0
+	intervalStart := ev.StartTime
+	for i, timeType := range ev.TimeType {
+		if i >= len(ev.TypeSpecs) {
+			break
+		}
+		intervalEnd := intervalStart + ev.TypeSpecs[i]
+		from, to := intervalStart, intervalEnd
+		intervalStart = intervalEnd
+		if timeType != model.CPUType_ON {
+			continue
+		}
+		var layout string
+		if onIndex < len(layouts) {
+			layout = layouts[onIndex]
+		}
+		onIndex++
+		if from < startTime {
+			from = startTime
+		}
+		if to > endTime {
+			to = endTime
+		}
+		if from >= to {
+			continue
+		}
+		fg.addInterval(threadName, tid, functions, layout, to-from)
+		if fg.startTime == 0 || from < fg.startTime {
+			fg.startTime = from
+		}
+		if to > fg.endTime {
+			fg.endTime = to
+		}
+	}
+}
+
+type stackFrame struct {
+	depth int
+	index int
+	width int
+	// self is the number of samples in which the frame is the leaf.
+	self   int
+	frames []string
+}
+
+func (fg *Graph) addInterval(threadName string, tid uint32, functions []string, layout string, nanos uint64) {
+	frames := parseStackLayout(functions, layout)
+	total := 0
+	for _, f := range frames {
+		if f.depth == 0 {
+			total += f.width
+		}
+	}
+	if total == 0 {
+		fg.addSample(threadName, tid, []string{unknownFrame}, 0, int64(nanos))
+		return
+	}
+	for _, f := range frames {
+		if f.self <= 0 {
+			continue
+		}
+		fg.addSample(threadName, tid, f.frames, int64(f.self), int64(nanos*uint64(f.self)/uint64(total)))
+	}
+}
+
+// parseStackLayout rebuilds the stacks from the rectangles of an on-CPU interval. The
+// malformed rectangles and the ones without parents are ignored.
+func parseStackLayout(functions []string, layout string) []*stackFrame {
+	if layout == "" {
+		return nil
+	}
+	var frames []*stackFrame
+	for _, rect := range strings.Split(layout, "#") {
+		fields := strings.Split(rect, "-")
+		if len(fields) != 5 {
+			continue
+		}
+		depth, err1 := strconv.Atoi(fields[0])
+		index, err2 := strconv.Atoi(fields[1])
+		width, err3 := strconv.Atoi(fields[2])
+		nameIndex, err4 := strconv.Atoi(fields[4])
+		if err1 != nil || err2 != nil || err3 != nil || err4 != nil ||
+			depth < 0 || width <= 0 || nameIndex < 0 || nameIndex >= len(functions) {
+			continue
+		}
+		frames = append(frames, &stackFrame{
+			depth:  depth,
+			index:  index,
+			width:  width,
+			self:   width,
+			frames: []string{functions[nameIndex]},
+		})
+	}
+	sort.SliceStable(frames, func(i, j int) bool {
+		if frames[i].depth != frames[j].depth {
+			return frames[i].depth < frames[j].depth
+		}
+		return frames[i].index < frames[j].index
+	})
+	ret := frames[:0]
+	var upper, current []*stackFrame
+	currentDepth := 0
+	for _, f := range frames {
+		if f.depth != currentDepth {
+			if f.depth == currentDepth+1 {
+				upper = current
+			} else {
+				upper = nil
+			}
+			current = nil
+			currentDepth = f.depth
+		}
+		if f.depth > 0 {
+			parent := findParent(upper, f.index)
+			if parent == nil {
+				continue
+			}
+			parent.self -= f.width
+			f.frames = append(append(make([]string, 0, len(parent.frames)+1), parent.frames...), f.frames[0])
+		}
+		current = append(current, f)
+		ret = append(ret, f)
+	}
+	return ret
+}
+
+// findParent returns the frame whose range contains the index. The frames are sorted by index.
+func findParent(frames []*stackFrame, index int) *stackFrame {
+	i := sort.Search(len(frames), func(i int) bool {
+		return frames[i].index > index
+	})
+	if i == 0 {
+		return nil
+	}
+	parent := frames[i-1]
+	if index >= parent.index+parent.width {
+		return nil
+	}
+	return parent
+}
+
+func (fg *Graph) addSample(threadName string, tid uint32, frames []string, count int64, nanos int64) {
+	key := flameSampleKey{tid: tid, stack: strings.Join(frames, ";")}
+	sample, ok := fg.samples[key]
+	if !ok {
+		sample = &flameSample{tid: tid, threadName: threadName, frames: frames}
+		fg.samples[key] = sample
+	}
+	sample.count += count
+	sample.nanos += nanos
+}
+
+// Empty returns true if no on-CPU time has been added.
+func (fg *Graph) Empty() bool {
+	return len(fg.samples) == 0
+}
+
+// sortedSamples returns the samples ordered by thread and stack.
+func (fg *Graph) sortedSamples() []*flameSample {
+	ret := make([]*flameSample, 0, len(fg.samples))
+	for _, sample := range fg.samples {
+		ret = append(ret, sample)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].tid != ret[j].tid {
+			return ret[i].tid < ret[j].tid
+		}
+		return strings.Join(ret[i].frames, ";") < strings.Join(ret[j].frames, ";")
+	})
+	return ret
+}
+
+// Write writes the flame graph in the format.
+func (fg *Graph) Write(w io.Writer, format string) error {
+	switch format {
+	case FormatCollapsed:
+		return fg.WriteCollapsed(w)
+	case FormatPprof:
+		return fg.WritePprof(w)
+	default:
+		return fmt.Errorf("unsupported flame graph format: %s", format)
+	}
+}
+
+// WriteCollapsed writes the stacks in the collapsed format. The root frame of each stack
+// is the thread in the format of "threadName-tid". The ';' in the function names is
+// replaced with ':' as it is the separator of the frames.
+func (fg *Graph) WriteCollapsed(w io.Writer) error {
+	var sb strings.Builder
+	for _, sample := range fg.sortedSamples() {
+		if sample.nanos <= 0 {
+			continue
+		}
+		sb.Reset()
+		sb.WriteString(collapsedFrame(sample.threadName + "-" + strconv.FormatUint(uint64(sample.tid), 10)))
+		for _, frame := range sample.frames {
+			sb.WriteByte(';')
+			sb.WriteString(collapsedFrame(frame))
+		}
+		sb.WriteByte(' ')
+		sb.WriteString(strconv.FormatInt(sample.nanos, 10))
+		sb.WriteByte('\n')
+		if _, err := io.WriteString(w, sb.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func collapsedFrame(name string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(name, ";", ":"), "\n", " ")
+}
+
+// Serve writes the flame graph as an attachment named after the name.
+func Serve(w http.ResponseWriter, fg *Graph, format string, name string) error {
+	switch format {
+	case FormatPprof:
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".pb.gz"))
+	default:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".collapsed"))
+	}
+	return fg.Write(w, format)
+}
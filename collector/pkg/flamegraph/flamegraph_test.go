@@ -0,0 +1,114 @@
+package flamegraph
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/Kindling-project/kindling/collector/pkg/model"
+)
+
+// testCpuEvent has two on-CPU intervals of 100ns and 40ns and an off-CPU interval between them.
+// In the first interval, main is sampled 10 times, in which foo is sampled 6 times and
+// bar is sampled 2 times. The second interval has 4 samples of main only.
+func testCpuEvent(startTime uint64) *model.CpuEvent {
+	return &model.CpuEvent{
+		StartTime: startTime,
+		EndTime:   startTime + 200,
+		TypeSpecs: []uint64{100, 60, 40},
+		TimeType:  []model.CPUType{model.CPUType_ON, model.CPUType_FUTEX, model.CPUType_ON},
+		Stack:     "main#foo#bar|0-0-10-1-0#1-0-6-1-1#1-6-2-1-2|0-0-4-1-0",
+	}
+}
+
+func TestCollapsed(t *testing.T) {
+	fg := New()
+	fg.AddCpuEvent("worker", 12, testCpuEvent(1000), 0, 0)
+	// The duplicated event is ignored.
+	fg.AddCpuEvent("worker", 12, testCpuEvent(1000), 0, 0)
+	fg.AddCpuEvent("worker", 13, &model.CpuEvent{
+		StartTime: 2000,
+		TypeSpecs: []uint64{30},
+		TimeType:  []model.CPUType{model.CPUType_ON},
+	}, 0, 0)
+	var buf bytes.Buffer
+	assert.NoError(t, fg.Write(&buf, FormatCollapsed))
+	assert.Equal(t, "worker-12;main 60\n"+
+		"worker-12;main;bar 20\n"+
+		"worker-12;main;foo 60\n"+
+		"worker-13;[unknown] 30\n", buf.String())
+}
+
+func TestTimeRange(t *testing.T) {
+	fg := New()
+	// Only the second half of the first interval and the second interval are included.
+	fg.AddCpuEvent("worker", 12, testCpuEvent(1000), 1050, 1200)
+	var buf bytes.Buffer
+	assert.NoError(t, fg.WriteCollapsed(&buf))
+	assert.Equal(t, "worker-12;main 50\n"+
+		"worker-12;main;bar 10\n"+
+		"worker-12;main;foo 30\n", buf.String())
+
+	fg = New()
+	fg.AddCpuEvent("worker", 12, testCpuEvent(1000), 1100, 1160)
+	assert.True(t, fg.Empty())
+}
+
+func TestParseStackLayout(t *testing.T) {
+	// The rectangle of baz has no parent and the one of qux is malformed.
+	frames := parseStackLayout([]string{"main", "foo", "baz"}, "1-0-3-1-1#0-0-5-1-0#2-4-1-1-2#1-x-1-1-1")
+	assert.Len(t, frames, 2)
+	assert.Equal(t, []string{"main"}, frames[0].frames)
+	assert.Equal(t, 2, frames[0].self)
+	assert.Equal(t, []string{"main", "foo"}, frames[1].frames)
+	assert.Equal(t, 3, frames[1].self)
+}
+
+func TestPprof(t *testing.T) {
+	fg := New()
+	fg.AddCpuEvent("worker", 12, testCpuEvent(1000), 0, 0)
+	var buf bytes.Buffer
+	assert.NoError(t, fg.Write(&buf, FormatPprof))
+	gzipReader, err := gzip.NewReader(&buf)
+	assert.NoError(t, err)
+	data, err := io.ReadAll(gzipReader)
+	assert.NoError(t, err)
+
+	var stringTable []string
+	fields := make(map[protowire.Number]int)
+	var timeNanos, durationNanos uint64
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		assert.Greater(t, n, 0)
+		data = data[n:]
+		fields[num]++
+		switch {
+		case num == 6:
+			s, n := protowire.ConsumeString(data)
+			stringTable = append(stringTable, s)
+			data = data[n:]
+		case num == 9:
+			timeNanos, n = protowire.ConsumeVarint(data)
+			data = data[n:]
+		case num == 10:
+			durationNanos, n = protowire.ConsumeVarint(data)
+			data = data[n:]
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, data)
+			assert.Greater(t, n, 0)
+			data = data[n:]
+		}
+	}
+	assert.Equal(t, 2, fields[1], "sample types")
+	assert.Equal(t, 3, fields[2], "samples")
+	assert.Equal(t, 3, fields[4], "locations")
+	assert.Equal(t, 3, fields[5], "functions")
+	assert.Equal(t, "", stringTable[0])
+	assert.Subset(t, stringTable, []string{"samples", "count", "cpu", "nanoseconds", "main", "foo", "bar", "thread", "worker", "tid"})
+	assert.Equal(t, uint64(1000), timeNanos)
+	assert.Equal(t, uint64(200), durationNanos)
+}
@@ -0,0 +1,155 @@
+package flamegraph
+
+import (
+	"compress/gzip"
+	"io"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The messages below are the subset of pprof's profile.proto used by Graph. They
+// are encoded by hand to avoid depending on the whole pprof module.
+//
+//	message Profile {
+//	  repeated ValueType sample_type = 1; repeated Sample sample = 2;
+//	  repeated Location location = 4; repeated Function function = 5;
+//	  repeated string string_table = 6; int64 time_nanos = 9; int64 duration_nanos = 10;
+//	  ValueType period_type = 11; int64 period = 12; int64 default_sample_type = 14;
+//	}
+//	message ValueType { int64 type = 1; int64 unit = 2; }
+//	message Sample    { repeated uint64 location_id = 1; repeated int64 value = 2; repeated Label label = 3; }
+//	message Label     { int64 key = 1; int64 str = 2; int64 num = 3; }
+//	message Location  { uint64 id = 1; repeated Line line = 4; }
+//	message Line      { uint64 function_id = 1; }
+//	message Function  { uint64 id = 1; int64 name = 2; int64 system_name = 3; }
+
+type pprofBuilder struct {
+	buf     []byte
+	strings map[string]int64
+	table   []string
+	// functions maps the function names to the ids of their functions and locations,
+	// which are the same.
+	functions map[string]uint64
+}
+
+// WritePprof writes the gzipped profile with two sample types: the number of stack
+// samples and the on-CPU time in nanoseconds. The threads are recorded as the labels
+// "thread" and "tid" of the samples.
+func (fg *Graph) WritePprof(w io.Writer) error {
+	b := &pprofBuilder{
+		strings:   map[string]int64{"": 0},
+		table:     []string{""},
+		functions: make(map[string]uint64),
+	}
+	b.appendValueType(1, "samples", "count")
+	b.appendValueType(1, "cpu", "nanoseconds")
+	var sampleBuf, itemBuf []byte
+	for _, sample := range fg.sortedSamples() {
+		sampleBuf = sampleBuf[:0]
+		itemBuf = itemBuf[:0]
+		// The locations start from the leaf.
+		for i := len(sample.frames) - 1; i >= 0; i-- {
+			itemBuf = protowire.AppendVarint(itemBuf, b.function(sample.frames[i]))
+		}
+		sampleBuf = protowire.AppendTag(sampleBuf, 1, protowire.BytesType)
+		sampleBuf = protowire.AppendBytes(sampleBuf, itemBuf)
+		itemBuf = itemBuf[:0]
+		itemBuf = protowire.AppendVarint(itemBuf, uint64(sample.count))
+		itemBuf = protowire.AppendVarint(itemBuf, uint64(sample.nanos))
+		sampleBuf = protowire.AppendTag(sampleBuf, 2, protowire.BytesType)
+		sampleBuf = protowire.AppendBytes(sampleBuf, itemBuf)
+		sampleBuf = b.appendLabel(sampleBuf, "thread", b.str(sample.threadName), 0)
+		sampleBuf = b.appendLabel(sampleBuf, "tid", 0, int64(sample.tid))
+		b.buf = protowire.AppendTag(b.buf, 2, protowire.BytesType)
+		b.buf = protowire.AppendBytes(b.buf, sampleBuf)
+	}
+	for _, s := range b.table {
+		b.buf = protowire.AppendTag(b.buf, 6, protowire.BytesType)
+		b.buf = protowire.AppendString(b.buf, s)
+	}
+	b.buf = protowire.AppendTag(b.buf, 9, protowire.VarintType)
+	b.buf = protowire.AppendVarint(b.buf, fg.startTime)
+	b.buf = protowire.AppendTag(b.buf, 10, protowire.VarintType)
+	b.buf = protowire.AppendVarint(b.buf, fg.endTime-fg.startTime)
+	b.appendValueType(11, "cpu", "nanoseconds")
+	b.buf = protowire.AppendTag(b.buf, 12, protowire.VarintType)
+	b.buf = protowire.AppendVarint(b.buf, 1)
+	b.buf = protowire.AppendTag(b.buf, 14, protowire.VarintType)
+	b.buf = protowire.AppendVarint(b.buf, uint64(b.str("cpu")))
+
+	gzipWriter := gzip.NewWriter(w)
+	if _, err := gzipWriter.Write(b.buf); err != nil {
+		return err
+	}
+	return gzipWriter.Close()
+}
+
+// str returns the index of the string in the string table. The strings used by the
+// messages written later must be added first, so the string table is written last.
+func (b *pprofBuilder) str(s string) int64 {
+	if i, ok := b.strings[s]; ok {
+		return i
+	}
+	i := int64(len(b.table))
+	b.strings[s] = i
+	b.table = append(b.table, s)
+	return i
+}
+
+// function returns the id of the location of the function, and writes the location and
+// the function if it is the first time the function is used.
+func (b *pprofBuilder) function(name string) uint64 {
+	if id, ok := b.functions[name]; ok {
+		return id
+	}
+	id := uint64(len(b.functions) + 1)
+	b.functions[name] = id
+	nameIndex := uint64(b.str(name))
+
+	var msg, line []byte
+	msg = protowire.AppendTag(msg, 1, protowire.VarintType)
+	msg = protowire.AppendVarint(msg, id)
+	msg = protowire.AppendTag(msg, 2, protowire.VarintType)
+	msg = protowire.AppendVarint(msg, nameIndex)
+	msg = protowire.AppendTag(msg, 3, protowire.VarintType)
+	msg = protowire.AppendVarint(msg, nameIndex)
+	b.buf = protowire.AppendTag(b.buf, 5, protowire.BytesType)
+	b.buf = protowire.AppendBytes(b.buf, msg)
+
+	line = protowire.AppendTag(line, 1, protowire.VarintType)
+	line = protowire.AppendVarint(line, id)
+	msg = msg[:0]
+	msg = protowire.AppendTag(msg, 1, protowire.VarintType)
+	msg = protowire.AppendVarint(msg, id)
+	msg = protowire.AppendTag(msg, 4, protowire.BytesType)
+	msg = protowire.AppendBytes(msg, line)
+	b.buf = protowire.AppendTag(b.buf, 4, protowire.BytesType)
+	b.buf = protowire.AppendBytes(b.buf, msg)
+	return id
+}
+
+func (b *pprofBuilder) appendValueType(field protowire.Number, typ string, unit string) {
+	var msg []byte
+	msg = protowire.AppendTag(msg, 1, protowire.VarintType)
+	msg = protowire.AppendVarint(msg, uint64(b.str(typ)))
+	msg = protowire.AppendTag(msg, 2, protowire.VarintType)
+	msg = protowire.AppendVarint(msg, uint64(b.str(unit)))
+	b.buf = protowire.AppendTag(b.buf, field, protowire.BytesType)
+	b.buf = protowire.AppendBytes(b.buf, msg)
+}
+
+// appendLabel appends a string label if str is not 0, or a numeric label otherwise.
+func (b *pprofBuilder) appendLabel(buf []byte, key string, str int64, num int64) []byte {
+	var msg []byte
+	msg = protowire.AppendTag(msg, 1, protowire.VarintType)
+	msg = protowire.AppendVarint(msg, uint64(b.str(key)))
+	if str != 0 {
+		msg = protowire.AppendTag(msg, 2, protowire.VarintType)
+		msg = protowire.AppendVarint(msg, uint64(str))
+	} else {
+		msg = protowire.AppendTag(msg, 3, protowire.VarintType)
+		msg = protowire.AppendVarint(msg, uint64(num))
+	}
+	buf = protowire.AppendTag(buf, 3, protowire.BytesType)
+	return protowire.AppendBytes(buf, msg)
+}
@@ -92,6 +92,10 @@ func initWatcherFromAPIServer(k8sConfig config) error {
 		return fmt.Errorf("cannot connect to kubernetes: %w", err)
 	}
 	IsInitSuccess = true
+	metadataSync.expect(nodeSource, serviceSource, podSource)
+	if k8sConfig.EnableFetchReplicaSet {
+		metadataSync.expect(replicaSetSource)
+	}
 	go NodeWatch(clientSet, k8sConfig.nodeEventHander)
 	time.Sleep(1 * time.Second)
 	if k8sConfig.EnableFetchReplicaSet {
@@ -107,6 +111,7 @@ func initWatcherFromAPIServer(k8sConfig config) error {
 
 func initWatcherFromMetadataProvider(k8sConfig config) error {
 	stopCh := make(chan struct{})
+	metadataSync.expect(metadataProviderSource)
 	// Enable PodDeleteGrace
 	go podDeleteLoop(10*time.Second, k8sConfig.GraceDeletePeriod, stopCh)
 	go watchFromMPWithRetry(k8sConfig)
@@ -127,6 +132,7 @@ func watchFromMPWithRetry(k8sConfig config) {
 		}
 
 		// Failed after 3 times
+		metadataSync.unsynced(metadataProviderSource)
 		log.Printf("listAndWatch From Provider failled for 3 time, will retry after 1 minute")
 		time.Sleep(1 * time.Minute)
 	}
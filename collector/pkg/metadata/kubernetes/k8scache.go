@@ -23,6 +23,7 @@ type K8sPodInfo struct {
 	HostPorts    []int32
 	ContainerIds []string
 	Labels       map[string]string
+	Annotations  map[string]string
 	// TODO: There may be multiple kinds of workload or services for the same pod
 	WorkloadKind  string
 	WorkloadName  string
@@ -300,12 +301,14 @@ func (c *K8sMetaDataCache) GetNodeNameByIp(ip string) (string, bool) {
 func SetupCache(cache *K8sMetaDataCache, nodeMap *NodeMap, serviceMap *ServiceMap, rsMap *ReplicaSetMap) {
 	LockMetadataCache()
 	defer UnlockMetadataCache()
+	defer metadataSync.synced(metadataProviderSource)
 	if cache != nil {
 		if cache.ContainerIdInfo != nil {
 			// Recalculate local cacheMap
 			tmpLocalWorkloadMap := newWorkloadMap()
 			tmpGlobalPodInfo := newPodMap()
 			for _, containersInfo := range cache.ContainerIdInfo {
+				podAnnotations.filterContainer(containersInfo)
 				refPodInfo := containersInfo.RefPodInfo
 				tmpGlobalPodInfo.add(refPodInfo)
 				if refPodInfo.NodeName == MyNodeName {
@@ -322,9 +325,17 @@ func SetupCache(cache *K8sMetaDataCache, nodeMap *NodeMap, serviceMap *ServiceMa
 			MetaDataCache.ContainerIdInfo = cache.ContainerIdInfo
 		}
 		if cache.HostPortInfo != nil {
+			for _, containerInfo := range cache.HostPortInfo.HostPortInfo {
+				podAnnotations.filterContainer(containerInfo)
+			}
 			MetaDataCache.HostPortInfo.HostPortInfo = cache.HostPortInfo.HostPortInfo
 		}
 		if cache.IpContainerInfo != nil {
+			for _, portContainerInfo := range cache.IpContainerInfo {
+				for _, containerInfo := range portContainerInfo {
+					podAnnotations.filterContainer(containerInfo)
+				}
+			}
 			MetaDataCache.IpContainerInfo = cache.IpContainerInfo
 		}
 		if cache.IpServiceInfo != nil {
@@ -91,6 +91,7 @@ func NodeWatch(clientSet *kubernetes.Clientset, handler cache.ResourceEventHandl
 		})
 	}
 
+	metadataSync.synced(nodeSource)
 	// TODO: use workqueue to avoid blocking
 	<-stopper
 }
@@ -0,0 +1,97 @@
+package kubernetes
+
+import (
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// annotationFilter holds the keys of the pod annotations kept in K8sPodInfo. The annotations
+// can be large, e.g. last-applied-configuration or the blobs of sidecar injectors, so none is
+// kept unless a component asks for it.
+type annotationFilter struct {
+	mutex sync.RWMutex
+	all   bool
+	keys  map[string]struct{}
+	// store holds the pods watched from the API server. They are added again when new keys
+	// are registered after they were cached.
+	store cache.Store
+}
+
+var podAnnotations = &annotationFilter{keys: make(map[string]struct{})}
+
+// CachePodAnnotations keeps the annotations of the keys in K8sPodInfo.Annotations. The pods
+// watched from the API server are cached again if the keys are new. The ones received from
+// the metadata provider get the new keys when they are updated or watched again. The keys
+// are never removed, as the components registering them may be running until the exit.
+func CachePodAnnotations(keys ...string) {
+	podAnnotations.mutex.Lock()
+	added := false
+	for _, key := range keys {
+		if _, ok := podAnnotations.keys[key]; !ok {
+			podAnnotations.keys[key] = struct{}{}
+			added = true
+		}
+	}
+	store := podAnnotations.store
+	podAnnotations.mutex.Unlock()
+	if added && store != nil {
+		readdCachedPods(store)
+	}
+}
+
+// CacheAllPodAnnotations keeps all the annotations. It is used by the metadata provider,
+// which doesn't know the keys its clients need. The clients filter the pods they receive.
+func CacheAllPodAnnotations() {
+	podAnnotations.mutex.Lock()
+	podAnnotations.all = true
+	podAnnotations.mutex.Unlock()
+}
+
+func (f *annotationFilter) setStore(store cache.Store) {
+	f.mutex.Lock()
+	f.store = store
+	f.mutex.Unlock()
+}
+
+// filter returns the annotations of the registered keys, or nil if there is none.
+func (f *annotationFilter) filter(annotations map[string]string) map[string]string {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	if f.all {
+		return annotations
+	}
+	var ret map[string]string
+	for key := range f.keys {
+		if value, ok := annotations[key]; ok {
+			if ret == nil {
+				ret = make(map[string]string, len(f.keys))
+			}
+			ret[key] = value
+		}
+	}
+	return ret
+}
+
+// filterContainer filters the annotations of the pod of the container received from the
+// metadata provider.
+func (f *annotationFilter) filterContainer(containerInfo *K8sContainerInfo) {
+	if containerInfo != nil && containerInfo.RefPodInfo != nil {
+		containerInfo.RefPodInfo.Annotations = f.filter(containerInfo.RefPodInfo.Annotations)
+	}
+}
+
+// readdCachedPods adds the pods in the store again so the annotations of the new keys are
+// cached. The pods deleted or not cached yet are skipped, they are handled by the informer.
+func readdCachedPods(store cache.Store) {
+	for _, obj := range store.List() {
+		pod, ok := obj.(*corev1.Pod)
+		if !ok {
+			continue
+		}
+		if cachedPod, ok := GlobalPodInfo.get(pod.Namespace, pod.Name); ok && cachedPod.UID == string(pod.UID) {
+			AddPod(pod)
+		}
+	}
+}
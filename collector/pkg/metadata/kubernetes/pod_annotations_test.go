@@ -0,0 +1,47 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestCachePodAnnotations(t *testing.T) {
+	GlobalPodInfo = newPodMap()
+	podAnnotations = &annotationFilter{keys: make(map[string]struct{})}
+	defer func() { podAnnotations = &annotationFilter{keys: make(map[string]struct{})} }()
+	pod := CreatePod(true)
+	pod.Annotations = map[string]string{
+		"example.com/cost-center":                          "cc-42",
+		"kubectl.kubernetes.io/last-applied-configuration": "{}",
+	}
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	require.NoError(t, store.Add(pod))
+	podAnnotations.setStore(store)
+
+	// No annotation is cached if no key is registered.
+	AddPod(pod)
+	cachedPod, ok := GetPodByName(pod.Namespace, pod.Name)
+	require.True(t, ok)
+	assert.Nil(t, cachedPod.Annotations)
+
+	// The pods cached are added again with the annotations of the new keys.
+	CachePodAnnotations("example.com/cost-center", "example.com/missing")
+	cachedPod, ok = GetPodByName(pod.Namespace, pod.Name)
+	require.True(t, ok)
+	assert.Equal(t, map[string]string{"example.com/cost-center": "cc-42"}, cachedPod.Annotations)
+
+	// The pods received from the metadata provider are filtered too.
+	podAnnotations.setStore(nil)
+	received := &K8sContainerInfo{RefPodInfo: &K8sPodInfo{Annotations: pod.Annotations}}
+	podAnnotations.filterContainer(received)
+	assert.Equal(t, map[string]string{"example.com/cost-center": "cc-42"}, received.RefPodInfo.Annotations)
+
+	CacheAllPodAnnotations()
+	AddPod(pod)
+	cachedPod, ok = GetPodByName(pod.Namespace, pod.Name)
+	require.True(t, ok)
+	assert.Equal(t, pod.Annotations, cachedPod.Annotations)
+}
@@ -145,6 +145,11 @@ func (m *podMap) get(namespace string, name string) (*K8sPodInfo, bool) {
 	return podInfo, true
 }
 
+// GetPodByName returns the pod in the namespace with the name.
+func GetPodByName(namespace string, name string) (*K8sPodInfo, bool) {
+	return GlobalPodInfo.get(namespace, name)
+}
+
 // getPodsMatchSelectors gets K8sPodInfo(s) whose labels match with selectors in such namespace.
 // Return empty slice if not found. Note there may be multiple match.
 func (m *podMap) getPodsMatchSelectors(namespace string, selectors map[string]string) []*K8sPodInfo {
@@ -185,6 +190,7 @@ func PodWatch(clientSet *kubernetes.Clientset, graceDeletePeriod time.Duration,
 
 	go podDeleteLoop(10*time.Second, graceDeletePeriod, stopper)
 
+	podAnnotations.setStore(informer.GetStore())
 	if handler == nil {
 		informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
 			AddFunc:    AddPod,
@@ -194,6 +200,7 @@ func PodWatch(clientSet *kubernetes.Clientset, graceDeletePeriod time.Duration,
 	} else {
 		informer.AddEventHandler(handler)
 	}
+	metadataSync.synced(podSource)
 	// TODO: use workqueue to avoid blocking
 	<-stopper
 }
@@ -232,6 +239,7 @@ func AddPod(obj interface{}) {
 		HostPorts:     make([]int32, 0),
 		ContainerIds:  make([]string, 0, 2),
 		Labels:        pod.Labels,
+		Annotations:   podAnnotations.filter(pod.Annotations),
 		WorkloadKind:  workloadTypeTmp,
 		WorkloadName:  workloadNameTmp,
 		NodeName:      pod.Spec.NodeName,
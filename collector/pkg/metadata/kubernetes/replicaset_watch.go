@@ -89,6 +89,7 @@ func RsWatch(clientSet *kubernetes.Clientset, handler cache.ResourceEventHandler
 		runtime.HandleError(fmt.Errorf("timed out waiting for caches to sync"))
 		return
 	}
+	metadataSync.synced(replicaSetSource)
 	// TODO: use workqueue to avoid blocking
 	<-stopper
 }
@@ -114,6 +114,7 @@ func ServiceWatch(clientSet *kubernetes.Clientset, handler cache.ResourceEventHa
 			DeleteFunc: DeleteService,
 		})
 	}
+	metadataSync.synced(serviceSource)
 	// TODO: use workqueue to avoid blocking
 	<-stopper
 }
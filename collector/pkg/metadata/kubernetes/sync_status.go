@@ -0,0 +1,66 @@
+package kubernetes
+
+import (
+	"sort"
+	"sync"
+)
+
+// The sources of the metadata whose caches must be synced before the metadata is complete.
+const (
+	nodeSource             = "node"
+	replicaSetSource       = "replicaset"
+	serviceSource          = "service"
+	podSource              = "pod"
+	metadataProviderSource = "metadata_provider"
+)
+
+// syncTracker records the sources of the metadata not synced yet.
+type syncTracker struct {
+	mutex   sync.Mutex
+	enabled bool
+	pending map[string]struct{}
+}
+
+var metadataSync = &syncTracker{pending: make(map[string]struct{})}
+
+// expect adds the sources which are synced later.
+func (s *syncTracker) expect(sources ...string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.enabled = true
+	for _, source := range sources {
+		s.pending[source] = struct{}{}
+	}
+}
+
+func (s *syncTracker) synced(source string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.pending, source)
+}
+
+// unsynced marks the source expected as not synced again, e.g. it is disconnected.
+func (s *syncTracker) unsynced(source string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.enabled {
+		s.pending[source] = struct{}{}
+	}
+}
+
+func (s *syncTracker) status() (bool, []string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	pending := make([]string, 0, len(s.pending))
+	for source := range s.pending {
+		pending = append(pending, source)
+	}
+	sort.Strings(pending)
+	return s.enabled, pending
+}
+
+// SyncStatus returns whether the Kubernetes metadata is initialized, and the sources of
+// the metadata whose caches are not synced yet. The metadata is complete if none is pending.
+func SyncStatus() (enabled bool, pending []string) {
+	return metadataSync.status()
+}
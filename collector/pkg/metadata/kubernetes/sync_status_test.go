@@ -0,0 +1,28 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncTracker(t *testing.T) {
+	s := &syncTracker{pending: make(map[string]struct{})}
+	s.synced(podSource)
+	enabled, pending := s.status()
+	assert.False(t, enabled)
+	assert.Empty(t, pending)
+
+	s.expect(podSource, nodeSource)
+	s.synced(podSource)
+	enabled, pending = s.status()
+	assert.True(t, enabled)
+	assert.Equal(t, []string{nodeSource}, pending)
+
+	s.synced(nodeSource)
+	_, pending = s.status()
+	assert.Empty(t, pending)
+	s.unsynced(nodeSource)
+	_, pending = s.status()
+	assert.Equal(t, []string{nodeSource}, pending)
+}
@@ -88,6 +88,8 @@ func NewMetaDataWrapper(config *Config) (*MetaDataWrapper, error) {
 		}()
 	}
 
+	// The clients keep the annotations they need from the pods sent to them.
+	kubernetes.CacheAllPodAnnotations()
 	var options []kubernetes.Option
 	options = append(options, kubernetes.WithAuthType(config.KubeAuthType))
 	options = append(options, kubernetes.WithKubeConfigDir(config.KubeConfigDir))
@@ -56,6 +56,13 @@ const (
 	// EndTimestamp is the end timestamp of a trace
 	EndTimestamp = "end_timestamp"
 
+	// The labels of the lock contention of Java threads.
+	LockAddress      = "lock_address"
+	LockEventType    = "lock_event_type"
+	LockStack        = "lock_stack"
+	HolderTid        = "holder_tid"
+	HolderThreadName = "holder_thread_name"
+
 	Errno           = "errno"
 	Success         = "success"
 	RequestContent  = "request_content"
@@ -127,6 +134,7 @@ const (
 	ThreadName = "threadName"
 	StartTime  = "startTime"
 	EndTime    = "endTime"
+	CpuEvents  = "cpuEvents"
 )
 const (
 	STR_EMPTY = ""
@@ -39,6 +39,10 @@ const (
 	AggregatedNetRequestMetricGroup = "aggregated_net_request_metric_group"
 
 	CameraEventGroupName = "camera_event_group"
+	// RequestTimeBreakdownMetricGroupName stands for the time breakdown of a profiled request.
+	RequestTimeBreakdownMetricGroupName = "request_time_breakdown_metric_group"
+	// LockContentionMetricGroupName stands for the waits of Java threads for the same lock.
+	LockContentionMetricGroupName = "lock_contention_metric_group"
 
 	TcpRttMetricGroupName        = "tcp_rtt_metric_group"
 	TcpRetransmitMetricGroupName = "tcp_retransmit_metric_group"
@@ -12,6 +12,22 @@ const (
 	RequestIo  = "request_io"
 	ResponseIo = "response_io"
 
+	// The time breakdown of the profiled requests, which is derived from the CPU events
+	// of the threads handling them. The unit is nanoseconds.
+	OnCpuTime = "oncpu_time"
+	FileTime  = "file_time"
+	NetTime   = "net_time"
+	FutexTime = "futex_time"
+	IdleTime  = "idle_time"
+	OtherTime = "other_time"
+	EpollTime = "epoll_time"
+	RunqTime  = "runq_time"
+
+	// The waits of Java threads for the same lock. The unit of the times is nanoseconds.
+	LockWaitTime    = "lock_wait_time"
+	LockMaxWaitTime = "lock_max_wait_time"
+	LockWaitCount   = "lock_wait_count"
+
 	SpanInfo = "KSpanInfo"
 
 	ProtocolError   = "error"
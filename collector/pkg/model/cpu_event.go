@@ -0,0 +1,45 @@
+package model
+
+import "encoding/json"
+
+// CPUType is the type of an interval of CpuEvent.
+type CPUType uint8
+
+const (
+	CPUType_ON    CPUType = 0
+	CPUType_FILE  CPUType = 1
+	CPUType_NET   CPUType = 2
+	CPUType_FUTEX CPUType = 3
+	CPUType_IDLE  CPUType = 4
+	CPUType_OTHER CPUType = 5
+	CPUType_EPOLL CPUType = 6
+	CPUTYPE_MAX   CPUType = 7
+)
+
+func (ct CPUType) MarshalJSON() ([]byte, error) {
+	return json.Marshal(uint16(ct))
+}
+
+func (ct *CPUType) UnmarshalJSON(data []byte) error {
+	var val uint16
+	err := json.Unmarshal(data, &val)
+	if err != nil {
+		return err
+	}
+	*ct = CPUType(val)
+	return nil
+}
+
+// CpuEvent is the on/off-CPU intervals of a thread, which is sent in the label "cpuEvents"
+// of the camera_event_group.
+type CpuEvent struct {
+	StartTime   uint64    `json:"startTime"`
+	EndTime     uint64    `json:"endTime"`
+	TypeSpecs   []uint64  `json:"typeSpecs"`
+	RunqLatency []uint64  `json:"runqLatency"`
+	TimeType    []CPUType `json:"timeType"`
+	OnInfo      string    `json:"onInfo"`
+	OffInfo     string    `json:"offInfo"`
+	Log         string    `json:"log"`
+	Stack       string    `json:"stack"`
+}